@@ -13,20 +13,69 @@ type Message struct {
 	Description *string `json:"description,omitempty"`
 }
 
+// A value of a property and the number of resources with that value.
+type PropertyCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// A resource that exists only on the hub or only on managed clusters.
+type SearchDrift struct {
+	// Values of the identity properties, in the same order as requested.
+	Identity []string `json:"identity"`
+	// True if the resource exists on the hub.
+	Hub bool `json:"hub"`
+	// Managed clusters where the resource exists.
+	Clusters []string `json:"clusters"`
+}
+
+// Values and counts for a property (facet).
+type SearchFacet struct {
+	// Name of the property.
+	Property string `json:"property"`
+	// Top values for the property, sorted by count in descending order.
+	Values []*SearchFacetValue `json:"values,omitempty"`
+}
+
+// A value of a facet and the number of resources with that value.
+type SearchFacetValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
 // Defines a key/value to filter results.
 // When multiple values are provided for a property, it is interpreted as an OR operation.
 type SearchFilter struct {
 	// Name of the property (key).
+	// Use a dotted path to match a nested value with the string operators, for example `metadata.labels.app`.
+	// The paths metadata.name, metadata.namespace, metadata.creationTimestamp and metadata.labels.<key> match the
+	// name, namespace, created and label properties.
 	Property string `json:"property"`
 	// Values for the property. Multiple values per property are interpreted as an OR operation.
 	// Optionally one of these operations `=,!,!=,>,>=,<,<=` can be included at the beginning of the value.
 	// By default the equality operation is used.
+	// Values with the not equal operation (`!` or `!=`) are excluded together. For example, `kind:!Pod,!Service`.
+	// Use `~` (equal) or `!~` (not equal) to match the value ignoring case. For example, `name:~MyPod` matches `mypod`.
+	// These operations aren't supported for labels and arrays.
+	// Labels are matched by `key=value`, or by `key` to match any value. For example, `label:app=nginx,env`.
+	// Use `:exists` to match the resources with the property, and `!:exists` for the resources without it. For example, `deletionTimestamp::exists`.
+	// Use `*` to match any characters and `?` to match a single character. For example, `name:nginx-*`.
+	// Use `=~` to match a POSIX regular expression, or `=~*` to match it ignoring case. For example, `name:=~^web-[0-9]+$`.
+	// The regular expression is limited to 256 characters and can't use backreferences.
 	// The values available for datetime fields (Ex: `created`, `startedAt`) are `hour`, `day`, `week`, `month` and `year`.
+	// Datetime fields also match a time range, compared as timestamps. Use a count for the last N units of time, for example `created:6hours`,
+	// or two RFC3339 timestamps for an absolute range, for example `created:2024-01-01T00:00:00Z..2024-01-02T00:00:00Z`.
 	// Property `kind`, if included in the filter, will be matched using a case-insensitive comparison.
 	// For example, `kind:Pod` and `kind:pod` will bring up all pods. This is to maintain compatibility with Search V1.
 	Values []*string `json:"values"`
 }
 
+// Group of filters where results will match any of the filters (OR operation).
+type SearchFilterGroup struct {
+	// List of SearchFilter. The managedHub property isn't supported in a group.
+	Filters []*SearchFilter `json:"filters"`
+}
+
 // Input options to the search query.
 type SearchInput struct {
 	// List of strings to match resources.
@@ -37,12 +86,56 @@ type SearchInput struct {
 	// List of SearchFilter, which is a key(property) and values.
 	// When multiple filters are provided, results will match all filters (AND operation).
 	Filters []*SearchFilter `json:"filters,omitempty"`
+	// Groups of filters combined with an OR operation, for example (kind=Pod OR namespace=kube-system).
+	// Results will match all the filters and, for each group, at least one filter in the group.
+	FilterGroups []*SearchFilterGroup `json:"filterGroups,omitempty"`
+	// Limit the search to the resources in these clusters, for example `["prod-east", "prod-west"]`.
+	// The clusters the authenticated user isn't authorized to search are ignored.
+	// **Default is** all the clusters the user is authorized to search.
+	Clusters []string `json:"clusters,omitempty"`
+	// Exclude the resources in these clusters from the search, for example `["dev-east"]`.
+	// Applied after the clusters option, and it can only narrow the clusters the authenticated user is authorized to search.
+	ExcludeClusters []string `json:"excludeClusters,omitempty"`
+	// Limit the search to cluster-scoped resources (`cluster`), namespaced resources (`namespace`) or both (`all`).
+	// **Default is** `all`
+	Scope *string `json:"scope,omitempty"`
 	// Max number of results returned by the query.
 	// **Default is** 10,000
-	// A value of -1 will remove the limit. Use carefully because it may impact the service.
+	// A value of -1 returns all results, up to the configured MAX_QUERY_LIMIT. Use carefully because it may impact the service.
 	Limit *int `json:"limit,omitempty"`
 	// Filter relationships to the specified kinds.
 	// If empty, all relationships will be included.
 	// This filter is used with the 'related' field on SearchResult.
 	RelatedKinds []*string `json:"relatedKinds,omitempty"`
+	// Number of levels (hops) used to find the related resources.
+	// **Default is** 1, or 3 if the search includes applications. The max value is 3, unless RELATION_LEVEL is greater.
+	// This option is used with the 'related' field on SearchResult.
+	RelatedDepth *int `json:"relatedDepth,omitempty"`
+	// Sort the items by a property. By default, the order of the items isn't defined.
+	// Resources without the property are returned last.
+	SortBy *SearchSort `json:"sortBy,omitempty"`
+	// Return only these properties of the items, plus the uid. Used to reduce the size of the response, for example
+	// when a list only shows the name, namespace, kind and status.
+	// **Default is** all the properties. Denied properties are rejected or dropped as configured with PROPERTY_DENYLIST_ACTION.
+	Fields []string `json:"fields,omitempty"`
+	// Max number of items returned in a page. Use with the cursor to page through the results.
+	// **Default is** the limit.
+	PageSize *int `json:"pageSize,omitempty"`
+	// Cursor to get the next page of items. Use the nextCursor returned with the previous page.
+	// The cursor is only valid with the same sortBy option used to get the previous page.
+	Cursor *string `json:"cursor,omitempty"`
+	// Confirm the query can scan all the resources. Required when EMPTY_WHERE_ACTION is `confirm` and the query
+	// doesn't restrict the results, for example a user authorized to search all the resources without a filter.
+	// **Default is** false
+	ConfirmFullScan *bool `json:"confirmFullScan,omitempty"`
+}
+
+// Property and direction used to sort the search results.
+type SearchSort struct {
+	// Name of the property (key).
+	// Numbers are sorted by value. Dates are sorted by their text, which follows the chronological order.
+	Property string `json:"property"`
+	// Sort direction, `asc` or `desc`.
+	// **Default is** `asc`
+	Direction *string `json:"direction,omitempty"`
 }
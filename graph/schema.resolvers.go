@@ -19,14 +19,32 @@ func (r *queryResolver) Search(ctx context.Context, input []*model.SearchInput)
 	return resolver.Search(ctx, input)
 }
 
+// SearchCount is the resolver for the searchCount field.
+func (r *queryResolver) SearchCount(ctx context.Context, input model.SearchInput) (int, error) {
+	klog.V(3).Infoln("Received SearchCount query")
+	return resolver.SearchCount(ctx, &input)
+}
+
+// SearchUIDs is the resolver for the searchUIDs field.
+func (r *queryResolver) SearchUIDs(ctx context.Context, input model.SearchInput) ([]string, error) {
+	klog.V(3).Infoln("Received SearchUIDs query")
+	return resolver.SearchUIDs(ctx, &input)
+}
+
 // SearchComplete is the resolver for the searchComplete field.
-func (r *queryResolver) SearchComplete(ctx context.Context, property string, query *model.SearchInput, limit *int) ([]*string, error) {
+func (r *queryResolver) SearchComplete(ctx context.Context, property string, query *model.SearchInput, limit *int, filter *string, resolveReferences *bool) ([]*string, error) {
 	if limit != nil {
 		klog.V(3).Infof("Received SearchComplete query with input property **%s** and limit %d", property, *limit)
 	} else {
 		klog.V(3).Infof("Received SearchComplete query with input property **%s**", property)
 	}
-	return resolver.SearchComplete(ctx, property, query, limit)
+	return resolver.SearchComplete(ctx, property, query, limit, filter, resolveReferences)
+}
+
+// SearchCompleteWithCounts is the resolver for the searchCompleteWithCounts field.
+func (r *queryResolver) SearchCompleteWithCounts(ctx context.Context, property string, query *model.SearchInput, limit *int, filter *string) ([]*model.PropertyCount, error) {
+	klog.V(3).Infof("Received SearchCompleteWithCounts query with input property **%s**", property)
+	return resolver.SearchCompleteWithCounts(ctx, property, query, limit, filter)
 }
 
 // SearchSchema is the resolver for the searchSchema field.
@@ -35,6 +53,42 @@ func (r *queryResolver) SearchSchema(ctx context.Context) (map[string]interface{
 	return resolver.SearchSchemaResolver(ctx)
 }
 
+// SearchSchemaSamples is the resolver for the searchSchemaSamples field.
+func (r *queryResolver) SearchSchemaSamples(ctx context.Context, limit *int) (map[string]interface{}, error) {
+	klog.V(3).Infoln("Received SearchSchemaSamples query")
+	return resolver.SearchSchemaSamplesResolver(ctx, limit)
+}
+
+// SearchFacets is the resolver for the searchFacets field.
+func (r *queryResolver) SearchFacets(ctx context.Context, properties []string, query *model.SearchInput, limit *int) ([]*model.SearchFacet, error) {
+	klog.V(3).Infof("Received SearchFacets query with properties %v", properties)
+	return resolver.SearchFacets(ctx, properties, query, limit)
+}
+
+// SearchDrift is the resolver for the searchDrift field.
+func (r *queryResolver) SearchDrift(ctx context.Context, kind string, identity []string, limit *int) ([]*model.SearchDrift, error) {
+	klog.V(3).Infof("Received SearchDrift query for kind %s with identity %v", kind, identity)
+	return resolver.SearchDrift(ctx, kind, identity, limit)
+}
+
+// GetResource is the resolver for the getResource field.
+func (r *queryResolver) GetResource(ctx context.Context, uid string) (map[string]interface{}, error) {
+	klog.V(3).Infof("Received GetResource query for uid %s", uid)
+	return resolver.GetResource(ctx, uid)
+}
+
+// SearchRelated is the resolver for the searchRelated field.
+func (r *queryResolver) SearchRelated(ctx context.Context, uid string, depth *int, kinds []string) ([]*resolver.SearchRelatedResult, error) {
+	klog.V(3).Infoln("Received SearchRelated query")
+	return resolver.SearchRelated(ctx, uid, depth, kinds)
+}
+
+// UserManagedClusters is the resolver for the userManagedClusters field.
+func (r *queryResolver) UserManagedClusters(ctx context.Context) ([]string, error) {
+	klog.V(3).Infoln("Received UserManagedClusters query")
+	return resolver.UserManagedClusters(ctx)
+}
+
 // Messages is the resolver for the messages field.
 func (r *queryResolver) Messages(ctx context.Context) ([]*model.Message, error) {
 	klog.V(3).Infoln("Received Messages query")
@@ -8,6 +8,7 @@ import (
 	"github.com/stolostron/search-v2-api/pkg/database"
 	"github.com/stolostron/search-v2-api/pkg/rbac"
 	"github.com/stolostron/search-v2-api/pkg/server"
+	"github.com/stolostron/search-v2-api/pkg/tracing"
 	klog "k8s.io/klog/v2"
 )
 
@@ -29,11 +30,34 @@ func main() {
 
 	ctx := context.Background()
 
+	// Export the request traces when TRACING_ENDPOINT is set.
+	shutdownTracing, tracingErr := tracing.Init(ctx)
+	if tracingErr != nil {
+		klog.Warning("Unable to start tracing. ", tracingErr)
+	} else {
+		defer func() { _ = shutdownTracing(ctx) }()
+	}
+
 	// Establish the database connection.
 	database.GetConnPool(ctx)
 
+	// Report the connections in the database pool.
+	go database.StartPoolMetrics(ctx)
+
+	// Load the shared cache before the readiness probe passes.
+	go rbac.GetCache().StartSharedCacheWarmup(ctx)
+
 	// Start process to watch the RBAC config andd update the cache.
 	go rbac.GetCache().StartBackgroundValidation(ctx)
 
+	// Refresh the shared cache before it expires.
+	go rbac.GetCache().StartBackgroundRefresh(ctx)
+
+	// Report the size of the user cache.
+	go rbac.GetCache().StartUserCacheMetrics(ctx)
+
+	// Remove the stale users from the user cache.
+	go rbac.GetCache().StartUserCacheEviction(ctx)
+
 	server.StartAndListen()
 }
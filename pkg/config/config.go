@@ -5,9 +5,12 @@ package config
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/url"
 	"os"
+	"path"
 	"strconv"
+	"strings"
 
 	klog "k8s.io/klog/v2"
 )
@@ -20,9 +23,13 @@ type Config struct {
 	HubName             string //Display Name of the cluster where ACM is deployed
 	API_SERVER_URL      string // address for Kubernetes API Server
 	AuthCacheTTL        int    // Time-to-live (milliseconds) of Authentication (TokenReview) cache.
+	AutocompleteNorm    string // Normalize autocomplete values: none, whitespace or casefold. Default: none
 	SharedCacheTTL      int    // Time-to-live (milliseconds) of common resources (shared across users) cache.
+	CacheRefreshAhead   int    // Time (milliseconds) before the shared cache expires to refresh it in background.
 	UserCacheTTL        int    // Time-to-live (milliseconds) of namespaced resources (specifc to users) cache.
 	ContextPath         string
+	DBAcquireTimeout    int    // Max time (milliseconds) to wait for a connection from the pool. 0 disables it. Default: 10s
+	DBApplicationName   string // Identifies the search api connections in Postgres. Default: search-v2-api
 	DBHost              string
 	DBMinConns          int // Overrides pgxpool.Config{ MinConns } Default: 0
 	DBMaxConns          int // Overrides pgxpool.Config{ MaxConns } Default: 10
@@ -34,14 +41,64 @@ type Config struct {
 	DBPort              int
 	DBUser              string
 	DevelopmentMode     bool             // Indicates if running in local development mode.
+	EmptyWhereAction    string           // Action for queries that scan all resources: log, warn or confirm. Default: warn
+	ExcludedNamespaces  []string         // Namespaces (names or glob patterns) skipped by the RBAC namespace scan.
+	FacetLimit          int              // Max number of values returned per facet by the searchFacets query.
 	Features            featureFlags     // Enable or disable features.
 	Federation          federationConfig // Federated search configuration.
 	HttpPort            int
+	ItemsSerialization  string // Serialization of search result items: map or stream. Default: map
+	KubeListTimeout     int    // Timeout (milliseconds) to list managed clusters and namespaces. Default: 30s
+	LogSampleRate       int    // Log 1 in N of the per-namespace and per-resource RBAC lines. 0 or 1 logs all. Default: 1
+	MaxCachedUsers      int    // Max number of users in the user cache. 0 disables the limit. Default: 0
+	MaxQueryLimit       int    // Max LIMIT a client can request, including -1 for all results. Default: QueryLimit * 100
 	PlaygroundMode      bool   // Enable the GraphQL Playground client.
 	PodNamespace        string // Kubernetes namespace where the pod is running.
 	QueryLimit          int    // The default LIMIT to use on queries. Client can override.
+	QueryTimeout        int    // Timeout (milliseconds) of the search database queries. 0 disables it. Default: 60s
+	RBACConcurrency     int    // Max number of parallel SelfSubjectRulesReview requests for a user. Default: 10
+	RBACMaxRetries      int    // Max number of retries of the RBAC requests on transient Kube API errors. Default: 3
 	RelationLevel       int    // The number of levels/hops for finding relationships for a particular resource
+	SchemaSampleLimit   int    // Max number of sample values per property returned by searchSchemaSamples.
 	SlowLog             int    // Logs when queries are slower than the specified time duration in ms. Default 300ms
+	TracingEndpoint     string // OTLP HTTP endpoint to export traces, e.g. http://otel-collector:4318. Default: disabled
+	VerboseErrors       bool   // Include internal error details in GraphQL responses. Default: true in development mode.
+
+	// Label used to identify the managed clusters, for example to distinguish them from the hub cluster.
+	// When the label is set, a ManagedCluster is only treated as a managed cluster if it has the label, and the
+	// label value matches ManagedClusterLabelValue when it's set. Default: none, all except local-cluster.
+	ManagedClusterLabel      string // Label key, for example vendor.
+	ManagedClusterLabelValue string // Label value, for example OpenShift. Default: any value
+
+	// Max time (seconds) to wait for the in-flight requests to complete on shutdown, before closing the
+	// database connections. Default: 30s
+	ShutdownGraceSeconds int
+
+	// Time-to-live (milliseconds) of each section of the user cache. Default: UserCacheTTL
+	ClusterScopedCacheTTL  int // Cluster-scoped resources the user can list.
+	ManagedClusterCacheTTL int // Managed clusters the user can access.
+	NamespacedCacheTTL     int // Namespaced resources the user can list.
+
+	// Time-to-live (milliseconds) of the query results caches. 0 disables the cache.
+	AutocompleteCacheTTL int // Results of the searchComplete query.
+	SchemaCacheTTL       int // Results of the searchSchema query.
+
+	// Max number of resources scanned to find the property names suggested by the searchSchema query.
+	// A higher limit finds more of the distinct properties, but the query is slower. Default: QueryLimit * 100
+	AutocompleteScanLimit int
+
+	// Properties with the uid of another resource, for example _ownerUID. The searchComplete query can
+	// resolve these values to the kind and name of the referenced resource. Default: _ownerUID
+	AutocompleteReferenceProps []string
+
+	// Audit log of the search queries, written as one JSON line per query.
+	AuditLog          bool // Log the user, filter properties and result count of the search queries. Default: false
+	AuditRedactValues bool // Redact the filter values in the audit log. Default: true
+
+	// Properties hidden from search, for example annotations carrying secrets. The denied properties can't be
+	// used to filter, sort or autocomplete, aren't matched by keywords and aren't listed by searchSchema.
+	PropertyDenylist       []string // Property names. Default: none
+	PropertyDenylistAction string   // Action for queries using a denied property: reject or drop. Default: reject
 }
 
 // Define feature flags.
@@ -69,14 +126,20 @@ type federationConfig struct {
 func new() *Config {
 	// If environment variables are set, use default values
 	// Simply put, the order of preference is env -> default values (from left to right)
+	userCacheTTL := getEnvAsInt("USER_CACHE_TTL", 300000) // 5 min (increase to 10min after implementation)
+	queryLimit := getEnvAsInt("QUERY_LIMIT", 1000)
 	conf := &Config{
-		HubName:        getEnv("HUB_NAME", ""),
-		API_SERVER_URL: getEnv("API_SERVER_URL", "https://kubernetes.default.svc"),
-		AuthCacheTTL:   getEnvAsInt("AUTH_CACHE_TTL", 60000),    // 1 minute
-		SharedCacheTTL: getEnvAsInt("SHARED_CACHE_TTL", 300000), // 5 min (increase to 10min after implementation)
-		UserCacheTTL:   getEnvAsInt("USER_CACHE_TTL", 300000),   // 5 min (increase to 10min after implementation)
-		ContextPath:    getEnv("CONTEXT_PATH", "/searchapi"),
-		DBHost:         getEnv("DB_HOST", "localhost"),
+		HubName:           getEnv("HUB_NAME", ""),
+		API_SERVER_URL:    getEnv("API_SERVER_URL", "https://kubernetes.default.svc"),
+		AuthCacheTTL:      getEnvAsInt("AUTH_CACHE_TTL", 60000),             // 1 minute
+		AutocompleteNorm:  getEnv("AUTOCOMPLETE_NORMALIZE", "none"),         // none, whitespace or casefold
+		SharedCacheTTL:    getEnvAsInt("SHARED_CACHE_TTL", 300000),          // 5 min (increase to 10min after implementation)
+		CacheRefreshAhead: getEnvAsInt("SHARED_CACHE_REFRESH_AHEAD", 30000), // 30 seconds. 0 disables it.
+		UserCacheTTL:      userCacheTTL,
+		ContextPath:       getEnv("CONTEXT_PATH", "/searchapi"),
+		DBAcquireTimeout:  getEnvAsInt("DB_ACQUIRE_TIMEOUT", 10*1000), // 10 seconds.
+		DBApplicationName: getEnv("DB_APPLICATION_NAME", "search-v2-api"),
+		DBHost:            getEnv("DB_HOST", "localhost"),
 		// Postgres has 100 conns by default. Using 20 allows scaling indexer and api.
 		DBMaxConns:          getEnvAsInt("DB_MAX_CONNS", 10),                   // 10 - Overrides pgxpool default
 		DBMaxConnIdleTime:   getEnvAsInt("DB_MAX_CONN_IDLE_TIME", 30*60*1000),  // 30 min - Default for pgxpool.Config
@@ -88,6 +151,9 @@ func new() *Config {
 		DBPort:              getEnvAsInt("DB_PORT", 5432),
 		DBUser:              getEnv("DB_USER", ""),
 		DevelopmentMode:     DEVELOPMENT_MODE,
+		EmptyWhereAction:    getEnv("EMPTY_WHERE_ACTION", "warn"),
+		ExcludedNamespaces:  getEnvAsSlice("EXCLUDED_NAMESPACES", []string{}),
+		FacetLimit:          getEnvAsInt("FACET_LIMIT", 10),
 		Features: featureFlags{
 			FederatedSearch: getEnvAsBool("FEATURE_FEDERATED_SEARCH", false), // In Dev mode default to true.
 		},
@@ -103,14 +169,48 @@ func new() *Config {
 				RequestTimeout:        getEnvAsInt("FEDERATED_REQUEST_TIMEOUT", 60*1000), // 60 seconds.
 			},
 		},
-		HttpPort:       getEnvAsInt("HTTP_PORT", 4010),
-		PlaygroundMode: getEnvAsBool("PLAYGROUND_MODE", false),
-		PodNamespace:   getEnv("POD_NAMESPACE", "open-cluster-management"),
-		QueryLimit:     getEnvAsInt("QUERY_LIMIT", 1000),
-		SlowLog:        getEnvAsInt("SLOW_LOG", 300),
+		HttpPort:           getEnvAsInt("HTTP_PORT", 4010),
+		ItemsSerialization: getEnv("ITEMS_SERIALIZATION", "map"),
+		KubeListTimeout:    getEnvAsInt("KUBE_LIST_TIMEOUT", 30*1000), // 30 seconds.
+		LogSampleRate:      getEnvAsInt("LOG_SAMPLE_RATE", 1),
+		MaxCachedUsers:     getEnvAsInt("MAX_CACHED_USERS", 0),
+		MaxQueryLimit:      getEnvAsInt("MAX_QUERY_LIMIT", queryLimit*100),
+		PlaygroundMode:     getEnvAsBool("PLAYGROUND_MODE", false),
+		PodNamespace:       getEnv("POD_NAMESPACE", "open-cluster-management"),
+		QueryLimit:         queryLimit,
+		QueryTimeout:       getEnvAsInt("QUERY_TIMEOUT", 60*1000), // 60 seconds.
+		RBACConcurrency:    getEnvAsInt("RBAC_CONCURRENCY", 10),
+		RBACMaxRetries:     getEnvAsInt("RBAC_MAX_RETRIES", 3),
+		SchemaSampleLimit:  getEnvAsInt("SCHEMA_SAMPLE_LIMIT", 5),
+		SlowLog:            getEnvAsInt("SLOW_LOG", 300),
+		TracingEndpoint:    getEnv("TRACING_ENDPOINT", ""),
+		VerboseErrors:      getEnvAsBool("VERBOSE_ERRORS", DEVELOPMENT_MODE),
 		// Setting default level to 0 to check if user has explicitly set this variable
 		// This will be updated to 1 for default searches and 3 for applications - unless set by the user
 		RelationLevel: getEnvAsInt("RELATION_LEVEL", 0),
+
+		ManagedClusterLabel:      getEnv("MANAGED_CLUSTER_LABEL", ""),
+		ManagedClusterLabelValue: getEnv("MANAGED_CLUSTER_LABEL_VALUE", ""),
+
+		ShutdownGraceSeconds: getEnvAsInt("SHUTDOWN_GRACE_SECONDS", 30),
+
+		// Use the USER_CACHE_TTL if the TTL for the section isn't set.
+		ClusterScopedCacheTTL:  getEnvAsInt("CLUSTER_SCOPED_CACHE_TTL", userCacheTTL),
+		ManagedClusterCacheTTL: getEnvAsInt("MANAGED_CLUSTER_CACHE_TTL", userCacheTTL),
+		NamespacedCacheTTL:     getEnvAsInt("NAMESPACED_CACHE_TTL", userCacheTTL),
+
+		AutocompleteCacheTTL: getEnvAsInt("AUTOCOMPLETE_CACHE_TTL", 5*1000), // 5 seconds
+		SchemaCacheTTL:       getEnvAsInt("SCHEMA_CACHE_TTL", 60*1000),      // 1 minute
+
+		AutocompleteScanLimit: getEnvAsInt("AUTOCOMPLETE_SCAN_LIMIT", queryLimit*100),
+
+		AutocompleteReferenceProps: getEnvAsSlice("AUTOCOMPLETE_REFERENCE_PROPERTIES", []string{"_ownerUID"}),
+
+		AuditLog:          getEnvAsBool("AUDIT_LOG", false),
+		AuditRedactValues: getEnvAsBool("AUDIT_REDACT_VALUES", true),
+
+		PropertyDenylist:       getEnvAsSlice("PROPERTY_DENYLIST", []string{}),
+		PropertyDenylistAction: getEnv("PROPERTY_DENYLIST_ACTION", "reject"),
 	}
 	conf.DBPass = url.QueryEscape(conf.DBPass)
 	return conf
@@ -142,6 +242,83 @@ func (cfg *Config) Validate() error {
 	if cfg.DBPass == "" {
 		return errors.New("required environment DB_PASS is not set")
 	}
+	// Postgres truncates application_name to 63 characters and only accepts printable ASCII.
+	if len(cfg.DBApplicationName) > 63 {
+		return errors.New("environment DB_APPLICATION_NAME must be 63 characters or less")
+	}
+	for _, c := range cfg.DBApplicationName {
+		if c < 32 || c > 126 || c == ' ' || c == '\'' || c == '\\' {
+			return errors.New("environment DB_APPLICATION_NAME must contain only printable ASCII characters without spaces or quotes")
+		}
+	}
+	if cfg.CacheRefreshAhead >= cfg.SharedCacheTTL {
+		return errors.New("environment SHARED_CACHE_REFRESH_AHEAD must be less than SHARED_CACHE_TTL")
+	}
+	if cfg.KubeListTimeout <= 0 {
+		return errors.New("environment KUBE_LIST_TIMEOUT must be greater than 0")
+	}
+	if cfg.AutocompleteScanLimit <= 0 {
+		return errors.New("environment AUTOCOMPLETE_SCAN_LIMIT must be greater than 0")
+	}
+	if cfg.LogSampleRate < 0 {
+		return errors.New("environment LOG_SAMPLE_RATE must be greater than or equal to 0")
+	}
+	if cfg.ManagedClusterLabelValue != "" && cfg.ManagedClusterLabel == "" {
+		return errors.New("environment MANAGED_CLUSTER_LABEL_VALUE requires MANAGED_CLUSTER_LABEL")
+	}
+	if cfg.MaxCachedUsers < 0 {
+		return errors.New("environment MAX_CACHED_USERS must be greater than or equal to 0")
+	}
+	if cfg.MaxQueryLimit < cfg.QueryLimit {
+		return errors.New("environment MAX_QUERY_LIMIT must be greater than or equal to QUERY_LIMIT")
+	}
+	if cfg.RBACConcurrency <= 0 {
+		return errors.New("environment RBAC_CONCURRENCY must be greater than 0")
+	}
+	if cfg.RBACMaxRetries < 0 {
+		return errors.New("environment RBAC_MAX_RETRIES must be greater than or equal to 0")
+	}
+	if cfg.SchemaSampleLimit <= 0 {
+		return errors.New("environment SCHEMA_SAMPLE_LIMIT must be greater than 0")
+	}
+	if cfg.ShutdownGraceSeconds < 0 {
+		return errors.New("environment SHUTDOWN_GRACE_SECONDS must be greater than or equal to 0")
+	}
+	if cfg.DBAcquireTimeout < 0 {
+		return errors.New("environment DB_ACQUIRE_TIMEOUT must be greater than or equal to 0")
+	}
+	if cfg.QueryTimeout < 0 {
+		return errors.New("environment QUERY_TIMEOUT must be greater than or equal to 0")
+	}
+	if cfg.ItemsSerialization != "map" && cfg.ItemsSerialization != "stream" {
+		return errors.New("environment ITEMS_SERIALIZATION must be one of: map, stream")
+	}
+	for _, pattern := range cfg.ExcludedNamespaces {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("environment EXCLUDED_NAMESPACES has an invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if cfg.TracingEndpoint != "" {
+		endpoint, err := url.Parse(cfg.TracingEndpoint)
+		if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+			return errors.New("environment TRACING_ENDPOINT must be an http or https URL")
+		}
+	}
+	switch cfg.AutocompleteNorm {
+	case "none", "whitespace", "casefold":
+	default:
+		return errors.New("environment AUTOCOMPLETE_NORMALIZE must be one of: none, whitespace, casefold")
+	}
+	switch cfg.EmptyWhereAction {
+	case "log", "warn", "confirm":
+	default:
+		return errors.New("environment EMPTY_WHERE_ACTION must be one of: log, warn, confirm")
+	}
+	switch cfg.PropertyDenylistAction {
+	case "reject", "drop":
+	default:
+		return errors.New("environment PROPERTY_DENYLIST_ACTION must be one of: reject, drop")
+	}
 	return nil
 }
 
@@ -171,3 +348,18 @@ func getEnvAsBool(name string, defaultVal bool) bool {
 
 	return defaultVal
 }
+
+// Helper to read a comma separated environment variable into a slice or return default value
+func getEnvAsSlice(name string, defaultVal []string) []string {
+	valStr, exists := os.LookupEnv(name)
+	if !exists {
+		return defaultVal
+	}
+	values := []string{}
+	for _, value := range strings.Split(valStr, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -127,3 +127,439 @@ func Test_Validate(t *testing.T) {
 		t.Errorf("Expected %s Got: %s", "required environment DB_NAME is not set", result)
 	}
 }
+
+// Should validate DB_APPLICATION_NAME is a valid Postgres application_name.
+func Test_Validate_DBApplicationName(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	defer os.Unsetenv("DB_APPLICATION_NAME")
+
+	conf := new()
+	if conf.DBApplicationName != "search-v2-api" {
+		t.Errorf("Expected default DBApplicationName %s Got: %s", "search-v2-api", conf.DBApplicationName)
+	}
+
+	os.Setenv("DB_APPLICATION_NAME", strings.Repeat("a", 64))
+	conf = new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment DB_APPLICATION_NAME must be 63 characters or less" {
+		t.Errorf("Expected error for long DB_APPLICATION_NAME Got: %v", result)
+	}
+
+	os.Setenv("DB_APPLICATION_NAME", "search api")
+	conf = new()
+	result = conf.Validate()
+	if result == nil {
+		t.Error("Expected error for DB_APPLICATION_NAME containing a space.")
+	}
+}
+
+func Test_Validate_EmptyWhereAction(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	defer os.Unsetenv("EMPTY_WHERE_ACTION")
+
+	conf := new()
+	if conf.EmptyWhereAction != "warn" {
+		t.Errorf("Expected default EmptyWhereAction %s Got: %s", "warn", conf.EmptyWhereAction)
+	}
+
+	os.Setenv("EMPTY_WHERE_ACTION", "confirm")
+	conf = new()
+	if result := conf.Validate(); result != nil {
+		t.Errorf("Expected %v Got: %+v", nil, result)
+	}
+
+	os.Setenv("EMPTY_WHERE_ACTION", "ignore")
+	conf = new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment EMPTY_WHERE_ACTION must be one of: log, warn, confirm" {
+		t.Errorf("Expected error for invalid EMPTY_WHERE_ACTION Got: %v", result)
+	}
+}
+
+func Test_Validate_CacheRefreshAhead(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("SHARED_CACHE_REFRESH_AHEAD", "300000")
+	defer os.Unsetenv("SHARED_CACHE_REFRESH_AHEAD")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment SHARED_CACHE_REFRESH_AHEAD must be less than SHARED_CACHE_TTL" {
+		t.Errorf("Expected error for SHARED_CACHE_REFRESH_AHEAD Got: %v", result)
+	}
+}
+
+func Test_Validate_ItemsSerialization(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("ITEMS_SERIALIZATION", "xml")
+	defer os.Unsetenv("ITEMS_SERIALIZATION")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment ITEMS_SERIALIZATION must be one of: map, stream" {
+		t.Errorf("Expected error for invalid ITEMS_SERIALIZATION Got: %v", result)
+	}
+}
+
+func Test_Validate_KubeListTimeout(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("KUBE_LIST_TIMEOUT", "0")
+	defer os.Unsetenv("KUBE_LIST_TIMEOUT")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment KUBE_LIST_TIMEOUT must be greater than 0" {
+		t.Errorf("Expected error for KUBE_LIST_TIMEOUT Got: %v", result)
+	}
+}
+
+// Should default AUTOCOMPLETE_SCAN_LIMIT to QUERY_LIMIT * 100.
+func Test_AutocompleteScanLimit_default(t *testing.T) {
+	os.Setenv("QUERY_LIMIT", "500")
+	defer os.Unsetenv("QUERY_LIMIT")
+
+	conf := new()
+	if conf.AutocompleteScanLimit != 50000 {
+		t.Errorf("Expected default AutocompleteScanLimit %d Got: %d", 50000, conf.AutocompleteScanLimit)
+	}
+
+	os.Setenv("AUTOCOMPLETE_SCAN_LIMIT", "20000")
+	defer os.Unsetenv("AUTOCOMPLETE_SCAN_LIMIT")
+	conf = new()
+	if conf.AutocompleteScanLimit != 20000 {
+		t.Errorf("Expected AutocompleteScanLimit %d Got: %d", 20000, conf.AutocompleteScanLimit)
+	}
+}
+
+// Should read the comma separated AUTOCOMPLETE_REFERENCE_PROPERTIES.
+func Test_AutocompleteReferenceProps(t *testing.T) {
+	conf := new()
+	if len(conf.AutocompleteReferenceProps) != 1 || conf.AutocompleteReferenceProps[0] != "_ownerUID" {
+		t.Errorf("Expected default AutocompleteReferenceProps [_ownerUID] Got: %v", conf.AutocompleteReferenceProps)
+	}
+
+	os.Setenv("AUTOCOMPLETE_REFERENCE_PROPERTIES", "_ownerUID, _hostingResourceUID,")
+	defer os.Unsetenv("AUTOCOMPLETE_REFERENCE_PROPERTIES")
+	conf = new()
+	if len(conf.AutocompleteReferenceProps) != 2 || conf.AutocompleteReferenceProps[1] != "_hostingResourceUID" {
+		t.Errorf("Expected AutocompleteReferenceProps [_ownerUID _hostingResourceUID] Got: %v",
+			conf.AutocompleteReferenceProps)
+	}
+}
+
+// Should read the comma separated EXCLUDED_NAMESPACES. No namespaces are excluded by default.
+func Test_ExcludedNamespaces(t *testing.T) {
+	conf := new()
+	if len(conf.ExcludedNamespaces) != 0 {
+		t.Errorf("Expected default ExcludedNamespaces [] Got: %v", conf.ExcludedNamespaces)
+	}
+
+	os.Setenv("EXCLUDED_NAMESPACES", "openshift-*, kube-system")
+	defer os.Unsetenv("EXCLUDED_NAMESPACES")
+	conf = new()
+	if len(conf.ExcludedNamespaces) != 2 || conf.ExcludedNamespaces[0] != "openshift-*" ||
+		conf.ExcludedNamespaces[1] != "kube-system" {
+		t.Errorf("Expected ExcludedNamespaces [openshift-* kube-system] Got: %v", conf.ExcludedNamespaces)
+	}
+}
+
+func Test_Validate_ExcludedNamespaces(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("EXCLUDED_NAMESPACES", "openshift-*,kube-[")
+	defer os.Unsetenv("EXCLUDED_NAMESPACES")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != `environment EXCLUDED_NAMESPACES has an invalid pattern "kube-[": `+
+		"syntax error in pattern" {
+		t.Errorf("Expected error for EXCLUDED_NAMESPACES Got: %v", result)
+	}
+}
+
+// The audit log is disabled by default and redacts the filter values when enabled.
+func Test_AuditLog(t *testing.T) {
+	conf := new()
+	if conf.AuditLog || !conf.AuditRedactValues {
+		t.Errorf("Expected default AuditLog false and AuditRedactValues true Got: %t %t",
+			conf.AuditLog, conf.AuditRedactValues)
+	}
+
+	os.Setenv("AUDIT_LOG", "true")
+	os.Setenv("AUDIT_REDACT_VALUES", "false")
+	defer os.Unsetenv("AUDIT_LOG")
+	defer os.Unsetenv("AUDIT_REDACT_VALUES")
+	conf = new()
+	if !conf.AuditLog || conf.AuditRedactValues {
+		t.Errorf("Expected AuditLog true and AuditRedactValues false Got: %t %t", conf.AuditLog, conf.AuditRedactValues)
+	}
+}
+
+func Test_Validate_AutocompleteScanLimit(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("AUTOCOMPLETE_SCAN_LIMIT", "0")
+	defer os.Unsetenv("AUTOCOMPLETE_SCAN_LIMIT")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment AUTOCOMPLETE_SCAN_LIMIT must be greater than 0" {
+		t.Errorf("Expected error for AUTOCOMPLETE_SCAN_LIMIT Got: %v", result)
+	}
+}
+
+func Test_Validate_RBACConcurrency(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("RBAC_CONCURRENCY", "0")
+	defer os.Unsetenv("RBAC_CONCURRENCY")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment RBAC_CONCURRENCY must be greater than 0" {
+		t.Errorf("Expected error for RBAC_CONCURRENCY Got: %v", result)
+	}
+}
+
+func Test_Validate_MaxCachedUsers(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("MAX_CACHED_USERS", "-1")
+	defer os.Unsetenv("MAX_CACHED_USERS")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment MAX_CACHED_USERS must be greater than or equal to 0" {
+		t.Errorf("Expected error for MAX_CACHED_USERS Got: %v", result)
+	}
+}
+
+func Test_Validate_LogSampleRate(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("LOG_SAMPLE_RATE", "-1")
+	defer os.Unsetenv("LOG_SAMPLE_RATE")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment LOG_SAMPLE_RATE must be greater than or equal to 0" {
+		t.Errorf("Expected error for LOG_SAMPLE_RATE Got: %v", result)
+	}
+}
+
+func Test_Validate_ManagedClusterLabelValue(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("MANAGED_CLUSTER_LABEL_VALUE", "OpenShift")
+	defer os.Unsetenv("MANAGED_CLUSTER_LABEL_VALUE")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment MANAGED_CLUSTER_LABEL_VALUE requires MANAGED_CLUSTER_LABEL" {
+		t.Errorf("Expected error for MANAGED_CLUSTER_LABEL_VALUE Got: %v", result)
+	}
+
+	os.Setenv("MANAGED_CLUSTER_LABEL", "vendor")
+	defer os.Unsetenv("MANAGED_CLUSTER_LABEL")
+	conf = new()
+	if result = conf.Validate(); result != nil {
+		t.Errorf("Expected nil error with MANAGED_CLUSTER_LABEL set Got: %v", result)
+	}
+}
+
+func Test_Validate_ShutdownGraceSeconds(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("SHUTDOWN_GRACE_SECONDS", "-1")
+	defer os.Unsetenv("SHUTDOWN_GRACE_SECONDS")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment SHUTDOWN_GRACE_SECONDS must be greater than or equal to 0" {
+		t.Errorf("Expected error for SHUTDOWN_GRACE_SECONDS Got: %v", result)
+	}
+}
+
+func Test_Validate_DBAcquireTimeout(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("DB_ACQUIRE_TIMEOUT", "-1")
+	defer os.Unsetenv("DB_ACQUIRE_TIMEOUT")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment DB_ACQUIRE_TIMEOUT must be greater than or equal to 0" {
+		t.Errorf("Expected error for DB_ACQUIRE_TIMEOUT Got: %v", result)
+	}
+}
+
+func Test_Validate_RBACMaxRetries(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("RBAC_MAX_RETRIES", "-1")
+	defer os.Unsetenv("RBAC_MAX_RETRIES")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment RBAC_MAX_RETRIES must be greater than or equal to 0" {
+		t.Errorf("Expected error for RBAC_MAX_RETRIES Got: %v", result)
+	}
+}
+
+func Test_Validate_MaxQueryLimit(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("MAX_QUERY_LIMIT", "999")
+	defer os.Unsetenv("MAX_QUERY_LIMIT")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment MAX_QUERY_LIMIT must be greater than or equal to QUERY_LIMIT" {
+		t.Errorf("Expected error for MAX_QUERY_LIMIT Got: %v", result)
+	}
+}
+
+func Test_Validate_SchemaSampleLimit(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("SCHEMA_SAMPLE_LIMIT", "0")
+	defer os.Unsetenv("SCHEMA_SAMPLE_LIMIT")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment SCHEMA_SAMPLE_LIMIT must be greater than 0" {
+		t.Errorf("Expected error for SCHEMA_SAMPLE_LIMIT Got: %v", result)
+	}
+}
+
+func Test_Validate_QueryTimeout(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("QUERY_TIMEOUT", "-1")
+	defer os.Unsetenv("QUERY_TIMEOUT")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment QUERY_TIMEOUT must be greater than or equal to 0" {
+		t.Errorf("Expected error for QUERY_TIMEOUT Got: %v", result)
+	}
+}
+
+func Test_Validate_AutocompleteNormalize(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	defer os.Unsetenv("AUTOCOMPLETE_NORMALIZE")
+
+	conf := new()
+	if conf.AutocompleteNorm != "none" {
+		t.Errorf("Expected default AutocompleteNorm %s Got: %s", "none", conf.AutocompleteNorm)
+	}
+
+	os.Setenv("AUTOCOMPLETE_NORMALIZE", "casefold")
+	conf = new()
+	if result := conf.Validate(); result != nil {
+		t.Errorf("Expected %v Got: %+v", nil, result)
+	}
+
+	os.Setenv("AUTOCOMPLETE_NORMALIZE", "upper")
+	conf = new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment AUTOCOMPLETE_NORMALIZE must be one of: none, whitespace, casefold" {
+		t.Errorf("Expected error for invalid AUTOCOMPLETE_NORMALIZE Got: %v", result)
+	}
+}
+
+// The TTL of each section of the user cache defaults to USER_CACHE_TTL.
+func Test_UserCacheSectionTTL(t *testing.T) {
+	os.Setenv("USER_CACHE_TTL", "60000")
+	os.Setenv("MANAGED_CLUSTER_CACHE_TTL", "600000")
+	defer os.Unsetenv("USER_CACHE_TTL")
+	defer os.Unsetenv("MANAGED_CLUSTER_CACHE_TTL")
+
+	conf := new()
+	if conf.ClusterScopedCacheTTL != 60000 || conf.NamespacedCacheTTL != 60000 {
+		t.Errorf("Expected ClusterScopedCacheTTL and NamespacedCacheTTL to default to USER_CACHE_TTL. Got: %d and %d",
+			conf.ClusterScopedCacheTTL, conf.NamespacedCacheTTL)
+	}
+	if conf.ManagedClusterCacheTTL != 600000 {
+		t.Errorf("Expected ManagedClusterCacheTTL %d Got: %d", 600000, conf.ManagedClusterCacheTTL)
+	}
+}
+
+// No properties are denied by default, and queries using a denied property are rejected.
+func Test_PropertyDenylist(t *testing.T) {
+	conf := new()
+	if len(conf.PropertyDenylist) != 0 || conf.PropertyDenylistAction != "reject" {
+		t.Errorf("Expected default PropertyDenylist [] and PropertyDenylistAction reject Got: %v %s",
+			conf.PropertyDenylist, conf.PropertyDenylistAction)
+	}
+
+	os.Setenv("PROPERTY_DENYLIST", "annotation, secretRef")
+	os.Setenv("PROPERTY_DENYLIST_ACTION", "drop")
+	defer os.Unsetenv("PROPERTY_DENYLIST")
+	defer os.Unsetenv("PROPERTY_DENYLIST_ACTION")
+	conf = new()
+	if len(conf.PropertyDenylist) != 2 || conf.PropertyDenylist[0] != "annotation" ||
+		conf.PropertyDenylist[1] != "secretRef" || conf.PropertyDenylistAction != "drop" {
+		t.Errorf("Expected PropertyDenylist [annotation secretRef] and PropertyDenylistAction drop Got: %v %s",
+			conf.PropertyDenylist, conf.PropertyDenylistAction)
+	}
+}
+
+func Test_Validate_PropertyDenylistAction(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	os.Setenv("PROPERTY_DENYLIST_ACTION", "ignore")
+	defer os.Unsetenv("PROPERTY_DENYLIST_ACTION")
+
+	conf := new()
+	result := conf.Validate()
+	if result == nil || result.Error() != "environment PROPERTY_DENYLIST_ACTION must be one of: reject, drop" {
+		t.Errorf("Expected error for invalid PROPERTY_DENYLIST_ACTION Got: %v", result)
+	}
+}
+
+func Test_Validate_TracingEndpoint(t *testing.T) {
+	os.Setenv("DB_NAME", "test")
+	os.Setenv("DB_USER", "test")
+	os.Setenv("DB_PASS", "test")
+	defer os.Unsetenv("TRACING_ENDPOINT")
+
+	for _, endpoint := range []string{"otel-collector:4318", "grpc://otel-collector:4317", "http://"} {
+		os.Setenv("TRACING_ENDPOINT", endpoint)
+		conf := new()
+		result := conf.Validate()
+		if result == nil || result.Error() != "environment TRACING_ENDPOINT must be an http or https URL" {
+			t.Errorf("Expected error for TRACING_ENDPOINT %s Got: %v", endpoint, result)
+		}
+	}
+
+	os.Setenv("TRACING_ENDPOINT", "https://otel-collector:4318/v1/traces")
+	conf := new()
+	if result := conf.Validate(); result != nil {
+		t.Errorf("Expected valid TRACING_ENDPOINT Got: %v", result)
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright Contributors to the Open Cluster Management project
+package database
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/stolostron/search-v2-api/pkg/config"
+)
+
+// Returned when a connection isn't acquired from the pool within DB_ACQUIRE_TIMEOUT because all the
+// connections are in use. Clients should back off and retry.
+var ErrPoolExhausted = errors.New("the search service is busy, all the database connections are in use")
+
+type acquireTimerKey struct{}
+
+// Cancels the context of a query when a connection isn't acquired in time.
+type acquireTimer struct {
+	timer   *time.Timer
+	expired atomic.Bool
+}
+
+// Returns a context canceled when a connection isn't acquired from the pool within DB_ACQUIRE_TIMEOUT.
+// The pool acquires the connection with the context of the query, so the timer is stopped by the
+// BeforeAcquire hook and the timeout doesn't apply to the query. Disabled when DB_ACQUIRE_TIMEOUT is 0.
+func WithAcquireTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if config.Cfg.DBAcquireTimeout <= 0 {
+		return ctx, cancel
+	}
+	t := &acquireTimer{}
+	t.timer = time.AfterFunc(time.Duration(config.Cfg.DBAcquireTimeout)*time.Millisecond, func() {
+		t.expired.Store(true)
+		cancel()
+	})
+	return context.WithValue(ctx, acquireTimerKey{}, t), func() {
+		t.timer.Stop()
+		cancel()
+	}
+}
+
+// Stops the acquire timer of the context. Called when the pool acquires a connection for the query.
+func StopAcquireTimer(ctx context.Context) {
+	if t, ok := ctx.Value(acquireTimerKey{}).(*acquireTimer); ok {
+		t.timer.Stop()
+	}
+}
+
+// Checks if the context was canceled because a connection wasn't acquired within DB_ACQUIRE_TIMEOUT.
+func AcquireTimedOut(ctx context.Context) bool {
+	t, ok := ctx.Value(acquireTimerKey{}).(*acquireTimer)
+	return ok && t.expired.Load()
+}
@@ -0,0 +1,50 @@
+// Copyright Contributors to the Open Cluster Management project
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// Should cancel the context when the connection isn't acquired in time.
+func Test_WithAcquireTimeout_Expired(t *testing.T) {
+	defer func(timeout int) { config.Cfg.DBAcquireTimeout = timeout }(config.Cfg.DBAcquireTimeout)
+	config.Cfg.DBAcquireTimeout = 1
+
+	ctx, cancel := WithAcquireTimeout(context.Background())
+	defer cancel()
+	<-ctx.Done()
+
+	assert.True(t, AcquireTimedOut(ctx))
+}
+
+// Should keep the context after the connection is acquired.
+func Test_WithAcquireTimeout_Acquired(t *testing.T) {
+	defer func(timeout int) { config.Cfg.DBAcquireTimeout = timeout }(config.Cfg.DBAcquireTimeout)
+	config.Cfg.DBAcquireTimeout = 5
+
+	ctx, cancel := WithAcquireTimeout(context.Background())
+	StopAcquireTimer(ctx)
+	time.Sleep(20 * time.Millisecond)
+
+	assert.Nil(t, ctx.Err())
+	assert.False(t, AcquireTimedOut(ctx))
+	cancel()
+	assert.False(t, AcquireTimedOut(ctx))
+}
+
+// Should not set a timer when DB_ACQUIRE_TIMEOUT is 0.
+func Test_WithAcquireTimeout_Disabled(t *testing.T) {
+	defer func(timeout int) { config.Cfg.DBAcquireTimeout = timeout }(config.Cfg.DBAcquireTimeout)
+	config.Cfg.DBAcquireTimeout = 0
+
+	ctx, cancel := WithAcquireTimeout(context.Background())
+	defer cancel()
+
+	assert.Nil(t, ctx.Value(acquireTimerKey{}))
+	assert.False(t, AcquireTimedOut(ctx))
+}
@@ -3,10 +3,14 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/driftprogramming/pgxpoolmock"
 	"github.com/jackc/pgx/v4"
 	pgxpool "github.com/jackc/pgx/v4/pgxpool"
 	"github.com/stolostron/search-v2-api/pkg/config"
@@ -16,6 +20,10 @@ import (
 
 var pool *pgxpool.Pool
 var timeLastPing time.Time
+var poolLock sync.Mutex // Protects pool and timeLastPing, read by the requests and the pool metrics.
+
+// Max time to wait for the database in the health check.
+const healthzTimeout = 2 * time.Second
 
 // Checks new connection is healthy before using it.
 func afterConnect(ctx context.Context, c *pgx.Conn) error {
@@ -27,15 +35,18 @@ func afterConnect(ctx context.Context, c *pgx.Conn) error {
 }
 
 // Checks idle connection is healthy before using it.
+// Also stops the acquire timer of the query, the connection is acquired.
 func beforeAcquire(ctx context.Context, c *pgx.Conn) bool {
 	if err := c.Ping(ctx); err != nil {
 		klog.V(7).Info("Idle DB connection from pool is unhealthy, destroying it. ", err)
 		return false
 	}
+	StopAcquireTimer(ctx)
 	return true
 }
 
-func initializePool(ctx context.Context) {
+// Build the pgxpool configuration from the search-api config.
+func getPoolConfig() (*pgxpool.Config, error) {
 	cfg := config.Cfg
 	dbConnString := fmt.Sprint(
 		"host=", cfg.DBHost,
@@ -44,6 +55,8 @@ func initializePool(ctx context.Context) {
 		" password=", cfg.DBPass,
 		" dbname=", cfg.DBName,
 		" sslmode=require", // https://www.postgresql.org/docs/current/libpq-connect.html
+		// Identifies the search-api connections in Postgres monitoring tools, like pg_stat_activity.
+		" application_name=", cfg.DBApplicationName,
 	)
 
 	// Remove password from connection log.
@@ -53,6 +66,7 @@ func initializePool(ctx context.Context) {
 	config, configErr := pgxpool.ParseConfig(dbConnString)
 	if configErr != nil {
 		klog.Error("Error parsing database connection configuration.", configErr)
+		return nil, configErr
 	}
 
 	config.AfterConnect = afterConnect   // Checks new connection health before using it.
@@ -63,8 +77,21 @@ func initializePool(ctx context.Context) {
 	config.MaxConnIdleTime = time.Duration(cfg.DBMaxConnIdleTime) * time.Millisecond
 	config.MaxConnLifetime = time.Duration(cfg.DBMaxConnLifeTime) * time.Millisecond
 	config.MinConns = int32(cfg.DBMinConns)
+	// Postgres cancels the queries exceeding the timeout, so these don't keep running when the client gives up.
+	if cfg.QueryTimeout > 0 {
+		config.ConnConfig.RuntimeParams["statement_timeout"] = strconv.Itoa(cfg.QueryTimeout)
+	}
 
 	klog.Infof("Using pgxpool.Config %+v", config)
+	return config, nil
+}
+
+func initializePool(ctx context.Context) {
+	config, configErr := getPoolConfig()
+	if configErr != nil {
+		metrics.DBConnectionFailed.Inc()
+		return
+	}
 
 	conn, err := pgxpool.ConnectConfig(ctx, config)
 	if err != nil {
@@ -77,23 +104,63 @@ func initializePool(ctx context.Context) {
 }
 
 func GetConnPool(ctx context.Context) *pgxpool.Pool {
+	poolLock.Lock()
 	if pool == nil {
 		initializePool(ctx)
 	}
+	p, lastPing := pool, timeLastPing
+	poolLock.Unlock()
 
-	if pool != nil {
+	if p != nil {
 		// Skip database ping if checked less than 1 second ago.
-		if time.Since(timeLastPing) < time.Second {
-			return pool
+		if time.Since(lastPing) < time.Second {
+			return p
 		}
-		err := pool.Ping(ctx)
+		err := p.Ping(ctx)
 		if err != nil {
 			klog.Error("Unable to get a healthy database connection. ", err)
 			metrics.DBConnectionFailed.Inc()
 			return nil
 		}
+		poolLock.Lock()
 		timeLastPing = time.Now()
+		poolLock.Unlock()
 		klog.V(5).Info("Database pool connection is healthy.")
 	}
+	return p
+}
+
+// Returns the pool without connecting to the database. The pool is nil until the connection is established.
+func getPool() *pgxpool.Pool {
+	poolLock.Lock()
+	defer poolLock.Unlock()
 	return pool
 }
+
+// Closes all the connections in the pool. Used on shutdown, after the in-flight requests complete.
+func ClosePool() {
+	if p := getPool(); p != nil {
+		klog.Info("Closing the database connection pool.")
+		p.Close()
+	}
+}
+
+// Checks the database is reachable by running a simple query. Used by the readiness probe.
+func Healthz(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, healthzTimeout)
+	defer cancel()
+	conn := GetConnPool(ctx)
+	if conn == nil {
+		return errors.New("unable to get a healthy database connection")
+	}
+	return healthz(ctx, conn)
+}
+
+func healthz(ctx context.Context, conn pgxpoolmock.PgxPool) error {
+	var result int
+	if err := conn.QueryRow(ctx, "SELECT 1").Scan(&result); err != nil {
+		klog.Warning("Database health check failed. ", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright Contributors to the Open Cluster Management project
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/golang/mock/gomock"
+	"github.com/stolostron/search-v2-api/pkg/config"
+)
+
+// Should set the application_name on the pool connection config.
+func Test_getPoolConfig_ApplicationName(t *testing.T) {
+	config.Cfg.DBApplicationName = "search-v2-api-test"
+
+	poolConfig, err := getPoolConfig()
+	if err != nil {
+		t.Fatalf("Expected no error building pool config. Got: %s", err)
+	}
+
+	appName := poolConfig.ConnConfig.RuntimeParams["application_name"]
+	if appName != "search-v2-api-test" {
+		t.Errorf("Expected application_name to be %s. Got: %s", "search-v2-api-test", appName)
+	}
+}
+
+// Should set the Postgres statement_timeout with the QUERY_TIMEOUT.
+func Test_getPoolConfig_StatementTimeout(t *testing.T) {
+	defer func(timeout int) { config.Cfg.QueryTimeout = timeout }(config.Cfg.QueryTimeout)
+	config.Cfg.QueryTimeout = 30000
+
+	poolConfig, err := getPoolConfig()
+	if err != nil {
+		t.Fatalf("Expected no error building pool config. Got: %s", err)
+	}
+	if timeout := poolConfig.ConnConfig.RuntimeParams["statement_timeout"]; timeout != "30000" {
+		t.Errorf("Expected statement_timeout to be %s. Got: %s", "30000", timeout)
+	}
+
+	// The statement_timeout isn't set when the timeout is disabled.
+	config.Cfg.QueryTimeout = 0
+	poolConfig, _ = getPoolConfig()
+	if timeout, ok := poolConfig.ConnConfig.RuntimeParams["statement_timeout"]; ok {
+		t.Errorf("Expected statement_timeout to not be set. Got: %s", timeout)
+	}
+}
+
+// Mock the pgx.Row returned by the health check query.
+type mockRow struct {
+	err error
+}
+
+func (r mockRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*int) = 1
+	return nil
+}
+
+// Should run a simple query to check the database is reachable.
+func Test_healthz(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	mockPool.EXPECT().QueryRow(gomock.Any(), gomock.Eq("SELECT 1")).Return(mockRow{})
+
+	if err := healthz(context.Background(), mockPool); err != nil {
+		t.Errorf("Expected no error from the health check. Got: %s", err)
+	}
+}
+
+// Should return the error when the database isn't reachable.
+func Test_healthz_Error(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	mockPool.EXPECT().QueryRow(gomock.Any(), gomock.Eq("SELECT 1")).
+		Return(mockRow{err: errors.New("connection refused")})
+
+	if err := healthz(context.Background(), mockPool); err == nil || err.Error() != "connection refused" {
+		t.Errorf("Expected connection refused error from the health check. Got: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright Contributors to the Open Cluster Management project
+package database
+
+import (
+	"context"
+	"time"
+
+	pgxpool "github.com/jackc/pgx/v4/pgxpool"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
+	"k8s.io/klog/v2"
+)
+
+// How often the database pool stats are sampled.
+var poolMetricsInterval = 30 * time.Second
+
+// Periodically update the metrics with the connections in the database pool. Used to size the pool with
+// DB_MAX_CONNS and DB_MIN_CONNS, and to detect when the pool is exhausted.
+func StartPoolMetrics(ctx context.Context) {
+	klog.Info("Starting database pool metrics.")
+	ticker := time.NewTicker(poolMetricsInterval)
+	defer ticker.Stop()
+
+	for {
+		// The pool is nil until the database connection is established.
+		if p := getPool(); p != nil {
+			updatePoolMetrics(p)
+		}
+		select {
+		case <-ctx.Done():
+			klog.Info("Stopped database pool metrics.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func updatePoolMetrics(p *pgxpool.Pool) {
+	stat := p.Stat()
+	metrics.DBPoolAcquired.Set(float64(stat.AcquiredConns()))
+	metrics.DBPoolIdle.Set(float64(stat.IdleConns()))
+	metrics.DBPoolTotal.Set(float64(stat.TotalConns()))
+}
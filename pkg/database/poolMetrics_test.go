@@ -0,0 +1,65 @@
+// Copyright Contributors to the Open Cluster Management project
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+// Builds a pool with the configured sizes. The pool doesn't connect to the database until it's used.
+func newLazyPool(t *testing.T) *pgxpool.Pool {
+	poolConfig, err := getPoolConfig()
+	assert.Nil(t, err)
+	poolConfig.LazyConnect = true
+	lazyPool, err := pgxpool.ConnectConfig(context.Background(), poolConfig)
+	assert.Nil(t, err)
+	t.Cleanup(lazyPool.Close)
+	return lazyPool
+}
+
+// Should build the pool with the configured sizes and report the pool stats.
+func Test_updatePoolMetrics(t *testing.T) {
+	defer func(maxConns, minConns int) {
+		config.Cfg.DBMaxConns, config.Cfg.DBMinConns = maxConns, minConns
+	}(config.Cfg.DBMaxConns, config.Cfg.DBMinConns)
+	config.Cfg.DBMaxConns = 7
+	config.Cfg.DBMinConns = 0
+	metrics.DBPoolTotal.Set(-1)
+
+	lazyPool := newLazyPool(t)
+	updatePoolMetrics(lazyPool)
+
+	assert.Equal(t, int32(7), lazyPool.Stat().MaxConns())
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.DBPoolAcquired))
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.DBPoolIdle))
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.DBPoolTotal))
+}
+
+// Should report the pool stats until the context is cancelled.
+func Test_StartPoolMetrics(t *testing.T) {
+	defer func(interval time.Duration) { poolMetricsInterval = interval }(poolMetricsInterval)
+	poolMetricsInterval = 5 * time.Millisecond
+	defer func(p *pgxpool.Pool) { pool = p }(pool)
+	pool = newLazyPool(t)
+	metrics.DBPoolTotal.Set(-1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		StartPoolMetrics(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(metrics.DBPoolTotal) == 0 },
+		time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
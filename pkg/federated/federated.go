@@ -16,6 +16,7 @@ import (
 type FederatedRequest struct {
 	InRequestBody []byte
 	Response      GraphQLPayload
+	errorsLock    sync.Mutex // Requests to the remote services are processed in parallel.
 }
 
 var getFedConfig = getFederationConfig
@@ -79,7 +80,7 @@ func (fedRequest *FederatedRequest) getFederatedResponse(remoteService RemoteSea
 	req, err := http.NewRequest("POST", remoteService.URL, bytes.NewBuffer(receivedBody))
 	if err != nil {
 		klog.Errorf("Error creating federated request: %s", err)
-		fedRequest.Response.Errors = append(fedRequest.Response.Errors, fmt.Errorf("error creating federated request: %s", err).Error())
+		fedRequest.addErrors(remoteService.Name, fmt.Errorf("error creating federated request: %s", err).Error())
 		return
 	}
 	req.Header.Set("Content-Type", "application/json")
@@ -89,7 +90,7 @@ func (fedRequest *FederatedRequest) getFederatedResponse(remoteService RemoteSea
 	resp, err := client.Do(req)
 	if err != nil {
 		klog.Errorf("Error sending federated request: %s", err)
-		fedRequest.Response.Errors = append(fedRequest.Response.Errors, fmt.Errorf("error sending federated request: %s", err).Error())
+		fedRequest.addErrors(remoteService.Name, fmt.Errorf("error sending federated request: %s", err).Error())
 		return
 	}
 
@@ -98,10 +99,20 @@ func (fedRequest *FederatedRequest) getFederatedResponse(remoteService RemoteSea
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		klog.Errorf("Error reading federated response from %s: %s", remoteService.Name, err)
-		fedRequest.Response.Errors = append(fedRequest.Response.Errors, fmt.Errorf("Error reading federated response body: %s", err).Error())
+		fedRequest.addErrors(remoteService.Name, fmt.Errorf("Error reading federated response body: %s", err).Error())
 		return
 	}
 
 	klog.V(3).Infof("Received response from %s:\n%s", remoteService.Name, string(body))
 	parseResponse(fedRequest, body, remoteService.Name)
 }
+
+// Add the errors to the federated response. Each error is prefixed with the name of the
+// managed hub, so the client can tell which hubs failed while using the data from the others.
+func (fedRequest *FederatedRequest) addErrors(hubName string, errs ...string) {
+	fedRequest.errorsLock.Lock()
+	defer fedRequest.errorsLock.Unlock()
+	for _, err := range errs {
+		fedRequest.Response.Errors = append(fedRequest.Response.Errors, fmt.Sprintf("[%s] %s", hubName, err))
+	}
+}
@@ -164,6 +164,55 @@ func TestHandleFederatedRequestWithConfig(t *testing.T) {
 	assert.Equal(t, 0, len(responseBody.Data.Search))
 }
 
+// Should return the data from the hubs that responded and an error for each hub that failed.
+func TestHandleFederatedRequestPartialFailure(t *testing.T) {
+	hubResponse, _ := json.Marshal(&GraphQLPayload{Data: Data{
+		Search: []SearchResult{{Count: 1, Items: []map[string]interface{}{{"kind": "Pod", "name": "pod1"}}}},
+	}})
+	mockClient := &MockHTTPClient{
+		DoFunc: func(req *http.Request) (*http.Response, error) {
+			switch req.URL.Host {
+			case "hub1.com":
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBuffer(hubResponse))}, nil
+			case "hub2.com":
+				return nil, errors.New("connection refused")
+			default:
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString("not json"))}, nil
+			}
+		},
+	}
+	realGetHttpClient := httpClientGetter
+	realGetFederationConfig := getFedConfig
+	defer func() {
+		httpClientGetter = realGetHttpClient
+		getFedConfig = realGetFederationConfig
+	}()
+	httpClientGetter = func() HTTPClient { return mockClient }
+	getFedConfig = func(ctx context.Context, request *http.Request) []RemoteSearchService {
+		return []RemoteSearchService{
+			{Name: "hub1", URL: "http://hub1.com"},
+			{Name: "hub2", URL: "http://hub2.com"},
+			{Name: "hub3", URL: "http://hub3.com"},
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/federated", bytes.NewBufferString(`{"query": "search"}`))
+	w := httptest.NewRecorder()
+	HandleFederatedRequest(w, req)
+
+	var responseBody GraphQLPayload
+	err := json.Unmarshal(w.Body.Bytes(), &responseBody)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, len(responseBody.Data.Search))
+	assert.Equal(t, 1, responseBody.Data.Search[0].Count)
+	assert.Equal(t, "hub1", responseBody.Data.Search[0].Items[0]["managedHub"])
+	assert.ElementsMatch(t, []string{
+		"[hub2] error sending federated request: connection refused",
+		"[hub3] error parsing response: invalid character 'o' in literal null (expecting 'u')",
+	}, responseBody.Errors)
+}
+
 func TestGetFederatedResponseSuccess(t *testing.T) {
 	// Create a sample response body
 	payLoad := GraphQLPayload{Data: Data{
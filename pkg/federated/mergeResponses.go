@@ -69,7 +69,7 @@ func (d *Data) mergeSearchResults(hubName string, results []SearchResult) {
 		// Items
 		// TODO: Handle SORT and LIMIT for Items.
 		for _, item := range result.Items {
-			item["managedHub"] = hubName
+			setManagedHub(item, hubName)
 			d.Search[index].Items = append(d.Search[index].Items, item)
 		}
 
@@ -80,7 +80,7 @@ func (d *Data) mergeSearchResults(hubName string, results []SearchResult) {
 		if len(result.Related) > 0 {
 			for _, related := range result.Related {
 				for _, item := range related.Items {
-					item["managedHub"] = hubName
+					setManagedHub(item, hubName)
 				}
 			}
 			d.Search[index].Related = d.appendRelatedResults(d.Search[index].Related, result.Related)
@@ -88,6 +88,14 @@ func (d *Data) mergeSearchResults(hubName string, results []SearchResult) {
 	}
 }
 
+// Set the managedHub where the item came from. Keeps the value if the remote hub already set it.
+func setManagedHub(item map[string]interface{}, hubName string) {
+	if managedHub, ok := item["managedHub"]; ok && managedHub != "" && managedHub != nil {
+		return
+	}
+	item["managedHub"] = hubName
+}
+
 func (d *Data) mergeMessages(msgs []string) {
 	klog.V(1).Info("Merge [message] results to federated response.")
 	d.writeLock.Lock()
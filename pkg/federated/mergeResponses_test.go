@@ -40,3 +40,34 @@ func Test_mergeSearchSchema(t *testing.T) {
 	shouldbeTrue := resolver.CheckIfInArray(d.SearchSchema.AllProperties, "managedHub")
 	assert.True(t, shouldbeTrue, true, "Expected managedHub to be present in the schema. Expected true, got %t", shouldbeTrue)
 }
+
+func Test_mergeSearchResults_ManagedHub(t *testing.T) {
+	d := &Data{}
+	d.mergeSearchResults("hub1", []SearchResult{{
+		Count: 2,
+		Items: []map[string]interface{}{{"kind": "Pod", "name": "pod1"}, {"kind": "ConfigMap", "name": "cm1"}},
+		Related: []SearchRelatedResult{
+			{Kind: "Deployment", Count: 1, Items: []map[string]interface{}{{"kind": "Deployment", "name": "dep1"}}},
+		},
+	}})
+	// The remote hub already set the managedHub for one of the items.
+	d.mergeSearchResults("hub2", []SearchResult{{
+		Count: 2,
+		Items: []map[string]interface{}{{"kind": "Pod", "name": "pod1"}, {"kind": "Pod", "name": "pod2", "managedHub": "hub3"}},
+		Related: []SearchRelatedResult{
+			{Kind: "Deployment", Count: 1, Items: []map[string]interface{}{{"kind": "Deployment", "name": "dep1"}}},
+		},
+	}})
+
+	assert.Equal(t, 4, d.Search[0].Count)
+	assert.Equal(t, []map[string]interface{}{
+		{"kind": "Pod", "name": "pod1", "managedHub": "hub1"},
+		{"kind": "ConfigMap", "name": "cm1", "managedHub": "hub1"},
+		{"kind": "Pod", "name": "pod1", "managedHub": "hub2"},
+		{"kind": "Pod", "name": "pod2", "managedHub": "hub3"},
+	}, d.Search[0].Items)
+	assert.Equal(t, []SearchRelatedResult{{Kind: "Deployment", Count: 2, Items: []map[string]interface{}{
+		{"kind": "Deployment", "name": "dep1", "managedHub": "hub1"},
+		{"kind": "Deployment", "name": "dep1", "managedHub": "hub2"},
+	}}}, d.Search[0].Related)
+}
@@ -49,12 +49,12 @@ func parseResponse(fedRequest *FederatedRequest, body []byte, hubName string) {
 
 	if err != nil {
 		klog.Errorf("Error parsing response: %s", err)
-		fedRequest.Response.Errors = append(fedRequest.Response.Errors, fmt.Errorf("error parsing response: %s", err).Error())
+		fedRequest.addErrors(hubName, fmt.Errorf("error parsing response: %s", err).Error())
 		return
 	}
 
 	if len(response.Errors) > 0 {
-		fedRequest.Response.Errors = append(fedRequest.Response.Errors, response.Errors...)
+		fedRequest.addErrors(hubName, response.Errors...)
 	}
 
 	if response.Data.SearchSchema != nil {
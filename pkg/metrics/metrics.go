@@ -2,6 +2,8 @@
 package metrics
 
 import (
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -19,8 +21,116 @@ var (
 		Help: "The number of failed database connection attempts.",
 	})
 
+	AuthzFailed = promauto.With(PromRegistry).NewCounterVec(prometheus.CounterOpts{
+		Name: "search_api_authz_failed",
+		Help: "The number of failed attempts to resolve the user's access.",
+	}, []string{"reason"})
+
+	UserCacheHits = promauto.With(PromRegistry).NewCounter(prometheus.CounterOpts{
+		Name: "rbac_user_cache_hits_total",
+		Help: "The number of requests resolved with the user's data from the cache.",
+	})
+
+	UserCacheMisses = promauto.With(PromRegistry).NewCounter(prometheus.CounterOpts{
+		Name: "rbac_user_cache_misses_total",
+		Help: "The number of requests that refreshed the user's data because it was missing or expired.",
+	})
+
+	UserCacheSize = promauto.With(PromRegistry).NewGauge(prometheus.GaugeOpts{
+		Name: "rbac_user_cache_size",
+		Help: "The number of users in the user data cache.",
+	})
+
+	DBPoolAcquired = promauto.With(PromRegistry).NewGauge(prometheus.GaugeOpts{
+		Name: "search_api_db_pool_acquired",
+		Help: "The number of database connections currently acquired from the pool.",
+	})
+
+	DBPoolIdle = promauto.With(PromRegistry).NewGauge(prometheus.GaugeOpts{
+		Name: "search_api_db_pool_idle",
+		Help: "The number of idle database connections in the pool.",
+	})
+
+	DBPoolTotal = promauto.With(PromRegistry).NewGauge(prometheus.GaugeOpts{
+		Name: "search_api_db_pool_total",
+		Help: "The total number of database connections in the pool, up to DB_MAX_CONNS.",
+	})
+
+	DBPoolExhausted = promauto.With(PromRegistry).NewCounter(prometheus.CounterOpts{
+		Name: "search_api_db_pool_exhausted_total",
+		Help: "The number of queries rejected because a database connection wasn't acquired within DB_ACQUIRE_TIMEOUT.",
+	})
+
 	DBQueryDuration = promauto.With(PromRegistry).NewHistogramVec(prometheus.HistogramOpts{
 		Name: "search_api_db_query_duration",
 		Help: "Latency (seconds) for database queries.",
 	}, []string{"query_name"})
+
+	// Buckets from 5ms to 10s.
+	queryDurationBuckets = prometheus.ExponentialBuckets(0.005, 2, 12)
+
+	SearchQueryDuration = promauto.With(PromRegistry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "search_query_duration_seconds",
+		Help:    "Latency (seconds) of the database queries to resolve the search items and count.",
+		Buckets: queryDurationBuckets,
+	}, []string{"status"})
+
+	AutocompleteQueryDuration = promauto.With(PromRegistry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "search_autocomplete_duration_seconds",
+		Help:    "Latency (seconds) of the database queries to resolve the autocomplete values.",
+		Buckets: queryDurationBuckets,
+	}, []string{"status"})
+
+	// Buckets from 10ms to 20s.
+	rbacRefreshBuckets = prometheus.ExponentialBuckets(0.01, 2, 12)
+
+	// The cache label is miss when the user wasn't in the cache, or hit when the user was in the cache
+	// and only the expired sections were refreshed.
+	RBACManagedClusterRefresh = promauto.With(PromRegistry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rbac_managedcluster_refresh_seconds",
+		Help:    "Time (seconds) to refresh the managed clusters the user can access.",
+		Buckets: rbacRefreshBuckets,
+	}, []string{"cache"})
+
+	RBACNamespacedRefresh = promauto.With(PromRegistry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rbac_namespaced_refresh_seconds",
+		Help:    "Time (seconds) to refresh the namespaced resources the user can list.",
+		Buckets: rbacRefreshBuckets,
+	}, []string{"cache"})
+
+	RBACClusterScopedRefresh = promauto.With(PromRegistry).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rbac_clusterscoped_refresh_seconds",
+		Help:    "Time (seconds) to refresh the cluster-scoped resources the user can list.",
+		Buckets: rbacRefreshBuckets,
+	}, []string{"cache"})
+
+	// A lower ratio means a smaller RBAC clause in the queries. 1 means consolidation didn't group any namespaces.
+	RBACNamespaceConsolidationRatio = promauto.With(PromRegistry).NewHistogram(prometheus.HistogramOpts{
+		Name:    "rbac_namespace_consolidation_ratio",
+		Help:    "Ratio of namespace groups to namespaces after consolidating the user's namespaced resources.",
+		Buckets: prometheus.LinearBuckets(0.1, 0.1, 10),
+	})
+
+	RBACNamespaceConsolidationFallback = promauto.With(PromRegistry).NewCounter(prometheus.CounterOpts{
+		Name: "rbac_namespace_consolidation_fallback_total",
+		Help: "The number of RBAC clauses built without consolidating the namespaces because consolidation failed.",
+	})
 )
+
+// Record the duration of a query since start, with status ok or error.
+func ObserveQueryDuration(histogram *prometheus.HistogramVec, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	histogram.WithLabelValues(status).Observe(time.Since(start).Seconds())
+}
+
+// Record the duration of a user data refresh since start, with the cache outcome hit or miss.
+func ObserveRefreshDuration(histogram *prometheus.HistogramVec, cacheHit bool, start time.Time) {
+	outcome := "miss"
+	if cacheHit {
+		outcome = "hit"
+	}
+	histogram.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,18 +22,38 @@ func Test_PrometheusInstrumentation(t *testing.T) {
 	// Validate the collected metrics.
 
 	collectedMetrics, _ := PromRegistry.Gather() // use the prometheus registry to confirm metrics have been scraped.
-	assert.Equal(t, 2, len(collectedMetrics))    // Validate total metrics collected.
+	assert.Equal(t, 11, len(collectedMetrics))   // Validate total metrics collected.
+	metricsByName := map[string]*dto.MetricFamily{}
+	for _, metric := range collectedMetrics {
+		metricsByName[metric.GetName()] = metric
+	}
 
 	// METRIC 1: search_api_db_connection_failed
-	assert.Equal(t, "search_api_db_connection_failed", collectedMetrics[0].GetName())
-	assert.Equal(t, float64(0), collectedMetrics[1].Metric[0].GetCounter().GetValue())
+	dbConnectionFailed := metricsByName["search_api_db_connection_failed"]
+	assert.NotNil(t, dbConnectionFailed)
+	assert.Equal(t, float64(0), dbConnectionFailed.Metric[0].GetCounter().GetValue())
 
 	// METRIC 2:  search_api_request_duration
-	assert.Equal(t, "search_api_request_duration", collectedMetrics[1].GetName())
-	assert.Equal(t, 3, len(collectedMetrics[1].Metric[0].GetLabel()))
-	assert.Equal(t, "code", *collectedMetrics[1].Metric[0].GetLabel()[0].Name)
-	assert.Equal(t, "200", *collectedMetrics[1].Metric[0].GetLabel()[0].Value)
-	assert.Equal(t, uint64(1), collectedMetrics[1].Metric[0].GetHistogram().GetSampleCount())
+	requestDuration := metricsByName["search_api_request_duration"]
+	assert.NotNil(t, requestDuration)
+	assert.Equal(t, 3, len(requestDuration.Metric[0].GetLabel()))
+	assert.Equal(t, "code", *requestDuration.Metric[0].GetLabel()[0].Name)
+	assert.Equal(t, "200", *requestDuration.Metric[0].GetLabel()[0].Value)
+	assert.Equal(t, uint64(1), requestDuration.Metric[0].GetHistogram().GetSampleCount())
+
+	// METRICS 3-5: rbac_user_cache_hits_total, rbac_user_cache_misses_total and rbac_user_cache_size
+	assert.Contains(t, metricsByName, "rbac_user_cache_hits_total")
+	assert.Contains(t, metricsByName, "rbac_user_cache_misses_total")
+	assert.Contains(t, metricsByName, "rbac_user_cache_size")
+
+	// METRICS 6-8: search_api_db_pool_acquired, search_api_db_pool_idle and search_api_db_pool_total
+	assert.Contains(t, metricsByName, "search_api_db_pool_acquired")
+	assert.Contains(t, metricsByName, "search_api_db_pool_idle")
+	assert.Contains(t, metricsByName, "search_api_db_pool_total")
+
+	// METRICS 9-10: rbac_namespace_consolidation_ratio and rbac_namespace_consolidation_fallback_total
+	assert.Contains(t, metricsByName, "rbac_namespace_consolidation_ratio")
+	assert.Contains(t, metricsByName, "rbac_namespace_consolidation_fallback_total")
 
 	// METRIC 3: search_api_db_query_duration
 	// Not generated in this scenario because there's no queries triggered by this test.
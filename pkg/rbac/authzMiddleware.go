@@ -1,20 +1,45 @@
 package rbac
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
+	"github.com/stolostron/search-v2-api/pkg/metrics"
+	"github.com/stolostron/search-v2-api/pkg/tracing"
 	"k8s.io/klog/v2"
 )
 
 func AuthorizeUser(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, span := tracing.Start(r.Context(), "AuthorizeUser")
+
+		// The shared cache is loaded by StartSharedCacheWarmup. Reject the requests received before it's ready,
+		// instead of loading it again for each request.
+		if !GetCache().SharedCacheReady() {
+			tracing.End(span, ErrSharedCacheNotReady)
+			klog.V(3).Info("Search API is not ready, the shared cache is loading.")
+			w.Header().Set("Retry-After", strconv.Itoa(int(warmupRetryInterval.Seconds())))
+			http.Error(w, "{\"message\":\"Search API is not ready, the RBAC cache is loading. Try again later.\"}",
+				http.StatusServiceUnavailable)
+			return
+		}
 
 		// Trigger initialization of the shared cache. We should move this to a
 		// different place where it's independent of the request.
-		GetCache().shared.PopulateSharedCache(r.Context())
+		GetCache().shared.PopulateSharedCache(ctx)
 
-		_, userErr := GetCache().GetUserDataCache(r.Context(), nil)
+		_, userErr := GetCache().GetUserDataCache(ctx, nil)
+		tracing.End(span, userErr)
 		if userErr != nil {
+			metrics.AuthzFailed.WithLabelValues(authzFailedReason(userErr)).Inc()
+		}
+		if errors.Is(userErr, ErrRBACUnavailable) {
+			klog.Warning("Unable to resolve the user's access. ", userErr)
+			http.Error(w, "{\"message\":\"Unable to resolve the user's access. Try again later.\"}",
+				http.StatusServiceUnavailable)
+			return
+		} else if userErr != nil {
 			klog.Warning("Unexpected error while obtaining user data.", userErr)
 		}
 
@@ -23,3 +48,23 @@ func AuthorizeUser(next http.Handler) http.Handler {
 
 	})
 }
+
+// Reason used as the metric label for the error resolving the user's access.
+// The most specific failure is checked first, for example a failure to create the impersonation
+// client also causes the namespaced and cluster-scoped requests to fail.
+func authzFailedReason(err error) string {
+	switch {
+	case errors.Is(err, ErrTokenReviewMissing):
+		return "TokenReviewMissing"
+	case errors.Is(err, ErrImpersonationSetup):
+		return "ImpersonationSetup"
+	case errors.Is(err, ErrRBACUnavailable):
+		return "RBACUnavailable"
+	case errors.Is(err, ErrNamespacedFetch):
+		return "NamespacedFetch"
+	case errors.Is(err, ErrClusterScopedFetch):
+		return "ClusterScopedFetch"
+	default:
+		return "UnexpectedAuthzError"
+	}
+}
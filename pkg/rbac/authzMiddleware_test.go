@@ -0,0 +1,48 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_authzFailedReason(t *testing.T) {
+	nsErr := fmt.Errorf("%w: %w", ErrNamespacedFetch, ErrImpersonationSetup)
+	csErr := fmt.Errorf("%w: %w", ErrClusterScopedFetch, errors.New("ssar unavailable"))
+
+	testcases := []struct {
+		err      error
+		expected string
+	}{
+		{fmt.Errorf("%w: cannot find user with uid: noUidFound", ErrTokenReviewMissing), "TokenReviewMissing"},
+		{fmt.Errorf("%w. %w. %w", ErrRBACUnavailable, nsErr, csErr), "ImpersonationSetup"},
+		{fmt.Errorf("%w. %w. %w", ErrRBACUnavailable, ErrNamespacedFetch, csErr), "RBACUnavailable"},
+		{fmt.Errorf("%w: %w", ErrNamespacedFetch, errors.New("ssrr unavailable")), "NamespacedFetch"},
+		{csErr, "ClusterScopedFetch"},
+		{errors.New("unexpected"), "UnexpectedAuthzError"},
+	}
+
+	for _, tc := range testcases {
+		assert.Equal(t, tc.expected, authzFailedReason(tc.err), tc.err.Error())
+	}
+}
+
+// Should reject the requests until the shared cache is loaded, without loading it for the request.
+func Test_AuthorizeUser_SharedCacheNotReady(t *testing.T) {
+	nextCalled := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { nextCalled = true })
+	r := httptest.NewRequest("POST", "https://localhost:4010/searchapi/graphql", nil)
+	response := httptest.NewRecorder()
+
+	AuthorizeUser(next).ServeHTTP(response, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, response.Code)
+	assert.Equal(t, "5", response.Header().Get("Retry-After"))
+	assert.False(t, nextCalled)
+	assert.False(t, GetCache().SharedCacheReady())
+}
@@ -16,7 +16,7 @@ import (
 // Cache helps optimize requests to external APIs (Kubernetes and Database)
 type Cache struct {
 	shared           SharedData
-	tokenReviews     map[string]*tokenReviewCache //Key:ClientToken
+	tokenReviews     map[string]*tokenReviewCache //Key:Hash of the ClientToken
 	tokenReviewsLock sync.Mutex
 	users            map[string]*UserDataCache // UID:{userdata} UID comes from tokenreview
 	usersLock        sync.Mutex
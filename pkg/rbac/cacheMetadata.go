@@ -18,6 +18,11 @@ type cacheMetadata struct {
 
 // Checks if the cached data is valid or expired.
 func (cacheMeta *cacheMetadata) isValid() bool {
+	return time.Now().Before(cacheMeta.expiresAt())
+}
+
+// Time when the cached data expires.
+func (cacheMeta *cacheMetadata) expiresAt() time.Time {
 	// Default TTL
 	cacheTTL := time.Duration(config.Cfg.SharedCacheTTL) * time.Millisecond
 
@@ -30,5 +35,5 @@ func (cacheMeta *cacheMetadata) isValid() bool {
 	if cacheMeta.err != nil {
 		cacheTTL = time.Duration(1000) * time.Millisecond
 	}
-	return time.Now().Before(cacheMeta.updatedAt.Add(cacheTTL))
+	return cacheMeta.updatedAt.Add(cacheTTL)
 }
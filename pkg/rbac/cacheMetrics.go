@@ -0,0 +1,36 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/stolostron/search-v2-api/pkg/metrics"
+	"k8s.io/klog/v2"
+)
+
+// How often the size of the user cache is sampled.
+var userCacheMetricsInterval = 30 * time.Second
+
+// Periodically update the metric with the number of users in the cache.
+func (c *Cache) StartUserCacheMetrics(ctx context.Context) {
+	klog.Info("Starting user cache metrics.")
+	ticker := time.NewTicker(userCacheMetricsInterval)
+	defer ticker.Stop()
+
+	for {
+		c.updateUserCacheSize()
+		select {
+		case <-ctx.Done():
+			klog.Info("Stopped user cache metrics.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Cache) updateUserCacheSize() {
+	c.usersLock.Lock()
+	defer c.usersLock.Unlock()
+	metrics.UserCacheSize.Set(float64(len(c.users)))
+}
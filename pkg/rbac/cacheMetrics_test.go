@@ -0,0 +1,98 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+// Should count a miss when the user's data is resolved and a hit when it's used from the cache.
+func Test_UserCacheHitsAndMisses(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+	fs := mockAuthzClientset(t, nil, nil)
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	hits := testutil.ToFloat64(metrics.UserCacheHits)
+	misses := testutil.ToFloat64(metrics.UserCacheMisses)
+
+	_, err := mock_cache.GetUserDataCache(ctx, fs.AuthorizationV1())
+	assert.Nil(t, err)
+	assert.Equal(t, hits, testutil.ToFloat64(metrics.UserCacheHits))
+	assert.Equal(t, misses+1, testutil.ToFloat64(metrics.UserCacheMisses))
+
+	_, err = mock_cache.GetUserDataCache(ctx, fs.AuthorizationV1())
+	assert.Nil(t, err)
+	assert.Equal(t, hits+1, testutil.ToFloat64(metrics.UserCacheHits))
+	assert.Equal(t, misses+1, testutil.ToFloat64(metrics.UserCacheMisses))
+}
+
+// Returns the number of observations of the refresh histogram with the cache outcome.
+func refreshCount(t *testing.T, histogram *prometheus.HistogramVec, outcome string) uint64 {
+	metric := &dto.Metric{}
+	assert.Nil(t, histogram.WithLabelValues(outcome).(prometheus.Metric).Write(metric))
+	return metric.GetHistogram().GetSampleCount()
+}
+
+// Should record the refresh duration of each section, and only refresh the expired sections of a cached user.
+func Test_RBACRefreshDuration(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+	fs := mockAuthzClientset(t, nil, nil)
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	sections := []*prometheus.HistogramVec{
+		metrics.RBACManagedClusterRefresh, metrics.RBACNamespacedRefresh, metrics.RBACClusterScopedRefresh}
+	count := func(outcome string) []uint64 {
+		counts := []uint64{}
+		for _, histogram := range sections {
+			counts = append(counts, refreshCount(t, histogram, outcome))
+		}
+		return counts
+	}
+	hits, misses := count("hit"), count("miss")
+
+	// The user isn't in the cache, all the sections are refreshed.
+	user, err := mock_cache.GetUserDataCache(ctx, fs.AuthorizationV1())
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{misses[0] + 1, misses[1] + 1, misses[2] + 1}, count("miss"))
+	assert.Equal(t, hits, count("hit"))
+
+	// Force the refresh of the cluster-scoped resources.
+	user.csrCache.updatedAt = time.Time{}
+	_, err = mock_cache.GetUserDataCache(ctx, fs.AuthorizationV1())
+	assert.Nil(t, err)
+	assert.Equal(t, []uint64{misses[0] + 1, misses[1] + 1, misses[2] + 1}, count("miss"))
+	assert.Equal(t, []uint64{hits[0], hits[1], hits[2] + 1}, count("hit"))
+}
+
+// Should report the number of users in the cache until the context is cancelled.
+func Test_StartUserCacheMetrics(t *testing.T) {
+	defer func(interval time.Duration) { userCacheMetricsInterval = interval }(userCacheMetricsInterval)
+	userCacheMetricsInterval = 5 * time.Millisecond
+
+	mock_cache := mockNamespaceCache()
+	mock_cache.users["uid1"] = &UserDataCache{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		mock_cache.StartUserCacheMetrics(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(metrics.UserCacheSize) == 1 },
+		time.Second, time.Millisecond)
+
+	mock_cache.usersLock.Lock()
+	mock_cache.users["uid2"] = &UserDataCache{}
+	mock_cache.usersLock.Unlock()
+	assert.Eventually(t, func() bool { return testutil.ToFloat64(metrics.UserCacheSize) == 2 },
+		time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
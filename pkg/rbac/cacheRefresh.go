@@ -0,0 +1,91 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"k8s.io/klog/v2"
+)
+
+// Minimum wait between background refreshes. Also used to retry after a failed refresh.
+var minRefreshInterval = 1 * time.Second
+
+// Refresh the shared cluster-scoped resources and namespaces in the background shortly before the
+// cached data expires, so user requests don't pay the cost to rebuild the cache.
+// The refresh-ahead time is configured with SHARED_CACHE_REFRESH_AHEAD, use 0 to disable.
+func (c *Cache) StartBackgroundRefresh(ctx context.Context) {
+	refreshAhead := time.Duration(config.Cfg.CacheRefreshAhead) * time.Millisecond
+	if refreshAhead <= 0 {
+		klog.Info("Shared cache background refresh is disabled.")
+		return
+	}
+	klog.Info("Starting shared cache background refresh.")
+
+	for {
+		timer := time.NewTimer(c.shared.nextRefresh(refreshAhead))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			klog.Info("Stopped shared cache background refresh.")
+			return
+		case <-timer.C:
+			c.shared.refreshAhead(ctx)
+		}
+	}
+}
+
+// Time to wait before the next refresh. Uses the earliest expiration of the cluster-scoped
+// resources and namespaces caches minus the refresh-ahead time.
+func (shared *SharedData) nextRefresh(refreshAhead time.Duration) time.Duration {
+	shared.csrCache.lock.Lock()
+	expiresAt := shared.csrCache.expiresAt()
+	shared.csrCache.lock.Unlock()
+
+	shared.nsCache.lock.Lock()
+	if nsExpiresAt := shared.nsCache.expiresAt(); nsExpiresAt.Before(expiresAt) {
+		expiresAt = nsExpiresAt
+	}
+	shared.nsCache.lock.Unlock()
+
+	wait := time.Until(expiresAt.Add(-refreshAhead))
+	if wait < minRefreshInterval {
+		wait = minRefreshInterval
+	}
+	return wait
+}
+
+// Refresh the shared cluster-scoped resources and namespaces, even if the cached data is still valid.
+// Concurrent calls are coalesced, returns false if another refresh is already running.
+func (shared *SharedData) refreshAhead(ctx context.Context) bool {
+	if !shared.refreshLock.TryLock() {
+		klog.V(5).Info("Shared cache refresh already in progress.")
+		return false
+	}
+	defer shared.refreshLock.Unlock()
+	klog.V(5).Info("Refreshing shared cache before it expires.")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := shared.getClusterScopedResources(ctx); err != nil {
+			klog.Warningf("Error refreshing cluster scoped resources, will retry. Error: [%+v]", err)
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		shared.nsCache.lock.Lock()
+		defer shared.nsCache.lock.Unlock()
+		if _, err := shared.loadNamespaces(ctx); err != nil {
+			klog.Warningf("Error refreshing shared namespaces, will retry. Error: [%+v]", err)
+		}
+	}()
+
+	wg.Wait()
+	return true
+}
@@ -0,0 +1,156 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	fakedynclient "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	testingk8s "k8s.io/client-go/testing"
+)
+
+func mockClusterScopedResourcesQuery(mockPool *pgxpoolmock.MockPgxPool) *gomock.Call {
+	return mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT DISTINCT COALESCE("data"->>'apigroup', '') AS "apigroup", COALESCE("data"->>'kind_plural', '') AS "kind" FROM "search"."resources" WHERE ("data"?'_hubClusterResource' AND ("data"?'namespace' IS FALSE))`),
+		gomock.Eq([]interface{}{}),
+	).DoAndReturn(func(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+		return pgxpoolmock.NewRows([]string{"apigroup", "kind"}).AddRow("storage.k8s.io", "csinodes").ToPgxRows(), nil
+	})
+}
+
+// Should refresh the shared cache in background before it expires.
+func Test_StartBackgroundRefresh(t *testing.T) {
+	mockPool, mockCache := mockResourcesListCache(t)
+	mockClusterScopedResourcesQuery(mockPool).MinTimes(1)
+
+	defer func(interval time.Duration, refreshAhead int) {
+		minRefreshInterval = interval
+		config.Cfg.CacheRefreshAhead = refreshAhead
+	}(minRefreshInterval, config.Cfg.CacheRefreshAhead)
+	minRefreshInterval = 10 * time.Millisecond
+	config.Cfg.CacheRefreshAhead = 200
+
+	// Cache is valid for 300ms, so it should be refreshed after 100ms.
+	startedAt := time.Now()
+	mockCache.shared.csrCache.ttl = 300 * time.Millisecond
+	mockCache.shared.csrCache.updatedAt = startedAt
+	mockCache.shared.nsCache.ttl = 300 * time.Millisecond
+	mockCache.shared.nsCache.updatedAt = startedAt
+	expiresAt := startedAt.Add(300 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		mockCache.StartBackgroundRefresh(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		mockCache.shared.nsCache.lock.Lock()
+		defer mockCache.shared.nsCache.lock.Unlock()
+		return mockCache.shared.nsCache.updatedAt.After(startedAt)
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done // Wait for the refresh to stop before restoring the configuration.
+
+	mockCache.shared.csrCache.lock.Lock()
+	defer mockCache.shared.csrCache.lock.Unlock()
+	mockCache.shared.nsCache.lock.Lock()
+	defer mockCache.shared.nsCache.lock.Unlock()
+	assert.True(t, mockCache.shared.csrCache.updatedAt.Before(expiresAt), "Expected refresh before the cache expired.")
+	assert.True(t, mockCache.shared.nsCache.updatedAt.Before(expiresAt), "Expected refresh before the cache expired.")
+	_, found := mockCache.shared.csResourcesMap[Resource{Apigroup: "storage.k8s.io", Kind: "csinodes"}]
+	assert.True(t, found)
+	assert.Equal(t, []string{"test-namespace"}, mockCache.shared.namespaces)
+}
+
+// Should not run the background refresh when SHARED_CACHE_REFRESH_AHEAD is 0.
+func Test_StartBackgroundRefresh_Disabled(t *testing.T) {
+	_, mockCache := mockResourcesListCache(t)
+	defer func(refreshAhead int) { config.Cfg.CacheRefreshAhead = refreshAhead }(config.Cfg.CacheRefreshAhead)
+	config.Cfg.CacheRefreshAhead = 0
+
+	done := make(chan struct{})
+	go func() {
+		mockCache.StartBackgroundRefresh(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expected background refresh to return when disabled.")
+	}
+}
+
+// Should coalesce concurrent refresh requests into a single refresh.
+func Test_refreshAhead_Coalesced(t *testing.T) {
+	mockPool, mockCache := mockResourcesListCache(t)
+	mockClusterScopedResourcesQuery(mockPool).Times(1)
+
+	// Simulate a refresh in progress.
+	mockCache.shared.refreshLock.Lock()
+	assert.False(t, mockCache.shared.refreshAhead(context.Background()))
+	mockCache.shared.refreshLock.Unlock()
+
+	assert.True(t, mockCache.shared.refreshAhead(context.Background()))
+}
+
+// Should retry soon after a failed refresh.
+func Test_nextRefresh(t *testing.T) {
+	_, mockCache := mockResourcesListCache(t)
+	mockCache.shared.csrCache.ttl = 10 * time.Minute
+	mockCache.shared.csrCache.updatedAt = time.Now()
+	mockCache.shared.nsCache.ttl = 5 * time.Minute
+	mockCache.shared.nsCache.updatedAt = time.Now()
+
+	wait := mockCache.shared.nextRefresh(time.Minute)
+	assert.True(t, wait > 3*time.Minute && wait <= 4*time.Minute, "Expected wait of about 4 minutes, got %s", wait)
+
+	// Failed refresh.
+	mockCache.shared.csrCache.err = assert.AnError
+	mockCache.shared.csrCache.updatedAt = time.Now().Add(-time.Minute)
+	assert.Equal(t, minRefreshInterval, mockCache.shared.nextRefresh(time.Minute))
+}
+
+// Should keep the cached data when the refresh fails before the cache expires.
+func Test_refreshAhead_Failed(t *testing.T) {
+	mockPool, mockCache := mockResourcesListCache(t)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Eq([]interface{}{})).
+		Return(nil, errors.New("database unavailable"))
+	fakeClient := fakedynclient.NewSimpleDynamicClient(scheme.Scheme)
+	fakeClient.PrependReactor("list", "namespaces",
+		func(action testingk8s.Action) (handled bool, ret runtime.Object, err error) {
+			return true, nil, errors.New("kube API unavailable")
+		})
+	mockCache.shared.dynamicClient = fakeClient
+
+	updatedAt := time.Now()
+	csResources := map[Resource]struct{}{{Apigroup: "storage.k8s.io", Kind: "csinodes"}: {}}
+	mockCache.shared.csResourcesMap = csResources
+	mockCache.shared.csrCache.updatedAt = updatedAt
+	mockCache.shared.namespaces = []string{"test-namespace"}
+	mockCache.shared.nsCache.updatedAt = updatedAt
+
+	assert.True(t, mockCache.shared.refreshAhead(context.Background()))
+
+	assert.Equal(t, csResources, mockCache.shared.csResourcesMap)
+	assert.Nil(t, mockCache.shared.csrCache.err)
+	assert.Equal(t, updatedAt, mockCache.shared.csrCache.updatedAt)
+	assert.Equal(t, []string{"test-namespace"}, mockCache.shared.namespaces)
+	assert.Nil(t, mockCache.shared.nsCache.err)
+	assert.Equal(t, updatedAt, mockCache.shared.nsCache.updatedAt)
+
+	// The refresh should be retried before the cache expires.
+	assert.Equal(t, minRefreshInterval, mockCache.shared.nextRefresh(time.Hour))
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/stolostron/search-v2-api/pkg/config"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -169,6 +170,11 @@ func (c *Cache) namespaceDeleted(obj *unstructured.Unstructured) {
 }
 
 func (c *Cache) managedClusterAdded(obj *unstructured.Unstructured) {
+	if !matchesManagedClusterLabel(obj.GetLabels()) {
+		klog.V(3).Infof("Ignoring ManagedCluster %s without the label %s=%s.", obj.GetName(),
+			config.Cfg.ManagedClusterLabel, config.Cfg.ManagedClusterLabelValue)
+		return
+	}
 	// Addd Managed Cluster to shared cache.
 	c.shared.mcCache.lock.Lock()
 	c.shared.managedClusters[obj.GetName()] = struct{}{}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/stolostron/search-v2-api/pkg/config"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -87,6 +88,30 @@ func Test_cacheValidation_ManagedClusterAdded(t *testing.T) {
 	assert.Equal(t, map[string]struct{}{"a": {}, "b": {}, "c": {}}, mock_cache.shared.managedClusters)
 }
 
+func Test_cacheValidation_ManagedClusterAdded_LabelMismatch(t *testing.T) {
+	defer func(label, value string) {
+		config.Cfg.ManagedClusterLabel = label
+		config.Cfg.ManagedClusterLabelValue = value
+	}(config.Cfg.ManagedClusterLabel, config.Cfg.ManagedClusterLabelValue)
+	config.Cfg.ManagedClusterLabel = "vendor"
+	config.Cfg.ManagedClusterLabelValue = "OpenShift"
+
+	mock_cache := initMockCache()
+	mock_managedCluster := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ManagedCluster",
+			"metadata": map[string]interface{}{
+				"name":   "c",
+				"labels": map[string]interface{}{"vendor": "EKS"},
+			},
+		},
+	}
+
+	mock_cache.managedClusterAdded(mock_managedCluster)
+	assert.Equal(t, map[string]struct{}{"a": {}, "b": {}}, mock_cache.shared.managedClusters)
+}
+
 func Test_cacheValidation_managedClusterDeleted(t *testing.T) {
 	mock_cache := initMockCache()
 
@@ -0,0 +1,67 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// Wait between attempts to warm the shared cache on startup.
+var warmupRetryInterval = 5 * time.Second
+
+// Error returned to the requests received before the shared cache is loaded.
+var ErrSharedCacheNotReady = errors.New("shared cache is not ready")
+
+// Load the shared cache on startup and retry until it succeeds. The readiness probe fails until the shared
+// cache is loaded, so requests aren't resolved with an empty list of cluster-scoped resources or namespaces.
+func (cache *Cache) StartSharedCacheWarmup(ctx context.Context) {
+	for {
+		err := cache.WarmSharedCache(ctx)
+		if err == nil {
+			return
+		}
+		klog.Warningf("Error warming the shared cache, will retry in %s. Error: [%+v]", warmupRetryInterval, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(warmupRetryInterval):
+		}
+	}
+}
+
+// Load the shared cluster-scoped resources and namespaces. Returns an error if either fails to load.
+// The cache is ready after both are loaded once, the expired data is refreshed as usual after that.
+func (cache *Cache) WarmSharedCache(ctx context.Context) error {
+	shared := &cache.shared
+	var csrErr, nsErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		csrErr = shared.getClusterScopedResources(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		shared.nsCache.lock.Lock()
+		defer shared.nsCache.lock.Unlock()
+		_, nsErr = shared.loadNamespaces(ctx)
+	}()
+	wg.Wait()
+
+	if err := errors.Join(csrErr, nsErr); err != nil {
+		return errors.Join(ErrSharedCacheNotReady, err)
+	}
+	if !shared.warmed.Swap(true) {
+		klog.Info("Shared cache is ready.")
+	}
+	return nil
+}
+
+// Returns true after the shared cluster-scoped resources and namespaces were loaded once.
+func (cache *Cache) SharedCacheReady() bool {
+	return cache.shared.warmed.Load()
+}
@@ -0,0 +1,65 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Should load the cluster-scoped resources and namespaces and mark the shared cache as ready.
+func Test_WarmSharedCache(t *testing.T) {
+	mockPool, mockCache := mockResourcesListCache(t)
+	mockClusterScopedResourcesQuery(mockPool)
+
+	assert.False(t, mockCache.SharedCacheReady())
+
+	err := mockCache.WarmSharedCache(context.Background())
+
+	assert.Nil(t, err)
+	assert.True(t, mockCache.SharedCacheReady())
+	assert.Equal(t, map[Resource]struct{}{{Apigroup: "storage.k8s.io", Kind: "csinodes"}: {}},
+		mockCache.shared.csResourcesMap)
+	assert.Equal(t, []string{"test-namespace"}, mockCache.shared.namespaces)
+}
+
+// Should return an error and keep the shared cache not ready when the data can't be loaded.
+func Test_WarmSharedCache_Error(t *testing.T) {
+	mockPool, mockCache := mockResourcesListCache(t)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("db unavailable"))
+
+	err := mockCache.WarmSharedCache(context.Background())
+
+	assert.ErrorIs(t, err, ErrSharedCacheNotReady)
+	assert.ErrorContains(t, err, "db unavailable")
+	assert.False(t, mockCache.SharedCacheReady())
+}
+
+// Should retry until the shared cache is loaded.
+func Test_StartSharedCacheWarmup(t *testing.T) {
+	mockPool, mockCache := mockResourcesListCache(t)
+	gomock.InOrder(
+		mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("db unavailable")),
+		mockClusterScopedResourcesQuery(mockPool),
+	)
+
+	defer func(interval time.Duration) { warmupRetryInterval = interval }(warmupRetryInterval)
+	warmupRetryInterval = 10 * time.Millisecond
+
+	done := make(chan struct{})
+	go func() {
+		mockCache.StartSharedCacheWarmup(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Shared cache warmup didn't complete.")
+	}
+	assert.True(t, mockCache.SharedCacheReady())
+}
@@ -0,0 +1,62 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/stolostron/search-v2-api/pkg/config"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// Backoff between the retries of the RBAC requests. The number of retries is set with RBAC_MAX_RETRIES.
+var kubeRetryBackoff = wait.Backoff{
+	Duration: 100 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.5,
+}
+
+// Returns true for the transient Kube API errors, the request is expected to succeed if retried.
+func isTransientKubeError(err error) bool {
+	return apierrors.IsTooManyRequests(err) || apierrors.IsServerTimeout(err) || apierrors.IsServiceUnavailable(err)
+}
+
+// Max wait for the delay suggested by the Kube API, so a long Retry-After doesn't hold the RBAC refresh.
+var maxKubeRetryAfter = 5 * time.Second
+
+// Retries the Kube API request with exponential backoff and jitter on transient errors, so a single
+// throttled request doesn't fail the whole RBAC refresh. Other errors, like 401 and 403, are returned
+// without retrying. Stops waiting and returns the last error when the context is cancelled.
+func retryKubeRequest(ctx context.Context, request string, fn func() error) error {
+	backoff := kubeRetryBackoff
+	backoff.Steps = config.Cfg.RBACMaxRetries + 1
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !isTransientKubeError(err) || attempt > config.Cfg.RBACMaxRetries {
+			return err
+		}
+		delay := kubeRetryDelay(err, &backoff)
+		klog.V(3).Infof("Retrying %s in %s after transient error (attempt %d of %d). %s",
+			request, delay, attempt, config.Cfg.RBACMaxRetries, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Returns the wait before the next retry. Uses the delay suggested by the Kube API, like the Retry-After
+// of a 429 response, up to maxKubeRetryAfter. Otherwise, uses the next step of the backoff.
+func kubeRetryDelay(err error, backoff *wait.Backoff) time.Duration {
+	delay := backoff.Step()
+	if seconds, ok := apierrors.SuggestsClientDelay(err); ok && seconds > 0 {
+		delay = time.Duration(seconds) * time.Second
+		if delay > maxKubeRetryAfter {
+			delay = maxKubeRetryAfter
+		}
+	}
+	return delay
+}
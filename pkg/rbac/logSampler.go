@@ -0,0 +1,30 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"sync/atomic"
+
+	"github.com/stolostron/search-v2-api/pkg/config"
+)
+
+// Samples the log lines written for each namespace or resource while resolving the user data. A user with
+// access to many namespaces writes thousands of these lines per refresh at high verbosity.
+// Logs the first line and then 1 in LOG_SAMPLE_RATE lines. Logs all lines when the rate is 0 or 1.
+type logSampler struct {
+	count atomic.Uint64
+}
+
+var (
+	ssarLogSampler  logSampler // SelfSubjectAccessReview results for each cluster-scoped resource.
+	ssrrLogSampler  logSampler // SelfSubjectRulesReview results for each namespace.
+	rulesLogSampler logSampler // Resources excluded from the SelfSubjectRulesReview results.
+)
+
+// Returns true if the next line should be logged.
+func (s *logSampler) sample() bool {
+	rate := config.Cfg.LogSampleRate
+	if rate <= 1 {
+		return true
+	}
+	return s.count.Add(1)%uint64(rate) == 1
+}
@@ -3,14 +3,20 @@ package rbac
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/doug-martin/goqu/v9"
 	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/stolostron/search-v2-api/pkg/config"
 	"github.com/stolostron/search-v2-api/pkg/metrics"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/dynamic"
@@ -31,7 +37,9 @@ type SharedData struct {
 	dcCache     cacheMetadata
 	mcCache     cacheMetadata
 	nsCache     cacheMetadata
-	propTypeErr error // Capture errors retrieving property types
+	propTypeErr error       // Capture errors retrieving property types
+	refreshLock sync.Mutex  // Ensures only one background refresh runs at a time.
+	warmed      atomic.Bool // Set after the shared cache is loaded for the first time.
 
 	// Clients to external APIs to be replaced with a mock by unit tests.
 	dynamicClient dynamic.Interface
@@ -43,6 +51,9 @@ type Resource struct {
 	Kind     string
 }
 
+// Returned when listing a resource from the Kube API exceeds KUBE_LIST_TIMEOUT.
+var ErrListTimeout = errors.New("timed out listing resources from the Kube API")
+
 var managedClusterResourceGvr = schema.GroupVersionResource{
 	Group:    "cluster.open-cluster-management.io",
 	Version:  "v1",
@@ -194,9 +205,6 @@ func (shared *SharedData) getClusterScopedResources(ctx context.Context) error {
 	// lock to prevent checking more than one at a time and check if cluster scoped resources already in cache
 	shared.csrCache.lock.Lock()
 	defer shared.csrCache.lock.Unlock()
-	//clear previous cache
-	shared.csResourcesMap = make(map[Resource]struct{})
-	shared.csrCache.err = nil
 	klog.V(6).Info("Querying database for cluster-scoped resources.")
 
 	// Building query to get cluster scoped resources
@@ -210,20 +218,16 @@ func (shared *SharedData) getClusterScopedResources(ctx context.Context) error {
 			goqu.L("???", goqu.C("data"), goqu.Literal("?"), "namespace").IsFalse()).ToSQL()
 	if err != nil {
 		klog.Errorf("Error creating query [%s]. Error: [%+v]", query, err)
-		shared.csrCache.err = err
-		shared.csResourcesMap = map[Resource]struct{}{}
-		return shared.csrCache.err
+		return shared.setClusterScopedResourcesError(err)
 	}
 
 	rows, err := shared.pool.Query(ctx, query)
 	if err != nil {
 		klog.Errorf("Error resolving cluster scoped resources. Query [%s]. Error: [%+v]", query, err.Error())
-		shared.csrCache.err = err
-		shared.csResourcesMap = map[Resource]struct{}{}
-
-		return shared.csrCache.err
+		return shared.setClusterScopedResourcesError(err)
 	}
 
+	csResourcesMap := make(map[Resource]struct{})
 	if rows != nil {
 		defer rows.Close()
 
@@ -235,14 +239,32 @@ func (shared *SharedData) getClusterScopedResources(ctx context.Context) error {
 					apigroup, kind)
 				continue
 			}
-			shared.csResourcesMap[Resource{Apigroup: apigroup, Kind: kind}] = struct{}{}
+			csResourcesMap[Resource{Apigroup: apigroup, Kind: kind}] = struct{}{}
+		}
+		if err = rows.Err(); err != nil {
+			klog.Errorf("Error reading cluster scoped resources. Query [%s]. Error: [%+v]", query, err)
+			return shared.setClusterScopedResourcesError(err)
 		}
 	}
+	// Replace the cached data only after the new data is loaded.
+	shared.csResourcesMap = csResourcesMap
+	shared.csrCache.err = nil
 	shared.csrCache.updatedAt = time.Now()
 
 	return shared.csrCache.err
 }
 
+// Record an error loading the cluster-scoped resources. The caller must hold the csrCache lock.
+// A valid cache, which is being refreshed ahead of its expiration, keeps its data until it expires.
+func (shared *SharedData) setClusterScopedResourcesError(err error) error {
+	if shared.csrCache.isValid() {
+		return err
+	}
+	shared.csrCache.err = err
+	shared.csResourcesMap = map[Resource]struct{}{}
+	return shared.csrCache.err
+}
+
 // Obtain all the namespaces in the hub cluster.
 // Equivalent to `oc get namespaces`
 func (shared *SharedData) getNamespaces(ctx context.Context) ([]string, error) {
@@ -254,32 +276,77 @@ func (shared *SharedData) getNamespaces(ctx context.Context) ([]string, error) {
 	if shared.nsCache.isValid() {
 		return shared.namespaces, nil
 	}
+	return shared.loadNamespaces(ctx)
+}
 
-	// Empty previous cache and request new data.
-	shared.namespaces = nil
-	shared.nsCache.err = nil
-
+// Request the namespaces from the Kube API and update the cache.
+// The caller must hold the nsCache lock.
+func (shared *SharedData) loadNamespaces(ctx context.Context) ([]string, error) {
 	klog.V(5).Info("Getting namespaces from Kube Client.")
 	scheme := runtime.NewScheme()
 	scheme.AddKnownTypes(namespacesGvr.GroupVersion())
 
-	namespaceList, nsErr := shared.dynamicClient.Resource(namespacesGvr).List(ctx, metav1.ListOptions{})
+	namespaceList, nsErr := listWithTimeout(ctx, shared.dynamicClient, namespacesGvr)
 	if nsErr != nil {
 		klog.Warning("Error resolving namespaces from KubeClient: ", nsErr)
+		// A valid cache, which is being refreshed ahead of its expiration, keeps its data until it expires.
+		if shared.nsCache.isValid() {
+			return shared.namespaces, nsErr
+		}
+		shared.namespaces = nil
 		shared.nsCache.err = nsErr
 		shared.nsCache.updatedAt = time.Now()
+		if errors.Is(nsErr, ErrListTimeout) {
+			shared.nsCache.updatedAt = time.Time{} // Invalidate the cache to retry with the next request.
+		}
 		return shared.namespaces, shared.nsCache.err
 	}
 
 	// add namespaces to allNamespace List
+	namespaces := make([]string, 0, len(namespaceList.Items))
 	for _, n := range namespaceList.Items {
-		shared.namespaces = append(shared.namespaces, n.GetName())
+		namespaces = append(namespaces, n.GetName())
 	}
+	// Replace the cached data only after the new data is loaded.
+	shared.namespaces = namespaces
+	shared.nsCache.err = nil
 	shared.nsCache.updatedAt = time.Now()
 
 	return shared.namespaces, shared.nsCache.err
 }
 
+// List a resource from the Kube API. Returns ErrListTimeout if the request doesn't complete within
+// KUBE_LIST_TIMEOUT, even when the client doesn't honor the context deadline.
+func listWithTimeout(ctx context.Context, client dynamic.Interface,
+	gvr schema.GroupVersionResource) (*unstructured.UnstructuredList, error) {
+	timeout := time.Duration(config.Cfg.KubeListTimeout) * time.Millisecond
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type listResult struct {
+		list *unstructured.UnstructuredList
+		err  error
+	}
+	resultCh := make(chan listResult, 1)
+	go func() {
+		list, err := client.Resource(gvr).List(ctx, metav1.ListOptions{})
+		resultCh <- listResult{list: list, err: err}
+	}()
+
+	select {
+	case result := <-resultCh:
+		if errors.Is(result.err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %s after %s", ErrListTimeout, gvr.Resource, timeout)
+		}
+		return result.list, result.err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %s after %s", ErrListTimeout, gvr.Resource, timeout)
+		}
+		return nil, ctx.Err()
+	}
+}
+
 // Obtain all the managedclusters.
 // Equivalent to `oc get managedclusters`
 func (shared *SharedData) getManagedClusters(ctx context.Context) error {
@@ -296,18 +363,21 @@ func (shared *SharedData) getManagedClusters(ctx context.Context) error {
 	scheme := runtime.NewScheme()
 	scheme.AddKnownTypes(managedClusterResourceGvr.GroupVersion())
 
-	resourceObj, err := shared.dynamicClient.Resource(managedClusterResourceGvr).List(ctx, metav1.ListOptions{})
+	resourceObj, err := listWithTimeout(ctx, shared.dynamicClient, managedClusterResourceGvr)
 
 	if err != nil {
 		klog.Warning("Error resolving ManagedClusters with dynamic client", err.Error())
 		shared.mcCache.err = err
 		shared.mcCache.updatedAt = time.Now()
+		if errors.Is(err, ErrListTimeout) {
+			shared.mcCache.updatedAt = time.Time{} // Invalidate the cache to retry with the next request.
+		}
 		return shared.mcCache.err
 	}
 
 	for _, item := range resourceObj.Items {
 		// Add to list if it is not local-cluster
-		if item.GetName() != "local-cluster" {
+		if item.GetName() != "local-cluster" && matchesManagedClusterLabel(item.GetLabels()) {
 			managedClusters[item.GetName()] = struct{}{}
 		}
 	}
@@ -319,6 +389,19 @@ func (shared *SharedData) getManagedClusters(ctx context.Context) error {
 
 }
 
+// Checks the labels of a ManagedCluster when MANAGED_CLUSTER_LABEL is set. The cluster must have the label,
+// and when MANAGED_CLUSTER_LABEL_VALUE is set the label value must match it.
+func matchesManagedClusterLabel(labels map[string]string) bool {
+	if config.Cfg.ManagedClusterLabel == "" {
+		return true
+	}
+	value, found := labels[config.Cfg.ManagedClusterLabel]
+	if !found {
+		return false
+	}
+	return config.Cfg.ManagedClusterLabelValue == "" || value == config.Cfg.ManagedClusterLabelValue
+}
+
 // Returns a map of managed clusters for which the search add-on has been disabled.
 func (cache *Cache) GetDisabledClusters(ctx context.Context) (*map[string]struct{}, error) {
 	uid, _ := cache.GetUserUID(ctx)
@@ -368,6 +451,29 @@ func disabledClustersForUser(disabledClusters map[string]struct{},
 	return userAccessDisabledClusters
 }
 
+// Returns the sorted names of the managed clusters the user can access.
+// Users with access to all managed clusters get all the managed clusters in the shared cache.
+func (cache *Cache) GetUserManagedClusters(ctx context.Context) ([]string, error) {
+	userData, err := cache.GetUserData(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userClusters := userData.ManagedClusters
+	if userData.ManagedClusterAllAccess {
+		cache.shared.mcCache.lock.Lock()
+		defer cache.shared.mcCache.lock.Unlock()
+		userClusters = cache.shared.managedClusters
+	}
+
+	clusters := make([]string, 0, len(userClusters))
+	for cluster := range userClusters {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+	return clusters, nil
+}
+
 func (shared *SharedData) setDisabledClusters(disabledClusters map[string]struct{}, err error) {
 	shared.disabledClusters = disabledClusters
 	shared.dcCache.updatedAt = time.Now()
@@ -2,18 +2,23 @@ package rbac
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/driftprogramming/pgxpoolmock"
 	"github.com/golang/mock/gomock"
+	"github.com/stolostron/search-v2-api/pkg/config"
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	fakedynclient "k8s.io/client-go/dynamic/fake"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	testingk8s "k8s.io/client-go/testing"
 	clusterv1 "open-cluster-management.io/api/cluster/v1"
 )
 
@@ -429,3 +434,149 @@ func Test_Messages_Query(t *testing.T) {
 		t.Errorf("Expected error to be nil, but got : %s", err)
 	}
 }
+
+// Should return ErrListTimeout and invalidate the cache when listing managed clusters takes too long.
+func Test_getManagedClusters_Timeout(t *testing.T) {
+	_, mockCache := mockResourcesListCache(t)
+	defer func(timeout int) { config.Cfg.KubeListTimeout = timeout }(config.Cfg.KubeListTimeout)
+	config.Cfg.KubeListTimeout = 20
+
+	// Fake client that blocks the list request.
+	unblock := make(chan struct{})
+	defer close(unblock)
+	fakeClient := fakedynclient.NewSimpleDynamicClientWithCustomListKinds(scheme.Scheme,
+		map[schema.GroupVersionResource]string{managedClusterResourceGvr: "ManagedClusterList"})
+	fakeClient.PrependReactor("list", "managedclusters",
+		func(action testingk8s.Action) (handled bool, ret runtime.Object, err error) {
+			<-unblock
+			return true, nil, nil
+		})
+	mockCache.shared.dynamicClient = fakeClient
+
+	start := time.Now()
+	err := mockCache.shared.getManagedClusters(context.Background())
+
+	assert.True(t, errors.Is(err, ErrListTimeout), "Expected ErrListTimeout. Got: %v", err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.False(t, mockCache.shared.mcCache.isValid(), "Expected the managed clusters cache to be invalid.")
+}
+
+// Should only treat a ManagedCluster as a managed cluster when it matches MANAGED_CLUSTER_LABEL and
+// MANAGED_CLUSTER_LABEL_VALUE.
+func Test_getManagedClusters_Label(t *testing.T) {
+	defer func(label, value string) {
+		config.Cfg.ManagedClusterLabel = label
+		config.Cfg.ManagedClusterLabelValue = value
+	}(config.Cfg.ManagedClusterLabel, config.Cfg.ManagedClusterLabelValue)
+
+	tests := []struct {
+		name     string
+		label    string
+		value    string
+		expected map[string]struct{}
+	}{
+		{"label not configured", "", "",
+			map[string]struct{}{"hub-2": {}, "managed-ocp": {}, "managed-eks": {}, "managed-nolabel": {}}},
+		{"label presence only", "vendor", "",
+			map[string]struct{}{"hub-2": {}, "managed-ocp": {}, "managed-eks": {}}},
+		{"label value match", "vendor", "OpenShift",
+			map[string]struct{}{"managed-ocp": {}}},
+		{"label value mismatch", "vendor", "IKS",
+			map[string]struct{}{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config.Cfg.ManagedClusterLabel = test.label
+			config.Cfg.ManagedClusterLabelValue = test.value
+			_, mockCache := mockResourcesListCache(t)
+			mockCache.shared.dynamicClient = fakedynclient.NewSimpleDynamicClient(scheme.Scheme,
+				&clusterv1.ManagedCluster{TypeMeta: metav1.TypeMeta{Kind: "ManagedCluster"},
+					ObjectMeta: metav1.ObjectMeta{Name: "local-cluster", Labels: map[string]string{"vendor": "OpenShift"}}},
+				&clusterv1.ManagedCluster{TypeMeta: metav1.TypeMeta{Kind: "ManagedCluster"},
+					ObjectMeta: metav1.ObjectMeta{Name: "hub-2", Labels: map[string]string{"vendor": "OpenShiftHub"}}},
+				&clusterv1.ManagedCluster{TypeMeta: metav1.TypeMeta{Kind: "ManagedCluster"},
+					ObjectMeta: metav1.ObjectMeta{Name: "managed-ocp", Labels: map[string]string{"vendor": "OpenShift"}}},
+				&clusterv1.ManagedCluster{TypeMeta: metav1.TypeMeta{Kind: "ManagedCluster"},
+					ObjectMeta: metav1.ObjectMeta{Name: "managed-eks", Labels: map[string]string{"vendor": "EKS"}}},
+				&clusterv1.ManagedCluster{TypeMeta: metav1.TypeMeta{Kind: "ManagedCluster"},
+					ObjectMeta: metav1.ObjectMeta{Name: "managed-nolabel"}})
+
+			err := mockCache.shared.getManagedClusters(context.Background())
+
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, mockCache.shared.managedClusters)
+		})
+	}
+}
+
+// Should return ErrListTimeout and invalidate the cache when listing namespaces takes too long.
+func Test_getNamespaces_Timeout(t *testing.T) {
+	_, mockCache := mockResourcesListCache(t)
+	defer func(timeout int) { config.Cfg.KubeListTimeout = timeout }(config.Cfg.KubeListTimeout)
+	config.Cfg.KubeListTimeout = 20
+
+	// Fake client that blocks the list request.
+	unblock := make(chan struct{})
+	defer close(unblock)
+	fakeClient := fakedynclient.NewSimpleDynamicClient(scheme.Scheme)
+	fakeClient.PrependReactor("list", "namespaces",
+		func(action testingk8s.Action) (handled bool, ret runtime.Object, err error) {
+			<-unblock
+			return true, nil, nil
+		})
+	mockCache.shared.dynamicClient = fakeClient
+
+	_, err := mockCache.shared.getNamespaces(context.Background())
+
+	assert.True(t, errors.Is(err, ErrListTimeout), "Expected ErrListTimeout. Got: %v", err)
+	assert.False(t, mockCache.shared.nsCache.isValid(), "Expected the namespaces cache to be invalid.")
+}
+
+// Should return the sorted managed clusters the user can access.
+func Test_GetUserManagedClusters(t *testing.T) {
+	_, mock_cache := mockResourcesListCache(t)
+	setupToken(&mock_cache)
+	mock_cache.shared.managedClusters = map[string]struct{}{"managed1": {}, "managed2": {}, "managed3": {}}
+
+	userdataCache := UserDataCache{UserData: UserData{ManagedClusters: map[string]struct{}{"managed3": {}, "managed1": {}}},
+		csrCache:      cacheMetadata{updatedAt: time.Now()},
+		nsrCache:      cacheMetadata{updatedAt: time.Now()},
+		clustersCache: cacheMetadata{updatedAt: time.Now()}}
+	setupUserDataCache(&mock_cache, &userdataCache)
+
+	clusters, err := mock_cache.GetUserManagedClusters(context.WithValue(context.Background(),
+		ContextAuthTokenKey, "123456"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"managed1", "managed3"}, clusters)
+}
+
+// Should return all the managed clusters when the user has access to all managed clusters.
+func Test_GetUserManagedClusters_AllAccess(t *testing.T) {
+	_, mock_cache := mockResourcesListCache(t)
+	setupToken(&mock_cache)
+	mock_cache.shared.managedClusters = map[string]struct{}{"managed2": {}, "managed1": {}, "managed3": {}}
+
+	userdataCache := UserDataCache{UserData: UserData{ManagedClusterAllAccess: true},
+		csrCache:      cacheMetadata{updatedAt: time.Now()},
+		nsrCache:      cacheMetadata{updatedAt: time.Now()},
+		clustersCache: cacheMetadata{updatedAt: time.Now()}}
+	setupUserDataCache(&mock_cache, &userdataCache)
+
+	clusters, err := mock_cache.GetUserManagedClusters(context.WithValue(context.Background(),
+		ContextAuthTokenKey, "123456"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"managed1", "managed2", "managed3"}, clusters)
+}
+
+// Should return an error when the user data can't be resolved.
+func Test_GetUserManagedClusters_UserNotFound(t *testing.T) {
+	_, mock_cache := mockResourcesListCache(t)
+	mock_cache.tokenReviews = map[string]*tokenReviewCache{}
+
+	clusters, err := mock_cache.GetUserManagedClusters(context.TODO())
+
+	assert.NotNil(t, err)
+	assert.Nil(t, clusters)
+}
@@ -3,10 +3,13 @@ package rbac
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"time"
 
 	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/tracing"
 	authv1 "k8s.io/api/authentication/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/client-go/kubernetes/typed/authentication/v1"
@@ -18,7 +21,6 @@ type tokenReviewCache struct {
 	meta cacheMetadata
 
 	authClient  v1.AuthenticationV1Interface // This allows tests to replace with mock client.
-	token       string
 	tokenReview *authv1.TokenReview
 }
 
@@ -26,54 +28,94 @@ type tokenReviewCache struct {
 // Will use cached data if available and valid, otherwise starts a new request.
 func (c *Cache) IsValidToken(ctx context.Context, token string) (bool, error) {
 	tr, err := c.GetTokenReview(ctx, token)
-	return tr.Status.Authenticated, err
+	if err != nil || tr == nil {
+		return false, err
+	}
+	return tr.Status.Authenticated, nil
 }
 
 // Get the TokenReview response for a given token.
 // Will use cached data if available and valid, otherwise starts a new request.
+// The TokenReview is cached for AUTH_CACHE_TTL with the hash of the token. Failed requests and rejected
+// tokens aren't cached.
 func (c *Cache) GetTokenReview(ctx context.Context, token string) (*authv1.TokenReview, error) {
+	_, span := tracing.Start(ctx, "rbac.TokenReview")
 	c.tokenReviewsLock.Lock()
 	defer c.tokenReviewsLock.Unlock()
 
 	// Check if a TokenReviewCacheRequest exists in the cache or create a new one.
-	cachedTR, tokenExists := c.tokenReviews[token]
+	key := tokenReviewKey(token)
+	cachedTR, tokenExists := c.tokenReviews[key]
 	if !tokenExists {
+		c.evictExpiredTokenReviews()
 		cachedTR = &tokenReviewCache{
 			authClient: c.getAuthClient(),
-			token:      token,
 		}
 		if c.tokenReviews == nil {
 			c.tokenReviews = map[string]*tokenReviewCache{}
 		}
-		c.tokenReviews[token] = cachedTR
+		c.tokenReviews[key] = cachedTR
+	}
+	tr, err := cachedTR.getTokenReview(token)
+	tracing.End(span, err)
+	if err != nil || tr == nil || !tr.Status.Authenticated {
+		// Remove the failed or rejected review, the next request for the token starts a new TokenReview.
+		delete(c.tokenReviews, key)
 	}
-	return cachedTR.getTokenReview()
+	return tr, err
+}
+
+// Key of the token in the cache. Uses a hash so the token isn't retained by the cache.
+func tokenReviewKey(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// Remove the expired TokenReviews, otherwise the tokens that aren't used again stay in the cache.
+// Must be called with the tokenReviewsLock.
+func (c *Cache) evictExpiredTokenReviews() {
+	for key, trc := range c.tokenReviews {
+		if trc.isExpired() {
+			delete(c.tokenReviews, key)
+		}
+	}
+}
+
+func (trc *tokenReviewCache) isExpired() bool {
+	return time.Now().After(trc.meta.updatedAt.Add(time.Duration(config.Cfg.AuthCacheTTL) * time.Millisecond))
 }
 
 // Get the resolved TokenReview from the cached tokenReviewCachedRequest object.
-func (trc *tokenReviewCache) getTokenReview() (*authv1.TokenReview, error) {
+// The token is only used to start a new TokenReview, it isn't stored.
+func (trc *tokenReviewCache) getTokenReview(token string) (*authv1.TokenReview, error) {
 	// This ensures that only 1 process is updating the TokenReview data from API request.
 	trc.meta.lock.Lock()
 	defer trc.meta.lock.Unlock()
 
 	// Check if cached TokenReview data is valid. Update if needed.
-	if time.Now().After(trc.meta.updatedAt.Add(time.Duration(config.Cfg.AuthCacheTTL) * time.Millisecond)) {
+	if trc.isExpired() {
 		klog.V(6).Infof("Starting TokenReview. tokenReviewCache expired or never updated. UpdatedAt %s", trc.meta.updatedAt)
 
 		tr := authv1.TokenReview{
 			Spec: authv1.TokenReviewSpec{
-				Token: trc.token,
+				Token: token,
 			},
 		}
 
 		result, err := trc.authClient.TokenReviews().Create(context.TODO(), &tr, metav1.CreateOptions{})
 		if err != nil {
 			klog.Warning("Error resolving TokenReview from Kube API.", err.Error())
+			return result, err
 		}
 		klog.V(9).Infof("TokenReview Kube API result: %v\n", prettyPrint(result.Status))
+		result.Spec.Token = "" // The response includes the token, don't keep it in the cache.
+		// Only cache the authenticated tokens, a rejected token is reviewed again on the next request.
+		if !result.Status.Authenticated {
+			return result, nil
+		}
 
 		trc.meta.updatedAt = time.Now()
-		trc.meta.err = err
+		trc.meta.err = nil
 		trc.tokenReview = result
 	} else {
 		klog.V(6).Info("Using cached TokenReview.")
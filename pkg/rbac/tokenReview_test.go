@@ -3,19 +3,28 @@ package rbac
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	authv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	fake "k8s.io/client-go/kubernetes/fake"
+	testingk8s "k8s.io/client-go/testing"
 )
 
 // Initialize cache object to use tests.
 func newMockCache() Cache {
+	// Use a fake Kubernetes authentication client. The TokenReviews aren't stored, same as the API server,
+	// so the same token can be reviewed again. The tokens aren't authenticated.
+	fs := fake.NewSimpleClientset()
+	fs.PrependReactor("create", "tokenreviews", func(action testingk8s.Action) (bool, runtime.Object, error) {
+		return true, action.(testingk8s.CreateAction).GetObject(), nil
+	})
 	return Cache{
-		// Use a fake Kubernetes authentication client.
-		authnClient:      fake.NewSimpleClientset().AuthenticationV1(),
+		authnClient:      fs.AuthenticationV1(),
 		tokenReviews:     map[string]*tokenReviewCache{},
 		tokenReviewsLock: sync.Mutex{},
 	}
@@ -42,7 +51,7 @@ func Test_IsValidToken_emptyCache(t *testing.T) {
 func Test_IsValidToken_usingCache(t *testing.T) {
 	// Initialize cache and set state.
 	mock_cache := newMockCache()
-	mock_cache.tokenReviews["1234567890"] = &tokenReviewCache{
+	mock_cache.tokenReviews[tokenReviewKey("1234567890")] = &tokenReviewCache{
 		meta: cacheMetadata{updatedAt: time.Now()},
 		tokenReview: &authv1.TokenReview{
 			Status: authv1.TokenReviewStatus{
@@ -67,10 +76,9 @@ func Test_IsValidToken_usingCache(t *testing.T) {
 func Test_IsValidToken_expiredCache(t *testing.T) {
 	// Initialize cache and set state to TokenReview updated 5 minutes ago.
 	mock_cache := newMockCache()
-	mock_cache.tokenReviews["1234567890-expired"] = &tokenReviewCache{
+	mock_cache.tokenReviews[tokenReviewKey("1234567890-expired")] = &tokenReviewCache{
 		authClient: fake.NewSimpleClientset().AuthenticationV1(),
 		meta:       cacheMetadata{updatedAt: time.Now().Add(time.Duration(-5) * time.Minute)},
-		token:      "1234567890-expired",
 		tokenReview: &authv1.TokenReview{
 			Status: authv1.TokenReviewStatus{
 				Authenticated: true,
@@ -88,9 +96,141 @@ func Test_IsValidToken_expiredCache(t *testing.T) {
 	if err != nil {
 		t.Error("Received unexpected error from IsValidToken()", err)
 	}
-	// Verify that cache was updated within the last 1 millisecond.
-	if mock_cache.tokenReviews["1234567890-expired"].meta.updatedAt.Before(time.Now().Add(time.Duration(-1) * time.Millisecond)) {
-		t.Error("Expected the cached TokenReview to be updated within the last millisecond.")
+	// The fake client rejects the token, so the expired TokenReview is removed instead of updated.
+	if _, found := mock_cache.tokenReviews[tokenReviewKey("1234567890-expired")]; found {
+		t.Error("Expected the rejected TokenReview to be removed from the cache.")
 	}
 
 }
+
+// Initialize cache with a fake client that counts the TokenReview requests and fails while reviewErr is set.
+func newCountingMockCache(requests *int, reviewErr *error) *Cache {
+	fs := fake.NewSimpleClientset()
+	fs.PrependReactor("create", "tokenreviews", func(action testingk8s.Action) (bool, runtime.Object, error) {
+		*requests++
+		if *reviewErr != nil {
+			return true, &authv1.TokenReview{}, *reviewErr
+		}
+		tr := action.(testingk8s.CreateAction).GetObject().(*authv1.TokenReview)
+		tr.Status.Authenticated = true
+		return true, tr, nil
+	})
+	mock_cache := newMockCache()
+	mock_cache.authnClient = fs.AuthenticationV1()
+	return &mock_cache
+}
+
+// Second request for the token uses the cached TokenReview, keyed by the hash of the token.
+func Test_GetTokenReview_cacheHit(t *testing.T) {
+	requests := 0
+	var reviewErr error
+	mock_cache := newCountingMockCache(&requests, &reviewErr)
+
+	for i := 0; i < 2; i++ {
+		if valid, err := mock_cache.IsValidToken(context.TODO(), "1234567890"); !valid || err != nil {
+			t.Errorf("Expected token to be valid. Got valid: %t err: %v", valid, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected 1 TokenReview request, got %d.", requests)
+	}
+	if _, found := mock_cache.tokenReviews["1234567890"]; found {
+		t.Error("Expected the token not to be used as the cache key.")
+	}
+	if _, found := mock_cache.tokenReviews[tokenReviewKey("1234567890")]; !found {
+		t.Error("Expected the TokenReview to be cached with the hash of the token.")
+	}
+}
+
+// Failed TokenReview isn't cached, the next request starts a new TokenReview.
+func Test_GetTokenReview_failureNotCached(t *testing.T) {
+	requests := 0
+	reviewErr := errors.New("kube api unavailable")
+	mock_cache := newCountingMockCache(&requests, &reviewErr)
+
+	if valid, err := mock_cache.IsValidToken(context.TODO(), "1234567890"); valid || err == nil {
+		t.Errorf("Expected an error from the failed TokenReview. Got valid: %t err: %v", valid, err)
+	}
+	if _, found := mock_cache.tokenReviews[tokenReviewKey("1234567890")]; found {
+		t.Error("Expected the failed TokenReview not to be cached.")
+	}
+
+	reviewErr = nil
+	if valid, err := mock_cache.IsValidToken(context.TODO(), "1234567890"); !valid || err != nil {
+		t.Errorf("Expected token to be valid after retrying. Got valid: %t err: %v", valid, err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 TokenReview requests, got %d.", requests)
+	}
+}
+
+// Rejected token isn't cached, the next request starts a new TokenReview.
+func Test_GetTokenReview_rejectedNotCached(t *testing.T) {
+	requests := 0
+	fs := fake.NewSimpleClientset()
+	fs.PrependReactor("create", "tokenreviews", func(action testingk8s.Action) (bool, runtime.Object, error) {
+		requests++
+		return true, action.(testingk8s.CreateAction).GetObject(), nil // Status.Authenticated is false.
+	})
+	mock_cache := newMockCache()
+	mock_cache.authnClient = fs.AuthenticationV1()
+
+	for i := 0; i < 2; i++ {
+		if valid, err := mock_cache.IsValidToken(context.TODO(), "1234567890"); valid || err != nil {
+			t.Errorf("Expected token to be rejected. Got valid: %t err: %v", valid, err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 TokenReview requests, got %d.", requests)
+	}
+	if _, found := mock_cache.tokenReviews[tokenReviewKey("1234567890")]; found {
+		t.Error("Expected the rejected TokenReview not to be cached.")
+	}
+}
+
+// Expired TokenReviews are removed from the cache when a new token is reviewed.
+func Test_GetTokenReview_evictExpired(t *testing.T) {
+	requests := 0
+	var reviewErr error
+	mock_cache := newCountingMockCache(&requests, &reviewErr)
+	mock_cache.tokenReviews[tokenReviewKey("expired-token")] = &tokenReviewCache{
+		meta:        cacheMetadata{updatedAt: time.Now().Add(time.Duration(-5) * time.Minute)},
+		tokenReview: &authv1.TokenReview{Status: authv1.TokenReviewStatus{Authenticated: true}},
+	}
+	mock_cache.tokenReviews[tokenReviewKey("valid-token")] = &tokenReviewCache{
+		meta:        cacheMetadata{updatedAt: time.Now()},
+		tokenReview: &authv1.TokenReview{Status: authv1.TokenReviewStatus{Authenticated: true}},
+	}
+
+	if _, err := mock_cache.GetTokenReview(context.TODO(), "new-token"); err != nil {
+		t.Error("Received unexpected error from GetTokenReview()", err)
+	}
+	if _, found := mock_cache.tokenReviews[tokenReviewKey("expired-token")]; found {
+		t.Error("Expected the expired TokenReview to be evicted.")
+	}
+	if len(mock_cache.tokenReviews) != 2 {
+		t.Errorf("Expected the valid and new TokenReviews in the cache, got %d.", len(mock_cache.tokenReviews))
+	}
+}
+
+// Lookups with the same token use the same cache entry, and the token isn't stored in the cache.
+func Test_GetTokenReview_tokenNotStored(t *testing.T) {
+	requests := 0
+	var reviewErr error
+	mock_cache := newCountingMockCache(&requests, &reviewErr)
+
+	first, err := mock_cache.GetTokenReview(context.TODO(), "secret-token")
+	if err != nil {
+		t.Error("Received unexpected error from GetTokenReview()", err)
+	}
+	second, _ := mock_cache.GetTokenReview(context.TODO(), "secret-token")
+	if first != second || requests != 1 || len(mock_cache.tokenReviews) != 1 {
+		t.Errorf("Expected both lookups to use the same cache entry. Requests: %d Entries: %d",
+			requests, len(mock_cache.tokenReviews))
+	}
+	for key, trc := range mock_cache.tokenReviews {
+		if strings.Contains(key, "secret-token") || strings.Contains(prettyPrint(trc.tokenReview), "secret-token") {
+			t.Error("Expected the token not to be stored in the cache.")
+		}
+	}
+}
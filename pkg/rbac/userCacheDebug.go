@@ -0,0 +1,128 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	authv1 "k8s.io/api/authentication/v1"
+	"k8s.io/klog/v2"
+)
+
+// Returned when the user requesting the RBAC dump doesn't have access to all resources.
+var ErrDebugForbidden = errors.New("only users with access to all resources can view the RBAC data of other users")
+
+// Returned when the data for the requested user isn't in the cache.
+var ErrUserNotCached = errors.New("the user's data isn't in the cache")
+
+// The cached RBAC data of a user. Used by support engineers to understand unexpected search results.
+type UserRBACDump struct {
+	UID                     string                `json:"uid"`
+	Username                string                `json:"username"`
+	Groups                  []string              `json:"groups"`
+	ManagedClusters         []string              `json:"managedClusters"`
+	ManagedClusterAllAccess bool                  `json:"managedClusterAllAccess"`
+	ClusterScopedResources  []Resource            `json:"clusterScopedResources"`
+	NamespacedResources     map[string][]Resource `json:"namespacedResources"`
+
+	// Time when each section of the user's data was last updated.
+	ManagedClustersUpdatedAt        time.Time `json:"managedClustersUpdatedAt"`
+	ClusterScopedResourcesUpdatedAt time.Time `json:"clusterScopedResourcesUpdatedAt"`
+	NamespacedResourcesUpdatedAt    time.Time `json:"namespacedResourcesUpdatedAt"`
+	LastUsedAt                      time.Time `json:"lastUsedAt"`
+}
+
+// Returns the cached RBAC data of the target user, identified by the uid or the username like InvalidateUser().
+// Only users with access to all resources are allowed, because the data exposes another user's access.
+func (cache *Cache) DebugUserRBAC(ctx context.Context, targetUser authv1.UserInfo) (*UserRBACDump, error) {
+	userData, err := cache.GetUserData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !hasAllAccess(userData) {
+		return nil, ErrDebugForbidden
+	}
+
+	cache.usersLock.Lock()
+	var target *UserDataCache
+	var lastUsedAt time.Time
+	if keys := cache.cachedUserKeys(targetUser); len(keys) > 0 {
+		target = cache.users[keys[0]]
+		lastUsedAt = target.lastUsedAt
+	}
+	cache.usersLock.Unlock()
+	if target == nil {
+		return nil, ErrUserNotCached
+	}
+
+	dump := &UserRBACDump{
+		UID:                    target.userInfo.UID,
+		Username:               target.userInfo.Username,
+		Groups:                 target.userInfo.Groups,
+		ManagedClusters:        []string{},
+		ClusterScopedResources: target.GetCsResourcesCopy(),
+		NamespacedResources:    target.GetNsResourcesCopy(),
+		LastUsedAt:             lastUsedAt,
+	}
+	for cluster := range target.GetManagedClustersCopy() {
+		dump.ManagedClusters = append(dump.ManagedClusters, cluster)
+	}
+	sort.Strings(dump.ManagedClusters)
+
+	target.clustersCache.lock.Lock()
+	dump.ManagedClusterAllAccess = target.ManagedClusterAllAccess
+	dump.ManagedClustersUpdatedAt = target.clustersCache.updatedAt
+	target.clustersCache.lock.Unlock()
+	target.csrCache.lock.Lock()
+	dump.ClusterScopedResourcesUpdatedAt = target.csrCache.updatedAt
+	target.csrCache.lock.Unlock()
+	target.nsrCache.lock.Lock()
+	dump.NamespacedResourcesUpdatedAt = target.nsrCache.updatedAt
+	target.nsrCache.lock.Unlock()
+
+	return dump, nil
+}
+
+// Handles requests to view the cached RBAC data of the user in the uid or username query parameter.
+// Only users with access to all resources are allowed to view the data.
+func HandleDebugUserRBAC(w http.ResponseWriter, r *http.Request) {
+	GetCache().handleDebugUserRBAC(w, r)
+}
+
+func (cache *Cache) handleDebugUserRBAC(w http.ResponseWriter, r *http.Request) {
+	target := authv1.UserInfo{UID: r.URL.Query().Get("uid"), Username: r.URL.Query().Get("username")}
+	if target.UID == "" && target.Username == "" {
+		http.Error(w, "{\"message\":\"The uid or username query parameter is required.\"}", http.StatusBadRequest)
+		return
+	}
+
+	dump, err := cache.DebugUserRBAC(r.Context(), target)
+	if err != nil {
+		_, userInfo := cache.GetUserUID(r.Context())
+		switch {
+		case errors.Is(err, ErrDebugForbidden):
+			klog.V(2).Infof("Rejecting request from user %s to view the RBAC data of user %s with uid %s.",
+				userInfo.Username, target.Username, target.UID)
+			http.Error(w, fmt.Sprintf("{\"message\":\"%s\"}", err), http.StatusForbidden)
+		case errors.Is(err, ErrUserNotCached):
+			http.Error(w, fmt.Sprintf("{\"message\":\"%s\"}", err), http.StatusNotFound)
+		default:
+			klog.Warning("Unable to resolve the user's access to view the RBAC data of another user. ", err)
+			http.Error(w, "{\"message\":\"Unable to resolve the user's access.\"}", http.StatusServiceUnavailable)
+		}
+		return
+	}
+
+	_, userInfo := cache.GetUserUID(r.Context())
+	klog.Infof("User %s viewed the cached RBAC data of user %s with uid %s.", userInfo.Username,
+		dump.Username, dump.UID)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(dump); err != nil {
+		klog.Error("Error encoding the user's RBAC data. ", err)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	authv1 "k8s.io/api/authentication/v1"
+)
+
+// Mimic an admin user and another user with access to some resources.
+func mockCacheForDebugUserRBAC(callerData UserData) (*Cache, time.Time) {
+	mock_cache := setupToken(mockNamespaceCache())
+	updatedAt := time.Now().Add(-time.Minute).UTC()
+	mock_cache.users["unique-user-id"] = &UserDataCache{
+		UserData:      callerData,
+		clustersCache: cacheMetadata{updatedAt: time.Now()},
+		csrCache:      cacheMetadata{updatedAt: time.Now()},
+		nsrCache:      cacheMetadata{updatedAt: time.Now()},
+	}
+	mock_cache.users["other-user"] = &UserDataCache{
+		UserData: UserData{
+			CsResources:     []Resource{{Apigroup: "", Kind: "nodes"}},
+			NsResources:     map[string][]Resource{"ns1": {{Apigroup: "", Kind: "pods"}}},
+			ManagedClusters: map[string]struct{}{"managed2": {}, "managed1": {}},
+		},
+		userInfo:      authv1.UserInfo{Username: "other", UID: "other-user", Groups: []string{"dev-team"}},
+		clustersCache: cacheMetadata{updatedAt: updatedAt},
+		csrCache:      cacheMetadata{updatedAt: updatedAt},
+		nsrCache:      cacheMetadata{updatedAt: updatedAt},
+		lastUsedAt:    updatedAt,
+	}
+	return mock_cache, updatedAt
+}
+
+func Test_DebugUserRBAC(t *testing.T) {
+	mock_cache, updatedAt := mockCacheForDebugUserRBAC(UserData{
+		CsResources:             []Resource{{Apigroup: "*", Kind: "*"}},
+		NsResources:             map[string][]Resource{"*": {{Apigroup: "*", Kind: "*"}}},
+		ManagedClusterAllAccess: true,
+	})
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+
+	r := httptest.NewRequest("GET", "/searchapi/cache/user?uid=other-user", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	mock_cache.handleDebugUserRBAC(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	dump := &UserRBACDump{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), dump))
+	assert.Equal(t, &UserRBACDump{
+		UID:                             "other-user",
+		Username:                        "other",
+		Groups:                          []string{"dev-team"},
+		ManagedClusters:                 []string{"managed1", "managed2"},
+		ClusterScopedResources:          []Resource{{Apigroup: "", Kind: "nodes"}},
+		NamespacedResources:             map[string][]Resource{"ns1": {{Apigroup: "", Kind: "pods"}}},
+		ManagedClustersUpdatedAt:        updatedAt,
+		ClusterScopedResourcesUpdatedAt: updatedAt,
+		NamespacedResourcesUpdatedAt:    updatedAt,
+		LastUsedAt:                      updatedAt,
+	}, dump)
+}
+
+func Test_DebugUserRBAC_Forbidden(t *testing.T) {
+	mock_cache, _ := mockCacheForDebugUserRBAC(UserData{
+		CsResources: []Resource{{Apigroup: "", Kind: "nodes"}},
+		NsResources: map[string][]Resource{"ns1": {{Apigroup: "", Kind: "pods"}}},
+	})
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+
+	_, err := mock_cache.DebugUserRBAC(ctx, authv1.UserInfo{UID: "other-user"})
+	assert.ErrorIs(t, err, ErrDebugForbidden)
+
+	r := httptest.NewRequest("GET", "/searchapi/cache/user?uid=other-user", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	mock_cache.handleDebugUserRBAC(w, r)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotContains(t, w.Body.String(), "nodes")
+}
+
+func Test_DebugUserRBAC_NotFound(t *testing.T) {
+	mock_cache, _ := mockCacheForDebugUserRBAC(UserData{CsResources: []Resource{{Apigroup: "*", Kind: "*"}}})
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+
+	// User not in the cache.
+	r := httptest.NewRequest("GET", "/searchapi/cache/user?uid=unknown-user", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	mock_cache.handleDebugUserRBAC(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Missing uid.
+	r = httptest.NewRequest("GET", "/searchapi/cache/user", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	mock_cache.handleDebugUserRBAC(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// Should find the users without a uid, like kube:admin, and the users with a uid by the username.
+func Test_DebugUserRBAC_Username(t *testing.T) {
+	mock_cache, _ := mockCacheForDebugUserRBAC(UserData{
+		CsResources:             []Resource{{Apigroup: "*", Kind: "*"}},
+		NsResources:             map[string][]Resource{"*": {{Apigroup: "*", Kind: "*"}}},
+		ManagedClusterAllAccess: true,
+	})
+	mock_cache.users["kube:admin"] = &UserDataCache{
+		UserData: UserData{CsResources: []Resource{{Apigroup: "*", Kind: "*"}}},
+		userInfo: authv1.UserInfo{Username: "kube:admin", Groups: []string{"system:cluster-admins"}},
+	}
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+
+	dump, err := mock_cache.DebugUserRBAC(ctx, authv1.UserInfo{Username: "kube:admin"})
+	assert.Nil(t, err)
+	assert.Equal(t, "", dump.UID)
+	assert.Equal(t, "kube:admin", dump.Username)
+	assert.Equal(t, []Resource{{Apigroup: "*", Kind: "*"}}, dump.ClusterScopedResources)
+
+	r := httptest.NewRequest("GET", "/searchapi/cache/user?username=other", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	mock_cache.handleDebugUserRBAC(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	dump = &UserRBACDump{}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), dump))
+	assert.Equal(t, "other-user", dump.UID)
+}
@@ -0,0 +1,79 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"k8s.io/klog/v2"
+)
+
+// How often the user cache is scanned to remove the stale users.
+var userCacheEvictionInterval = 5 * time.Minute
+
+// Users are removed from the cache when the data wasn't updated for this multiple of USER_CACHE_TTL.
+const userCacheEvictionTTLs = 2
+
+// Periodically remove the users that haven't used the cache for a while. Otherwise every user that
+// sends a request keeps an entry in the cache after the data expires.
+func (c *Cache) StartUserCacheEviction(ctx context.Context) {
+	klog.Info("Starting user cache eviction.")
+	ticker := time.NewTicker(userCacheEvictionInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			klog.Info("Stopped user cache eviction.")
+			return
+		case <-ticker.C:
+			c.evictStaleUsers()
+		}
+	}
+}
+
+// Remove the users whose data wasn't updated in userCacheEvictionTTLs * USER_CACHE_TTL.
+func (c *Cache) evictStaleUsers() {
+	c.usersLock.Lock()
+	defer c.usersLock.Unlock()
+
+	staleBefore := time.Now().Add(-userCacheEvictionTTLs * time.Duration(config.Cfg.UserCacheTTL) * time.Millisecond)
+	for uid, user := range c.users {
+		if user.lastUpdatedAt().Before(staleBefore) {
+			delete(c.users, uid)
+		}
+	}
+	klog.V(5).Infof("Evicted stale users from the user cache. Users in cache: %d", len(c.users))
+}
+
+// Time of the most recent update of any section of the user data.
+func (user *UserDataCache) lastUpdatedAt() time.Time {
+	lastUpdatedAt := user.csrCache.updatedAt
+	for _, updatedAt := range []time.Time{user.nsrCache.updatedAt, user.clustersCache.updatedAt} {
+		if updatedAt.After(lastUpdatedAt) {
+			lastUpdatedAt = updatedAt
+		}
+	}
+	return lastUpdatedAt
+}
+
+// Remove the least recently used users to make room for a new user when the cache has MAX_CACHED_USERS.
+// Must be called with the usersLock.
+func (c *Cache) evictLeastRecentlyUsed() {
+	if config.Cfg.MaxCachedUsers <= 0 {
+		return
+	}
+	for len(c.users) >= config.Cfg.MaxCachedUsers {
+		var lruUID string
+		var lruUser *UserDataCache
+		for uid, user := range c.users {
+			if lruUser == nil || user.lastUsedAt.Before(lruUser.lastUsedAt) {
+				lruUID, lruUser = uid, user
+			}
+		}
+		klog.V(5).Infof("User cache has MAX_CACHED_USERS, evicting the least recently used user %s.",
+			lruUser.userInfo.Username)
+		delete(c.users, lruUID)
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stretchr/testify/assert"
+	authv1 "k8s.io/api/authentication/v1"
+)
+
+// Should remove the users whose data wasn't updated in 2 * USER_CACHE_TTL.
+func Test_evictStaleUsers(t *testing.T) {
+	ttl := time.Duration(config.Cfg.UserCacheTTL) * time.Millisecond
+	mock_cache := mockNamespaceCache()
+	mock_cache.users["fresh"] = &UserDataCache{csrCache: cacheMetadata{updatedAt: time.Now()}}
+	mock_cache.users["expired"] = &UserDataCache{
+		csrCache: cacheMetadata{updatedAt: time.Now().Add(-3 * ttl)},
+		nsrCache: cacheMetadata{updatedAt: time.Now().Add(-ttl)}, // Updated after the other sections.
+	}
+	mock_cache.users["stale"] = &UserDataCache{
+		csrCache:      cacheMetadata{updatedAt: time.Now().Add(-3 * ttl)},
+		nsrCache:      cacheMetadata{updatedAt: time.Now().Add(-3 * ttl)},
+		clustersCache: cacheMetadata{updatedAt: time.Now().Add(-3 * ttl)},
+	}
+	mock_cache.users["never-updated"] = &UserDataCache{}
+
+	mock_cache.evictStaleUsers()
+
+	assert.Equal(t, 2, len(mock_cache.users))
+	assert.Contains(t, mock_cache.users, "fresh")
+	assert.Contains(t, mock_cache.users, "expired")
+}
+
+// Should evict the least recently used user when the cache has MAX_CACHED_USERS.
+func Test_evictLeastRecentlyUsed(t *testing.T) {
+	defer func(maxUsers int) { config.Cfg.MaxCachedUsers = maxUsers }(config.Cfg.MaxCachedUsers)
+	config.Cfg.MaxCachedUsers = 2
+	mock_cache := mockCacheForRBACSources()
+	fs := mockAuthzClientset(t, nil, nil)
+	getUserData := func(name string) {
+		_, err := mock_cache.GetUserDataForUserInfo(context.Background(),
+			authv1.UserInfo{Username: name, UID: name + "-uid"}, fs.AuthorizationV1())
+		assert.Nil(t, err)
+	}
+
+	getUserData("user-a")
+	getUserData("user-b")
+	getUserData("user-a") // From the cache, user-b is now the least recently used.
+	getUserData("user-c")
+
+	assert.Equal(t, 2, len(mock_cache.users))
+	assert.Contains(t, mock_cache.users, "user-a-uid")
+	assert.Contains(t, mock_cache.users, "user-c-uid")
+}
+
+// Should not limit the number of users when MAX_CACHED_USERS is 0.
+func Test_evictLeastRecentlyUsed_Disabled(t *testing.T) {
+	defer func(maxUsers int) { config.Cfg.MaxCachedUsers = maxUsers }(config.Cfg.MaxCachedUsers)
+	config.Cfg.MaxCachedUsers = 0
+	mock_cache := mockNamespaceCache()
+	mock_cache.users["uid1"] = &UserDataCache{}
+	mock_cache.users["uid2"] = &UserDataCache{}
+
+	mock_cache.evictLeastRecentlyUsed()
+
+	assert.Equal(t, 2, len(mock_cache.users))
+}
+
+// Should evict the stale users periodically until the context is cancelled.
+func Test_StartUserCacheEviction(t *testing.T) {
+	defer func(interval time.Duration) { userCacheEvictionInterval = interval }(userCacheEvictionInterval)
+	userCacheEvictionInterval = 5 * time.Millisecond
+
+	mock_cache := mockNamespaceCache()
+	mock_cache.users["stale"] = &UserDataCache{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		mock_cache.StartUserCacheEviction(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		mock_cache.usersLock.Lock()
+		defer mock_cache.usersLock.Unlock()
+		return len(mock_cache.users) == 0
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,97 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	authv1 "k8s.io/api/authentication/v1"
+	"k8s.io/klog/v2"
+)
+
+// Remove the user's data from the cache, the next request will resolve the user's access again.
+// The user is identified by the uid or the username, see cachedUserKeys().
+// If the user's data is being refreshed, waits until the refresh completes.
+func (cache *Cache) InvalidateUser(userInfo authv1.UserInfo) {
+	cache.usersLock.Lock()
+	defer cache.usersLock.Unlock()
+	for _, key := range cache.cachedUserKeys(userInfo) {
+		delete(cache.users, key)
+	}
+}
+
+// Returns the keys of the cached users identified by the user info. Uses the cache key of the user,
+// which is the username for the users without a uid, like kube:admin. When only the username is set,
+// also finds the users cached with a uid. Must be called with the usersLock.
+func (cache *Cache) cachedUserKeys(userInfo authv1.UserInfo) []string {
+	keys := []string{}
+	key := userCacheKey(userInfo)
+	if key == "" {
+		return keys
+	}
+	if _, found := cache.users[key]; found {
+		keys = append(keys, key)
+	}
+	if userInfo.UID == "" {
+		for cachedKey, user := range cache.users {
+			if cachedKey != key && user.userInfo.Username == userInfo.Username {
+				keys = append(keys, cachedKey)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Remove the data for all users from the cache.
+func (cache *Cache) InvalidateAllUsers() {
+	cache.usersLock.Lock()
+	defer cache.usersLock.Unlock()
+	cache.users = map[string]*UserDataCache{}
+}
+
+// Handles requests to invalidate the user cache. Use the uid or username query parameter to invalidate
+// a single user, otherwise the data for all users is invalidated.
+// Only users with access to all resources are allowed to invalidate the cache.
+func InvalidateUserCache(w http.ResponseWriter, r *http.Request) {
+	GetCache().invalidateUserCache(w, r)
+}
+
+func (cache *Cache) invalidateUserCache(w http.ResponseWriter, r *http.Request) {
+	userData, err := cache.GetUserData(r.Context())
+	if err != nil {
+		klog.Warning("Unable to resolve the user's access to invalidate the user cache. ", err)
+		http.Error(w, "{\"message\":\"Unable to resolve the user's access.\"}", http.StatusServiceUnavailable)
+		return
+	}
+	_, userInfo := cache.GetUserUID(r.Context())
+	if !hasAllAccess(userData) {
+		klog.V(2).Infof("Rejecting request from user %s to invalidate the user cache.", userInfo.Username)
+		http.Error(w, "{\"message\":\"Only users with access to all resources can invalidate the user cache.\"}",
+			http.StatusForbidden)
+		return
+	}
+
+	target := authv1.UserInfo{UID: r.URL.Query().Get("uid"), Username: r.URL.Query().Get("username")}
+	if target.UID != "" || target.Username != "" {
+		cache.InvalidateUser(target)
+		klog.Infof("User %s invalidated the cached data for user %s with uid %s.", userInfo.Username,
+			target.Username, target.UID)
+		fmt.Fprint(w, "{\"message\":\"Invalidated the cached data for the user.\"}")
+		return
+	}
+	cache.InvalidateAllUsers()
+	klog.Infof("User %s invalidated the cached data for all users.", userInfo.Username)
+	fmt.Fprint(w, "{\"message\":\"Invalidated the cached data for all users.\"}")
+}
+
+// Checks if the user has access to all resources, like a cluster admin.
+func hasAllAccess(userData UserData) bool {
+	for _, res := range userData.CsResources {
+		if res.Apigroup == "*" && res.Kind == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+// Copyright Contributors to the Open Cluster Management project
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	authv1 "k8s.io/api/authentication/v1"
+)
+
+func Test_InvalidateUser(t *testing.T) {
+	mock_cache := mockNamespaceCache()
+	mock_cache.users["user-a"] = &UserDataCache{}
+	mock_cache.users["user-b"] = &UserDataCache{}
+
+	mock_cache.InvalidateUser(authv1.UserInfo{UID: "user-a"})
+
+	_, found := mock_cache.users["user-a"]
+	assert.False(t, found)
+	_, found = mock_cache.users["user-b"]
+	assert.True(t, found)
+}
+
+// Should find the users without a uid, like kube:admin, and the users with a uid by the username.
+func Test_InvalidateUser_Username(t *testing.T) {
+	mock_cache := mockNamespaceCache()
+	mock_cache.users["kube:admin"] = &UserDataCache{userInfo: authv1.UserInfo{Username: "kube:admin"}}
+	mock_cache.users["user-a-uid"] = &UserDataCache{userInfo: authv1.UserInfo{Username: "user-a", UID: "user-a-uid"}}
+	mock_cache.users["user-b-uid"] = &UserDataCache{userInfo: authv1.UserInfo{Username: "user-b", UID: "user-b-uid"}}
+
+	mock_cache.InvalidateUser(authv1.UserInfo{Username: "kube:admin"})
+	mock_cache.InvalidateUser(authv1.UserInfo{Username: "user-a"})
+	mock_cache.InvalidateUser(authv1.UserInfo{})
+
+	assert.Equal(t, 1, len(mock_cache.users))
+	assert.Contains(t, mock_cache.users, "user-b-uid")
+}
+
+func Test_InvalidateAllUsers(t *testing.T) {
+	mock_cache := mockNamespaceCache()
+	mock_cache.users["user-a"] = &UserDataCache{}
+	mock_cache.users["user-b"] = &UserDataCache{}
+
+	mock_cache.InvalidateAllUsers()
+
+	assert.Equal(t, 0, len(mock_cache.users))
+}
+
+// Invalidating while the user's data is being refreshed should wait for the refresh, and the
+// next request should initialize the user's data again.
+func Test_InvalidateUser_DuringRefresh(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+	authzClient := slowAuthzClient{mockAuthzClientset(t, nil, nil).AuthorizationV1(), 200 * time.Millisecond}
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+
+	done := make(chan *UserDataCache)
+	go func() {
+		user, err := mock_cache.GetUserDataCache(ctx, authzClient)
+		assert.Nil(t, err)
+		done <- user
+	}()
+	// Wait until the refresh is in progress, the users lock is held while refreshing.
+	assert.Eventually(t, func() bool {
+		if mock_cache.usersLock.TryLock() {
+			mock_cache.usersLock.Unlock()
+			return false
+		}
+		return true
+	}, time.Second, time.Millisecond)
+
+	mock_cache.InvalidateUser(authv1.UserInfo{UID: "unique-user-id"})
+	refreshed := <-done
+	_, found := mock_cache.users["unique-user-id"]
+	assert.False(t, found, "Expected user to be removed from the cache.")
+
+	// Next request initializes the user's data again.
+	user, err := mock_cache.GetUserDataCache(ctx, authzClient)
+	assert.Nil(t, err)
+	assert.NotSame(t, refreshed, user)
+	assert.True(t, user.isValid())
+	assert.Equal(t, []Resource{{Apigroup: "", Kind: "nodes"}}, user.CsResources)
+}
+
+func Test_invalidateUserCache_Handler(t *testing.T) {
+	mock_cache := setupToken(mockNamespaceCache())
+	// Mimic an admin user.
+	mock_cache.users["unique-user-id"] = &UserDataCache{
+		UserData: UserData{
+			CsResources:             []Resource{{Apigroup: "*", Kind: "*"}},
+			NsResources:             map[string][]Resource{"*": {{Apigroup: "*", Kind: "*"}}},
+			ManagedClusterAllAccess: true,
+		},
+		clustersCache: cacheMetadata{updatedAt: time.Now()},
+		csrCache:      cacheMetadata{updatedAt: time.Now()},
+		nsrCache:      cacheMetadata{updatedAt: time.Now()},
+	}
+	mock_cache.users["other-user"] = &UserDataCache{}
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+
+	// Invalidate a single user.
+	r := httptest.NewRequest("POST", "/searchapi/cache/invalidate?uid=other-user", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	mock_cache.invalidateUserCache(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, len(mock_cache.users))
+
+	// Invalidate a user without a uid with the username.
+	mock_cache.users["kube:admin"] = &UserDataCache{userInfo: authv1.UserInfo{Username: "kube:admin"}}
+	r = httptest.NewRequest("POST", "/searchapi/cache/invalidate?username=kube:admin", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	mock_cache.invalidateUserCache(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, len(mock_cache.users))
+	assert.Contains(t, mock_cache.users, "unique-user-id")
+
+	// Invalidate all users.
+	r = httptest.NewRequest("POST", "/searchapi/cache/invalidate", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	mock_cache.invalidateUserCache(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 0, len(mock_cache.users))
+}
+
+func Test_invalidateUserCache_Forbidden(t *testing.T) {
+	mock_cache := setupToken(mockNamespaceCache())
+	mock_cache.users["unique-user-id"] = &UserDataCache{
+		UserData: UserData{
+			CsResources: []Resource{{Apigroup: "", Kind: "nodes"}},
+			NsResources: map[string][]Resource{"ns1": {{Apigroup: "", Kind: "pods"}}},
+		},
+		clustersCache: cacheMetadata{updatedAt: time.Now()},
+		csrCache:      cacheMetadata{updatedAt: time.Now()},
+		nsrCache:      cacheMetadata{updatedAt: time.Now()},
+	}
+	mock_cache.users["other-user"] = &UserDataCache{}
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+
+	r := httptest.NewRequest("POST", "/searchapi/cache/invalidate", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	mock_cache.invalidateUserCache(w, r)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, 2, len(mock_cache.users))
+}
@@ -3,14 +3,21 @@ package rbac
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"path"
+	"reflect"
 	"sort"
 	"sync"
 	"time"
 
 	"github.com/stolostron/search-v2-api/pkg/config"
 	"github.com/stolostron/search-v2-api/pkg/metrics"
+	"github.com/stolostron/search-v2-api/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	authv1 "k8s.io/api/authentication/v1"
 	authz "k8s.io/api/authorization/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -22,11 +29,49 @@ import (
 
 const impersonationConfigCreationerror = "error creating clientset with impersonation config"
 
+// Errors returned when resolving the user's access. The underlying error is wrapped,
+// use errors.Is() to find the failure.
+var (
+	// Returned when both the cluster-scoped and namespaced resources can't be resolved for the user.
+	// The user's access is unknown, so clients should retry instead of assuming there isn't any data.
+	ErrRBACUnavailable = errors.New("unable to resolve user's access, RBAC sources are unavailable")
+	// The user can't be identified from the TokenReview.
+	ErrTokenReviewMissing = errors.New("unable to find the TokenReview for the user")
+	// The user info passed to GetUserDataForUserInfo doesn't identify the user.
+	ErrUserInfoMissing = errors.New("unable to identify the user, the user info doesn't have a username")
+	// The client impersonating the user can't be created.
+	ErrImpersonationSetup = errors.New(impersonationConfigCreationerror)
+	// Requests to resolve the namespaced resources for the user failed.
+	ErrNamespacedFetch = errors.New("unable to fetch the namespaced resources for the user")
+	// Requests to resolve the cluster-scoped resources for the user failed.
+	ErrClusterScopedFetch = errors.New("unable to fetch the cluster scoped resources for the user")
+	// Wrapped by all the errors returned by GetUserData. The message doesn't include internal details,
+	// so it can be returned to the client.
+	ErrUserAccess = errors.New("unable to resolve the user's access")
+)
+
+// Error returned by GetUserData. The message returned to the client doesn't include the details,
+// but errors.Is() can still find the failure.
+type userDataError struct {
+	msg string
+	err error
+}
+
+func (e *userDataError) Error() string {
+	return e.msg
+}
+
+func (e *userDataError) Unwrap() []error {
+	return []error{ErrUserAccess, e.err}
+}
+
 // Contains data about the resources the user is allowed to access.
 type UserData struct {
 	CsResources     []Resource            // Cluster-scoped resources on hub the user has list access.
 	NsResources     map[string][]Resource // Namespaced resources on hub the user has list access.
 	ManagedClusters map[string]struct{}   // Managed clusters where the user has view access.
+	// The user has view access to all managed clusters. ManagedClusters is empty when it's set.
+	ManagedClusterAllAccess bool
 }
 
 // Extend UserData with caching information.
@@ -40,7 +85,20 @@ type UserDataCache struct {
 	nsrCache      cacheMetadata
 
 	// Client to external API to be replaced with a mock by unit tests.
-	authzClient v1.AuthorizationV1Interface
+	authzClient     v1.AuthorizationV1Interface
+	authzClientKey  string // Identifies the user and rest config used to build authzClient.
+	authzClientLock sync.Mutex
+
+	lastUsedAt time.Time // Time when the user data was last requested. Protected by the cache usersLock.
+}
+
+// Builds the client impersonating the user. Defined as a variable so unit tests can count the clients created.
+var newImpersonationClientSet = func(restConfig *rest.Config) (v1.AuthorizationV1Interface, error) {
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	return clientset.AuthorizationV1(), nil
 }
 
 // Get user's UID
@@ -51,14 +109,20 @@ func (cache *Cache) GetUserUID(ctx context.Context) (string, authv1.UserInfo) {
 		clientToken := authKey.(string)
 
 		//get uid from tokenreview
-		if tokenReview, err := cache.GetTokenReview(ctx, clientToken); err == nil {
-			uid := tokenReview.Status.User.UID
-			klog.V(9).Info("Found uid: ", uid, " for user: ", tokenReview.Status.User.Username)
-			return uid, tokenReview.Status.User
-		} else {
-			klog.Error("Error finding uid for user: ", tokenReview.Status.User.Username, err)
+		tokenReview, err := cache.GetTokenReview(ctx, clientToken)
+		if err != nil {
+			klog.Error("Error finding uid for user. TokenReview failed: ", err)
+			return "noUidFound", authv1.UserInfo{}
+		}
+		// The TokenReview is missing or doesn't identify the user, for example if the token expired
+		// after it was validated. Don't resolve it as a user with an empty uid.
+		if tokenReview == nil || (tokenReview.Status.User.UID == "" && tokenReview.Status.User.Username == "") {
+			klog.Error("Error finding uid for user: the TokenReview doesn't identify the user.")
 			return "noUidFound", authv1.UserInfo{}
 		}
+		uid := tokenReview.Status.User.UID
+		klog.V(9).Info("Found uid: ", uid, " for user: ", tokenReview.Status.User.Username)
+		return uid, tokenReview.Status.User
 	} else {
 		klog.Error("Error finding uid for user: ContextAuthTokenKey IS NOT SET ")
 		return "noUidFound", authv1.UserInfo{}
@@ -67,16 +131,31 @@ func (cache *Cache) GetUserUID(ctx context.Context) (string, authv1.UserInfo) {
 
 func (cache *Cache) GetUserDataCache(ctx context.Context,
 	authzClient v1.AuthorizationV1Interface) (*UserDataCache, error) {
+	// get uid from tokenreview
+	uid, userInfo := cache.GetUserUID(ctx)
+	if uid == "noUidFound" {
+		return nil, fmt.Errorf("%w: cannot find user with uid: %s", ErrTokenReviewMissing, uid)
+	}
+	return cache.getUserDataCacheForUserInfo(ctx, userInfo, authzClient)
+}
+
+// Get the user data cached with the UID of the user info. The user info must be already validated,
+// the token review isn't used to identify the user.
+func (cache *Cache) getUserDataCacheForUserInfo(ctx context.Context, userInfo authv1.UserInfo,
+	authzClient v1.AuthorizationV1Interface) (*UserDataCache, error) {
+	ctx, span := tracing.Start(ctx, "rbac.GetUserData", attribute.String("enduser.id", userInfo.Username))
+	user, err := cache.resolveUserDataCache(ctx, userInfo, authzClient)
+	tracing.End(span, err)
+	return user, err
+}
+
+// Resolve the expired sections of the user data, or use the cached data if it's valid.
+func (cache *Cache) resolveUserDataCache(ctx context.Context, userInfo authv1.UserInfo,
+	authzClient v1.AuthorizationV1Interface) (*UserDataCache, error) {
 
 	var user *UserDataCache
-	var uid string
 	var err error
-	var userInfo authv1.UserInfo
-	// get uid from tokenreview
-	if uid, userInfo = cache.GetUserUID(ctx); uid == "noUidFound" {
-		return user, fmt.Errorf("cannot find user with uid: %s", uid)
-	}
-	clientToken := ctx.Value(ContextAuthTokenKey).(string)
+	uid := userCacheKey(userInfo)
 
 	cache.usersLock.Lock()
 	defer cache.usersLock.Unlock()
@@ -84,9 +163,22 @@ func (cache *Cache) GetUserDataCache(ctx context.Context,
 
 	// UserDataExists and its valid
 	if userDataExists && cachedUserData.isValid() {
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("rbac.cache_hit", true))
 		klog.V(5).Info("Using user data from cache.")
+		metrics.UserCacheHits.Inc()
+		cachedUserData.lastUsedAt = time.Now()
 
 		return cachedUserData, nil
+	}
+	metrics.UserCacheMisses.Inc()
+	cacheHit := false
+	if userDataExists && reflect.DeepEqual(cachedUserData.userInfo, userInfo) {
+		// Some sections expired. Keep the cached user to refresh only the expired sections.
+		// The user is recreated if the user info (for example the groups) changed, because the
+		// impersonation client uses the user info.
+		klog.V(5).Info("User data in cache is partially expired.")
+		user = cachedUserData
+		cacheHit = true
 	} else {
 		if cache.users == nil {
 			cache.users = map[string]*UserDataCache{}
@@ -94,23 +186,28 @@ func (cache *Cache) GetUserDataCache(ctx context.Context,
 		// User not in cache , Initialize and assign to the UID
 		user = &UserDataCache{
 			userInfo:      userInfo,
-			clustersCache: cacheMetadata{ttl: time.Duration(config.Cfg.UserCacheTTL) * time.Millisecond},
-			csrCache:      cacheMetadata{ttl: time.Duration(config.Cfg.UserCacheTTL) * time.Millisecond},
-			nsrCache:      cacheMetadata{ttl: time.Duration(config.Cfg.UserCacheTTL) * time.Millisecond},
+			clustersCache: cacheMetadata{ttl: time.Duration(config.Cfg.ManagedClusterCacheTTL) * time.Millisecond},
+			csrCache:      cacheMetadata{ttl: time.Duration(config.Cfg.ClusterScopedCacheTTL) * time.Millisecond},
+			nsrCache:      cacheMetadata{ttl: time.Duration(config.Cfg.NamespacedCacheTTL) * time.Millisecond},
 		}
-		if cache.users == nil {
-			cache.users = map[string]*UserDataCache{}
+		if !userDataExists {
+			cache.evictLeastRecentlyUsed()
 		}
 		cache.users[uid] = user
-
-		// We want to setup the client if passed, this is only for unit tests
-		if authzClient != nil {
-			user.authzClient = authzClient
-		}
+	}
+	user.lastUsedAt = time.Now()
+	// We want to setup the client if passed, this is only for unit tests
+	if authzClient != nil {
+		user.authzClient = authzClient
+	} else {
+		user.refreshImpersonationClientSet()
 	}
 
 	// Before checking each namespace and clusterscoped resource, check if user has access to everything
-	userHasAllAccess, err := user.userHasAllAccess(ctx, cache)
+	allAccessCtx, span := tracing.Start(ctx, "rbac.userHasAllAccess")
+	userHasAllAccess, err := user.userHasAllAccess(allAccessCtx, cache)
+	span.SetAttributes(attribute.Bool("rbac.all_access", userHasAllAccess))
+	tracing.End(span, err)
 	if err != nil {
 		klog.Warning("Encountered error while checking if user has access to everything ", err)
 	} else {
@@ -122,15 +219,59 @@ func (cache *Cache) GetUserDataCache(ctx context.Context,
 			userInfo.Username, userInfo.UID)
 	}
 
-	userDataCache, err := user.getNamespacedResources(cache, ctx, clientToken)
+	// Get namespaced and cluster scoped resource access for the user in parallel.
+	// Each section is updated under its own lock (nsrCache and csrCache), so a section that
+	// succeeds is cached even if the other fails. Only the expired sections are refreshed.
+	// The managed clusters are obtained from the namespaced resources requests, so both sections
+	// record the same refresh duration.
+	var nsErr, csErr error
+	wg := sync.WaitGroup{}
+	if !user.nsrCache.isValid() || !user.clustersCache.isValid() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			nsCtx, span := tracing.Start(ctx, "rbac.getNamespacedResources")
+			_, nsErr = user.getNamespacedResources(cache, nsCtx)
+			span.SetAttributes(attribute.Int("rbac.authorized_namespaces", len(user.NsResources)))
+			tracing.End(span, nsErr)
+			metrics.ObserveRefreshDuration(metrics.RBACNamespacedRefresh, cacheHit, start)
+			metrics.ObserveRefreshDuration(metrics.RBACManagedClusterRefresh, cacheHit, start)
+			if nsErr == nil {
+				klog.V(5).Info("No errors on namespacedresources present for: ", userInfo.Username)
+			}
+		}()
+	}
+	if !user.csrCache.isValid() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			csCtx, span := tracing.Start(ctx, "rbac.getClusterScopedResources")
+			_, csErr = user.getClusterScopedResources(csCtx, cache)
+			span.SetAttributes(attribute.Int("rbac.cluster_scoped_resources", len(user.CsResources)))
+			tracing.End(span, csErr)
+			metrics.ObserveRefreshDuration(metrics.RBACClusterScopedRefresh, cacheHit, start)
+		}()
+	}
+	wg.Wait()
+	userDataCache := user
 
-	// Get cluster scoped resource access for the user.
-	if err == nil {
-		klog.V(5).Info("No errors on namespacedresources present for: ",
-			cache.tokenReviews[clientToken].tokenReview.Status.User.Username)
-		userDataCache, err = user.getClusterScopedResources(ctx, cache)
+	if nsErr != nil {
+		nsErr = fmt.Errorf("%w: %w", ErrNamespacedFetch, nsErr)
+	}
+	if csErr != nil {
+		csErr = fmt.Errorf("%w: %w", ErrClusterScopedFetch, csErr)
 	}
-	return userDataCache, err
+	// When both sources fail we can't tell if the user has access to anything.
+	if nsErr != nil && csErr != nil {
+		return userDataCache, fmt.Errorf("%w. Namespaced resources error: %w. Cluster scoped resources error: %w",
+			ErrRBACUnavailable, nsErr, csErr)
+	}
+	if nsErr != nil {
+		return userDataCache, nsErr
+	}
+	return userDataCache, csErr
 }
 
 func (user *UserDataCache) userHasAllAccess(ctx context.Context, cache *Cache) (bool, error) {
@@ -138,10 +279,10 @@ func (user *UserDataCache) userHasAllAccess(ctx context.Context, cache *Cache) (
 	impersClientSet := user.getImpersonationClientSet()
 	if impersClientSet == nil {
 		klog.Warning(impersonationConfigCreationerror)
-		return false, errors.New(impersonationConfigCreationerror)
+		return false, ErrImpersonationSetup
 	}
 	//If we have a new set of authorized list for the user reset the previous one
-	if user.userAuthorizedListSSAR(ctx, impersClientSet, "list", "*", "*") {
+	if allAccess, _ := user.userAuthorizedListSSAR(ctx, impersClientSet, "list", "*", "*"); allAccess {
 		user.csrCache.lock.Lock()
 		defer user.csrCache.lock.Unlock()
 		user.CsResources = []Resource{{Apigroup: "*", Kind: "*"}}
@@ -154,15 +295,16 @@ func (user *UserDataCache) userHasAllAccess(ctx context.Context, cache *Cache) (
 
 		cache.shared.mcCache.lock.Lock()
 		defer cache.shared.mcCache.lock.Unlock()
-		user.ManagedClusters = map[string]struct{}{"*": {}}
+		user.ManagedClusters = map[string]struct{}{}
+		user.ManagedClusterAllAccess = true
 		user.clustersCache.updatedAt = time.Now()
 		user.csrCache.err, user.nsrCache.err, user.clustersCache.err = nil, nil, nil
 		klog.V(5).Infof("User %s with uid %s has access to all resources.",
 			user.userInfo.Username, user.userInfo.UID)
 
 		return true, nil
-	} else if user.userAuthorizedListSSAR(ctx, impersClientSet,
-		"get", "search.open-cluster-management.io", "searches/allManagedData") {
+	} else if allManagedData, _ := user.userAuthorizedListSSAR(ctx, impersClientSet,
+		"get", "search.open-cluster-management.io", "searches/allManagedData"); allManagedData {
 		// Added to handle global hub search case.
 		// Refer to documentation https://github.com/stolostron/search-v2-operator/wiki/Global-Search-User-Configuration
 		user.csrCache.lock.Lock()
@@ -177,7 +319,8 @@ func (user *UserDataCache) userHasAllAccess(ctx context.Context, cache *Cache) (
 
 		cache.shared.mcCache.lock.Lock()
 		defer cache.shared.mcCache.lock.Unlock()
-		user.ManagedClusters = map[string]struct{}{"*": {}}
+		user.ManagedClusters = map[string]struct{}{}
+		user.ManagedClusterAllAccess = true
 		user.clustersCache.updatedAt = time.Now()
 		user.csrCache.err, user.nsrCache.err, user.clustersCache.err = nil, nil, nil
 		klog.V(5).Infof("User %s with uid %s is authorized to search/allManagedData which gives access to all managed cluster resources.",
@@ -188,13 +331,39 @@ func (user *UserDataCache) userHasAllAccess(ctx context.Context, cache *Cache) (
 	return false, nil
 }
 
+// Key of the user in the cache. Some users don't have a uid, for example kube:admin, so the username is
+// used instead to avoid sharing the same cache entry between those users.
+func userCacheKey(userInfo authv1.UserInfo) string {
+	if userInfo.UID == "" {
+		return userInfo.Username
+	}
+	return userInfo.UID
+}
+
 // Get a static copy of the current user data. It will use cached data if valid or refresh if needed.
 func (cache *Cache) GetUserData(ctx context.Context) (UserData, error) {
-	userDataCache, userDataErr := cache.GetUserDataCache(ctx, nil)
+	return copyUserData(cache.GetUserDataCache(ctx, nil))
+}
+
+// Get a static copy of the user data for a user info already validated, for example by an authenticating
+// proxy. The data is cached with the user's UID (or username) and doesn't require a TokenReview for the request.
+func (cache *Cache) GetUserDataForUserInfo(ctx context.Context, userInfo authv1.UserInfo,
+	authzClient v1.AuthorizationV1Interface) (UserData, error) {
+	if userInfo.Username == "" {
+		return UserData{}, &userDataError{msg: ErrUserInfoMissing.Error(), err: ErrUserInfoMissing}
+	}
+	return copyUserData(cache.getUserDataCacheForUserInfo(ctx, userInfo, authzClient))
+}
 
+// Get a copy of the user data, or the error returned to the client.
+func copyUserData(userDataCache *UserDataCache, userDataErr error) (UserData, error) {
 	if userDataErr != nil {
 		klog.Error("Error fetching UserAccessData: ", userDataErr)
-		return UserData{}, errors.New("unable to resolve query because of error while resolving user's access")
+		if errors.Is(userDataErr, ErrRBACUnavailable) {
+			return UserData{}, &userDataError{msg: ErrRBACUnavailable.Error(), err: userDataErr}
+		}
+		return UserData{}, &userDataError{
+			msg: "unable to resolve query because of error while resolving user's access", err: userDataErr}
 	}
 	// Proceed if user's rbac data exists
 	// Get a copy of the current user access if user data exists
@@ -202,6 +371,8 @@ func (cache *Cache) GetUserData(ctx context.Context) (UserData, error) {
 		CsResources:     userDataCache.GetCsResourcesCopy(),
 		NsResources:     userDataCache.GetNsResourcesCopy(),
 		ManagedClusters: userDataCache.GetManagedClustersCopy(),
+
+		ManagedClusterAllAccess: userDataCache.GetManagedClusterAllAccess(),
 	}
 	return userAccess, nil
 }
@@ -223,12 +394,13 @@ func (user *UserDataCache) getClusterScopedResources(ctx context.Context, cache
 	// Not present in cache, find all cluster scoped resources
 	clusterScopedResources := cache.shared.csResourcesMap
 	if len(clusterScopedResources) == 0 {
+		user.csrCache.err = cache.shared.csrCache.err
 		klog.Warning("Cluster scoped resources from shared cache empty.", user.csrCache.err)
 		return user, user.csrCache.err
 	}
 	impersClientSet := user.getImpersonationClientSet()
 	if impersClientSet == nil {
-		user.csrCache.err = errors.New(impersonationConfigCreationerror)
+		user.csrCache.err = ErrImpersonationSetup
 		klog.Warning(impersonationConfigCreationerror)
 		return user, user.csrCache.err
 	}
@@ -239,13 +411,19 @@ func (user *UserDataCache) getClusterScopedResources(ctx context.Context, cache
 	// Paralellize SSAR API calls.
 	wg := sync.WaitGroup{}
 	lock := sync.Mutex{}
+	failed := 0
+	var lastErr error
 	for res := range clusterScopedResources {
 		wg.Add(1)
 		go func(group, kind string) {
 			defer wg.Done()
-			if user.userAuthorizedListSSAR(ctx, impersClientSet, "list", group, kind) {
-				lock.Lock()
-				defer lock.Unlock()
+			allowed, err := user.userAuthorizedListSSAR(ctx, impersClientSet, "list", group, kind)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				failed++
+				lastErr = err
+			} else if allowed {
 				user.CsResources = append(user.CsResources,
 					Resource{Apigroup: group, Kind: kind})
 			}
@@ -253,15 +431,20 @@ func (user *UserDataCache) getClusterScopedResources(ctx context.Context, cache
 	}
 	wg.Wait() // Wait for all requests to complete.
 
-	uid, userInfo := cache.GetUserUID(ctx)
-	klog.V(7).Infof("User %s with uid: %s has access to these cluster scoped res: %+v \n", userInfo.Username, uid,
-		user.CsResources)
+	// Only fail if none of the requests succeeded, otherwise use the partial result.
+	if failed == len(clusterScopedResources) {
+		user.csrCache.err = fmt.Errorf("all SelfSubjectAccessReviews for cluster scoped resources failed: %w", lastErr)
+		return user, user.csrCache.err
+	}
+
+	klog.V(7).Infof("User %s with uid: %s has access to these cluster scoped res: %+v \n", user.userInfo.Username,
+		user.userInfo.UID, user.CsResources)
 	user.csrCache.updatedAt = time.Now()
 	return user, user.csrCache.err
 }
 
 func (user *UserDataCache) userAuthorizedListSSAR(ctx context.Context, authzClient v1.AuthorizationV1Interface,
-	verb string, apigroup string, kindPlural string) bool {
+	verb string, apigroup string, kindPlural string) (bool, error) {
 	accessCheck := &authz.SelfSubjectAccessReview{
 		Spec: authz.SelfSubjectAccessReviewSpec{
 			ResourceAttributes: &authz.ResourceAttributes{
@@ -271,24 +454,75 @@ func (user *UserDataCache) userAuthorizedListSSAR(ctx context.Context, authzClie
 			},
 		},
 	}
-	result, err := authzClient.SelfSubjectAccessReviews().Create(ctx, accessCheck, metav1.CreateOptions{})
+	var result *authz.SelfSubjectAccessReview
+	err := retryKubeRequest(ctx, "SelfSubjectAccessReview", func() (createErr error) {
+		result, createErr = authzClient.SelfSubjectAccessReviews().Create(ctx, accessCheck, metav1.CreateOptions{})
+		return createErr
+	})
 
 	if err != nil {
 		klog.Error("Error creating SelfSubjectAccessReviews.", err)
-	} else {
+		return false, err
+	}
+	if klog.V(6).Enabled() && ssarLogSampler.sample() {
 		klog.V(6).Infof("SelfSubjectAccessReviews API result for resource %s group %s : %v\n",
 			kindPlural, apigroup, prettyPrint(result.Status.String()))
-		if result.Status.Allowed {
+	}
+	return result.Status.Allowed, nil
+
+}
+
+// Returns true if the user can list ManagedClusters at cluster scope, which gives access to all the
+// managed clusters. Returns false if the request fails, so the managed clusters are obtained from
+// the rules in each namespace.
+// Equivalent to: oc auth can-i list managedclusters.cluster.open-cluster-management.io --as=<user>
+func (user *UserDataCache) userHasAllManagedClusters(ctx context.Context) bool {
+	impersClientSet := user.getImpersonationClientSet()
+	if impersClientSet == nil {
+		return false
+	}
+	allowed, _ := user.userAuthorizedListSSAR(ctx, impersClientSet, "list", "cluster.open-cluster-management.io",
+		"managedclusters")
+	if allowed {
+		klog.V(5).Infof("User %s with uid %s has access to all managed clusters.",
+			user.userInfo.Username, user.userInfo.UID)
+	}
+	return allowed
+}
+
+// Returns true if any rule allows to list all resources in all apigroups, for example
+// {Verbs:["*"], APIGroups:["*"], Resources:["*"]}. Rules restricted to resource names are ignored.
+func hasWildcardRule(rules []authz.ResourceRule) bool {
+	for _, rule := range rules {
+		if len(rule.ResourceNames) > 0 && rule.ResourceNames[0] != "*" {
+			continue
+		}
+		if containsString(rule.Verbs, "list", "*") && containsString(rule.APIGroups, "*") &&
+			containsString(rule.Resources, "*") {
 			return true
 		}
 	}
 	return false
+}
 
+// Returns true if the list contains any of the values.
+func containsString(list []string, values ...string) bool {
+	for _, item := range list {
+		for _, value := range values {
+			if item == value {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (user *UserDataCache) updateUserManagedClusterList(cache *Cache, ns string) {
 	user.clustersCache.lock.Lock()
 	defer user.clustersCache.lock.Unlock()
+	if user.ManagedClusterAllAccess {
+		return // User has access to all managed clusters.
+	}
 	_, managedClusterNs := cache.shared.managedClusters[ns]
 	if managedClusterNs {
 		if user.ManagedClusters == nil {
@@ -301,23 +535,51 @@ func (user *UserDataCache) updateUserManagedClusterList(cache *Cache, ns string)
 
 // Request the SelfSubjectRullesRreview(SSRR) for the namespace and process the rules.
 func (user *UserDataCache) getSSRRforNamespace(ctx context.Context, cache *Cache, ns string,
-	lock *sync.Mutex) {
+	lock *sync.Mutex) error {
 	// Request the SelfSubjectRulesReview for the namespace.
 	rulesCheck := authz.SelfSubjectRulesReview{
 		Spec: authz.SelfSubjectRulesReviewSpec{
 			Namespace: ns,
 		},
 	}
-	result, err := user.getImpersonationClientSet().SelfSubjectRulesReviews().Create(ctx,
-		&rulesCheck, metav1.CreateOptions{})
+	impersClientSet := user.getImpersonationClientSet()
+	if impersClientSet == nil {
+		klog.Warning(impersonationConfigCreationerror)
+		return ErrImpersonationSetup
+	}
+	var result *authz.SelfSubjectRulesReview
+	err := retryKubeRequest(ctx, "SelfSubjectRulesReview", func() (createErr error) {
+		result, createErr = impersClientSet.SelfSubjectRulesReviews().Create(ctx, &rulesCheck, metav1.CreateOptions{})
+		return createErr
+	})
 	if err != nil {
 		klog.Error("Error creating SelfSubjectRulesReviews for namespace", err, ns)
-	} else {
+		return err
+	}
+	if klog.V(9).Enabled() && ssrrLogSampler.sample() {
 		klog.V(9).Infof("SelfSubjectRulesReviews Kube API result for ns:%s : %v\n", ns, prettyPrint(result.Status))
 	}
 
+	if len(result.Status.NonResourceRules) > 0 && klog.V(6).Enabled() && ssrrLogSampler.sample() {
+		klog.V(6).Infof("Excluding %d non-resource rules for namespace %s from ns scoped resources.",
+			len(result.Status.NonResourceRules), ns)
+	}
+
 	lock.Lock()
 	defer lock.Unlock()
+	// If the user has access to all resources, save a single wildcard instead of expanding the rules.
+	// The resolver doesn't need a filter for the namespace when it finds the wildcard.
+	if hasWildcardRule(result.Status.ResourceRules) {
+		user.NsResources[ns] = []Resource{{Apigroup: "*", Kind: "*"}}
+		if klog.V(5).Enabled() && ssrrLogSampler.sample() {
+			klog.V(5).Infof("User %s with uid: %s has access to everything in the namespace %s",
+				user.userInfo.Username, user.userInfo.UID, ns)
+		}
+
+		// Update user's managedcluster list too as the user has access to everything
+		user.updateUserManagedClusterList(cache, ns)
+		return nil
+	}
 	// Keep track of processed resources (apigroup + kind). Used to remove duplicates.
 	trackResources := map[Resource]struct{}{}
 	// Process the SSRR result and add to this UserDataCache object.
@@ -330,18 +592,6 @@ func (user *UserDataCache) getSSRRforNamespace(ctx context.Context, cache *Cache
 						// fail-safe mechanism to avoid whitelist - TODO: incorporate whitelist
 						if !cache.shared.isClusterScoped(res, api) && (len(rules.ResourceNames) == 0 ||
 							(len(rules.ResourceNames) > 0 && rules.ResourceNames[0] == "*")) {
-							// if the user has access to all resources, reset userData.NsResources for the namespace
-							// No need to loop through all resources. Save the wildcard *
-							// exit the resourceRulesLoop
-							if res == "*" && api == "*" {
-								user.NsResources[ns] = []Resource{{Apigroup: api, Kind: res}}
-								klog.V(5).Infof("User %s with uid: %s has access to everything in the namespace %s",
-									user.userInfo.Username, user.userInfo.UID, ns)
-
-								// Update user's managedcluster list too as the user has access to everything
-								user.updateUserManagedClusterList(cache, ns)
-								return
-							}
 							currRes := Resource{Apigroup: api, Kind: res}
 							//to avoid duplicates, check before appending to nsResources
 							if _, found := trackResources[currRes]; !found {
@@ -350,11 +600,15 @@ func (user *UserDataCache) getSSRRforNamespace(ctx context.Context, cache *Cache
 							}
 
 						} else if cache.shared.isClusterScoped(res, api) {
-							klog.V(6).Info("Got clusterscoped resource ", api, "/",
-								res, " from SelfSubjectRulesReviews. Excluding it from ns scoped resoures.")
+							if klog.V(6).Enabled() && rulesLogSampler.sample() {
+								klog.V(6).Info("Got clusterscoped resource ", api, "/",
+									res, " from SelfSubjectRulesReviews. Excluding it from ns scoped resoures.")
+							}
 						} else if len(rules.ResourceNames) > 0 && rules.ResourceNames[0] != "*" {
-							klog.V(5).Info("Got whitelist in resourcenames. Excluding resource", api, "/", res,
-								" from ns scoped resoures.")
+							if klog.V(5).Enabled() && rulesLogSampler.sample() {
+								klog.V(5).Info("Got whitelist in resourcenames. Excluding resource", api, "/", res,
+									" from ns scoped resoures.")
+							}
 						}
 					}
 				}
@@ -382,11 +636,40 @@ func (user *UserDataCache) getSSRRforNamespace(ctx context.Context, cache *Cache
 			return resAApiGrp < resBApiGrp
 		}
 	})
+	return nil
 }
 
 // Equivalent to: oc auth can-i --list -n <iterate-each-namespace>
-func (user *UserDataCache) getNamespacedResources(cache *Cache, ctx context.Context,
-	clientToken string) (*UserDataCache, error) {
+// Removes the namespaces matching EXCLUDED_NAMESPACES, so the SelfSubjectRulesReview isn't requested for them.
+// Glob patterns don't apply to the managed cluster namespaces, these are only excluded when listed by name.
+func (shared *SharedData) excludeNamespaces(namespaces []string) []string {
+	if len(config.Cfg.ExcludedNamespaces) == 0 {
+		return namespaces
+	}
+	included := make([]string, 0, len(namespaces))
+	for _, ns := range namespaces {
+		if !shared.isExcludedNamespace(ns) {
+			included = append(included, ns)
+		}
+	}
+	klog.V(5).Infof("Excluded %d namespaces from the RBAC namespace scan.", len(namespaces)-len(included))
+	return included
+}
+
+func (shared *SharedData) isExcludedNamespace(ns string) bool {
+	_, managedClusterNs := shared.managedClusters[ns]
+	for _, pattern := range config.Cfg.ExcludedNamespaces {
+		if pattern == ns {
+			return true
+		}
+		if matched, _ := path.Match(pattern, ns); matched && !managedClusterNs {
+			return true
+		}
+	}
+	return false
+}
+
+func (user *UserDataCache) getNamespacedResources(cache *Cache, ctx context.Context) (*UserDataCache, error) {
 	defer metrics.SlowLog("UserDataCache::getNamespacedResources", 250*time.Millisecond)()
 
 	// Lock the cache
@@ -398,32 +681,79 @@ func (user *UserDataCache) getNamespacedResources(cache *Cache, ctx context.Cont
 	user.NsResources = make(map[string][]Resource)
 	user.clustersCache.err = nil
 	user.ManagedClusters = make(map[string]struct{})
+	user.ManagedClusterAllAccess = false
 
 	// get all namespaces from shared cache
 	klog.V(5).Info("Getting namespaces from shared cache.")
 	allNamespaces, err := cache.shared.getNamespaces(ctx)
 	if err != nil || len(allNamespaces) == 0 {
 		klog.Warning("All namespaces array from shared cache is empty.", cache.shared.nsCache.err)
-		return user, cache.shared.nsCache.err
+		user.nsrCache.err = cache.shared.nsCache.err
+		return user, user.nsrCache.err
+	}
+	allNamespaces = cache.shared.excludeNamespaces(allNamespaces)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("rbac.namespaces", len(allNamespaces)))
+
+	// Skip the managed clusters from each namespace when the user has access to all of them.
+	if user.userHasAllManagedClusters(ctx) {
+		user.ManagedClusterAllAccess = true
 	}
 
 	// Process each namespace SSRR in an async go routine.
+	// The number of parallel requests is limited by RBAC_CONCURRENCY to avoid overwhelming the Kube API.
 	wg := sync.WaitGroup{}
 	lock := sync.Mutex{}
+	errLock := sync.Mutex{}
+	failed := 0
+	var lastErr error
+	workers := make(chan struct{}, config.Cfg.RBACConcurrency)
+processNamespaces:
 	for _, ns := range allNamespaces {
+		// Wait for a worker. Stop sending requests if the client disconnected or the request deadline elapsed.
+		select {
+		case <-ctx.Done():
+			break processNamespaces
+		case workers <- struct{}{}:
+		}
 		wg.Add(1)
 		go func(namespace string) {
 			defer wg.Done()
-			user.getSSRRforNamespace(ctx, cache, namespace, &lock)
+			defer func() { <-workers }()
+			if ctx.Err() != nil {
+				return
+			}
+			if err := user.getSSRRforNamespace(ctx, cache, namespace, &lock); err != nil {
+				errLock.Lock()
+				defer errLock.Unlock()
+				failed++
+				lastErr = err
+			}
 		}(ns)
 	}
 	wg.Wait() // Wait for all go routines to complete.
 
-	uid, userInfo := cache.GetUserUID(ctx)
-	klog.V(7).Infof("User %s with uid: %s has access to these namespace scoped res: %+v \n", userInfo.Username, uid,
-		user.NsResources)
-	klog.V(7).Infof("User %s with uid: %s has access to these ManagedClusters: %+v \n", userInfo.Username, uid,
-		user.ManagedClusters)
+	// Keep the namespaces already processed, but don't update the timestamp so the partial
+	// result isn't used as a valid cache entry.
+	if ctx.Err() != nil {
+		klog.Warningf("Stopped processing namespaces for user %s. Error: %v", user.userInfo.Username, ctx.Err())
+		user.nsrCache.err = ctx.Err()
+		return user, user.nsrCache.err
+	}
+
+	// Only fail if none of the requests succeeded, otherwise use the partial result.
+	if failed > 0 && failed == len(allNamespaces) {
+		user.nsrCache.err = fmt.Errorf("all SelfSubjectRulesReviews for namespaces failed: %w", lastErr)
+		return user, user.nsrCache.err
+	}
+
+	// Log only the counts to avoid writing the user's authorization details to the logs.
+	resourceCount := 0
+	for _, resources := range user.NsResources {
+		resourceCount += len(resources)
+	}
+	klog.V(6).Infof("User %s with uid: %s has access to %d namespace scoped resources in %d namespaces"+
+		" and %d ManagedClusters.", user.userInfo.Username, user.userInfo.UID, resourceCount, len(user.NsResources),
+		len(user.ManagedClusters))
 
 	user.nsrCache.updatedAt = time.Now()
 	user.clustersCache.updatedAt = time.Now()
@@ -454,28 +784,68 @@ func setImpersonationUserInfo(userInfo authv1.UserInfo) *rest.ImpersonationConfi
 		}
 		impersonConfig.Extra = extraUpdated //set additional information
 	}
-	klog.V(9).Info("UserInfo available for impersonation is %+v:", userInfo)
+	klog.V(9).Infof("UserInfo available for impersonation is %+v", userInfo)
 	return impersonConfig
 }
 
 // Get a client impersonating the user.
+// The client is built once and reused by all the requests to refresh the user's data.
 func (user *UserDataCache) getImpersonationClientSet() v1.AuthorizationV1Interface {
+	user.authzClientLock.Lock()
+	defer user.authzClientLock.Unlock()
 	if user.authzClient == nil {
-		klog.V(5).Info("Creating New ImpersonationClientSet. ")
-		restConfig := config.GetClientConfig()
-
-		// set Impersonation user info
-		restConfig.Impersonate = *setImpersonationUserInfo(user.userInfo)
-		clientset, err := kubernetes.NewForConfig(restConfig)
-		if err != nil {
-			klog.Error("Error with creating a new clientset with impersonation config.", err.Error())
-			return nil
-		}
-		user.authzClient = clientset.AuthorizationV1()
+		user.buildImpersonationClientSet(config.GetClientConfig())
 	}
 	return user.authzClient
 }
 
+// Rebuild the impersonation client only if the rest config changed since the client was built.
+// Called once before refreshing the user's data instead of with every request to the Kube API.
+func (user *UserDataCache) refreshImpersonationClientSet() {
+	user.authzClientLock.Lock()
+	defer user.authzClientLock.Unlock()
+	if user.authzClient != nil && user.authzClientKey == "" {
+		return // Client was set by the unit tests.
+	}
+	restConfig := config.GetClientConfig()
+	if user.authzClient != nil && user.authzClientKey == impersonationClientKey(user.userInfo, restConfig) {
+		return
+	}
+	user.buildImpersonationClientSet(restConfig)
+}
+
+// Build the impersonation client. Must be called while holding the authzClientLock.
+func (user *UserDataCache) buildImpersonationClientSet(restConfig *rest.Config) {
+	klog.V(5).Info("Creating New ImpersonationClientSet. ")
+	key := impersonationClientKey(user.userInfo, restConfig)
+
+	// set Impersonation user info
+	restConfig.Impersonate = *setImpersonationUserInfo(user.userInfo)
+	client, err := newImpersonationClientSet(restConfig)
+	if err != nil {
+		klog.Error("Error with creating a new clientset with impersonation config.", err.Error())
+		user.authzClient = nil
+		user.authzClientKey = ""
+		return
+	}
+	user.authzClient = client
+	user.authzClientKey = key
+}
+
+// Key to identify the impersonation client. Changes when the username, the UID or
+// the connection settings of the rest config change.
+func impersonationClientKey(userInfo authv1.UserInfo, restConfig *rest.Config) string {
+	hash := sha256.New()
+	for _, value := range []string{userInfo.Username, userInfo.UID, restConfig.Host, restConfig.APIPath,
+		restConfig.BearerToken, restConfig.BearerTokenFile, restConfig.ServerName,
+		restConfig.CAFile, string(restConfig.CAData), restConfig.CertFile, string(restConfig.CertData),
+		restConfig.KeyFile, string(restConfig.KeyData)} {
+		hash.Write([]byte(value))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
 func (user *UserDataCache) GetCsResourcesCopy() []Resource {
 	user.csrCache.lock.Lock()
 	defer user.csrCache.lock.Unlock()
@@ -502,3 +872,10 @@ func (user *UserDataCache) GetManagedClustersCopy() map[string]struct{} {
 	}
 	return mcCopy
 }
+
+// Returns true if the user has view access to all managed clusters.
+func (user *UserDataCache) GetManagedClusterAllAccess() bool {
+	user.clustersCache.lock.Lock()
+	defer user.clustersCache.lock.Unlock()
+	return user.ManagedClusterAllAccess
+}
@@ -1,22 +1,39 @@
 package rbac
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"flag"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/stolostron/search-v2-api/pkg/config"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	authv1 "k8s.io/api/authentication/v1"
 	authz "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	fake "k8s.io/client-go/kubernetes/fake"
 
+	v1 "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/client-go/rest"
 	testingk8s "k8s.io/client-go/testing"
+	"k8s.io/klog/v2"
 )
 
 // Initialize cache object to use tests.
@@ -35,11 +52,12 @@ func setupToken(cache *Cache) *Cache {
 	if cache.tokenReviews == nil {
 		cache.tokenReviews = map[string]*tokenReviewCache{}
 	}
-	cache.tokenReviews["123456"] = &tokenReviewCache{
+	cache.tokenReviews[tokenReviewKey("123456")] = &tokenReviewCache{
 		meta:       cacheMetadata{updatedAt: time.Now()},
 		authClient: fake.NewSimpleClientset().AuthenticationV1(),
 		tokenReview: &authv1.TokenReview{
 			Status: authv1.TokenReviewStatus{
+				Authenticated: true,
 				User: authv1.UserInfo{
 					UID: "unique-user-id",
 				},
@@ -675,6 +693,144 @@ func Test_getImpersonationClientSet(t *testing.T) {
 	assert.NotNil(t, clientSet)
 }
 
+// Transport to count the clients built and the requests sent with each client.
+type countingTransport struct {
+	rt       http.RoundTripper
+	requests *int32
+}
+
+func (c countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(c.requests, 1)
+	return c.rt.RoundTrip(req)
+}
+
+// Start a Kube API server that allows all SelfSubjectAccessReviews and write a kubeconfig for it.
+func mockKubeAPIServer(t *testing.T, kubeconfigPath string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"kind":"SelfSubjectAccessReview","apiVersion":"authorization.k8s.io/v1",` +
+			`"status":{"allowed":true}}`))
+	}))
+	t.Cleanup(server.Close)
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: %s
+  name: mock
+contexts:
+- context:
+    cluster: mock
+    user: mock
+  name: mock
+current-context: mock
+users:
+- name: mock
+  user:
+    token: mock-token
+`, server.URL)
+	if err := os.WriteFile(kubeconfigPath, []byte(kubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_getImpersonationClientSet_Reused(t *testing.T) {
+	kubeconfigPath := filepath.Join(t.TempDir(), "kubeconfig")
+	mockKubeAPIServer(t, kubeconfigPath)
+	t.Setenv("KUBECONFIG", kubeconfigPath)
+
+	var transports, requests int32
+	newClientSet := newImpersonationClientSet
+	defer func() { newImpersonationClientSet = newClientSet }()
+	newImpersonationClientSet = func(restConfig *rest.Config) (v1.AuthorizationV1Interface, error) {
+		restConfig.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			atomic.AddInt32(&transports, 1)
+			return countingTransport{rt: rt, requests: &requests}
+		})
+		return newClientSet(restConfig)
+	}
+
+	user := &UserDataCache{userInfo: authv1.UserInfo{Username: "user-a", UID: "uid-a"}}
+	for i := 0; i < 3; i++ {
+		user.refreshImpersonationClientSet()
+		allowed, err := user.userAuthorizedListSSAR(context.TODO(), user.getImpersonationClientSet(), "list", "", "pods")
+		assert.Nil(t, err)
+		assert.True(t, allowed)
+	}
+	// The client is built once and reused by all the requests.
+	assert.Equal(t, int32(1), atomic.LoadInt32(&transports))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+
+	// Another user gets its own client.
+	otherUser := &UserDataCache{userInfo: authv1.UserInfo{Username: "user-b", UID: "uid-b"}}
+	otherUser.refreshImpersonationClientSet()
+	assert.NotNil(t, otherUser.getImpersonationClientSet())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&transports))
+
+	// The client is rebuilt when the rest config changes.
+	mockKubeAPIServer(t, kubeconfigPath)
+	user.refreshImpersonationClientSet()
+	allowed, err := user.userAuthorizedListSSAR(context.TODO(), user.getImpersonationClientSet(), "list", "", "pods")
+	assert.Nil(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&transports))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&requests))
+}
+
+func Test_refreshImpersonationClientSet_KeepsMockClient(t *testing.T) {
+	fs := fake.NewSimpleClientset()
+	user := &UserDataCache{authzClient: fs.AuthorizationV1()}
+	user.refreshImpersonationClientSet()
+	assert.Equal(t, fs.AuthorizationV1(), user.getImpersonationClientSet())
+}
+
+// Mock the SelfSubjectRulesReview with the rules and get the namespaced resources for the namespace.
+func getSSRRforNamespaceWithRules(t *testing.T, rules []authz.ResourceRule) *UserDataCache {
+	mock_cache := mockNamespaceCache()
+	mock_cache.shared.managedClusters = map[string]struct{}{"ns1": {}}
+	fs := fake.NewSimpleClientset()
+	fs.PrependReactor("create", "selfsubjectrulesreviews", func(action testingk8s.Action) (bool, runtime.Object, error) {
+		return true, &authz.SelfSubjectRulesReview{Status: authz.SubjectRulesReviewStatus{ResourceRules: rules}}, nil
+	})
+	user := &UserDataCache{
+		UserData:    UserData{NsResources: map[string][]Resource{}},
+		authzClient: fs.AuthorizationV1(),
+	}
+	err := user.getSSRRforNamespace(context.TODO(), mock_cache, "ns1", &sync.Mutex{})
+	assert.Nil(t, err)
+	return user
+}
+
+func Test_getSSRRforNamespace_WildcardRule(t *testing.T) {
+	user := getSSRRforNamespaceWithRules(t, []authz.ResourceRule{
+		{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+	})
+
+	assert.Equal(t, []Resource{{Apigroup: "*", Kind: "*"}}, user.NsResources["ns1"])
+	assert.Contains(t, user.ManagedClusters, "ns1")
+}
+
+func Test_getSSRRforNamespace_MixedWildcardRules(t *testing.T) {
+	// The wildcard rule replaces the specific rules, even if the specific rules are first.
+	user := getSSRRforNamespaceWithRules(t, []authz.ResourceRule{
+		{Verbs: []string{"list"}, APIGroups: []string{""}, Resources: []string{"pods", "configmaps"}},
+		{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+		{Verbs: []string{"get", "list"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}},
+	})
+	assert.Equal(t, []Resource{{Apigroup: "*", Kind: "*"}}, user.NsResources["ns1"])
+
+	// Partial wildcards and wildcards restricted to resource names are expanded as specific rules.
+	user = getSSRRforNamespaceWithRules(t, []authz.ResourceRule{
+		{Verbs: []string{"list"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+		{Verbs: []string{"*"}, APIGroups: []string{"apps"}, Resources: []string{"*"}},
+		{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}, ResourceNames: []string{"name1"}},
+		{Verbs: []string{"get"}, APIGroups: []string{"*"}, Resources: []string{"*"}},
+	})
+	assert.Equal(t, []Resource{{Apigroup: "apps", Kind: "*"}, {Apigroup: "", Kind: "pods"}},
+		user.NsResources["ns1"])
+	assert.NotContains(t, user.ManagedClusters, "ns1")
+}
+
 func Test_hasAccessToAllResourcesInNamespace(t *testing.T) {
 	mock_cache := mockNamespaceCache()
 	mock_cache = setupToken(mock_cache)
@@ -763,9 +919,7 @@ func Test_hasAccessToAllResources(t *testing.T) {
 		t.Errorf("Cache does not have expected cluster-scoped resources ")
 
 	}
-	_, mcPresent := result.ManagedClusters["*"]
-	if len(result.ManagedClusters) != 1 ||
-		!mcPresent {
+	if len(result.ManagedClusters) != 0 || !result.ManagedClusterAllAccess {
 		t.Errorf("Cache does not have expected managed cluster resources ")
 
 	}
@@ -823,8 +977,7 @@ func Test_SearchUserAccessToResources(t *testing.T) {
 	if len(result.CsResources) != 0 {
 		t.Errorf("Cache does not have expected cluster-scoped resources ")
 	}
-	_, mcPresent := result.ManagedClusters["*"]
-	if len(result.ManagedClusters) != 1 || !mcPresent {
+	if len(result.ManagedClusters) != 0 || !result.ManagedClusterAllAccess {
 		t.Errorf("Cache does not have expected managed cluster resources ")
 	}
 	if err != nil {
@@ -852,3 +1005,817 @@ func Test_updateUserManagedClusterList(t *testing.T) {
 	}
 	assert.Equal(t, len(managedclusters), len(udc.ManagedClusters))
 }
+
+// Mock the SSAR and SSRR requests. Fails the requests when the corresponding error is set.
+func mockAuthzClientset(t *testing.T, ssarErr, ssrrErr error) *fake.Clientset {
+	fs := fake.NewSimpleClientset()
+	fs.PrependReactor("create", "selfsubjectaccessreviews", func(action testingk8s.Action) (bool, runtime.Object, error) {
+		if ssarErr != nil {
+			return true, nil, ssarErr
+		}
+		ssar, ok := action.(testingk8s.CreateAction).GetObject().(*authz.SelfSubjectAccessReview)
+		if !ok {
+			t.Error("Unexpected Error - expecting object with type *v1.SelfSubjectAccessReview")
+			return true, nil, fmt.Errorf("unexpected object")
+		}
+		// Mimic user has Authorization to list nodes only.
+		ssar.Status.Allowed = ssar.Spec.ResourceAttributes.Resource == "nodes"
+		return true, ssar, nil
+	})
+	fs.PrependReactor("create", "selfsubjectrulesreviews", func(action testingk8s.Action) (bool, runtime.Object, error) {
+		if ssrrErr != nil {
+			return true, nil, ssrrErr
+		}
+		ssrr := action.(testingk8s.CreateAction).GetObject().(*authz.SelfSubjectRulesReview)
+		ssrr.Status.ResourceRules = []authz.ResourceRule{
+			{Verbs: []string{"list"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+		}
+		return true, ssrr, nil
+	})
+	return fs
+}
+
+func mockCacheForRBACSources() *Cache {
+	mock_cache := mockNamespaceCache()
+	mock_cache = setupToken(mock_cache)
+	mock_cache.shared.namespaces = []string{"ns1"}
+	mock_cache.shared.nsCache.updatedAt = time.Now()
+	return addCSResources(mock_cache, []Resource{{Apigroup: "", Kind: "nodes"}})
+}
+
+func Test_GetUserDataCache_AllRBACSourcesFail(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+	fs := mockAuthzClientset(t, errors.New("ssar unavailable"), errors.New("ssrr unavailable"))
+
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	_, err := mock_cache.GetUserDataCache(ctx, fs.AuthorizationV1())
+
+	assert.ErrorIs(t, err, ErrRBACUnavailable)
+	assert.ErrorIs(t, err, ErrNamespacedFetch)
+	assert.ErrorIs(t, err, ErrClusterScopedFetch)
+	assert.ErrorContains(t, err, "ssar unavailable")
+	assert.ErrorContains(t, err, "ssrr unavailable")
+	assert.False(t, mock_cache.users["unique-user-id"].isValid(), "Expected failed user data to be refreshed.")
+
+	// GetUserData should return the typed error without the details.
+	_, err = mock_cache.GetUserData(ctx)
+	assert.ErrorIs(t, err, ErrRBACUnavailable)
+	assert.EqualError(t, err, ErrRBACUnavailable.Error())
+}
+
+func Test_GetUserDataCache_NamespacedResourcesFail(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+	fs := mockAuthzClientset(t, nil, errors.New("ssrr unavailable"))
+
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	result, err := mock_cache.GetUserDataCache(ctx, fs.AuthorizationV1())
+
+	assert.ErrorContains(t, err, "ssrr unavailable")
+	assert.ErrorIs(t, err, ErrNamespacedFetch)
+	assert.NotErrorIs(t, err, ErrClusterScopedFetch)
+	assert.NotErrorIs(t, err, ErrRBACUnavailable)
+	assert.Equal(t, []Resource{{Apigroup: "", Kind: "nodes"}}, result.CsResources)
+}
+
+func Test_GetUserDataCache_ClusterScopedResourcesFail(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+	fs := mockAuthzClientset(t, errors.New("ssar unavailable"), nil)
+
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	result, err := mock_cache.GetUserDataCache(ctx, fs.AuthorizationV1())
+
+	assert.ErrorContains(t, err, "ssar unavailable")
+	assert.ErrorIs(t, err, ErrClusterScopedFetch)
+	assert.NotErrorIs(t, err, ErrNamespacedFetch)
+	assert.NotErrorIs(t, err, ErrRBACUnavailable)
+	assert.Equal(t, map[string][]Resource{"ns1": {{Apigroup: "", Kind: "pods"}}}, result.NsResources)
+
+	// GetUserData hides the details, but keeps the failure.
+	_, err = mock_cache.GetUserData(ctx)
+	assert.ErrorIs(t, err, ErrClusterScopedFetch)
+	assert.EqualError(t, err, "unable to resolve query because of error while resolving user's access")
+}
+
+func Test_GetUserDataCache_TokenReviewMissing(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+
+	// Context without the auth token.
+	_, err := mock_cache.GetUserDataCache(context.Background(), nil)
+
+	assert.ErrorIs(t, err, ErrTokenReviewMissing)
+	assert.NotErrorIs(t, err, ErrRBACUnavailable)
+
+	_, err = mock_cache.GetUserData(context.Background())
+	assert.ErrorIs(t, err, ErrTokenReviewMissing)
+	assert.Empty(t, mock_cache.users, "Expected the user data not to be cached without a user.")
+}
+
+// Should return ErrTokenReviewMissing instead of panicking when the token doesn't have a reviewed user.
+func Test_GetUserData_TokenNotReviewed(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+	// Entry without a TokenReview, and a TokenReview that doesn't identify the user.
+	mock_cache.tokenReviews[tokenReviewKey("not-reviewed")] = &tokenReviewCache{meta: cacheMetadata{updatedAt: time.Now()}}
+	mock_cache.tokenReviews[tokenReviewKey("not-authenticated")] = &tokenReviewCache{
+		meta:        cacheMetadata{updatedAt: time.Now()},
+		tokenReview: &authv1.TokenReview{Status: authv1.TokenReviewStatus{Authenticated: false}},
+	}
+
+	for _, token := range []string{"not-reviewed", "not-authenticated"} {
+		ctx := context.WithValue(context.Background(), ContextAuthTokenKey, token)
+		assert.NotPanics(t, func() {
+			_, err := mock_cache.GetUserData(ctx)
+			assert.ErrorIs(t, err, ErrTokenReviewMissing, token)
+		})
+	}
+	assert.Empty(t, mock_cache.users, "Expected the user data not to be cached without a user.")
+}
+
+// Should cache the users without a uid, like kube:admin, with the username.
+func Test_GetUserDataCache_EmptyUID(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+	fs := mockAuthzClientset(t, nil, nil)
+	for _, username := range []string{"kube:admin", "other-user"} {
+		mock_cache.tokenReviews[tokenReviewKey(username)] = &tokenReviewCache{
+			meta: cacheMetadata{updatedAt: time.Now()},
+			tokenReview: &authv1.TokenReview{Status: authv1.TokenReviewStatus{
+				Authenticated: true, User: authv1.UserInfo{Username: username}}},
+		}
+		ctx := context.WithValue(context.Background(), ContextAuthTokenKey, username)
+		user, err := mock_cache.GetUserDataCache(ctx, fs.AuthorizationV1())
+		assert.Nil(t, err)
+		assert.Equal(t, username, user.userInfo.Username)
+	}
+	assert.Contains(t, mock_cache.users, "kube:admin")
+	assert.Contains(t, mock_cache.users, "other-user")
+	assert.NotContains(t, mock_cache.users, "")
+}
+
+// Should resolve and cache the user data with the UID of the user info, without a TokenReview.
+func Test_GetUserDataForUserInfo(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+	mock_cache.tokenReviews = map[string]*tokenReviewCache{}
+	fs := mockAuthzClientset(t, nil, nil)
+	userInfo := authv1.UserInfo{Username: "proxy-user", UID: "proxy-uid", Groups: []string{"group-a"}}
+
+	// Context without the auth token.
+	result, err := mock_cache.GetUserDataForUserInfo(context.Background(), userInfo, fs.AuthorizationV1())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Resource{{Apigroup: "", Kind: "nodes"}}, result.CsResources)
+	assert.Equal(t, map[string][]Resource{"ns1": {{Apigroup: "", Kind: "pods"}}}, result.NsResources)
+	assert.Contains(t, mock_cache.users, "proxy-uid")
+	assert.Equal(t, userInfo, mock_cache.users["proxy-uid"].userInfo)
+	assert.True(t, mock_cache.users["proxy-uid"].isValid())
+}
+
+func Test_GetUserDataForUserInfo_MissingUsername(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+	fs := mockAuthzClientset(t, nil, nil)
+
+	_, err := mock_cache.GetUserDataForUserInfo(context.Background(), authv1.UserInfo{UID: "proxy-uid"},
+		fs.AuthorizationV1())
+
+	assert.ErrorIs(t, err, ErrUserInfoMissing)
+	assert.Empty(t, mock_cache.users)
+}
+
+func Test_GetUserDataCache_ImpersonationSetup(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+	newClientSet := newImpersonationClientSet
+	defer func() { newImpersonationClientSet = newClientSet }()
+	newImpersonationClientSet = func(restConfig *rest.Config) (v1.AuthorizationV1Interface, error) {
+		return nil, errors.New("invalid rest config")
+	}
+
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	_, err := mock_cache.GetUserDataCache(ctx, nil)
+
+	assert.ErrorIs(t, err, ErrImpersonationSetup)
+	assert.ErrorIs(t, err, ErrNamespacedFetch)
+	assert.ErrorIs(t, err, ErrClusterScopedFetch)
+	assert.ErrorIs(t, err, ErrRBACUnavailable)
+}
+
+// Should impersonate the groups and extra info from the TokenReview, RBAC bindings to groups are common with OIDC.
+func Test_GetUserDataCache_ImpersonateGroups(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+	mock_cache.tokenReviews[tokenReviewKey("123456")].tokenReview.Status.User = authv1.UserInfo{
+		Username: "oidc-user",
+		UID:      "unique-user-id",
+		Groups:   []string{"system:authenticated", "oidc:cluster-admins"},
+		Extra:    map[string]authv1.ExtraValue{"scopes.authorization.openshift.io": {"user:full"}},
+	}
+	var impersonated rest.ImpersonationConfig
+	newClientSet := newImpersonationClientSet
+	defer func() { newImpersonationClientSet = newClientSet }()
+	newImpersonationClientSet = func(restConfig *rest.Config) (v1.AuthorizationV1Interface, error) {
+		impersonated = restConfig.Impersonate
+		return mockAuthzClientset(t, nil, nil).AuthorizationV1(), nil
+	}
+
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	_, err := mock_cache.GetUserDataCache(ctx, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, rest.ImpersonationConfig{
+		UserName: "oidc-user",
+		UID:      "unique-user-id",
+		Groups:   []string{"system:authenticated", "oidc:cluster-admins"},
+		Extra:    map[string][]string{"scopes.authorization.openshift.io": {"user:full"}},
+	}, impersonated)
+}
+
+// Authorization client that delays the SSAR and SSRR requests to mimic a slow Kube API.
+// The delay is added outside of the fake clientset, which serializes the requests.
+type slowAuthzClient struct {
+	v1.AuthorizationV1Interface
+	delay time.Duration
+}
+
+type slowSSAR struct {
+	v1.SelfSubjectAccessReviewInterface
+	delay time.Duration
+}
+
+type slowSSRR struct {
+	v1.SelfSubjectRulesReviewInterface
+	delay time.Duration
+}
+
+func (c slowAuthzClient) SelfSubjectAccessReviews() v1.SelfSubjectAccessReviewInterface {
+	return slowSSAR{c.AuthorizationV1Interface.SelfSubjectAccessReviews(), c.delay}
+}
+
+func (c slowAuthzClient) SelfSubjectRulesReviews() v1.SelfSubjectRulesReviewInterface {
+	return slowSSRR{c.AuthorizationV1Interface.SelfSubjectRulesReviews(), c.delay}
+}
+
+func (s slowSSAR) Create(ctx context.Context, ssar *authz.SelfSubjectAccessReview,
+	opts metav1.CreateOptions) (*authz.SelfSubjectAccessReview, error) {
+	// Don't delay the requests to check if the user has access to everything or all managed clusters.
+	if res := ssar.Spec.ResourceAttributes.Resource; res != "*" && res != "searches/allManagedData" &&
+		res != "managedclusters" {
+		time.Sleep(s.delay)
+	}
+	return s.SelfSubjectAccessReviewInterface.Create(ctx, ssar, opts)
+}
+
+func (s slowSSRR) Create(ctx context.Context, ssrr *authz.SelfSubjectRulesReview,
+	opts metav1.CreateOptions) (*authz.SelfSubjectRulesReview, error) {
+	time.Sleep(s.delay)
+	return s.SelfSubjectRulesReviewInterface.Create(ctx, ssrr, opts)
+}
+
+// The cluster scoped and namespaced resources should be fetched in parallel.
+func Test_GetUserDataCache_Parallel(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+	delay := 300 * time.Millisecond
+	authzClient := slowAuthzClient{mockAuthzClientset(t, nil, nil).AuthorizationV1(), delay}
+
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	start := time.Now()
+	result, err := mock_cache.GetUserDataCache(ctx, authzClient)
+	elapsed := time.Since(start)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Resource{{Apigroup: "", Kind: "nodes"}}, result.CsResources)
+	assert.Equal(t, map[string][]Resource{"ns1": {{Apigroup: "", Kind: "pods"}}}, result.NsResources)
+	// Running in sequence would take at least 2x delay.
+	assert.GreaterOrEqual(t, elapsed, delay)
+	assert.Less(t, elapsed, 2*delay, "Expected the requests to run in parallel.")
+}
+
+// Authorization client that cancels the context after a number of SSRR requests.
+// Like the Kube client, requests with a cancelled context fail without reaching the API.
+type cancelAfterSSRR struct {
+	v1.SelfSubjectRulesReviewInterface
+	cancel context.CancelFunc
+	after  int
+	calls  int
+	lock   sync.Mutex
+}
+
+type cancelAuthzClient struct {
+	v1.AuthorizationV1Interface
+	ssrr *cancelAfterSSRR
+}
+
+func (c cancelAuthzClient) SelfSubjectRulesReviews() v1.SelfSubjectRulesReviewInterface {
+	return c.ssrr
+}
+
+func (s *cancelAfterSSRR) Create(ctx context.Context, ssrr *authz.SelfSubjectRulesReview,
+	opts metav1.CreateOptions) (*authz.SelfSubjectRulesReview, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	s.calls++
+	if s.calls == s.after {
+		s.cancel()
+	}
+	return s.SelfSubjectRulesReviewInterface.Create(ctx, ssrr, opts)
+}
+
+// Should stop processing namespaces when the context is cancelled and mark the cache as stale.
+func Test_getNamespacedResources_Cancelled(t *testing.T) {
+	mock_cache := mockCacheForRBACSources()
+	mock_cache.shared.namespaces = []string{}
+	for i := 0; i < 100; i++ {
+		mock_cache.shared.namespaces = append(mock_cache.shared.namespaces, fmt.Sprintf("ns%d", i))
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ContextAuthTokenKey, "123456"))
+	defer cancel()
+	fs := mockAuthzClientset(t, nil, nil).AuthorizationV1()
+	ssrr := &cancelAfterSSRR{SelfSubjectRulesReviewInterface: fs.SelfSubjectRulesReviews(), cancel: cancel, after: 5}
+	user := &UserDataCache{authzClient: cancelAuthzClient{fs, ssrr}}
+
+	result, err := user.getNamespacedResources(mock_cache, ctx)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 5, ssrr.calls, "Expected to stop sending requests after the context was cancelled.")
+	assert.Equal(t, 5, len(result.NsResources), "Expected to keep the namespaces already processed.")
+	assert.True(t, user.nsrCache.updatedAt.IsZero(), "Expected the cache timestamp not to be updated.")
+	assert.False(t, user.nsrCache.isValid())
+	assert.False(t, user.clustersCache.isValid())
+}
+
+// Authorization client that tracks the max number of SSRR requests in flight.
+type concurrentSSRR struct {
+	v1.SelfSubjectRulesReviewInterface
+	inFlight    int32
+	maxInFlight int32
+}
+
+type concurrentAuthzClient struct {
+	v1.AuthorizationV1Interface
+	ssrr *concurrentSSRR
+}
+
+func (c concurrentAuthzClient) SelfSubjectRulesReviews() v1.SelfSubjectRulesReviewInterface {
+	return c.ssrr
+}
+
+func (s *concurrentSSRR) Create(ctx context.Context, ssrr *authz.SelfSubjectRulesReview,
+	opts metav1.CreateOptions) (*authz.SelfSubjectRulesReview, error) {
+	inFlight := atomic.AddInt32(&s.inFlight, 1)
+	defer atomic.AddInt32(&s.inFlight, -1)
+	for {
+		current := atomic.LoadInt32(&s.maxInFlight)
+		if inFlight <= current || atomic.CompareAndSwapInt32(&s.maxInFlight, current, inFlight) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return s.SelfSubjectRulesReviewInterface.Create(ctx, ssrr, opts)
+}
+
+// Should limit the parallel SSRR requests with RBAC_CONCURRENCY, with the same result as the serial requests.
+func Test_getNamespacedResources_BoundedConcurrency(t *testing.T) {
+	defer func(concurrency int) { config.Cfg.RBACConcurrency = concurrency }(config.Cfg.RBACConcurrency)
+	mock_cache := mockCacheForRBACSources()
+	mock_cache.shared.namespaces = []string{}
+	for i := 0; i < 30; i++ {
+		mock_cache.shared.namespaces = append(mock_cache.shared.namespaces, fmt.Sprintf("ns%d", i))
+	}
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+
+	results := map[int]map[string][]Resource{}
+	for _, concurrency := range []int{1, 3} {
+		config.Cfg.RBACConcurrency = concurrency
+		fs := mockAuthzClientset(t, nil, nil).AuthorizationV1()
+		ssrr := &concurrentSSRR{SelfSubjectRulesReviewInterface: fs.SelfSubjectRulesReviews()}
+		user := &UserDataCache{authzClient: concurrentAuthzClient{fs, ssrr}}
+
+		result, err := user.getNamespacedResources(mock_cache, ctx)
+
+		assert.Nil(t, err)
+		assert.Equal(t, 30, len(result.NsResources))
+		assert.LessOrEqual(t, ssrr.maxInFlight, int32(concurrency), "Expected the requests to be bounded.")
+		results[concurrency] = result.NsResources
+	}
+	assert.Equal(t, results[1], results[3])
+}
+
+// Should not write the user's namespaced resources to the logs.
+func Test_getNamespacedResources_Logs(t *testing.T) {
+	var buf bytes.Buffer
+	klog.LogToStderr(false)
+	klog.SetOutput(&buf)
+	flags := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(flags)
+	defer func() {
+		_ = flags.Set("v", "0")
+		klog.SetOutput(os.Stderr)
+		klog.LogToStderr(true)
+	}()
+
+	mock_cache := mockCacheForRBACSources()
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	fs := mockAuthzClientset(t, nil, nil)
+
+	// Nothing is logged at the default verbosity.
+	user := &UserDataCache{authzClient: fs.AuthorizationV1()}
+	_, err := user.getNamespacedResources(mock_cache, ctx)
+	assert.Nil(t, err)
+	klog.Flush()
+	assert.Empty(t, buf.String())
+
+	// Only the counts are logged at higher verbosity.
+	_ = flags.Set("v", "6")
+	user = &UserDataCache{authzClient: fs.AuthorizationV1()}
+	_, err = user.getNamespacedResources(mock_cache, ctx)
+	assert.Nil(t, err)
+	klog.Flush()
+	assert.Contains(t, buf.String(), "has access to 1 namespace scoped resources in 1 namespaces and 0 ManagedClusters.")
+	assert.NotContains(t, buf.String(), "pods")
+}
+
+// Count the SSRR requests and the SSAR requests for cluster scoped resources.
+// Excludes the SSAR requests to check if the user has access to all resources or managed clusters.
+func countAuthzRequests(fs *fake.Clientset) (ssrr int, ssar int) {
+	for _, action := range fs.Actions() {
+		switch obj := action.(testingk8s.CreateAction).GetObject().(type) {
+		case *authz.SelfSubjectRulesReview:
+			ssrr++
+		case *authz.SelfSubjectAccessReview:
+			if res := obj.Spec.ResourceAttributes.Resource; res != "*" && res != "searches/allManagedData" &&
+				res != "managedclusters" {
+				ssar++
+			}
+		}
+	}
+	return ssrr, ssar
+}
+
+// Each section of the user cache should expire and refresh independently.
+func Test_GetUserDataCache_SectionTTL(t *testing.T) {
+	expired := time.Now().Add(-10 * time.Minute)
+	tests := []struct {
+		name         string
+		csrUpdatedAt time.Time
+		nsrUpdatedAt time.Time
+		mcUpdatedAt  time.Time
+		expectedSSRR int
+		expectedSSAR int
+	}{
+		{"cluster scoped expired", expired, time.Now(), time.Now(), 0, 1},
+		{"namespaced expired", time.Now(), expired, time.Now(), 1, 0},
+		{"managed clusters expired", time.Now(), time.Now(), expired, 1, 0},
+	}
+	for _, tt := range tests {
+		mock_cache := mockCacheForRBACSources()
+		fs := mockAuthzClientset(t, nil, nil)
+		ttl := 5 * time.Minute
+		mock_cache.users["unique-user-id"] = &UserDataCache{
+			userInfo:      authv1.UserInfo{UID: "unique-user-id"},
+			csrCache:      cacheMetadata{updatedAt: tt.csrUpdatedAt, ttl: ttl},
+			nsrCache:      cacheMetadata{updatedAt: tt.nsrUpdatedAt, ttl: ttl},
+			clustersCache: cacheMetadata{updatedAt: tt.mcUpdatedAt, ttl: ttl},
+		}
+
+		ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+		result, err := mock_cache.GetUserDataCache(ctx, fs.AuthorizationV1())
+
+		assert.Nil(t, err, tt.name)
+		assert.True(t, result.isValid(), tt.name)
+		ssrr, ssar := countAuthzRequests(fs)
+		assert.Equal(t, tt.expectedSSRR, ssrr, "Unexpected SSRR requests when %s", tt.name)
+		assert.Equal(t, tt.expectedSSAR, ssar, "Unexpected SSAR requests when %s", tt.name)
+	}
+}
+
+// Should use the TTL configured for each section.
+func Test_GetUserDataCache_SectionTTLConfig(t *testing.T) {
+	defer func(cs, mc, ns int) {
+		config.Cfg.ClusterScopedCacheTTL, config.Cfg.ManagedClusterCacheTTL, config.Cfg.NamespacedCacheTTL = cs, mc, ns
+	}(config.Cfg.ClusterScopedCacheTTL, config.Cfg.ManagedClusterCacheTTL, config.Cfg.NamespacedCacheTTL)
+	config.Cfg.ClusterScopedCacheTTL = 1000
+	config.Cfg.ManagedClusterCacheTTL = 2000
+	config.Cfg.NamespacedCacheTTL = 3000
+
+	mock_cache := mockCacheForRBACSources()
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	result, err := mock_cache.GetUserDataCache(ctx, mockAuthzClientset(t, nil, nil).AuthorizationV1())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1*time.Second, result.csrCache.ttl)
+	assert.Equal(t, 2*time.Second, result.clustersCache.ttl)
+	assert.Equal(t, 3*time.Second, result.nsrCache.ttl)
+}
+
+// The managed clusters should be cached when the user has access to zero or more managed clusters.
+func Test_GetUserDataCache_ManagedClustersCached(t *testing.T) {
+	tests := []struct {
+		name             string
+		managedClusters  map[string]struct{}
+		expectedClusters map[string]struct{}
+	}{
+		{"zero managed clusters", map[string]struct{}{}, map[string]struct{}{}},
+		{"one managed cluster", map[string]struct{}{"ns1": {}}, map[string]struct{}{"ns1": {}}},
+	}
+	for _, tt := range tests {
+		mock_cache := mockCacheForRBACSources()
+		mock_cache.shared.managedClusters = tt.managedClusters
+		fs := mockAuthzClientset(t, nil, nil)
+		fs.PrependReactor("create", "selfsubjectrulesreviews", func(action testingk8s.Action) (bool, runtime.Object, error) {
+			ssrr := action.(testingk8s.CreateAction).GetObject().(*authz.SelfSubjectRulesReview)
+			ssrr.Status.ResourceRules = []authz.ResourceRule{
+				{Verbs: []string{"create"}, APIGroups: []string{"view.open-cluster-management.io"},
+					Resources: []string{"managedclusterviews"}},
+			}
+			return true, ssrr, nil
+		})
+
+		ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+		result, err := mock_cache.GetUserDataCache(ctx, fs.AuthorizationV1())
+		assert.Nil(t, err, tt.name)
+		assert.Equal(t, tt.expectedClusters, result.ManagedClusters, tt.name)
+		assert.True(t, result.clustersCache.isValid(), tt.name)
+
+		// The next request should use the cached managed clusters.
+		ssrr, _ := countAuthzRequests(fs)
+		result, err = mock_cache.GetUserDataCache(ctx, fs.AuthorizationV1())
+		assert.Nil(t, err, tt.name)
+		assert.Equal(t, tt.expectedClusters, result.ManagedClusters, tt.name)
+		cachedSSRR, _ := countAuthzRequests(fs)
+		assert.Equal(t, ssrr, cachedSSRR, "Expected the managed clusters from cache with %s", tt.name)
+	}
+}
+
+// Should use all the managed clusters when the user can list ManagedClusters at cluster scope.
+func Test_getNamespacedResources_AllManagedClusters(t *testing.T) {
+	tests := []struct {
+		name             string
+		allClusters      bool
+		expectedClusters map[string]struct{}
+	}{
+		{"all managed clusters", true, map[string]struct{}{}},
+		{"managed clusters in the namespaces", false, map[string]struct{}{"ns1": {}}},
+	}
+	for _, tt := range tests {
+		mock_cache := mockCacheForRBACSources()
+		mock_cache.shared.managedClusters = map[string]struct{}{"ns1": {}}
+		fs := mockAuthzClientset(t, nil, nil)
+		fs.PrependReactor("create", "selfsubjectaccessreviews", func(action testingk8s.Action) (bool, runtime.Object, error) {
+			ssar := action.(testingk8s.CreateAction).GetObject().(*authz.SelfSubjectAccessReview)
+			attributes := ssar.Spec.ResourceAttributes
+			ssar.Status.Allowed = tt.allClusters && attributes.Verb == "list" &&
+				attributes.Group == "cluster.open-cluster-management.io" &&
+				attributes.Resource == "managedclusters" && attributes.Namespace == ""
+			return true, ssar, nil
+		})
+		fs.PrependReactor("create", "selfsubjectrulesreviews", func(action testingk8s.Action) (bool, runtime.Object, error) {
+			ssrr := action.(testingk8s.CreateAction).GetObject().(*authz.SelfSubjectRulesReview)
+			ssrr.Status.ResourceRules = []authz.ResourceRule{
+				{Verbs: []string{"list"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+				{Verbs: []string{"create"}, APIGroups: []string{"view.open-cluster-management.io"},
+					Resources: []string{"managedclusterviews"}},
+			}
+			return true, ssrr, nil
+		})
+
+		ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+		user := &UserDataCache{authzClient: fs.AuthorizationV1()}
+		result, err := user.getNamespacedResources(mock_cache, ctx)
+
+		assert.Nil(t, err, tt.name)
+		assert.Equal(t, tt.expectedClusters, result.ManagedClusters, tt.name)
+		assert.Equal(t, tt.allClusters, result.ManagedClusterAllAccess, tt.name)
+		assert.Equal(t, map[string][]Resource{"ns1": {{Apigroup: "", Kind: "pods"}}}, result.NsResources, tt.name)
+		assert.True(t, result.clustersCache.isValid(), tt.name)
+	}
+}
+
+// Should skip the SSRR for the namespaces in EXCLUDED_NAMESPACES. Glob patterns don't exclude managed clusters.
+func Test_getNamespacedResources_ExcludedNamespaces(t *testing.T) {
+	defer func(excluded []string) { config.Cfg.ExcludedNamespaces = excluded }(config.Cfg.ExcludedNamespaces)
+	config.Cfg.ExcludedNamespaces = []string{"openshift-*", "kube-system", "cluster2"}
+	mock_cache := mockCacheForRBACSources()
+	mock_cache.shared.namespaces = []string{"ns1", "openshift-config", "kube-system", "openshift-cluster1", "cluster2"}
+	mock_cache.shared.managedClusters = map[string]struct{}{"openshift-cluster1": {}, "cluster2": {}}
+	fs := mockAuthzClientset(t, nil, nil)
+
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	user := &UserDataCache{authzClient: fs.AuthorizationV1()}
+	result, err := user.getNamespacedResources(mock_cache, ctx)
+
+	assert.Nil(t, err)
+	namespaces := []string{}
+	for ns := range result.NsResources {
+		namespaces = append(namespaces, ns)
+	}
+	assert.ElementsMatch(t, []string{"ns1", "openshift-cluster1"}, namespaces)
+	ssrr, _ := countAuthzRequests(fs)
+	assert.Equal(t, 2, ssrr)
+	assert.Equal(t, 5, len(mock_cache.shared.namespaces), "Shared namespaces shouldn't be modified.")
+}
+
+// Should return a valid empty result when all the namespaces are excluded.
+func Test_getNamespacedResources_AllNamespacesExcluded(t *testing.T) {
+	defer func(excluded []string) { config.Cfg.ExcludedNamespaces = excluded }(config.Cfg.ExcludedNamespaces)
+	config.Cfg.ExcludedNamespaces = []string{"*"}
+	mock_cache := mockCacheForRBACSources()
+	fs := mockAuthzClientset(t, nil, nil)
+
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	user := &UserDataCache{authzClient: fs.AuthorizationV1()}
+	result, err := user.getNamespacedResources(mock_cache, ctx)
+
+	assert.Nil(t, err)
+	assert.Empty(t, result.NsResources)
+	assert.True(t, result.nsrCache.isValid())
+}
+
+// Adds a reactor that fails the first requests with the error, then falls through to the mock reactors.
+func failFirstRequests(fs *fake.Clientset, resource string, failures int, err error) *int32 {
+	var calls int32
+	fs.PrependReactor("create", resource, func(action testingk8s.Action) (bool, runtime.Object, error) {
+		if atomic.AddInt32(&calls, 1) <= int32(failures) {
+			return true, nil, err
+		}
+		return false, nil, nil
+	})
+	return &calls
+}
+
+func mockRetryBackoff(t *testing.T, maxRetries int) {
+	backoff, retries, retryAfter := kubeRetryBackoff, config.Cfg.RBACMaxRetries, maxKubeRetryAfter
+	t.Cleanup(func() {
+		kubeRetryBackoff, config.Cfg.RBACMaxRetries, maxKubeRetryAfter = backoff, retries, retryAfter
+	})
+	kubeRetryBackoff.Duration = time.Millisecond
+	maxKubeRetryAfter = time.Millisecond
+	config.Cfg.RBACMaxRetries = maxRetries
+}
+
+// Should retry the SSAR and SSRR requests on transient errors.
+func Test_GetUserDataCache_RetryTransientErrors(t *testing.T) {
+	mockRetryBackoff(t, 3)
+	mock_cache := mockCacheForRBACSources()
+	fs := mockAuthzClientset(t, nil, nil)
+	ssarCalls := failFirstRequests(fs, "selfsubjectaccessreviews", 2,
+		apierrors.NewServiceUnavailable("apiserver is shutting down"))
+	ssrrCalls := failFirstRequests(fs, "selfsubjectrulesreviews", 2,
+		apierrors.NewTooManyRequests("too many requests", 1))
+
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	result, err := mock_cache.GetUserDataCache(ctx, fs.AuthorizationV1())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Resource{{Apigroup: "", Kind: "nodes"}}, result.CsResources)
+	assert.Equal(t, []Resource{{Apigroup: "", Kind: "pods"}}, result.NsResources["ns1"])
+	// The all-access check fails twice, then the remaining SSAR requests succeed on the first attempt.
+	assert.Less(t, int32(2), atomic.LoadInt32(ssarCalls))
+	assert.Equal(t, int32(3), atomic.LoadInt32(ssrrCalls))
+}
+
+// Should fail fast on errors that aren't transient.
+func Test_getSSRRforNamespace_NoRetryForbidden(t *testing.T) {
+	mockRetryBackoff(t, 3)
+	mock_cache := mockCacheForRBACSources()
+	fs := mockAuthzClientset(t, nil, nil)
+	calls := failFirstRequests(fs, "selfsubjectrulesreviews", 5,
+		apierrors.NewForbidden(authz.Resource("selfsubjectrulesreviews"), "", errors.New("forbidden")))
+	user := &UserDataCache{authzClient: fs.AuthorizationV1()}
+
+	err := user.getSSRRforNamespace(context.TODO(), mock_cache, "ns1", &sync.Mutex{})
+
+	assert.True(t, apierrors.IsForbidden(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+}
+
+// Should return the last error after RBAC_MAX_RETRIES.
+func Test_userAuthorizedListSSAR_MaxRetries(t *testing.T) {
+	mockRetryBackoff(t, 1)
+	fs := mockAuthzClientset(t, nil, nil)
+	calls := failFirstRequests(fs, "selfsubjectaccessreviews", 5, apierrors.NewServerTimeout(
+		authz.Resource("selfsubjectaccessreviews"), "create", 1))
+	user := &UserDataCache{}
+
+	allowed, err := user.userAuthorizedListSSAR(context.TODO(), fs.AuthorizationV1(), "list", "", "nodes")
+
+	assert.False(t, allowed)
+	assert.True(t, apierrors.IsServerTimeout(err))
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+}
+
+// Should stop retrying when the context is cancelled, without waiting for the backoff.
+func Test_retryKubeRequest_ContextCancelled(t *testing.T) {
+	mockRetryBackoff(t, 3)
+	kubeRetryBackoff.Duration = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+
+	done := make(chan error)
+	go func() {
+		done <- retryKubeRequest(ctx, "SelfSubjectAccessReview", func() error {
+			calls++
+			return apierrors.NewServiceUnavailable("apiserver is shutting down")
+		})
+	}()
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.True(t, apierrors.IsServiceUnavailable(err))
+		assert.Equal(t, 1, calls)
+	case <-time.After(time.Second):
+		t.Fatal("Expected the retry to stop when the context is cancelled.")
+	}
+}
+
+// Should wait the Retry-After suggested by the Kube API, up to maxKubeRetryAfter.
+func Test_kubeRetryDelay(t *testing.T) {
+	backoff := wait.Backoff{Duration: 100 * time.Millisecond, Factor: 2, Steps: 4}
+
+	assert.Equal(t, 2*time.Second, kubeRetryDelay(apierrors.NewTooManyRequests("too many requests", 2), &backoff))
+	assert.Equal(t, maxKubeRetryAfter,
+		kubeRetryDelay(apierrors.NewTooManyRequests("too many requests", 60), &backoff))
+	// Without a suggested delay, uses the next step of the backoff.
+	assert.Equal(t, 400*time.Millisecond,
+		kubeRetryDelay(apierrors.NewServiceUnavailable("apiserver is shutting down"), &backoff))
+}
+
+// Should trace the token review and each section of the user data, as children of the request span.
+func Test_GetUserDataCache_Spans(t *testing.T) {
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	mock_cache := mockCacheForRBACSources()
+	mock_cache.tokenReviews[tokenReviewKey("123456")].tokenReview.Status.User.Username = "unique-user"
+	fs := mockAuthzClientset(t, nil, nil)
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	ctx, root := otel.Tracer("test").Start(ctx, "request")
+	_, err := mock_cache.GetUserDataCache(ctx, fs.AuthorizationV1())
+	root.End()
+	assert.Nil(t, err)
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	assert.Equal(t, 6, len(spans))
+	rootID := spans["request"].SpanContext.SpanID()
+	userDataID := spans["rbac.GetUserData"].SpanContext.SpanID()
+	assert.Equal(t, rootID, spans["rbac.TokenReview"].Parent.SpanID())
+	assert.Equal(t, rootID, spans["rbac.GetUserData"].Parent.SpanID())
+	assert.Equal(t, userDataID, spans["rbac.userHasAllAccess"].Parent.SpanID())
+	assert.Equal(t, userDataID, spans["rbac.getNamespacedResources"].Parent.SpanID())
+	assert.Equal(t, userDataID, spans["rbac.getClusterScopedResources"].Parent.SpanID())
+
+	assert.Contains(t, spans["rbac.GetUserData"].Attributes, attribute.String("enduser.id", "unique-user"))
+	assert.Contains(t, spans["rbac.getNamespacedResources"].Attributes, attribute.Int("rbac.namespaces", 1))
+	assert.Contains(t, spans["rbac.getNamespacedResources"].Attributes,
+		attribute.Int("rbac.authorized_namespaces", 1))
+	assert.Contains(t, spans["rbac.getClusterScopedResources"].Attributes,
+		attribute.Int("rbac.cluster_scoped_resources", 1))
+}
+
+// Should sample the per-namespace log lines when LOG_SAMPLE_RATE is set.
+func Test_getNamespacedResources_LogSampling(t *testing.T) {
+	var buf bytes.Buffer
+	klog.LogToStderr(false)
+	klog.SetOutput(&buf)
+	flags := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(flags)
+	_ = flags.Set("v", "9")
+	defer func() {
+		_ = flags.Set("v", "0")
+		klog.SetOutput(os.Stderr)
+		klog.LogToStderr(true)
+		config.Cfg.LogSampleRate = 1
+	}()
+
+	mock_cache := mockCacheForRBACSources()
+	namespaces := make([]string, 100)
+	for i := range namespaces {
+		namespaces[i] = fmt.Sprintf("ns%d", i)
+	}
+	mock_cache.shared.namespaces = namespaces
+	ctx := context.WithValue(context.Background(), ContextAuthTokenKey, "123456")
+	fs := mockAuthzClientset(t, nil, nil)
+	countLines := func() int {
+		klog.Flush()
+		lines := strings.Count(buf.String(), "SelfSubjectRulesReviews Kube API result for ns:")
+		buf.Reset()
+		return lines
+	}
+
+	// Logs every namespace when sampling is disabled.
+	config.Cfg.LogSampleRate = 1
+	user := &UserDataCache{authzClient: fs.AuthorizationV1()}
+	_, err := user.getNamespacedResources(mock_cache, ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 100, countLines())
+
+	// Logs 1 in 10 namespaces when sampling is enabled.
+	config.Cfg.LogSampleRate = 10
+	user = &UserDataCache{authzClient: fs.AuthorizationV1()}
+	_, err = user.getNamespacedResources(mock_cache, ctx)
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, countLines(), 11)
+}
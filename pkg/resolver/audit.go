@@ -0,0 +1,98 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	authv1 "k8s.io/api/authentication/v1"
+	klog "k8s.io/klog/v2"
+)
+
+// Replaces the filter values and keywords when AUDIT_REDACT_VALUES is enabled.
+const auditRedacted = "[REDACTED]"
+
+// Resolves the user who sent the query. Replaced in tests.
+var auditUser = func(ctx context.Context) (string, authv1.UserInfo) {
+	return rbac.GetCache().GetUserUID(ctx)
+}
+
+// Audit log entry for a search query.
+type auditEntry struct {
+	Time     string        `json:"time"`
+	User     string        `json:"user"`
+	UID      string        `json:"uid"`
+	Field    string        `json:"field"` // First field resolved for the search result: items, count, uids or export.
+	Keywords []string      `json:"keywords,omitempty"`
+	Filters  []auditFilter `json:"filters"`
+	Results  int           `json:"results"`
+}
+
+type auditFilter struct {
+	Property string   `json:"property"`
+	Values   []string `json:"values"`
+}
+
+// Writes who searched for what as a single JSON line when AUDIT_LOG is enabled.
+func auditSearch(ctx context.Context, input *model.SearchInput, field string, results int) {
+	if !config.Cfg.AuditLog {
+		return
+	}
+	uid, userInfo := auditUser(ctx)
+	entry := auditEntry{
+		Time:    time.Now().UTC().Format(time.RFC3339),
+		User:    userInfo.Username,
+		UID:     uid,
+		Field:   field,
+		Filters: []auditFilter{},
+		Results: results,
+	}
+	if input != nil {
+		entry.Keywords = auditValues(input.Keywords)
+		// Copy the filters, the input is shared with the other fields resolved concurrently.
+		filters := append([]*model.SearchFilter{}, input.Filters...)
+		for _, group := range input.FilterGroups {
+			if group != nil {
+				filters = append(filters, group.Filters...)
+			}
+		}
+		for _, filter := range filters {
+			if filter != nil {
+				entry.Filters = append(entry.Filters,
+					auditFilter{Property: filter.Property, Values: auditValues(filter.Values)})
+			}
+		}
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		klog.Warning("Error writing the search audit log. ", err)
+		return
+	}
+	klog.Infof("AUDIT %s", line)
+}
+
+// Writes the audit log entry once for each search result, when the first field is resolved.
+// The items and count of the same search aren't logged as separate searches.
+func (s *SearchResult) audit(field string, results int) {
+	s.audited.Do(func() {
+		auditSearch(s.context, s.input, field, results)
+	})
+}
+
+// Returns the values for the audit log, redacted when AUDIT_REDACT_VALUES is enabled.
+func auditValues(values []*string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if config.Cfg.AuditRedactValues {
+			result = append(result, auditRedacted)
+		} else if value != nil {
+			result = append(result, *value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,169 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+	authv1 "k8s.io/api/authentication/v1"
+	klog "k8s.io/klog/v2"
+)
+
+// Returns the audit entry written to the log.
+func auditLine(t *testing.T, logs string) auditEntry {
+	var entry auditEntry
+	_, line, found := strings.Cut(logs, "AUDIT ")
+	assert.True(t, found, "audit line not found in logs")
+	line, _, _ = strings.Cut(line, "\n")
+	assert.Nil(t, json.Unmarshal([]byte(line), &entry))
+	return entry
+}
+
+// Enables the audit log and resolves the test user.
+func enableAuditLog(t *testing.T, redact bool) {
+	auditLog, redactValues, user := config.Cfg.AuditLog, config.Cfg.AuditRedactValues, auditUser
+	t.Cleanup(func() {
+		config.Cfg.AuditLog, config.Cfg.AuditRedactValues, auditUser = auditLog, redactValues, user
+	})
+	config.Cfg.AuditLog = true
+	config.Cfg.AuditRedactValues = redact
+	auditUser = func(ctx context.Context) (string, authv1.UserInfo) {
+		userInfo := getUserInfo()
+		return userInfo.UID, userInfo
+	}
+}
+
+// Should write the user, filter properties and result count of the search, with the values redacted.
+func Test_auditSearch_Items(t *testing.T) {
+	enableAuditLog(t, true)
+	buf := captureLogs(t)
+
+	value1 := "openshift"
+	keyword := "secret-app"
+	searchInput := &model.SearchInput{Keywords: []*string{&keyword},
+		Filters: []*model.SearchFilter{{Property: "namespace", Values: []*string{&value1}}}}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}},
+		map[string]string{"namespace": "string"})
+	mockRows := newMockRowsWithoutRBAC("../resolver/mocks/mock.json", searchInput, "string", 0)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockRows, nil)
+
+	result, err := resolver.Items()
+	klog.Flush()
+
+	assert.Nil(t, err)
+	entry := auditLine(t, buf.String())
+	assert.Equal(t, "unique-user-id", entry.UID)
+	assert.Equal(t, "unique-username", entry.User)
+	assert.Equal(t, "items", entry.Field)
+	assert.Equal(t, []string{"[REDACTED]"}, entry.Keywords)
+	assert.Equal(t, []auditFilter{{Property: "namespace", Values: []string{"[REDACTED]"}}}, entry.Filters)
+	assert.Equal(t, len(result), entry.Results)
+	assert.NotEmpty(t, entry.Time)
+	assert.NotContains(t, buf.String(), "openshift")
+	assert.NotContains(t, buf.String(), "secret-app")
+}
+
+// Should audit the items resolved with the stream serialization.
+func Test_auditSearch_EncodedItems(t *testing.T) {
+	defer func(serialization string) { config.Cfg.ItemsSerialization = serialization }(config.Cfg.ItemsSerialization)
+	config.Cfg.ItemsSerialization = "stream"
+	enableAuditLog(t, true)
+	buf := captureLogs(t)
+
+	value1 := "openshift"
+	searchInput := &model.SearchInput{
+		Filters: []*model.SearchFilter{{Property: "namespace", Values: []*string{&value1}}}}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}},
+		map[string]string{"namespace": "string"})
+	mockRows := newMockRowsWithoutRBAC("../resolver/mocks/mock.json", searchInput, "string", 0)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockRows, nil)
+
+	result, err := resolver.EncodedItems()
+	klog.Flush()
+
+	assert.Nil(t, err)
+	entry := auditLine(t, buf.String())
+	assert.Equal(t, "items", entry.Field)
+	assert.Equal(t, []auditFilter{{Property: "namespace", Values: []string{"[REDACTED]"}}}, entry.Filters)
+	assert.Equal(t, len(result), entry.Results)
+	assert.NotContains(t, buf.String(), "openshift")
+}
+
+// Should include the filter values, including the filter groups, when the redaction is disabled.
+func Test_auditSearch_Values(t *testing.T) {
+	enableAuditLog(t, false)
+	buf := captureLogs(t)
+
+	kind, namespace := "Pod", "kube-system"
+	searchInput := &model.SearchInput{
+		Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&kind}}},
+		FilterGroups: []*model.SearchFilterGroup{
+			{Filters: []*model.SearchFilter{{Property: "namespace", Values: []*string{&namespace}}}}},
+	}
+	auditSearch(context.Background(), searchInput, "count", 7)
+	klog.Flush()
+
+	entry := auditLine(t, buf.String())
+	assert.Equal(t, "count", entry.Field)
+	assert.Equal(t, 7, entry.Results)
+	assert.Equal(t, []auditFilter{{Property: "kind", Values: []string{"Pod"}},
+		{Property: "namespace", Values: []string{"kube-system"}}}, entry.Filters)
+}
+
+// Should write a single audit entry when the count and items of the same search are resolved.
+func Test_auditSearch_OncePerSearch(t *testing.T) {
+	enableAuditLog(t, true)
+	buf := captureLogs(t)
+
+	value1 := "openshift"
+	searchInput := &model.SearchInput{
+		Filters: []*model.SearchFilter{{Property: "namespace", Values: []*string{&value1}}}}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}},
+		map[string]string{"namespace": "string"})
+	mockPool.EXPECT().QueryRow(gomock.Any(), gomock.Any(), gomock.Any()).Return(&Row{MockValue: 10})
+	mockRows := newMockRowsWithoutRBAC("../resolver/mocks/mock.json", searchInput, "string", 0)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockRows, nil)
+
+	_, err := resolver.Count(context.Background())
+	assert.Nil(t, err)
+	_, err = resolver.Items()
+	assert.Nil(t, err)
+	klog.Flush()
+
+	assert.Equal(t, 1, strings.Count(buf.String(), "AUDIT "))
+	assert.Equal(t, "count", auditLine(t, buf.String()).Field)
+}
+
+// Should not modify the filters of the input when adding the filters of the groups.
+func Test_auditSearch_InputNotModified(t *testing.T) {
+	enableAuditLog(t, false)
+	captureLogs(t)
+
+	kind, namespace := "Pod", "kube-system"
+	filters := make([]*model.SearchFilter, 1, 2)
+	filters[0] = &model.SearchFilter{Property: "kind", Values: []*string{&kind}}
+	searchInput := &model.SearchInput{Filters: filters, FilterGroups: []*model.SearchFilterGroup{
+		{Filters: []*model.SearchFilter{{Property: "namespace", Values: []*string{&namespace}}}}}}
+
+	auditSearch(context.Background(), searchInput, "count", 7)
+
+	assert.Len(t, searchInput.Filters, 1)
+	assert.Nil(t, filters[:2][1])
+}
+
+// Should not write the audit log when AUDIT_LOG is disabled.
+func Test_auditSearch_Disabled(t *testing.T) {
+	buf := captureLogs(t)
+
+	auditSearch(context.Background(), &model.SearchInput{}, "items", 1)
+	klog.Flush()
+
+	assert.NotContains(t, buf.String(), "AUDIT")
+}
@@ -0,0 +1,101 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/driftprogramming/pgxpoolmock"
+	db "github.com/stolostron/search-v2-api/pkg/database"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	klog "k8s.io/klog/v2"
+)
+
+type GetResourceResult struct {
+	pool     pgxpoolmock.PgxPool
+	uid      string
+	query    string
+	params   []interface{}
+	userData rbac.UserData
+}
+
+// Returns the resource with the uid, or nil if it doesn't exist or the user isn't authorized to list it.
+// Both cases return nil, so the response doesn't reveal that a resource exists.
+func GetResource(ctx context.Context, uid string) (map[string]interface{}, error) {
+	defer metrics.SlowLog("GetResourceResolver", 0)()
+	userData, userDataErr := rbac.GetCache().GetUserData(ctx)
+	if userDataErr != nil {
+		return nil, userDataErr
+	}
+
+	getResourceResult := &GetResourceResult{
+		pool:     db.GetConnPool(ctx),
+		uid:      uid,
+		userData: userData,
+	}
+	return getResourceResult.resource(ctx)
+}
+
+func (s *GetResourceResult) resource(ctx context.Context) (map[string]interface{}, error) {
+	if err := s.buildGetResourceQuery(ctx); err != nil {
+		return nil, err
+	}
+	return s.getResourceResults(ctx)
+}
+
+// Selects the resource with the uid. The RBAC clause is always applied, so an unauthorized
+// resource isn't found.
+// Sample query:
+//
+//	SELECT "uid", "cluster", "data" FROM "search"."resources" WHERE (("uid" = 'local-cluster/abc') AND <rbac>) LIMIT 1
+func (s *GetResourceResult) buildGetResourceQuery(ctx context.Context) error {
+	s.query = ""
+	s.params = nil
+
+	if s.uid == "" {
+		return invalidInputf("uid is required for getResource query")
+	}
+
+	// get user info for logging
+	_, userInfo := rbac.GetCache().GetUserUID(ctx)
+
+	sql, params, err := goqu.From(goqu.S("search").Table("resources")).
+		Select("uid", "cluster", selectAllowedData()).
+		Where(goqu.C("uid").Eq(s.uid), buildRbacWhereClause(ctx, s.userData, userInfo)).
+		Limit(1).
+		ToSQL()
+	if err != nil {
+		klog.Errorf("Error building GetResource query: %s", err.Error())
+		return err
+	}
+	s.query = sql
+	s.params = params
+	klog.V(5).Info("GetResource Query: ", s.query)
+	return nil
+}
+
+func (s *GetResourceResult) getResourceResults(ctx context.Context) (map[string]interface{}, error) {
+	klog.V(2).Info("Resolving getResourceResults()")
+	rows, err := query(ctx, s.pool, s.query, s.params...)
+	if err != nil {
+		klog.Error("Error fetching resource from db ", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		klog.V(3).Infof("Resource with uid %s not found or not authorized.", s.uid)
+		return nil, rows.Err()
+	}
+	var uid, cluster string
+	var data map[string]interface{}
+	if err := rows.Scan(&uid, &cluster, &data); err != nil {
+		klog.Error("Error reading getResourceResults ", err)
+		return nil, err
+	}
+	item := formatDataMap(data)
+	item["_uid"] = uid
+	item["cluster"] = cluster
+	return item, nil
+}
@@ -0,0 +1,85 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/golang/mock/gomock"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMockGetResource(t *testing.T, uid string, ud rbac.UserData) (*GetResourceResult, *pgxpoolmock.MockPgxPool) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	mockResolver := &GetResourceResult{
+		pool:     mockPool,
+		uid:      uid,
+		userData: ud,
+	}
+	return mockResolver, mockPool
+}
+
+// Should return the resource when the user is authorized to list it.
+func Test_GetResource_Authorized(t *testing.T) {
+	resolver, mockPool := newMockGetResource(t, "managed1/pod-uid",
+		rbac.UserData{CsResources: []rbac.Resource{}, ManagedClusters: map[string]struct{}{"managed1": {}}})
+
+	mockRows := &MockRows{
+		mockData: []map[string]interface{}{
+			{"uid": "managed1/pod-uid", "cluster": "managed1",
+				"data": map[string]interface{}{"kind": "Pod", "name": "pod1", "namespace": "default"}},
+		},
+		columnHeaders: []string{"uid", "cluster", "data"},
+	}
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT "uid", "cluster", "data" FROM "search"."resources" WHERE (("uid" = 'managed1/pod-uid') AND ("cluster" = ANY ('{"managed1"}'))) LIMIT 1`),
+		gomock.Eq([]interface{}{})).Return(mockRows, nil)
+
+	result, err := resolver.resource(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"_uid": "managed1/pod-uid", "cluster": "managed1",
+		"kind": "Pod", "name": "pod1", "namespace": "default"}, result)
+}
+
+// Should return nil when the user isn't authorized, the RBAC clause doesn't match any resource.
+func Test_GetResource_Unauthorized(t *testing.T) {
+	resolver, mockPool := newMockGetResource(t, "managed2/pod-uid", rbac.UserData{})
+
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT "uid", "cluster", "data" FROM "search"."resources" WHERE (("uid" = 'managed2/pod-uid') AND ("cluster" = ANY ('{}'))) LIMIT 1`),
+		gomock.Eq([]interface{}{})).Return(&MockRows{}, nil)
+
+	result, err := resolver.resource(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+}
+
+// Should return nil when the resource doesn't exist.
+func Test_GetResource_NotFound(t *testing.T) {
+	csRes, nsRes, managedClusters := newUserData()
+	resolver, mockPool := newMockGetResource(t, "local-cluster/missing-uid",
+		rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters})
+
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Eq([]interface{}{})).Return(&MockRows{}, nil)
+
+	result, err := resolver.resource(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+}
+
+// Should return an error without querying the database when the uid is empty.
+func Test_GetResource_EmptyUID(t *testing.T) {
+	resolver, _ := newMockGetResource(t, "", rbac.UserData{})
+
+	result, err := resolver.resource(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	assert.EqualError(t, err, "uid is required for getResource query")
+	assert.Nil(t, result)
+}
@@ -0,0 +1,90 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"errors"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/lib/pq"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	klog "k8s.io/klog/v2"
+	"k8s.io/utils/strings/slices"
+)
+
+// Returned when a query uses a property in PROPERTY_DENYLIST and PROPERTY_DENYLIST_ACTION is reject.
+var ErrDeniedProperty = errors.New("the property can't be used in search queries")
+
+// Checks if the property is hidden from search with PROPERTY_DENYLIST. Dotted paths are denied with their
+// top-level property in the flattened data.
+func isDeniedProperty(property string) bool {
+	return slices.Contains(config.Cfg.PropertyDenylist, dataPath(property)[0])
+}
+
+// Returns the data without the properties in PROPERTY_DENYLIST.
+// Sample: ("data" - '{"annotation","secretRef"}'::text[])
+func allowedData() exp.LiteralExpression {
+	if len(config.Cfg.PropertyDenylist) == 0 {
+		return goqu.L(`"data"`)
+	}
+	return goqu.L(`("data" - ?::text[])`, pq.Array(config.Cfg.PropertyDenylist))
+}
+
+// Returns the data column to select, without the denied properties, so these aren't returned to the client.
+func selectAllowedData() interface{} {
+	if len(config.Cfg.PropertyDenylist) == 0 {
+		return "data"
+	}
+	return allowedData().As("data")
+}
+
+// Returns true if the property is denied and must be dropped from the query, or an error if
+// PROPERTY_DENYLIST_ACTION is reject.
+func checkDeniedProperty(property string) (bool, error) {
+	if !isDeniedProperty(property) {
+		return false, nil
+	}
+	if config.Cfg.PropertyDenylistAction == "drop" {
+		klog.V(3).Infof("Dropping the denied property [%s] from the query.", property)
+		return true, nil
+	}
+	return true, invalidInputf("%w: %s", ErrDeniedProperty, property)
+}
+
+// Removes the denied properties from the list.
+func withoutDeniedProperties(properties []string) []string {
+	if len(config.Cfg.PropertyDenylist) == 0 {
+		return properties
+	}
+	allowed := make([]string, 0, len(properties))
+	for _, prop := range properties {
+		if !isDeniedProperty(prop) {
+			allowed = append(allowed, prop)
+		}
+	}
+	return allowed
+}
+
+// Returns an error if PROPERTY_DENYLIST_ACTION is reject and a filter of the input uses a denied property.
+// Used by the queries that ignore the errors building the WHERE clause.
+func checkDeniedFilters(input *model.SearchInput) error {
+	if input == nil || len(config.Cfg.PropertyDenylist) == 0 {
+		return nil
+	}
+	filters := append([]*model.SearchFilter{}, input.Filters...)
+	for _, group := range input.FilterGroups {
+		if group != nil {
+			filters = append(filters, group.Filters...)
+		}
+	}
+	for _, filter := range filters {
+		if filter == nil {
+			continue
+		}
+		if _, err := checkDeniedProperty(filter.Property); err != nil {
+			return err
+		}
+	}
+	return nil
+}
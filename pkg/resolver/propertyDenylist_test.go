@@ -0,0 +1,256 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+// Configure the PROPERTY_DENYLIST for a test. Returns a function to restore the previous config.
+func setPropertyDenylist(denylist []string, action string) func() {
+	prevDenylist, prevAction := config.Cfg.PropertyDenylist, config.Cfg.PropertyDenylistAction
+	config.Cfg.PropertyDenylist = denylist
+	config.Cfg.PropertyDenylistAction = action
+	return func() {
+		config.Cfg.PropertyDenylist = prevDenylist
+		config.Cfg.PropertyDenylistAction = prevAction
+	}
+}
+
+// Should reject the search with a filter on a denied property, without querying the database.
+func Test_SearchResolver_DeniedPropertyReject(t *testing.T) {
+	defer setPropertyDenylist([]string{"annotation"}, "reject")()
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "kind", Values: stringArrayToPointer([]string{"Secret"})},
+		{Property: "annotation", Values: stringArrayToPointer([]string{"token=abc"})},
+	}}
+	resolver, _ := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}},
+		map[string]string{"kind": "string"})
+
+	_, err := resolver.Items()
+
+	assert.ErrorIs(t, err, ErrDeniedProperty)
+	assert.ErrorContains(t, err, "annotation")
+}
+
+// Should reject a dotted path within a denied property.
+func Test_SearchResolver_DeniedPropertyPath(t *testing.T) {
+	defer setPropertyDenylist([]string{"annotation"}, "reject")()
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "annotation.token", Values: stringArrayToPointer([]string{"abc"})},
+	}}
+	resolver, _ := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}},
+		map[string]string{"kind": "string"})
+
+	_, err := resolver.Items()
+
+	assert.ErrorIs(t, err, ErrDeniedProperty)
+}
+
+// Should drop the filter on a denied property when PROPERTY_DENYLIST_ACTION is drop.
+func Test_SearchResolver_DeniedPropertyDrop(t *testing.T) {
+	defer setPropertyDenylist([]string{"annotation"}, "drop")()
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "kind", Values: stringArrayToPointer([]string{"Secret"})},
+	}, FilterGroups: []*model.SearchFilterGroup{{Filters: []*model.SearchFilter{
+		{Property: "annotation", Values: stringArrayToPointer([]string{"token=abc"})},
+	}}}}
+	resolver, _ := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}},
+		map[string]string{"kind": "string"})
+
+	err := resolver.buildSearchQuery(context.Background(), false, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT DISTINCT "uid", "cluster", ("data" - '{"annotation"}'::text[]) AS "data" FROM "search"."resources" WHERE ("data"->'kind'?('Secret') AND ("cluster" = ANY ('{}'))) LIMIT 1000`, resolver.query)
+}
+
+// Should exclude the denied properties from the keyword match.
+func Test_SearchResolver_DeniedPropertyKeywords(t *testing.T) {
+	defer setPropertyDenylist([]string{"annotation", "secretRef"}, "reject")()
+	searchInput := &model.SearchInput{Keywords: stringArrayToPointer([]string{"abc"})}
+	resolver, _ := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+
+	err := resolver.buildSearchQuery(context.Background(), false, false)
+
+	assert.Nil(t, err)
+	assert.Contains(t, resolver.query,
+		`EXISTS((SELECT 1 FROM jsonb_each_text(("data" - '{"annotation","secretRef"}'::text[])) WHERE ("value" ILIKE '%abc%')))`)
+}
+
+// Should reject sorting by a denied property.
+func Test_SearchResolver_DeniedPropertySort(t *testing.T) {
+	defer setPropertyDenylist([]string{"annotation"}, "drop")()
+	searchInput := &model.SearchInput{Keywords: stringArrayToPointer([]string{"abc"}),
+		SortBy: &model.SearchSort{Property: "annotation"}}
+	resolver, _ := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+
+	err := resolver.buildSearchQuery(context.Background(), false, false)
+
+	assert.ErrorContains(t, err, "property [annotation] can't be used to sort the search results")
+}
+
+// Should reject or return no values when autocompleting a denied property, without querying the database.
+func Test_SearchComplete_DeniedProperty(t *testing.T) {
+	ctx := context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456")
+	defer setPropertyDenylist([]string{"annotation"}, "reject")()
+	resolver, _ := newMockSearchComplete(t, &model.SearchInput{}, "annotation",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+
+	result, err := resolver.autoComplete(ctx)
+	assert.ErrorIs(t, err, ErrDeniedProperty)
+	assert.Empty(t, result)
+
+	config.Cfg.PropertyDenylistAction = "drop"
+	result, err = resolver.autoComplete(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+}
+
+// Should reject autocomplete with a filter on a denied property.
+func Test_SearchComplete_DeniedPropertyFilter(t *testing.T) {
+	defer setPropertyDenylist([]string{"annotation"}, "reject")()
+	searchInput := &model.SearchInput{FilterGroups: []*model.SearchFilterGroup{{Filters: []*model.SearchFilter{
+		{Property: "annotation", Values: stringArrayToPointer([]string{"token=abc"})},
+	}}}}
+	resolver, _ := newMockSearchComplete(t, searchInput, "kind", rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+
+	_, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	assert.ErrorIs(t, err, ErrDeniedProperty)
+}
+
+// Should reject or return no counts for a denied property, and reject a filter on a denied property,
+// without querying the database.
+func Test_SearchCompleteWithCounts_DeniedProperty(t *testing.T) {
+	ctx := context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456")
+	defer setPropertyDenylist([]string{"annotation"}, "reject")()
+	resolver, _ := newMockSearchComplete(t, &model.SearchInput{}, "annotation",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+
+	result, err := resolver.autoCompleteWithCounts(ctx)
+	assert.ErrorIs(t, err, ErrDeniedProperty)
+	assert.Empty(t, result)
+
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "annotation", Values: stringArrayToPointer([]string{"token=abc"})},
+	}}
+	resolver, _ = newMockSearchComplete(t, searchInput, "kind", rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	_, err = resolver.autoCompleteWithCounts(ctx)
+	assert.ErrorIs(t, err, ErrDeniedProperty)
+
+	config.Cfg.PropertyDenylistAction = "drop"
+	resolver, _ = newMockSearchComplete(t, &model.SearchInput{}, "annotation",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	result, err = resolver.autoCompleteWithCounts(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+}
+
+// Should reject a denied identity property, or drop it from the identity.
+func Test_SearchDrift_DeniedProperty(t *testing.T) {
+	ctx := context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456")
+	defer setPropertyDenylist([]string{"annotation"}, "reject")()
+	resolver, _ := newMockSearchDrift(t, "Secret", []string{"name", "annotation"},
+		rbac.UserData{CsResources: []rbac.Resource{}})
+
+	result, err := resolver.drift(ctx)
+	assert.ErrorIs(t, err, ErrDeniedProperty)
+	assert.Empty(t, result)
+
+	// Drop
+	config.Cfg.PropertyDenylistAction = "drop"
+	resolver, mockPool := newMockSearchDrift(t, "Secret", []string{"name", "annotation"},
+		rbac.UserData{CsResources: []rbac.Resource{}})
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT COALESCE("data"->>'name', '') AS "id0", bool_or("data"?'_hubClusterResource') AS "hub", COALESCE(array_agg(DISTINCT "cluster") FILTER (WHERE NOT "data"?'_hubClusterResource'), '{}') AS "clusters" FROM "search"."resources" WHERE ("data"->'kind'?('Secret') AND ("cluster" = ANY ('{}'))) GROUP BY "id0" HAVING bool_or("data"?'_hubClusterResource') != bool_or(NOT "data"?'_hubClusterResource') ORDER BY "id0" ASC LIMIT 1000`),
+		gomock.Eq([]interface{}{})).Return(&MockRows{}, nil)
+	_, err = resolver.drift(ctx)
+	assert.Nil(t, err)
+
+	// Only denied properties, without querying the database.
+	resolver, _ = newMockSearchDrift(t, "Secret", []string{"annotation"}, rbac.UserData{CsResources: []rbac.Resource{}})
+	result, err = resolver.drift(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+}
+
+// Should hide the denied properties from the searchSchema results.
+func Test_SearchSchema_DeniedProperties(t *testing.T) {
+	defer setPropertyDenylist([]string{"label", "annotation"}, "reject")()
+	resolver, mockPool := newMockSearchSchema(t)
+	searchSchemaCache = &schemaCache{entries: map[string]schemaCacheEntry{}}
+	resolver.userData = rbac.UserData{CsResources: []rbac.Resource{}}
+	resolver.buildSearchSchemaQuery(context.TODO())
+
+	// MockRows scans a single string from the uid.
+	mockRows := &MockRows{mockData: []map[string]interface{}{
+		{"uid": "annotation"}, {"uid": "kind"}, {"uid": "restarts"}}}
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq(resolver.query)).Return(mockRows, nil)
+
+	res, err := resolver.searchSchemaResults(context.TODO())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"cluster", "kind", "name", "namespace", "status", "restarts"}, res["allProperties"])
+}
+
+// Matches the denied properties subtracted from the data column. Sample: ("data" - '{"annotation"}'::text[]) AS "data"
+var subtractedDataKeys = regexp.MustCompile(`\("data" - '\{([^}]*)\}'::text\[\]\) AS "data"`)
+
+// Mock the database for the resources with a denied property. The properties subtracted from the
+// selected data are removed from the rows, like the database does.
+func mockDeniedDataQuery(mockPool *pgxpoolmock.MockPgxPool) {
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Eq([]interface{}{})).
+		DoAndReturn(func(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+			data := map[string]interface{}{"kind": "Secret", "name": "db-creds", "annotation": "token=abc"}
+			if match := subtractedDataKeys.FindStringSubmatch(query); match != nil {
+				for _, key := range strings.Split(match[1], ",") {
+					delete(data, strings.Trim(key, `"`))
+				}
+			}
+			return &MockRows{mockData: []map[string]interface{}{
+				{"uid": "local-cluster/secret-uid", "cluster": "local-cluster", "data": data},
+			}, columnHeaders: []string{"uid", "cluster", "data"}}, nil
+		})
+}
+
+// Should leave out the denied properties from the data of the items, the export and getResource.
+func Test_DeniedPropertyData(t *testing.T) {
+	defer setPropertyDenylist([]string{"annotation"}, "reject")()
+	ud := rbac.UserData{CsResources: []rbac.Resource{}}
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "kind", Values: stringArrayToPointer([]string{"Secret"})}}}
+
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string"})
+	mockDeniedDataQuery(mockPool)
+	items, err := resolver.Items()
+	assert.Nil(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, "db-creds", items[0]["name"])
+	assert.NotContains(t, items[0], "annotation")
+
+	resolver, mockPool = newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string"})
+	mockDeniedDataQuery(mockPool)
+	w := &exportRecorder{}
+	count, err := resolver.exportItems(w)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, count)
+	assert.Contains(t, w.String(), "db-creds")
+	assert.NotContains(t, w.String(), "annotation")
+
+	getResource, mockPool := newMockGetResource(t, "local-cluster/secret-uid", ud)
+	mockDeniedDataQuery(mockPool)
+	item, err := getResource.resource(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+	assert.Nil(t, err)
+	assert.Equal(t, "db-creds", item["name"])
+	assert.NotContains(t, item, "annotation")
+}
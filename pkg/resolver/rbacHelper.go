@@ -3,13 +3,17 @@ package resolver
 
 import (
 	"encoding/json"
+	"errors"
 
 	"github.com/doug-martin/goqu/v9"
 	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/lib/pq"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
 	"github.com/stolostron/search-v2-api/pkg/rbac"
 	v1 "k8s.io/api/authentication/v1"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/strings/slices"
 )
 
 // function to loop through resources and build the where clause
@@ -82,6 +86,9 @@ func matchClusterScopedResources(csRes []rbac.Resource, userInfo v1.UserInfo) ex
 //	(namespace = 'a' AND ((apigroup='' AND kind='') OR (apigroup='' AND kind='') OR ... ) OR
 //	(namespace = 'b' AND ( ... ) OR (namespace = 'c' AND ( ... ) OR ...
 
+// Decodes the consolidated resource groups. Replaced by the unit tests to force the non-consolidated fallback.
+var unmarshalNsResources = json.Unmarshal
+
 func matchNamespacedResources(nsResources map[string][]rbac.Resource, userInfo v1.UserInfo) exp.ExpressionList {
 	var whereNsDs []exp.Expression
 	namespaces := getKeys(nsResources)
@@ -106,7 +113,7 @@ func matchNamespacedResources(nsResources map[string][]rbac.Resource, userInfo v
 			for count, resources := range keys {
 				namespaces := consolidateNsList[resources]
 				resList := []rbac.Resource{}
-				unMarshalErr = json.Unmarshal([]byte(resources), &resList)
+				unMarshalErr = unmarshalNsResources([]byte(resources), &resList)
 				if unMarshalErr == nil {
 					whereNsDs[count] = goqu.And(goqu.L("???", goqu.L(`data->?`, "namespace"),
 						goqu.Literal("?|"), pq.Array(namespaces)),
@@ -118,7 +125,9 @@ func matchNamespacedResources(nsResources map[string][]rbac.Resource, userInfo v
 		}
 		// if consolidating namespaces, doesn't work, proceed as usual without consolidation
 		if jsonMarshalErr != nil || unMarshalErr != nil {
-			klog.V(2).Info("Using non-consolidated namespace list")
+			klog.Warningf("Error consolidating namespaces, using non-consolidated namespace list. Error: %v",
+				errors.Join(jsonMarshalErr, unMarshalErr))
+			metrics.RBACNamespaceConsolidationFallback.Inc()
 			whereNsDs = make([]exp.Expression, len(nsResources))
 			for nsCount, namespace := range namespaces {
 				whereNsDs[nsCount] = goqu.And(goqu.L("???", goqu.L(`data->?`, "namespace"),
@@ -153,6 +162,9 @@ func consolidateNsResources(nsResources map[string][]rbac.Resource) (map[string]
 	}
 
 	klog.V(4).Infof("RBAC consolidation reduced from %d namespaces/s to %d namespace group/s.", len(nsResources), len(m))
+	if len(nsResources) > 0 {
+		metrics.RBACNamespaceConsolidationRatio.Observe(float64(len(m)) / float64(len(nsResources)))
+	}
 	return m, getKeys(m), nil
 }
 
@@ -183,11 +195,149 @@ func matchHubCluster(userrbac rbac.UserData, userInfo v1.UserInfo) exp.Expressio
 // Resolves to:
 //	( cluster IN ['a', 'b', ...] )
 
-func matchManagedCluster(managedClusters []string) exp.BooleanExpression {
-	if len(managedClusters) == 1 && managedClusters[0] == "*" {
+func matchManagedCluster(managedClusters []string, managedClusterAllAccess bool) exp.BooleanExpression {
+	if managedClusterAllAccess {
 		klog.V(2).Infof("user has access to all managed clusters")
 		return goqu.C("cluster").Neq("local-cluster")
 	}
 	//managed clusters
 	return goqu.C("cluster").Eq(goqu.Any(pq.Array(managedClusters)))
 }
+
+// Returns true when the RBAC clause doesn't restrict the results, the user is authorized to search all the
+// managed clusters and all the resources on the hub.
+func hasUnrestrictedAccess(userrbac rbac.UserData) bool {
+	if !userrbac.ManagedClusterAllAccess {
+		return false
+	}
+	if _, allNamespaces := userrbac.NsResources["*"]; !allNamespaces || len(userrbac.NsResources) != 1 {
+		return false
+	}
+	return len(userrbac.CsResources) == 1 && userrbac.CsResources[0].Apigroup == "*" &&
+		userrbac.CsResources[0].Kind == "*"
+}
+
+// Returns the clusters where the user is authorized to search resources.
+// The hub cluster (local-cluster) is included when the user can access any resource on the hub.
+// Returns nil when the user has access to all managed clusters.
+func authorizedClusters(userrbac rbac.UserData) []string {
+	if userrbac.ManagedClusterAllAccess {
+		return nil
+	}
+	clusters := getKeys(userrbac.ManagedClusters)
+	if len(userrbac.CsResources) > 0 || len(userrbac.NsResources) > 0 {
+		clusters = append(clusters, "local-cluster")
+	}
+	return clusters
+}
+
+// Returns the namespaces from exact match namespace filters. When there are multiple namespace filters,
+// returns the namespaces present in all of them.
+// Returns nil if there isn't a namespace filter or if it uses operators or partial match.
+func namespaceFilterValues(input *model.SearchInput) []string {
+	var namespaces []string
+	if input == nil {
+		return nil
+	}
+	for _, filter := range input.Filters {
+		if filter.Property != "namespace" || len(filter.Values) == 0 {
+			continue
+		}
+		filterNamespaces := []string{}
+		for _, value := range PointerToStringArray(filter.Values) {
+			operator, operand := getOperatorFromString(value)
+			if operator != "=" || isGlobPattern(operand) || operand == existsValue {
+				return nil
+			}
+			if namespaces == nil || slices.Contains(namespaces, operand) {
+				filterNamespaces = append(filterNamespaces, operand)
+			}
+		}
+		namespaces = filterNamespaces
+	}
+	return namespaces
+}
+
+// Restrict the user's authorized hub resources to the namespaces in the search filter.
+// Other namespaces and cluster-scoped resources can't match the filter, so these are removed
+// to avoid a redundant RBAC clause.
+func restrictToNamespaces(userrbac rbac.UserData, namespaces []string) rbac.UserData {
+	if namespaces == nil {
+		return userrbac
+	}
+	if _, allNamespaces := userrbac.NsResources["*"]; allNamespaces {
+		return userrbac
+	}
+	nsResources := map[string][]rbac.Resource{}
+	for _, namespace := range namespaces {
+		if resources, authorized := userrbac.NsResources[namespace]; authorized {
+			nsResources[namespace] = resources
+		}
+	}
+	return rbac.UserData{
+		CsResources:             []rbac.Resource{},
+		NsResources:             nsResources,
+		ManagedClusters:         userrbac.ManagedClusters,
+		ManagedClusterAllAccess: userrbac.ManagedClusterAllAccess,
+	}
+}
+
+// Restrict the user's authorized clusters to the clusters requested in the search input.
+// The requested clusters the user isn't authorized to search are ignored.
+func restrictToClusters(userrbac rbac.UserData, clusters []string) rbac.UserData {
+	if len(clusters) == 0 {
+		return userrbac
+	}
+	managedClusters := map[string]struct{}{}
+	for _, cluster := range clusters {
+		_, authorized := userrbac.ManagedClusters[cluster]
+		if cluster != "local-cluster" && (userrbac.ManagedClusterAllAccess || authorized) {
+			managedClusters[cluster] = struct{}{}
+		}
+	}
+	restricted := rbac.UserData{
+		CsResources:     userrbac.CsResources,
+		NsResources:     userrbac.NsResources,
+		ManagedClusters: managedClusters,
+	}
+	if !slices.Contains(clusters, "local-cluster") { // The hub resources aren't requested.
+		restricted.CsResources = []rbac.Resource{}
+		restricted.NsResources = map[string][]rbac.Resource{}
+	}
+	return restricted
+}
+
+// Remove the clusters excluded in the search input from the user's authorized clusters.
+// Users with access to all managed clusters keep the all access, the excluded clusters are removed by the
+// WHERE clause. Excluding the hub cluster (local-cluster) removes the hub resources.
+func excludeClusters(userrbac rbac.UserData, clusters []string) rbac.UserData {
+	if len(clusters) == 0 {
+		return userrbac
+	}
+	managedClusters := map[string]struct{}{}
+	for cluster := range userrbac.ManagedClusters {
+		if !slices.Contains(clusters, cluster) {
+			managedClusters[cluster] = struct{}{}
+		}
+	}
+	restricted := rbac.UserData{
+		CsResources:             userrbac.CsResources,
+		NsResources:             userrbac.NsResources,
+		ManagedClusters:         managedClusters,
+		ManagedClusterAllAccess: userrbac.ManagedClusterAllAccess,
+	}
+	if slices.Contains(clusters, "local-cluster") { // The hub resources are excluded.
+		restricted.CsResources = []rbac.Resource{}
+		restricted.NsResources = map[string][]rbac.Resource{}
+	}
+	return restricted
+}
+
+// Restrict the user's authorized resources to the namespaces and clusters in the search input.
+func restrictToInput(userrbac rbac.UserData, input *model.SearchInput) rbac.UserData {
+	userrbac = restrictToNamespaces(userrbac, namespaceFilterValues(input))
+	if input == nil {
+		return userrbac
+	}
+	return excludeClusters(restrictToClusters(userrbac, input.Clusters), input.ExcludeClusters)
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/doug-martin/goqu/v9"
 	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/stolostron/search-v2-api/graph/model"
 	"github.com/stolostron/search-v2-api/pkg/config"
 	"github.com/stolostron/search-v2-api/pkg/rbac"
 	klog "k8s.io/klog/v2"
@@ -209,7 +210,7 @@ func (s *SearchResult) buildQueryToGetItemsFromUIDs() {
 	ds := goqu.From(schemaTable)
 
 	// SELECT CLAUSE
-	selectDs := ds.Select("uid", "cluster", "data")
+	selectDs := ds.Select("uid", "cluster", selectAllowedData())
 
 	// WHERE CLAUSE
 	whereDs := []exp.Expression{goqu.C("uid").In(s.uids)} // Add filter to avoid selecting the search object itself
@@ -249,7 +250,7 @@ func (s *SearchResult) getRelationResolvers(ctx context.Context) []SearchRelated
 	}
 	// Build the relations query
 	s.buildRelationsQuery()
-	relations, relQueryError := s.pool.Query(s.context, s.query, s.params...) // how to deal with defaults.
+	relations, relQueryError := query(s.context, s.pool, s.query, s.params...) // how to deal with defaults.
 	if relQueryError != nil {
 		klog.Errorf("Error while executing getRelations query. Error :%s", relQueryError.Error())
 		return relatedSearch
@@ -278,6 +279,10 @@ func (s *SearchResult) getRelationResolvers(ctx context.Context) []SearchRelated
 			// Store result->currentSearchUID relation
 			s.updResultToCurrSearchUidsMap(uid, currSearchUidsMap, resultToCurrSearchUidsMap, path)
 		}
+		if err := relations.Err(); err != nil {
+			klog.Errorf("Error reading the rows of getRelations query. Error :%s", err.Error())
+			return relatedSearch
+		}
 	}
 	// get uids for related items that match the relatedKind filter.
 	s.filterRelatedUIDs(relatedMap)
@@ -388,11 +393,28 @@ func (s *SearchResult) updResultToCurrSearchUidsMap(resultUid string, currSearch
 	}
 }
 
+// Max number of levels the user can request with relatedDepth. RELATION_LEVEL can set a greater value.
+const maxRelatedDepth = 3
+
 func (s *SearchResult) setDepth() {
 	// This level will come into effect only in case of Application relations.
 	// For normal searches, we go only upto level 1. This can be changed later, if necessary.
 	s.level = config.Cfg.RelationLevel
-	//The level can be parameterized later, if needed
+
+	// Level requested by the user in the search input.
+	if s.input.RelatedDepth != nil && *s.input.RelatedDepth > 0 {
+		maxLevel := maxRelatedDepth
+		if config.Cfg.RelationLevel > maxLevel {
+			maxLevel = config.Cfg.RelationLevel
+		}
+		s.level = *s.input.RelatedDepth
+		if s.level > maxLevel {
+			klog.V(2).Infof("Requested relatedDepth %d exceeds the max level. Using %d.", s.level, maxLevel)
+			s.level = maxLevel
+		}
+		klog.V(3).Infof("Level set by relatedDepth: %d.", s.level)
+		return
+	}
 
 	//Set level
 	if s.searchApplication() && s.level == 0 {
@@ -407,7 +429,16 @@ func (s *SearchResult) setDepth() {
 // Check if the search input filters contain Application - either in kind field or relatedKinds
 func (s *SearchResult) searchApplication() bool {
 	srchString := "Application"
-	for _, filter := range s.input.Filters {
+	filters := append([]*model.SearchFilter{}, s.input.Filters...)
+	for _, group := range s.input.FilterGroups {
+		if group != nil {
+			filters = append(filters, group.Filters...)
+		}
+	}
+	for _, filter := range filters {
+		if filter == nil {
+			continue
+		}
 		for _, val := range filter.Values {
 			if strings.EqualFold(*val, srchString) {
 				klog.V(9).Info("searchApplication returns true. Search filter includes application")
@@ -177,7 +177,7 @@ func Test_SearchResolver_RelatedKindsRelationships_NegativeLimit(t *testing.T) {
 	).Return(mockRows, nil)
 
 	// Mock the SECOND database request.
-	query2 := `SELECT "uid", "cluster", "data" FROM "search"."resources" WHERE ("uid" IN ('local-cluster/30c35f12-320a-417f-98d1-fbee28a4b2a6'))`
+	query2 := `SELECT "uid", "cluster", "data" FROM "search"."resources" WHERE ("uid" IN ('local-cluster/30c35f12-320a-417f-98d1-fbee28a4b2a6')) LIMIT 100000`
 	mockRows2 := newMockRowsWithoutRBAC("./mocks/mock.json", searchInput, "", 0)
 	mockPool.EXPECT().Query(gomock.Any(),
 		gomock.Eq(query2),
@@ -220,7 +220,7 @@ func Test_SearchResolver_Level1Related(t *testing.T) {
 	).Return(mockRows, nil)
 
 	// Mock the SECOND database request.
-	query2 := `SELECT "uid", "cluster", "data" FROM "search"."resources" WHERE ("uid" IN ('local-cluster/30c35f12-320a-417f-98d1-fbee28a4b2a6'))`
+	query2 := `SELECT "uid", "cluster", "data" FROM "search"."resources" WHERE ("uid" IN ('local-cluster/30c35f12-320a-417f-98d1-fbee28a4b2a6')) LIMIT 100000`
 	mockRows2 := newMockRowsWithoutRBAC("./mocks/mock.json", searchInput, "", 0)
 	mockPool.EXPECT().Query(gomock.Any(),
 		gomock.Eq(query2),
@@ -315,3 +315,57 @@ func TestUpdResultToCurrSearchUidsMap(t *testing.T) {
 	assert.Equal(t, len(resultMap["uid678"]), 1, "There should be two related uids in the map")
 	assert.Equal(t, resultMap["uid678"], []string{"uid567"}, "There should be 1 related uid in the map")
 }
+
+func Test_setDepth(t *testing.T) {
+	defer func() { config.Cfg.RelationLevel = 0 }()
+	app := "Application"
+	depth := func(d int) *int { return &d }
+	tests := []struct {
+		name          string
+		relationLevel int
+		input         *model.SearchInput
+		expected      int
+	}{
+		{"default", 0, &model.SearchInput{}, 1},
+		{"application", 0, &model.SearchInput{RelatedKinds: []*string{&app}}, 3},
+		{"relation level", 2, &model.SearchInput{}, 2},
+		{"related depth", 0, &model.SearchInput{RelatedDepth: depth(2)}, 2},
+		{"related depth with application", 0, &model.SearchInput{RelatedKinds: []*string{&app}, RelatedDepth: depth(1)}, 1},
+		{"related depth overrides relation level", 3, &model.SearchInput{RelatedDepth: depth(1)}, 1},
+		{"related depth ignored if not positive", 2, &model.SearchInput{RelatedDepth: depth(0)}, 2},
+		{"related depth exceeds max", 0, &model.SearchInput{RelatedDepth: depth(10)}, maxRelatedDepth},
+		{"related depth exceeds relation level", 5, &model.SearchInput{RelatedDepth: depth(10)}, 5},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config.Cfg.RelationLevel = test.relationLevel
+			s := &SearchResult{input: test.input}
+			s.setDepth()
+			assert.Equal(t, test.expected, s.level)
+		})
+	}
+}
+
+func Test_SearchResolver_RelationshipsWithRelatedDepth(t *testing.T) {
+	config.Cfg.RelationLevel = 3
+	defer func() { config.Cfg.RelationLevel = 0 }()
+
+	// Build a mock SearchResolver{} using uids as filter input.
+	uid1 := "local-cluster/e12c2ddd-4ac5-499d-b0e0-20242f508afd"
+	resultList := []*string{&uid1}
+	depth := 1
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "uid", Values: resultList}},
+		RelatedDepth: &depth}
+	csRes, nsRes, mc := newUserData()
+	ud := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: mc}
+	resolver, _ := newMockSearchResolver(t, searchInput, resultList, ud, nil)
+	resolver.context = context.Background()
+
+	resolver.buildRelationsQuery()
+
+	// Relationships are found without recursion and the RBAC clause is applied to the related resources.
+	assert.Equal(t, 1, resolver.level)
+	assert.NotContains(t, resolver.query, "WITH RECURSIVE")
+	assert.Contains(t, resolver.query, `WHERE (("level" <= 1) AND ("uid" NOT IN ('local-cluster/e12c2ddd-4ac5-499d-b0e0-20242f508afd')))`)
+	assert.Contains(t, resolver.query, `INNER JOIN "search"."resources" ON ("related"."uid" = "resources".uid) WHERE (("cluster" = ANY ('{"managed1","managed2"}'))`)
+}
@@ -3,10 +3,12 @@ package resolver
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/doug-martin/goqu/v9"
 	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/driftprogramming/pgxpoolmock"
@@ -16,8 +18,10 @@ import (
 	db "github.com/stolostron/search-v2-api/pkg/database"
 	"github.com/stolostron/search-v2-api/pkg/metrics"
 	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stolostron/search-v2-api/pkg/tracing"
 	v1 "k8s.io/api/authentication/v1"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/strings/slices"
 )
 
 type SearchResult struct {
@@ -31,6 +35,13 @@ type SearchResult struct {
 	uids      []*string // List of uids from search result to be used to get relatioinships.
 	userData  rbac.UserData
 	wg        sync.WaitGroup // Used to serialize search query and relatioinships query.
+
+	count      *searchCount  // Count resolved concurrently with the items.
+	export     bool          // The items are streamed to an export, without the MAX_QUERY_LIMIT.
+	page       searchPage    // Items fetched once, shared with the next cursor.
+	itemsCount int           // Number of items resolved.
+	lastItem   *searchCursor // Position of the last item resolved, when the items are paged.
+	audited    sync.Once     // The audit log entry is written once for the search.
 }
 
 const ErrorMsg string = "Error building Search query:"
@@ -39,6 +50,11 @@ func Search(ctx context.Context, input []*model.SearchInput) ([]*SearchResult, e
 	defer metrics.SlowLog("SearchResolver", 0)()
 	// For each input, create a SearchResult resolver.
 	srchResult := make([]*SearchResult, len(input))
+	for _, in := range input {
+		if err := ValidateSearchInput(in); err != nil {
+			return srchResult, err
+		}
+	}
 	userData, userDataErr := rbac.GetCache().GetUserData(ctx)
 	if userDataErr != nil {
 		return srchResult, userDataErr
@@ -50,6 +66,12 @@ func Search(ctx context.Context, input []*model.SearchInput) ([]*SearchResult, e
 		klog.Warningf("Error creating datatype map. Error: [%s] ", err)
 	}
 
+	// The count is resolved after the items, start it now to run both queries concurrently.
+	countRequested := false
+	if graphql.GetFieldContext(ctx) != nil {
+		countRequested = slices.Contains(graphql.CollectAllFields(ctx), "count")
+	}
+
 	// Proceed if user's rbac data exists
 	if len(input) > 0 {
 		for index, in := range input {
@@ -60,17 +82,59 @@ func Search(ctx context.Context, input []*model.SearchInput) ([]*SearchResult, e
 				context:   ctx,
 				propTypes: propTypes,
 			}
+			if countRequested {
+				srchResult[index].startCount()
+			}
 		}
 	}
 	return srchResult, nil
 
 }
 
+// Count the resources matching the input, without fetching the items.
+func SearchCount(ctx context.Context, input *model.SearchInput) (int, error) {
+	results, err := Search(ctx, []*model.SearchInput{input})
+	if err != nil {
+		return 0, err
+	}
+	return results[0].Count(ctx)
+}
+
+// Returns the uids of the resources matching the input, without fetching the data.
+func SearchUIDs(ctx context.Context, input *model.SearchInput) ([]string, error) {
+	results, err := Search(ctx, []*model.SearchInput{input})
+	if err != nil {
+		return nil, err
+	}
+	return results[0].uidList()
+}
+
+// Resolves the uids of the matching resources. Selects only the uid column, with the same filters and RBAC.
+func (s *SearchResult) uidList() ([]string, error) {
+	if !s.matchesManagedHubFilter() { // if current hub is not part of managedHub filter, stop search
+		return []string{}, nil
+	}
+	if err := s.Uids(); err != nil {
+		return nil, err
+	}
+	s.audit("uids", len(s.uids))
+	return PointerToStringArray(s.uids), nil
+}
+
 // Stop search if managedHub is a filter and current hub name is not in values.
 // Otherwise, proceed with the search.
 func (s *SearchResult) matchesManagedHubFilter() bool {
+	return matchesManagedHub(s.input)
+}
+
+// Returns false if managedHub is a filter of the input and the current hub name is not in values.
+// Shared by the queries that use a search input, like search and searchFacets.
+func matchesManagedHub(input *model.SearchInput) bool {
 	klog.V(7).Info("HUB_NAME is ", config.Cfg.HubName)
-	for _, filter := range s.input.Filters {
+	if input == nil {
+		return true
+	}
+	for _, filter := range input.Filters {
 		if filter.Property == "managedHub" {
 			klog.V(5).Infof("managedHub filter: %s values: %+v \n", filter.Property,
 				PointerToStringArray(filter.Values))
@@ -92,34 +156,113 @@ func (s *SearchResult) matchesManagedHubFilter() bool {
 	return true
 }
 
-func (s *SearchResult) Count() (int, error) {
-	if !s.matchesManagedHubFilter() { // if current hub is not part of managedHub filter, stop search
-		return 0, nil
+// Total number of resources matching the query, without the limit.
+func (s *SearchResult) Count(ctx context.Context) (int, error) {
+	if s.count == nil {
+		if s.context == nil {
+			s.context = ctx
+		}
+		s.count = s.newCount()
 	}
-	klog.V(2).Info("Resolving SearchResult:Count()")
-	err := s.buildSearchQuery(s.context, true, false)
-	if err != nil {
-		return 0, err
+	count, err := s.count.wait()
+	if err == nil {
+		s.audit("count", count)
+	}
+	return count, err
+}
+
+// Start resolving the count in the background. The query is built before starting the goroutine, because
+// building it reads the shared cache. Only the database query runs concurrently with the items.
+func (s *SearchResult) startCount() {
+	s.count = s.newCount()
+	s.count.build()
+	go s.count.wait() // The result is kept for Count().
+}
+
+// Count of the resources, resolved once. Uses a copy of the search to build its own query,
+// so it can run concurrently with the items query.
+type searchCount struct {
+	once   sync.Once
+	search *SearchResult
+	built  bool // The query was built. Only the database query is left.
+	skip   bool // The current hub isn't part of the managedHub filter.
+	value  int
+	err    error
+}
+
+func (s *SearchResult) newCount() *searchCount {
+	propTypes := make(map[string]string, len(s.propTypes))
+	for prop, dataType := range s.propTypes {
+		propTypes[prop] = dataType
+	}
+	return &searchCount{search: &SearchResult{context: s.context, input: s.input, pool: s.pool,
+		propTypes: propTypes, userData: s.userData}}
+}
+
+func (c *searchCount) build() {
+	c.built = true
+	if !c.search.matchesManagedHubFilter() { // if current hub is not part of managedHub filter, stop search
+		c.skip = true
+		return
 	}
-	return s.resolveCount()
+	c.err = c.search.buildSearchQuery(c.search.context, true, false)
 }
 
+func (c *searchCount) wait() (int, error) {
+	c.once.Do(func() {
+		if !c.built {
+			c.build()
+		}
+		if c.skip || c.err != nil {
+			return
+		}
+		klog.V(2).Info("Resolving SearchResult:Count()")
+		c.value, c.err = c.search.resolveCount()
+	})
+	return c.value, c.err
+}
+
+// Returns the items as maps. The items encoded by the stream serialization are decoded.
 func (s *SearchResult) Items() ([]map[string]interface{}, error) {
+	page := s.fetchPage()
+	if page.err != nil {
+		return nil, page.err
+	}
+	items := make([]map[string]interface{}, 0, len(page.items))
+	for _, item := range page.items {
+		data := item.data
+		if data == nil {
+			if err := json.Unmarshal(item.encoded, &data); err != nil {
+				return nil, err
+			}
+		}
+		items = append(items, data)
+	}
+	return items, nil
+}
+
+// Builds the search query and resolves the items, calling addItem with each item.
+// Called once for the search by fetchPage(), so both serializations resolve and audit the items the same way.
+func (s *SearchResult) searchItems(addItem func(item map[string]interface{})) error {
 	s.wg.Add(1)
 	defer s.wg.Done()
 	if !s.matchesManagedHubFilter() { // if current hub is not part of managedHub filter, stop search
-		return []map[string]interface{}{}, nil
+		return nil
 	}
 	klog.V(2).Info("Resolving SearchResult:Items()")
+	s.itemsCount = 0
 	err := s.buildSearchQuery(s.context, false, false)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	r, e := s.resolveItems()
+	e := s.readItems(addItem)
 	if e != nil {
 		s.checkErrorBuildingQuery(e, "Error resolving items.")
 	}
-	return r, e
+	if e == nil {
+		s.audit("items", s.itemsCount)
+	}
+	return e
 }
 
 func (s *SearchResult) Related(ctx context.Context) ([]SearchRelatedResult, error) {
@@ -163,7 +306,7 @@ func (s *SearchResult) Uids() error {
 // Build where clause with rbac by combining clusterscoped, namespace scoped and managed cluster access
 func buildRbacWhereClause(ctx context.Context, userrbac rbac.UserData, userInfo v1.UserInfo) exp.ExpressionList {
 	return goqu.Or(
-		matchManagedCluster(getKeys(userrbac.ManagedClusters)), // goqu.I("cluster").In([]string{"clusterNames", ....})
+		matchManagedCluster(getKeys(userrbac.ManagedClusters), userrbac.ManagedClusterAllAccess), // goqu.I("cluster").In([]string{"clusterNames", ....})
 		matchHubCluster(userrbac, userInfo),
 	)
 }
@@ -171,6 +314,13 @@ func buildRbacWhereClause(ctx context.Context, userrbac rbac.UserData, userInfo
 // Example query: SELECT uid, cluster, data FROM search.resources  WHERE lower(data->> 'kind') IN
 // (lower('Pod')) AND lower(data->> 'cluster') IN (lower('local-cluster')) LIMIT 1000
 func (s *SearchResult) buildSearchQuery(ctx context.Context, count bool, uid bool) error {
+	ctx, span := tracing.Start(ctx, "resolver.buildSearchQuery")
+	err := s.buildQuery(ctx, count, uid)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *SearchResult) buildQuery(ctx context.Context, count bool, uid bool) error {
 	var limit int
 	var selectDs *goqu.SelectDataset
 	var whereDs []exp.Expression
@@ -182,12 +332,7 @@ func (s *SearchResult) buildSearchQuery(ctx context.Context, count bool, uid boo
 	schemaTable := goqu.S("search").Table("resources")
 	ds := goqu.From(schemaTable)
 
-	if s.input.Keywords != nil && len(s.input.Keywords) > 0 {
-		jsb := goqu.L("jsonb_each_text(?)", goqu.C("data"))
-		ds = goqu.From(schemaTable, jsb)
-	}
-
-	if s.input != nil && (len(s.input.Filters) > 0 || (s.input.Keywords != nil && len(s.input.Keywords) > 0)) {
+	if hasWhereFilters(s.input) {
 		// WHERE CLAUSE
 		whereDs, s.propTypes, err = WhereClauseFilter(s.context, s.input, s.propTypes)
 		if err != nil {
@@ -212,13 +357,12 @@ func (s *SearchResult) buildSearchQuery(ctx context.Context, count bool, uid boo
 
 		// if one of them is not nil, userData is not empty
 		if s.userData.CsResources != nil || s.userData.NsResources != nil || s.userData.ManagedClusters != nil {
-			whereDs = append(whereDs,
-				buildRbacWhereClause(ctx, s.userData, userInfo)) // add rbac
-			if len(whereDs) == 0 {
-				s.checkErrorBuildingQuery(fmt.Errorf("search query must contain a whereClause"),
-					ErrorMsg)
+			rbacData := restrictToInput(s.userData, s.input)
+			if err = checkFullScan("search", whereDs, rbacData, s.input); err != nil {
+				s.checkErrorBuildingQuery(err, ErrorMsg)
 				return err
 			}
+			whereDs = append(whereDs, buildRbacWhereClause(ctx, rbacData, userInfo)) // add rbac
 		} else {
 			errorStr := fmt.Sprintf("RBAC clause is required! None found for search query %+v for user %s with uid %s ",
 				s.input, userInfo.Username, userInfo.UID)
@@ -226,14 +370,54 @@ func (s *SearchResult) buildSearchQuery(ctx context.Context, count bool, uid boo
 			return fmt.Errorf(errorStr)
 		}
 	} else {
-		s.checkErrorBuildingQuery(fmt.Errorf("query input must contain a filter or keyword. Received: %+v",
-			s.input), ErrorMsg)
-		return fmt.Errorf("query input must contain a filter or keyword. Received: %+v",
-			s.input)
+		klog.V(3).Infof("Query input without filters or keywords. Received: %+v", s.input)
+		err = invalidInputf("query input must contain a filter or keyword")
+		s.checkErrorBuildingQuery(err, ErrorMsg)
+		return err
+	}
+
+	// ORDER BY CLAUSE
+	// The items are selected with DISTINCT, so these are sorted in an outer query.
+	// Paged items are sorted by uid if sortBy isn't set, and start after the cursor.
+	if !count && !uid {
+		orderExp, sortErr := s.sortExpressions()
+		if sortErr != nil {
+			s.checkErrorBuildingQuery(sortErr, ErrorMsg)
+			return sortErr
+		}
+		if orderExp == nil && (s.paginated() || s.export) {
+			orderExp = []exp.OrderedExpression{goqu.C("uid").Asc()}
+		}
+		cursorExp, cursorErr := s.cursorExpression()
+		if cursorErr != nil {
+			s.checkErrorBuildingQuery(cursorErr, ErrorMsg)
+			return cursorErr
+		}
+		// Select only the fields requested. The items are sorted by the data in the inner query.
+		dataExp, fieldsErr := s.selectData()
+		if fieldsErr != nil {
+			s.checkErrorBuildingQuery(fieldsErr, ErrorMsg)
+			return fieldsErr
+		}
+		if orderExp != nil {
+			selectDs = goqu.From(selectDs.Where(whereDs...).As("items")).
+				Select("uid", "cluster", dataExp).
+				Order(orderExp...)
+			whereDs = nil
+			if cursorExp != nil {
+				whereDs = []exp.Expression{cursorExp}
+			}
+		} else {
+			selectDs = ds.SelectDistinct("uid", "cluster", dataExp)
+		}
 	}
 
 	// LIMIT CLAUSE
-	if !count {
+	if s.export {
+		limit = s.exportLimit()
+	} else if !count && !uid {
+		limit = s.pageLimit()
+	} else if !count {
 		limit = s.setLimit()
 	}
 
@@ -260,10 +444,12 @@ func (s *SearchResult) checkErrorBuildingQuery(err error, logMessage string) {
 }
 
 func (s *SearchResult) resolveCount() (int, error) {
-	rows := s.pool.QueryRow(context.TODO(), s.query, s.params...)
+	start := time.Now()
+	rows := queryRow(s.context, s.pool, s.query, s.params...)
 
 	var count int
 	err := rows.Scan(&count)
+	metrics.ObserveQueryDuration(metrics.SearchQueryDuration, start, err)
 	if err != nil {
 		klog.Errorf("Error resolving count. Error: %s  Query: %s", err.Error(), s.query)
 	}
@@ -271,7 +457,7 @@ func (s *SearchResult) resolveCount() (int, error) {
 }
 
 func (s *SearchResult) resolveUids() error {
-	rows, err := s.pool.Query(s.context, s.query, s.params...)
+	rows, err := query(s.context, s.pool, s.query, s.params...)
 	if err != nil {
 		klog.Errorf("Error resolving UIDs. Query [%s] with args [%+v]. Error: [%+v]", s.query, s.params, err)
 		return err
@@ -285,22 +471,40 @@ func (s *SearchResult) resolveUids() error {
 		}
 		s.uids = append(s.uids, &uid)
 	}
-	return nil
+	// Errors reading the rows, like the query timeout, are only returned by rows.Err().
+	if err = rows.Err(); err != nil {
+		klog.Errorf("Error reading UIDs. Query [%s] with args [%+v]. Error: [%+v]", s.query, s.params, err)
+	}
+	return err
 }
 func (s *SearchResult) resolveItems() ([]map[string]interface{}, error) {
 	items := []map[string]interface{}{}
+	err := s.readItems(func(item map[string]interface{}) {
+		items = append(items, item)
+	})
+	if err != nil {
+		return []map[string]interface{}{}, err
+	}
+	return items, nil
+}
+
+// Runs the items query and calls addItem with each formatted item read from the rows.
+func (s *SearchResult) readItems(addItem func(item map[string]interface{})) error {
 	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("resolveItemsFunc"))
+	start := time.Now()
 	klog.V(5).Infof("Query issued by resolver [%s] ", s.query)
-	rows, err := s.pool.Query(s.context, s.query, s.params...)
+	rows, err := query(s.context, s.pool, s.query, s.params...)
 
 	defer timer.ObserveDuration()
 	if err != nil {
+		metrics.ObserveQueryDuration(metrics.SearchQueryDuration, start, err)
 		klog.Errorf("Error resolving query [%s] with args [%+v]. Error: [%+v]", s.query, s.params, err)
-		return items, err
+		return err
 	}
 	defer rows.Close()
+	defer func() { metrics.ObserveQueryDuration(metrics.SearchQueryDuration, start, rows.Err()) }() // After reading the rows.
 
-	s.uids = make([]*string, len(items))
+	s.uids = []*string{}
 
 	for rows.Next() {
 		var uid string
@@ -309,17 +513,30 @@ func (s *SearchResult) resolveItems() ([]map[string]interface{}, error) {
 		err = rows.Scan(&uid, &cluster, &data)
 		if err != nil {
 			klog.Errorf("Error %s retrieving rows for query:%s", err.Error(), s.query)
+			continue
 		}
 		currItem := formatDataMap(data)
 		currItem["_uid"] = uid
 		currItem["cluster"] = cluster
+		s.trimItem(currItem)
 
-		items = append(items, currItem)
+		addItem(currItem)
 		s.uids = append(s.uids, &uid)
-
+		s.itemsCount++
+		s.setLastItem(uid, cluster, data)
+	}
+	if err = rows.Err(); err != nil {
+		klog.Errorf("Error reading the rows of query [%s] with args [%+v]. Error: [%+v]", s.query, s.params, err)
+		return err
 	}
 
-	return items, nil
+	return nil
+}
+
+// Returns true if the input has keywords, filters, filter groups, clusters or scope used to build the WHERE clause.
+func hasWhereFilters(input *model.SearchInput) bool {
+	return input != nil && (len(input.Keywords) > 0 || len(input.Filters) > 0 || len(input.FilterGroups) > 0 ||
+		len(input.Clusters) > 0 || len(input.ExcludeClusters) > 0 || input.Scope != nil)
 }
 
 func WhereClauseFilter(ctx context.Context, input *model.SearchInput,
@@ -328,61 +545,184 @@ func WhereClauseFilter(ctx context.Context, input *model.SearchInput,
 	var whereDs []exp.Expression
 	var err error
 
-	if input.Keywords != nil && len(input.Keywords) > 0 {
-		// Sample query: SELECT COUNT("uid") FROM "search"."resources", jsonb_each_text("data")
-		// WHERE (("value" LIKE '%dns%') AND ("data"->>'kind' ILIKE ANY ('{"pod","deployment"}')))
-		keywords := PointerToStringArray(input.Keywords)
-		for _, key := range keywords {
-			key = "%" + key + "%"
-			whereDs = append(whereDs, goqu.L(`"value"`).ILike(key).Expression())
+	// Each keyword matches any property value of the resource, ignoring case. All the keywords must match.
+	// Sample query: SELECT COUNT("uid") FROM "search"."resources"
+	// WHERE (EXISTS((SELECT 1 FROM jsonb_each_text("data") WHERE ("value" ILIKE '%nginx%'))) AND <rbac>)
+	// The denied properties are removed from the data, so keywords don't match their values.
+	keywordData := allowedData()
+	for _, key := range PointerToStringArray(input.Keywords) {
+		whereDs = append(whereDs, goqu.L("EXISTS(?)", goqu.From(goqu.L(`jsonb_each_text(?)`, keywordData)).
+			Select(goqu.L("1")).
+			Where(goqu.L(`"value"`).ILike("%"+escapeLikePattern(key)+"%"))))
+	}
+
+	for _, filter := range input.Filters {
+		var filterExp exp.Expression
+		filterExp, propTypeMap, err = filterExpression(ctx, filter, propTypeMap)
+		if err != nil {
+			return whereDs, propTypeMap, err
+		}
+		if filterExp != nil {
+			whereDs = append(whereDs, filterExp)
 		}
 	}
 
-	if input.Filters != nil {
-		for _, filter := range input.Filters {
-			opValueMap := map[string][]string{}
-			if len(filter.Values) == 0 {
-				klog.Warningf("Ignoring filter [%s] because it has no values", filter.Property)
-				continue
-			}
-			values := PointerToStringArray(filter.Values)
-
-			dataType, dataTypeInMap := propTypeMap[filter.Property]
-			if len(propTypeMap) == 0 || !dataTypeInMap {
-				klog.V(3).Infof("Property type for [%s] doesn't exist in cache. Refreshing property type cache",
-					filter.Property)
-				propTypeMapNew, err := getPropertyType(ctx, true) // Refresh the property type cache.
-				propTypeMap = propTypeMapNew
-				dataType, dataTypeInMap = propTypeMap[filter.Property]
-				klog.Infof("For filter prop: %s, datatype is :%s dataTypeInMap: %t\n", filter.Property,
-					dataType, dataTypeInMap)
-				if err != nil || !dataTypeInMap {
-					klog.Errorf("Error creating property type map with err: [%s] or datatype for  [%s] not found in map",
-						err, filter.Property)
-					return whereDs, propTypeMap, fmt.Errorf("error [%s] fetching data type for property: [%s]",
-						err, filter.Property)
-				}
+	// Each group is combined with OR and ANDed with the other filters.
+	// Sample: ((kind=Pod) OR (namespace=kube-system)) AND (cluster=local-cluster)
+	for _, group := range input.FilterGroups {
+		if group == nil {
+			continue
+		}
+		var groupExps []exp.Expression
+		for _, filter := range group.Filters {
+			if filter != nil && filter.Property == "managedHub" {
+				return whereDs, propTypeMap, invalidInputf("the managedHub property isn't supported in filterGroups")
 			}
-
-			klog.V(5).Infof("For filter prop: %s, datatype is :%s\n", filter.Property, dataType)
-
-			// if property matches then call decode function:
-			values, err = decodePropertyTypes(values, dataType)
+			var filterExp exp.Expression
+			filterExp, propTypeMap, err = filterExpression(ctx, filter, propTypeMap)
 			if err != nil {
 				return whereDs, propTypeMap, err
 			}
-			opValueMap = matchOperatorToProperty(dataType, opValueMap, values, filter.Property)
-
-			//Sort map according to keys - This is for the ease/stability of tests when there are multiple operators
-			keys := getKeys(opValueMap)
-			var operatorWhereDs []exp.Expression //store all the clauses for this filter together
-			for _, operator := range keys {
-				operatorWhereDs = append(operatorWhereDs,
-					getWhereClauseExpression(filter.Property, operator, opValueMap[operator], propTypeMap[filter.Property])...)
+			if filterExp != nil {
+				groupExps = append(groupExps, filterExp)
 			}
-			whereDs = append(whereDs, goqu.Or(operatorWhereDs...)) //Join all the clauses with OR
+		}
+		if len(groupExps) > 0 {
+			whereDs = append(whereDs, goqu.Or(groupExps...))
+		}
+	}
+
+	// Limit the search to the requested clusters. The RBAC clause is ANDed, so it can't widen the access.
+	if len(input.Clusters) == 1 {
+		whereDs = append(whereDs, goqu.C("cluster").Eq(input.Clusters[0]))
+	} else if len(input.Clusters) > 1 {
+		whereDs = append(whereDs, goqu.C("cluster").In(input.Clusters))
+	}
+	if len(input.ExcludeClusters) == 1 {
+		whereDs = append(whereDs, goqu.C("cluster").Neq(input.ExcludeClusters[0]))
+	} else if len(input.ExcludeClusters) > 1 {
+		whereDs = append(whereDs, goqu.C("cluster").NotIn(input.ExcludeClusters))
+	}
+
+	// Limit the search to cluster-scoped or namespaced resources. Cluster-scoped resources don't have a namespace.
+	if input.Scope != nil {
+		switch *input.Scope {
+		case "", "all":
+		case "cluster":
+			whereDs = append(whereDs, goqu.L("NOT(???)", goqu.C("data"), goqu.Literal("?"), "namespace"))
+		case "namespace":
+			whereDs = append(whereDs, goqu.L("???", goqu.C("data"), goqu.Literal("?"), "namespace"))
+		default:
+			return whereDs, propTypeMap, invalidInputf("invalid scope [%s], use cluster, namespace or all", *input.Scope)
 		}
 	}
 
 	return whereDs, propTypeMap, err
 }
+
+// Returns the expression to match the filter values, or nil if the filter doesn't have values.
+func filterExpression(ctx context.Context, filter *model.SearchFilter,
+	propTypeMap map[string]string) (exp.Expression, map[string]string, error) {
+	if filter == nil || len(filter.Values) == 0 {
+		if filter != nil {
+			klog.Warningf("Ignoring filter [%s] because it has no values", filter.Property)
+		}
+		return nil, propTypeMap, nil
+	}
+	// The Kubernetes metadata paths, for example metadata.name, are stored as top-level properties.
+	property := flattenedProperty(filter.Property)
+	if drop, err := checkDeniedProperty(property); drop || err != nil {
+		return nil, propTypeMap, err
+	}
+	// Dotted paths, for example metadata.labels.app, match the nested value with the string operators.
+	path := propertyPath(property)
+	if slices.Contains(path, "") {
+		return nil, propTypeMap, invalidInputf("invalid property path [%s]", filter.Property)
+	}
+	opValueMap := map[string][]string{}
+	existsWhereDs, values := existsExpressions(property, PointerToStringArray(filter.Values))
+	regexWhereDs, values, err := regexExpressions(property, values)
+	if err != nil {
+		return nil, propTypeMap, err
+	}
+	rangeWhereDs, values, err := timeRangeExpressions(property, values)
+	if err != nil {
+		return nil, propTypeMap, err
+	}
+	existsWhereDs = append(existsWhereDs, regexWhereDs...)
+	existsWhereDs = append(existsWhereDs, rangeWhereDs...)
+	if len(values) == 0 { // Only :exists values, regex values and time ranges, the property type isn't needed.
+		return goqu.Or(existsWhereDs...), propTypeMap, nil
+	}
+
+	dataType, dataTypeInMap := propTypeMap[property]
+	if path != nil {
+		dataType = "string"
+	} else if len(propTypeMap) == 0 || !dataTypeInMap {
+		klog.V(3).Infof("Property type for [%s] doesn't exist in cache. Refreshing property type cache",
+			property)
+		propTypeMapNew, err := getPropertyType(ctx, true) // Refresh the property type cache.
+		propTypeMap = propTypeMapNew
+		dataType, dataTypeInMap = propTypeMap[property]
+		klog.Infof("For filter prop: %s, datatype is :%s dataTypeInMap: %t\n", property,
+			dataType, dataTypeInMap)
+		if err != nil || !dataTypeInMap {
+			klog.Errorf("Error creating property type map with err: [%s] or datatype for  [%s] not found in map",
+				err, property)
+			return nil, propTypeMap, fmt.Errorf("error [%s] fetching data type for property: [%s]",
+				err, property)
+		}
+	}
+
+	klog.V(5).Infof("For filter prop: %s, datatype is :%s\n", property, dataType)
+
+	// if property matches then call decode function:
+	values, err = decodePropertyTypes(values, dataType)
+	if err != nil {
+		return nil, propTypeMap, err
+	}
+	opValueMap = matchOperatorToProperty(dataType, opValueMap, values, property)
+
+	//Sort map according to keys - This is for the ease/stability of tests when there are multiple operators
+	keys := getKeys(opValueMap)
+	operatorWhereDs := existsWhereDs //store all the clauses for this filter together
+	for _, operator := range keys {
+		operatorWhereDs = append(operatorWhereDs,
+			getWhereClauseExpression(property, operator, opValueMap[operator], dataType)...)
+	}
+	return goqu.Or(operatorWhereDs...), propTypeMap, nil //Join all the clauses with OR
+}
+
+// Filter value to match the resources that have the property, with any value. Use !:exists for the resources
+// without the property.
+const existsValue = ":exists"
+
+// Returns the expressions for the :exists and !:exists values and the remaining values of the filter.
+// Sample: "data"?'deletionTimestamp' or NOT("data"?'deletionTimestamp')
+func existsExpressions(property string, values []string) ([]exp.Expression, []string) {
+	exps := []exp.Expression{}
+	remaining := make([]string, 0, len(values))
+	for _, value := range values {
+		operator, operand := getOperatorFromString(value)
+		// managedHub isn't a property in the database, it's used to federate the request.
+		if operand != existsValue || property == "managedHub" {
+			remaining = append(remaining, value)
+			continue
+		}
+		exists := goqu.L("???", goqu.C("data"), goqu.Literal("?"), property)
+		if property == "cluster" {
+			exists = goqu.L("(? IS NOT NULL)", goqu.C(property))
+		} else if propertyPath(property) != nil {
+			exists = goqu.L("(? IS NOT NULL)", jsonValue(property))
+		}
+		switch operator {
+		case "=":
+			exps = append(exps, exists)
+		case "!", "!=":
+			exps = append(exps, goqu.L("NOT(?)", exists))
+		default: // The other operators compare the value.
+			remaining = append(remaining, value)
+		}
+	}
+	return exps, remaining
+}
@@ -3,20 +3,24 @@ package resolver
 import (
 	"context"
 	"fmt"
+	"math"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/doug-martin/goqu/v9"
 	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/lib/pq"
 	"github.com/stolostron/search-v2-api/graph/model"
 	"github.com/stolostron/search-v2-api/pkg/config"
 	db "github.com/stolostron/search-v2-api/pkg/database"
 	"github.com/stolostron/search-v2-api/pkg/metrics"
 	"github.com/stolostron/search-v2-api/pkg/rbac"
 	klog "k8s.io/klog/v2"
+	"k8s.io/utils/strings/slices"
 )
 
 type SearchCompleteResult struct {
@@ -24,10 +28,14 @@ type SearchCompleteResult struct {
 	pool      pgxpoolmock.PgxPool
 	property  string
 	limit     *int
+	filter    *string // Return only the values containing the filter text, ignoring case.
 	query     string
 	params    []interface{}
 	propTypes map[string]string
 	userData  rbac.UserData
+
+	// Return the kind and name of the referenced resources for the AUTOCOMPLETE_REFERENCE_PROPERTIES.
+	resolveReferences bool
 }
 
 var arrayProperties = make(map[string]struct{})
@@ -37,16 +45,36 @@ func (s *SearchCompleteResult) autoComplete(ctx context.Context) ([]*string, err
 	if s.property == "managedHub" { // return hubName for managedHub property
 		return []*string{&hubName}, nil
 	}
+	// The filters on denied properties are dropped when the WHERE clause is built.
+	if drop, err := checkDeniedProperty(s.property); err != nil {
+		return []*string{}, err
+	} else if drop {
+		return []*string{}, nil
+	}
+	if err := checkDeniedFilters(s.input); err != nil {
+		return []*string{}, err
+	}
 	s.searchCompleteQuery(ctx)
+	// Use the cached results to avoid scanning the resources with every keystroke.
+	if cached, found := searchCompleteCache.get(s.query); found {
+		klog.V(5).Info("Using autocomplete results from cache.")
+		return cached, nil
+	}
 	res, autoCompleteErr := s.searchCompleteResults(ctx)
 	if autoCompleteErr != nil {
 		klog.Error("Error resolving properties in autoComplete. ", autoCompleteErr)
+	} else if s.query != "" {
+		searchCompleteCache.set(s.query, res)
 	}
 	return res, autoCompleteErr
 }
 
-func SearchComplete(ctx context.Context, property string, srchInput *model.SearchInput, limit *int) ([]*string, error) {
+func SearchComplete(ctx context.Context, property string, srchInput *model.SearchInput, limit *int,
+	filter *string, resolveReferences *bool) ([]*string, error) {
 	defer metrics.SlowLog("SearchCompleteResolver", 0)()
+	if err := validateSearchCompleteInput(property, srchInput, limit); err != nil {
+		return []*string{}, err
+	}
 	userData, userDataErr := rbac.GetCache().GetUserData(ctx)
 	if userDataErr != nil {
 		return []*string{}, userDataErr
@@ -64,17 +92,26 @@ func SearchComplete(ctx context.Context, property string, srchInput *model.Searc
 		pool:      db.GetConnPool(ctx),
 		property:  property,
 		limit:     limit,
+		filter:    filter,
 		userData:  userData,
 		propTypes: propTypes,
+
+		resolveReferences: resolveReferences != nil && *resolveReferences,
 	}
 	return searchCompleteResult.autoComplete(ctx)
 
 }
 
-// Sample query: SELECT DISTINCT name FROM
-// (SELECT "data"->>'name' as name FROM "search"."resources" WHERE ("data"->>'name' IS NOT NULL)
-// LIMIT 100000) as searchComplete
-// ORDER BY name ASC
+// Sample query: SELECT DISTINCT "data"->'name' FROM "search"."resources"
+// WHERE (("data"->'name' IS NOT NULL) AND <rbac>)
+// ORDER BY "data"->'name' ASC
+// LIMIT 1000
+//
+// Sample query to resolve a reference property:
+// SELECT DISTINCT concat_ws('/', "data"->>'kind', "data"->>'name') FROM "search"."resources"
+// WHERE (("uid" IN (SELECT "data"->>'_ownerUID' FROM "search"."resources"
+// WHERE (("data"->'_ownerUID' IS NOT NULL) AND <rbac>))) AND <rbac>)
+// ORDER BY concat_ws('/', "data"->>'kind', "data"->>'name') ASC
 // LIMIT 1000
 func (s *SearchCompleteResult) searchCompleteQuery(ctx context.Context) {
 	var limit int
@@ -86,7 +123,7 @@ func (s *SearchCompleteResult) searchCompleteQuery(ctx context.Context) {
 	if s.property != "" {
 
 		// WHERE CLAUSE
-		if s.input != nil && len(s.input.Filters) > 0 {
+		if hasWhereFilters(s.input) {
 			whereDs, s.propTypes, _ = WhereClauseFilter(ctx, s.input, s.propTypes)
 		}
 
@@ -96,12 +133,27 @@ func (s *SearchCompleteResult) searchCompleteQuery(ctx context.Context) {
 			//Adding notNull clause to filter out NULL values and ORDER by sort results
 			whereDs = append(whereDs, goqu.C(s.property).IsNotNull(),
 				goqu.C(s.property).Neq("")) // remove empty strings from results
+			// Only suggest the clusters the user is authorized to search.
+			if clusters := authorizedClusters(s.userData); clusters != nil {
+				whereDs = append(whereDs, goqu.C(s.property).Eq(goqu.Any(pq.Array(clusters))))
+			}
+			if s.hasFilter() {
+				whereDs = append(whereDs, goqu.C(s.property).ILike(s.filterPattern()))
+			}
+		} else if s.isReferenceProperty() {
+			// The referenced resources are selected after the RBAC clause is added.
+			whereDs = append(whereDs, goqu.L(`"data"->?`, s.property).IsNotNull())
 		} else {
 			// "->" - get data as json object
 			// "->>" - get data as string
 			selectDs = ds.SelectDistinct(goqu.L(`"data"->?`, s.property)).Order(goqu.L(`"data"->?`, s.property).Asc())
 			//Adding notNull clause to filter out NULL values and ORDER by sort results
 			whereDs = append(whereDs, goqu.L(`"data"->?`, s.property).IsNotNull())
+			// "->>" - match the text of the value. For labels and arrays it matches the JSON text,
+			// so the values are also filtered after they are read.
+			if s.hasFilter() {
+				whereDs = append(whereDs, goqu.L(`"data"->>?`, s.property).ILike(s.filterPattern()))
+			}
 		}
 
 		// get user info for logging
@@ -111,7 +163,11 @@ func (s *SearchCompleteResult) searchCompleteQuery(ctx context.Context) {
 		// if one of them is not nil, userData is not empty
 		if s.userData.CsResources != nil || s.userData.NsResources != nil || s.userData.ManagedClusters != nil {
 			whereDs = append(whereDs,
-				buildRbacWhereClause(ctx, s.userData, userInfo)) // add rbac
+				buildRbacWhereClause(ctx, restrictToInput(s.userData, s.input),
+					userInfo)) // add rbac
+			if s.isReferenceProperty() {
+				selectDs, whereDs = s.referencesQuery(ds, whereDs, buildRbacWhereClause(ctx, s.userData, userInfo))
+			}
 		} else {
 			klog.Errorf("Error building searchComplete query: RBAC clause is required!"+
 				" None found for searchComplete query %+v for user %s with uid %s ",
@@ -123,13 +179,7 @@ func (s *SearchCompleteResult) searchCompleteQuery(ctx context.Context) {
 		}
 
 		// LIMIT CLAUSE
-		if s.limit != nil && *s.limit > 0 {
-			limit = *s.limit
-		} else if s.limit != nil && *s.limit == -1 {
-			klog.Warning("Limit set to -1. Fetching all results. This may affect performance.")
-		} else {
-			limit = config.Cfg.QueryLimit
-		}
+		limit = s.completeLimit()
 
 		var params []interface{}
 		var sql string
@@ -152,25 +202,49 @@ func (s *SearchCompleteResult) searchCompleteQuery(ctx context.Context) {
 		s.query = ""
 		s.params = nil
 	}
-	// SELECT DISTINCT "prop" FROM (SELECT "data"->'?'
-	// AS "prop" FROM "search"."resources" WHERE ("data"->'?' IS NOT NULL) LIMIT 100000)
-	// AS "searchComplete" ORDER BY prop ASC LIMIT 1000
+}
+
+// Returns true if the values of the property are the uid of another resource and the client requested
+// to resolve them. Configured with AUTOCOMPLETE_REFERENCE_PROPERTIES.
+func (s *SearchCompleteResult) isReferenceProperty() bool {
+	return s.resolveReferences && slices.Contains(config.Cfg.AutocompleteReferenceProps, s.property)
+}
+
+// Select the kind and name of the resources referenced by the property. The uids are read from the
+// resources matching the filters, and the user must also be authorized to see the referenced resources.
+func (s *SearchCompleteResult) referencesQuery(ds *goqu.SelectDataset, referencingWhereDs []exp.Expression,
+	rbacDs exp.Expression) (*goqu.SelectDataset, []exp.Expression) {
+	referenceName := goqu.L(`concat_ws('/', "data"->>'kind', "data"->>'name')`)
+	uidsDs := ds.Select(goqu.L(`"data"->>?`, s.property)).Where(referencingWhereDs...)
 
+	whereDs := []exp.Expression{goqu.C("uid").In(uidsDs), rbacDs}
+	if s.hasFilter() {
+		whereDs = append(whereDs, referenceName.ILike(s.filterPattern()))
+	}
+	return ds.SelectDistinct(referenceName).Order(referenceName.Asc()), whereDs
 }
 
 func (s *SearchCompleteResult) searchCompleteResults(ctx context.Context) ([]*string, error) {
 	klog.V(2).Info("Resolving searchCompleteResults()")
-	rows, err := s.pool.Query(ctx, s.query, s.params...)
+	start := time.Now()
+	rows, err := query(ctx, s.pool, s.query, s.params...)
 	srchCompleteOut := make([]*string, 0)
 
 	if err != nil {
+		metrics.ObserveQueryDuration(metrics.AutocompleteQueryDuration, start, err)
 		klog.Error("Error fetching search complete results from db ", err)
 		return srchCompleteOut, err
 	}
 
 	if rows != nil {
 		defer rows.Close()
+		defer func() { metrics.ObserveQueryDuration(metrics.AutocompleteQueryDuration, start, rows.Err()) }()
 		props := make(map[string]struct{})
+		addProp := func(prop string) {
+			if s.matchesFilter(prop) {
+				props[prop] = struct{}{}
+			}
+		}
 		for rows.Next() {
 			prop := ""
 			var input interface{}
@@ -181,38 +255,46 @@ func (s *SearchCompleteResult) searchCompleteResults(ctx context.Context) ([]*st
 
 			switch v := input.(type) {
 			case string:
-				prop = v
-				props[v] = struct{}{}
+				prop = normalizeValue(v)
+				addProp(prop)
 			case bool:
 				prop = strconv.FormatBool(v)
-				props[prop] = struct{}{}
+				addProp(prop)
 			case float64:
-				prop = strconv.FormatInt(int64(v), 10)
-				props[prop] = struct{}{}
+				prop = strconv.FormatFloat(v, 'f', -1, 64) // Whole numbers are formatted without decimals.
+				addProp(prop)
 			case map[string]interface{}:
 				arrayProperties[s.property] = struct{}{}
 				for key, value := range v {
 					labelString := fmt.Sprintf("%s=%s", key, value.(string))
-					props[labelString] = struct{}{}
+					addProp(normalizeValue(labelString))
 				}
 			case []interface{}:
 				arrayProperties[s.property] = struct{}{}
 				for _, value := range v {
-					props[value.(string)] = struct{}{}
+					addProp(normalizeValue(value.(string)))
 				}
 			default:
 				prop = v.(string)
-				props[prop] = struct{}{}
+				addProp(prop)
 				klog.Warningf("Error formatting property with type: %+v\n", reflect.TypeOf(v))
 			}
 
 		}
+		if err = rows.Err(); err != nil {
+			klog.Error("Error reading searchCompleteResults ", err)
+			return make([]*string, 0), err
+		}
 		properties := stringArrayToPointer(getKeys(props))
 		srchCompleteOut = append(srchCompleteOut, properties...)
 	} else {
 		klog.Error("searchCompleteResults rows is nil", srchCompleteOut)
 	}
-	if len(srchCompleteOut) > 0 {
+	if len(srchCompleteOut) == 0 {
+		return srchCompleteOut, nil
+	}
+	// The number and date ranges are only used without a filter, otherwise return the matching values.
+	if !s.hasFilter() {
 		//Check if results are date or number
 		isNumber := isNumber(srchCompleteOut)
 		if isNumber { //check if valid number
@@ -221,8 +303,8 @@ func (s *SearchCompleteResult) searchCompleteResults(ctx context.Context) ([]*st
 			srchCompleteOutNum := []*string{&isNumberStr}
 			// Sort the values in srchCompleteOut
 			sort.Slice(srchCompleteOut, func(i, j int) bool {
-				numA, _ := strconv.Atoi(*srchCompleteOut[i])
-				numB, _ := strconv.Atoi(*srchCompleteOut[j])
+				numA, _ := strconv.ParseFloat(*srchCompleteOut[i], 64)
+				numB, _ := strconv.ParseFloat(*srchCompleteOut[j], 64)
 				return numA < numB
 			})
 			if len(srchCompleteOut) > 1 {
@@ -240,13 +322,63 @@ func (s *SearchCompleteResult) searchCompleteResults(ctx context.Context) ([]*st
 			isDateStr := "isDate"
 			srchCompleteOutDate := []*string{&isDateStr}
 			srchCompleteOut = srchCompleteOutDate
+		} else if !isNumber && isBoolean(srchCompleteOut) { //check if valid boolean
+			// Checked after isNumber, so a property with 0 and 1 values stays a number.
+			srchCompleteOut = stringArrayToPointer([]string{"isBoolean", "true", "false"})
 		}
 	}
 	return srchCompleteOut, nil
 }
 
+// Returns the limit for the query. The client can't exceed MAX_QUERY_LIMIT, including with -1 for all results.
+func (s *SearchCompleteResult) completeLimit() int {
+	if s.limit != nil {
+		return capLimit(*s.limit)
+	}
+	return config.Cfg.QueryLimit
+}
+
+func (s *SearchCompleteResult) hasFilter() bool {
+	return s.filter != nil && *s.filter != ""
+}
+
+// Pattern to match the values containing the filter text. Escapes the LIKE wildcards (% and _)
+// so they are matched as text.
+func (s *SearchCompleteResult) filterPattern() string {
+	return "%" + escapeLikePattern(*s.filter) + "%"
+}
+
+// Check if the value contains the filter text, ignoring case.
+func (s *SearchCompleteResult) matchesFilter(value string) bool {
+	if !s.hasFilter() {
+		return true
+	}
+	return strings.Contains(strings.ToLower(value), strings.ToLower(*s.filter))
+}
+
+// Normalize the autocomplete value so near-duplicates are collapsed. Configured with AUTOCOMPLETE_NORMALIZE:
+//
+//	none       - (default) use the raw values.
+//	whitespace - trim leading and trailing whitespace and collapse inner whitespace to a single space.
+//	casefold   - same as whitespace, and convert to lower case.
+//
+// Only used for autocomplete, the search queries use the raw values.
+func normalizeValue(value string) string {
+	switch config.Cfg.AutocompleteNorm {
+	case "whitespace":
+		return strings.Join(strings.Fields(value), " ")
+	case "casefold":
+		return strings.ToLower(strings.Join(strings.Fields(value), " "))
+	default:
+		return value
+	}
+}
+
 // check if a given string is of type date
 func isDate(vals []*string) bool {
+	if len(vals) == 0 {
+		return false
+	}
 	for _, val := range vals {
 		// parse string date to golang time format: YYYY-MM-DDTHH:mm:ssZ i.e. "2022-01-01T17:17:09Z"
 		// const time.RFC3339 is YYYY-MM-DDTHH:mm:ssZ format ex:"2006-01-02T15:04:05Z07:00"
@@ -258,11 +390,29 @@ func isDate(vals []*string) bool {
 	return true
 }
 
-// check if a given string is of type number (int)
-func isNumber(vals []*string) bool {
+// check if the non-empty values are of type boolean
+func isBoolean(vals []*string) bool {
+	found := false
+	for _, val := range vals {
+		switch *val {
+		case "true", "false":
+			found = true
+		case "":
+		default:
+			return false
+		}
+	}
+	return found
+}
 
+// check if a given string is of type number. Includes floats, negative numbers and scientific notation.
+func isNumber(vals []*string) bool {
+	if len(vals) == 0 {
+		return false
+	}
 	for _, val := range vals {
-		if _, err := strconv.Atoi(*val); err != nil {
+		num, err := strconv.ParseFloat(*val, 64)
+		if err != nil || math.IsNaN(num) || math.IsInf(num, 0) {
 			return false
 		}
 	}
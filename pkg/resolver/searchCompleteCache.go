@@ -0,0 +1,81 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/stolostron/search-v2-api/pkg/config"
+)
+
+// Max number of autocomplete results in the cache. The least recently used results are removed first.
+const autocompleteCacheSize = 1000
+
+// Least recently used cache of autocomplete results. Keyed by the query, which includes the property,
+// the filters and the user's RBAC clause, so users with different access don't share the results.
+type autocompleteCache struct {
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Most recently used first.
+}
+
+type autocompleteCacheEntry struct {
+	query     string
+	values    []string
+	updatedAt time.Time
+}
+
+var searchCompleteCache = newAutocompleteCache()
+
+func newAutocompleteCache() *autocompleteCache {
+	return &autocompleteCache{entries: map[string]*list.Element{}, order: list.New()}
+}
+
+// Time-to-live of the cached results. The cache is disabled when it's 0.
+func autocompleteCacheTTL() time.Duration {
+	return time.Duration(config.Cfg.AutocompleteCacheTTL) * time.Millisecond
+}
+
+// Returns a copy of the cached results for the query, or false if these aren't cached or expired.
+func (c *autocompleteCache) get(query string) ([]*string, bool) {
+	ttl := autocompleteCacheTTL()
+	if ttl <= 0 {
+		return nil, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, found := c.entries[query]
+	if !found {
+		return nil, false
+	}
+	entry := element.Value.(*autocompleteCacheEntry)
+	if time.Since(entry.updatedAt) >= ttl {
+		c.order.Remove(element)
+		delete(c.entries, query)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return stringArrayToPointer(entry.values), true
+}
+
+// Cache the results for the query. Removes the least recently used results when the cache is full.
+func (c *autocompleteCache) set(query string, values []*string) {
+	if autocompleteCacheTTL() <= 0 {
+		return
+	}
+	entry := &autocompleteCacheEntry{query: query, values: PointerToStringArray(values), updatedAt: time.Now()}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, found := c.entries[query]; found {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[query] = c.order.PushFront(entry)
+	for c.order.Len() > autocompleteCacheSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*autocompleteCacheEntry).query)
+	}
+}
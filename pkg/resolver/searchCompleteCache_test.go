@@ -0,0 +1,120 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockKindRows() *MockRows {
+	return &MockRows{mockData: []map[string]interface{}{{"prop": "Pod"}}}
+}
+
+func Test_SearchComplete_CacheHit(t *testing.T) {
+	ctx := context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456")
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "kind",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockKindRows(), nil).Times(1)
+
+	result, err := resolver.autoComplete(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Pod"}, PointerToStringArray(result))
+
+	// The same request within the TTL uses the cache, the mock fails if the database is queried again.
+	result, err = resolver.autoComplete(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Pod"}, PointerToStringArray(result))
+}
+
+func Test_SearchComplete_CacheMissDifferentFilter(t *testing.T) {
+	ctx := context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456")
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "kind",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockKindRows(), nil)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockKindRows(), nil)
+
+	_, err := resolver.autoComplete(ctx)
+	assert.Nil(t, err)
+
+	filter := "po"
+	resolver.filter = &filter
+	result, err := resolver.autoComplete(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Pod"}, PointerToStringArray(result))
+}
+
+func Test_SearchComplete_CacheMissDifferentUser(t *testing.T) {
+	ctx := context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456")
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "kind",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockKindRows(), nil)
+	_, err := resolver.autoComplete(ctx)
+	assert.Nil(t, err)
+
+	// A user with different access doesn't get the cached results.
+	otherUser := &SearchCompleteResult{input: &model.SearchInput{}, pool: resolver.pool, property: "kind",
+		userData: rbac.UserData{NsResources: map[string][]rbac.Resource{"ocm": {{Apigroup: "", Kind: "pods"}}}}}
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&MockRows{mockData: []map[string]interface{}{}}, nil)
+	result, err := otherUser.autoComplete(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+}
+
+func Test_SearchComplete_CacheExpired(t *testing.T) {
+	ctx := context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456")
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "kind",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockKindRows(), nil).Times(2)
+	_, err := resolver.autoComplete(ctx)
+	assert.Nil(t, err)
+
+	entry := searchCompleteCache.entries[resolver.query].Value.(*autocompleteCacheEntry)
+	entry.updatedAt = time.Now().Add(-autocompleteCacheTTL())
+	_, err = resolver.autoComplete(ctx)
+	assert.Nil(t, err)
+}
+
+func Test_SearchComplete_CacheDisabled(t *testing.T) {
+	defer func(ttl int) { config.Cfg.AutocompleteCacheTTL = ttl }(config.Cfg.AutocompleteCacheTTL)
+	config.Cfg.AutocompleteCacheTTL = 0
+	ctx := context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456")
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "kind",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockKindRows(), nil)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(mockKindRows(), nil)
+
+	_, err := resolver.autoComplete(ctx)
+	assert.Nil(t, err)
+	_, err = resolver.autoComplete(ctx)
+	assert.Nil(t, err)
+	assert.Empty(t, searchCompleteCache.entries)
+}
+
+// Should remove the least recently used results when the cache is full.
+func Test_autocompleteCache_Size(t *testing.T) {
+	cache := newAutocompleteCache()
+	for i := 0; i < autocompleteCacheSize; i++ {
+		cache.set(strconv.Itoa(i), stringArrayToPointer([]string{"value"}))
+	}
+	_, found := cache.get("0") // Use the oldest entry, so the next one is removed.
+	assert.True(t, found)
+
+	cache.set("new", stringArrayToPointer([]string{"value"}))
+
+	assert.Equal(t, autocompleteCacheSize, cache.order.Len())
+	_, found = cache.get("0")
+	assert.True(t, found)
+	_, found = cache.get("1")
+	assert.False(t, found)
+	_, found = cache.get("new")
+	assert.True(t, found)
+}
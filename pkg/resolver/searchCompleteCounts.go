@@ -0,0 +1,199 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/lib/pq"
+	"github.com/stolostron/search-v2-api/graph/model"
+	db "github.com/stolostron/search-v2-api/pkg/database"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	klog "k8s.io/klog/v2"
+)
+
+// Same as SearchComplete, but also returns the number of resources with each value.
+func SearchCompleteWithCounts(ctx context.Context, property string, srchInput *model.SearchInput, limit *int,
+	filter *string) ([]*model.PropertyCount, error) {
+	defer metrics.SlowLog("SearchCompleteWithCountsResolver", 0)()
+	if err := validateSearchCompleteInput(property, srchInput, limit); err != nil {
+		return []*model.PropertyCount{}, err
+	}
+	userData, userDataErr := rbac.GetCache().GetUserData(ctx)
+	if userDataErr != nil {
+		return []*model.PropertyCount{}, userDataErr
+	}
+
+	// Check that shared cache has property types:
+	propTypes, err := rbac.GetCache().GetPropertyTypes(ctx, false)
+	if err != nil {
+		klog.Warningf("Error creating datatype map with err: [%s] ", err)
+	}
+
+	// Proceed if user's rbac data exists
+	searchCompleteResult := &SearchCompleteResult{
+		input:     srchInput,
+		pool:      db.GetConnPool(ctx),
+		property:  property,
+		limit:     limit,
+		filter:    filter,
+		userData:  userData,
+		propTypes: propTypes,
+	}
+	return searchCompleteResult.autoCompleteWithCounts(ctx)
+}
+
+func (s *SearchCompleteResult) autoCompleteWithCounts(ctx context.Context) ([]*model.PropertyCount, error) {
+	// managedHub isn't a property in the database, it's used to federate the request.
+	if s.property == "" || s.property == "managedHub" {
+		return []*model.PropertyCount{}, nil
+	}
+	// The filters on denied properties are dropped when the WHERE clause is built.
+	if drop, err := checkDeniedProperty(s.property); err != nil {
+		return []*model.PropertyCount{}, err
+	} else if drop {
+		return []*model.PropertyCount{}, nil
+	}
+	if err := checkDeniedFilters(s.input); err != nil {
+		return []*model.PropertyCount{}, err
+	}
+	if err := s.searchCompleteCountsQuery(ctx); err != nil {
+		return []*model.PropertyCount{}, err
+	}
+	res, err := s.searchCompleteCountsResults(ctx)
+	if err != nil {
+		klog.Error("Error resolving property counts in autoComplete. ", err)
+	}
+	return res, err
+}
+
+// Counts the resources for each value of the property. Labels are counted by key=value and arrays by item.
+// Sample query:
+//
+//	SELECT "data"->>'kind' AS "value", COUNT(*) AS "count" FROM "search"."resources"
+//	WHERE (("data"->'kind' IS NOT NULL) AND <rbac>)
+//	GROUP BY "data"->>'kind' ORDER BY "count" DESC, "value" ASC LIMIT 1000
+func (s *SearchCompleteResult) searchCompleteCountsQuery(ctx context.Context) error {
+	var whereDs []exp.Expression
+	var err error
+	s.query = ""
+	s.params = nil
+
+	// WHERE CLAUSE
+	if hasWhereFilters(s.input) {
+		whereDs, s.propTypes, err = WhereClauseFilter(ctx, s.input, s.propTypes)
+		if err != nil {
+			klog.Errorf("Error building SearchCompleteWithCounts query: %s", err.Error())
+			return err
+		}
+	}
+
+	// SELECT CLAUSE
+	schemaTable := goqu.S("search").Table("resources")
+	ds := goqu.From(schemaTable)
+	var valueExp exp.Expression
+	switch {
+	case s.property == "cluster":
+		valueExp = goqu.C(s.property)
+		whereDs = append(whereDs, goqu.C(s.property).IsNotNull(), goqu.C(s.property).Neq(""))
+		// Only count the clusters the user is authorized to search.
+		if clusters := authorizedClusters(s.userData); clusters != nil {
+			whereDs = append(whereDs, goqu.C(s.property).Eq(goqu.Any(pq.Array(clusters))))
+		}
+	case s.propTypes[s.property] == "object":
+		ds = goqu.From(schemaTable, goqu.L(`jsonb_each_text("data"->?) AS kv(key, value)`, s.property))
+		valueExp = goqu.L(`kv.key || '=' || kv.value`)
+		whereDs = append(whereDs, goqu.L(`"data"->?`, s.property).IsNotNull())
+	case s.propTypes[s.property] == "array":
+		ds = goqu.From(schemaTable, goqu.L(`jsonb_array_elements_text("data"->?) AS arrayProp`, s.property))
+		valueExp = goqu.L(`arrayProp`)
+		whereDs = append(whereDs, goqu.L(`"data"->?`, s.property).IsNotNull())
+	default:
+		valueExp = goqu.L(`"data"->>?`, s.property)
+		whereDs = append(whereDs, goqu.L(`"data"->?`, s.property).IsNotNull())
+	}
+	if s.hasFilter() {
+		whereDs = append(whereDs, goqu.L("?", valueExp).ILike(s.filterPattern()))
+	}
+
+	// get user info for logging
+	_, userInfo := rbac.GetCache().GetUserUID(ctx)
+
+	// RBAC CLAUSE
+	// if one of them is not nil, userData is not empty
+	if s.userData.CsResources != nil || s.userData.NsResources != nil || s.userData.ManagedClusters != nil {
+		whereDs = append(whereDs,
+			buildRbacWhereClause(ctx, restrictToInput(s.userData, s.input),
+				userInfo)) // add rbac
+	} else {
+		klog.Errorf("Error building searchCompleteWithCounts query: RBAC clause is required!"+
+			" None found for searchCompleteWithCounts query %+v for user %s with uid %s ",
+			s.input, userInfo.Username, userInfo.UID)
+		return fmt.Errorf("RBAC clause is required! None found for searchCompleteWithCounts query")
+	}
+
+	selectDs := ds.Select(goqu.L("?", valueExp).As("value"), goqu.COUNT("*").As("count")).
+		Where(whereDs...).
+		GroupBy(valueExp). // The "value" alias would be ambiguous with the value column of the labels.
+		Order(goqu.C("count").Desc(), goqu.C("value").Asc())
+
+	// LIMIT CLAUSE
+	if limit := s.completeLimit(); limit > 0 {
+		selectDs = selectDs.Limit(uint(limit))
+	}
+
+	sql, params, err := selectDs.ToSQL()
+	if err != nil {
+		klog.Errorf("Error building SearchCompleteWithCounts query: %s", err.Error())
+		return err
+	}
+	s.query = sql
+	s.params = params
+	klog.V(5).Info("SearchCompleteWithCounts Query: ", s.query)
+	return nil
+}
+
+func (s *SearchCompleteResult) searchCompleteCountsResults(ctx context.Context) ([]*model.PropertyCount, error) {
+	klog.V(2).Info("Resolving searchCompleteCountsResults()")
+	results := make([]*model.PropertyCount, 0)
+	rows, err := query(ctx, s.pool, s.query, s.params...)
+	if err != nil {
+		klog.Error("Error fetching search complete counts from db ", err)
+		return results, err
+	}
+	defer rows.Close()
+
+	// The normalized values can collapse into the same value, so the counts are added.
+	counts := map[string]*model.PropertyCount{}
+	for rows.Next() {
+		var value string
+		var count int
+		if scanErr := rows.Scan(&value, &count); scanErr != nil {
+			klog.Error("Error reading searchCompleteCountsResults ", scanErr)
+			continue
+		}
+		value = normalizeValue(value)
+		if propCount, found := counts[value]; found {
+			propCount.Count += count
+			continue
+		}
+		counts[value] = &model.PropertyCount{Value: value, Count: count}
+		results = append(results, counts[value])
+	}
+	if err = rows.Err(); err != nil {
+		klog.Error("Error reading searchCompleteCountsResults ", err)
+		return make([]*model.PropertyCount, 0), err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+		return results[i].Value < results[j].Value
+	})
+	return results, nil
+}
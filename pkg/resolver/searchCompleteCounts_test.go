@@ -0,0 +1,98 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockCountRows(values []string, counts []int) *MockRows {
+	rows := []map[string]interface{}{}
+	for i, value := range values {
+		rows = append(rows, map[string]interface{}{"value": value, "count": float64(counts[i])})
+	}
+	return &MockRows{mockData: rows, columnHeaders: []string{"value", "count"}}
+}
+
+func Test_SearchCompleteWithCounts_Query(t *testing.T) {
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "kind",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT "data"->>'kind' AS "value", COUNT(*) AS "count" FROM "search"."resources" WHERE (("data"->'kind' IS NOT NULL) AND ("cluster" = ANY ('{}'))) GROUP BY "data"->>'kind' ORDER BY "count" DESC, "value" ASC LIMIT 1000`),
+		gomock.Eq([]interface{}{})).
+		Return(mockCountRows([]string{"Pod", "Deployment", "ConfigMap"}, []int{10, 3, 3}), nil)
+
+	result, err := resolver.autoCompleteWithCounts(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*model.PropertyCount{
+		{Value: "Pod", Count: 10}, {Value: "ConfigMap", Count: 3}, {Value: "Deployment", Count: 3},
+	}, result)
+}
+
+func Test_SearchCompleteWithCounts_LabelsWithFilterAndLimit(t *testing.T) {
+	limit := 2
+	filter := "app"
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "label",
+		rbac.UserData{CsResources: []rbac.Resource{}}, map[string]string{"label": "object"})
+	resolver.limit = &limit
+	resolver.filter = &filter
+
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT kv.key || '=' || kv.value AS "value", COUNT(*) AS "count" FROM "search"."resources", jsonb_each_text("data"->'label') AS kv(key, value) WHERE (("data"->'label' IS NOT NULL) AND (kv.key || '=' || kv.value ILIKE '%app%') AND ("cluster" = ANY ('{}'))) GROUP BY kv.key || '=' || kv.value ORDER BY "count" DESC, "value" ASC LIMIT 2`),
+		gomock.Eq([]interface{}{})).
+		Return(mockCountRows([]string{"app=web", "app=search"}, []int{4, 7}), nil)
+
+	result, err := resolver.autoCompleteWithCounts(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*model.PropertyCount{{Value: "app=search", Count: 7}, {Value: "app=web", Count: 4}}, result)
+}
+
+func Test_SearchCompleteWithCounts_Array(t *testing.T) {
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "container",
+		rbac.UserData{CsResources: []rbac.Resource{}}, map[string]string{"container": "array"})
+	limit := -1
+	resolver.limit = &limit
+
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT arrayProp AS "value", COUNT(*) AS "count" FROM "search"."resources", jsonb_array_elements_text("data"->'container') AS arrayProp WHERE (("data"->'container' IS NOT NULL) AND ("cluster" = ANY ('{}'))) GROUP BY arrayProp ORDER BY "count" DESC, "value" ASC LIMIT 100000`),
+		gomock.Eq([]interface{}{})).
+		Return(mockCountRows([]string{"nginx"}, []int{2}), nil)
+
+	result, err := resolver.autoCompleteWithCounts(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*model.PropertyCount{{Value: "nginx", Count: 2}}, result)
+}
+
+// The counts of the values collapsed by the normalization are added.
+func Test_SearchCompleteWithCounts_Normalize(t *testing.T) {
+	defer func() { config.Cfg.AutocompleteNorm = "none" }()
+	config.Cfg.AutocompleteNorm = "casefold"
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "status",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(mockCountRows([]string{"Running", "Pending", "running "}, []int{5, 4, 2}), nil)
+
+	result, err := resolver.autoCompleteWithCounts(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []*model.PropertyCount{{Value: "running", Count: 7}, {Value: "pending", Count: 4}}, result)
+}
+
+func Test_SearchCompleteWithCounts_WithoutRbac(t *testing.T) {
+	resolver, _ := newMockSearchComplete(t, &model.SearchInput{}, "kind", rbac.UserData{}, nil)
+
+	result, err := resolver.autoCompleteWithCounts(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	assert.NotNil(t, err)
+	assert.Equal(t, []*model.PropertyCount{}, result)
+}
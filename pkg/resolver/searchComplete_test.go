@@ -3,11 +3,14 @@ package resolver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
 	"github.com/stolostron/search-v2-api/pkg/rbac"
 	"github.com/stretchr/testify/assert"
 )
@@ -101,7 +104,7 @@ func Test_SearchComplete_Query_WithNegativeLimit(t *testing.T) {
 	mockRows := newMockRowsWithoutRBAC("../resolver/mocks/mock.json", searchInput, prop1, limit)
 	// Mock the database query
 	mockPool.EXPECT().Query(gomock.Any(),
-		gomock.Eq(`SELECT DISTINCT "data"->'kind' FROM "search"."resources" WHERE (("data"->'kind' IS NOT NULL) AND ("cluster" = ANY ('{}'))) ORDER BY "data"->'kind' ASC`),
+		gomock.Eq(`SELECT DISTINCT "data"->'kind' FROM "search"."resources" WHERE (("data"->'kind' IS NOT NULL) AND ("cluster" = ANY ('{}'))) ORDER BY "data"->'kind' ASC LIMIT 100000`),
 		gomock.Eq([]interface{}{})).Return(mockRows, nil)
 
 	// Execute function
@@ -114,6 +117,26 @@ func Test_SearchComplete_Query_WithNegativeLimit(t *testing.T) {
 	AssertStringArrayEqual(t, result, expectedProps, "Error in Test_SearchComplete_Query_WithNegativeLimit")
 }
 
+// Should clamp the limit to MAX_QUERY_LIMIT when the client requests all results or a larger limit.
+func Test_SearchComplete_Query_LimitCapped(t *testing.T) {
+	defer func(maxLimit int) { config.Cfg.MaxQueryLimit = maxLimit }(config.Cfg.MaxQueryLimit)
+	config.Cfg.MaxQueryLimit = 50
+
+	for _, limit := range []int{-1, 1000000000} {
+		limit := limit
+		resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "kind",
+			rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+		resolver.limit = &limit
+
+		mockPool.EXPECT().Query(gomock.Any(),
+			gomock.Eq(`SELECT DISTINCT "data"->'kind' FROM "search"."resources" WHERE (("data"->'kind' IS NOT NULL) AND ("cluster" = ANY ('{}'))) ORDER BY "data"->'kind' ASC LIMIT 50`),
+			gomock.Eq([]interface{}{})).Return(&MockRows{}, nil)
+
+		_, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+		assert.Nil(t, err)
+	}
+}
+
 func Test_SearchCompleteNoProp_Query(t *testing.T) {
 	// Create a SearchCompleteResolver instance with a mock connection pool.
 	prop1 := ""
@@ -158,7 +181,7 @@ func Test_SearchCompleteWithFilter_Query(t *testing.T) {
 	// Mock the database query
 	// check if cluster
 	mockPool.EXPECT().Query(gomock.Any(),
-		gomock.Eq(`SELECT DISTINCT "data"->'kind' FROM "search"."resources" WHERE ("data"->'namespace'?|'{"openshift","openshift-monitoring"}' AND ("cluster" IN ('local-cluster')) AND ("data"->'kind' IS NOT NULL) AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))) ORDER BY "data"->'kind' ASC LIMIT 10`),
+		gomock.Eq(`SELECT DISTINCT "data"->'kind' FROM "search"."resources" WHERE ("data"->'namespace'?|'{"openshift","openshift-monitoring"}' AND ("cluster" = 'local-cluster') AND ("data"->'kind' IS NOT NULL) AND ("cluster" = ANY ('{"managed1","managed2"}'))) ORDER BY "data"->'kind' ASC LIMIT 10`),
 		gomock.Eq([]interface{}{})).Return(mockRows, nil)
 
 	// Execute function
@@ -185,7 +208,7 @@ func Test_SearchCompleteWithCluster(t *testing.T) {
 	// Mock the database query
 	// SELECT DISTINCT "prop" FROM (SELECT DISTINCT "cluster" AS "prop" FROM "search"."resources" WHERE (("cluster" IS NOT NULL) AND ("cluster" != '')) LIMIT 100000) AS "searchComplete" ORDER BY prop ASC LIMIT 10
 	mockPool.EXPECT().Query(gomock.Any(),
-		gomock.Eq(`SELECT DISTINCT "cluster" FROM "search"."resources" WHERE (("cluster" IS NOT NULL) AND ("cluster" != '') AND ("cluster" = ANY ('{}'))) ORDER BY "cluster" ASC LIMIT 10`),
+		gomock.Eq(`SELECT DISTINCT "cluster" FROM "search"."resources" WHERE (("cluster" IS NOT NULL) AND ("cluster" != '') AND ("cluster" = ANY ('{}')) AND ("cluster" = ANY ('{}'))) ORDER BY "cluster" ASC LIMIT 10`),
 		gomock.Eq([]interface{}{})).Return(mockRows, nil)
 
 	// Execute function
@@ -195,6 +218,81 @@ func Test_SearchCompleteWithCluster(t *testing.T) {
 	AssertStringArrayEqual(t, result, expectedProps, "Error in Test_SearchCompleteWithFilter_Query")
 }
 
+// Should exclude the clusters the user is not authorized to search.
+func Test_SearchCompleteWithCluster_Authorized(t *testing.T) {
+	// Create a SearchCompleteResolver instance with a mock connection pool.
+	prop1 := "cluster"
+	managed1, hub := "managed1", "local-cluster"
+	_, _, mc := newUserData()
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, prop1,
+		rbac.UserData{NsResources: map[string][]rbac.Resource{"ocm": {{Apigroup: "v1", Kind: "pods"}}},
+			ManagedClusters: mc}, nil)
+	expectedProps := []*string{&hub, &managed1}
+
+	mockRows := &MockRows{mockData: []map[string]interface{}{{"prop": hub}, {"prop": managed1}}}
+	// Mock the database query
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT DISTINCT "cluster" FROM "search"."resources" WHERE (("cluster" IS NOT NULL) AND ("cluster" != '') AND ("cluster" = ANY ('{"managed1","managed2","local-cluster"}')) AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND (data->'namespace'?|'{"ocm"}' AND (data->'apigroup'?'v1' AND data->'kind_plural'?'pods'))))) ORDER BY "cluster" ASC LIMIT 1000`),
+		gomock.Eq([]interface{}{})).Return(mockRows, nil)
+
+	// Execute function
+	result, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	// Verify response
+	assert.Nil(t, err)
+	AssertStringArrayEqual(t, result, expectedProps, "Error in Test_SearchCompleteWithCluster_Authorized")
+}
+
+// Should not filter the cluster suggestions for users with access to all clusters.
+func Test_SearchCompleteWithCluster_AllAccess(t *testing.T) {
+	// Create a SearchCompleteResolver instance with a mock connection pool.
+	prop1 := "cluster"
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, prop1, rbac.UserData{
+		CsResources:             []rbac.Resource{{Apigroup: "*", Kind: "*"}},
+		NsResources:             map[string][]rbac.Resource{"*": {{Apigroup: "*", Kind: "*"}}},
+		ManagedClusterAllAccess: true,
+	}, nil)
+
+	// Mock the database query
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT DISTINCT "cluster" FROM "search"."resources" WHERE (("cluster" IS NOT NULL) AND ("cluster" != '') AND (("cluster" != 'local-cluster') OR "data"?'_hubClusterResource')) ORDER BY "cluster" ASC LIMIT 1000`),
+		gomock.Eq([]interface{}{})).Return(&MockRows{}, nil)
+
+	// Execute function
+	_, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+	assert.Nil(t, err)
+}
+
+// Near-duplicate values should collapse when AUTOCOMPLETE_NORMALIZE is set.
+func Test_SearchComplete_Normalize(t *testing.T) {
+	defer func() { config.Cfg.AutocompleteNorm = "none" }()
+	rows := []map[string]interface{}{
+		{"prop": "Running"}, {"prop": "Running "}, {"prop": " running"}, {"prop": "Crash  Loop"}, {"prop": "Crash Loop\t"},
+	}
+	tests := []struct {
+		normalize string
+		expected  []string
+	}{
+		{"none", []string{" running", "Crash  Loop", "Crash Loop\t", "Running", "Running "}},
+		{"whitespace", []string{"Crash Loop", "Running", "running"}},
+		{"casefold", []string{"crash loop", "running"}},
+	}
+	for _, tt := range tests {
+		config.Cfg.AutocompleteNorm = tt.normalize
+		resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "status",
+			rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+		mockPool.EXPECT().Query(gomock.Any(),
+			gomock.Eq(`SELECT DISTINCT "data"->'status' FROM "search"."resources" WHERE (("data"->'status' IS NOT NULL) AND ("cluster" = ANY ('{}'))) ORDER BY "data"->'status' ASC LIMIT 1000`),
+			gomock.Eq([]interface{}{})).Return(&MockRows{mockData: rows}, nil)
+
+		result, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+		assert.Nil(t, err)
+		assert.Len(t, result, len(tt.expected))
+		AssertStringArrayEqual(t, result, stringArrayToPointer(tt.expected), "Error normalizing with "+tt.normalize)
+	}
+}
+
 func Test_SearchCompleteQuery_PropDate(t *testing.T) {
 	// Create a SearchCompleteResolver instance with a mock connection pool.
 	prop1 := "created"
@@ -276,7 +374,7 @@ func Test_SearchCompleteWithObject_Query(t *testing.T) {
 
 	// Mock the database query
 	mockPool.EXPECT().Query(gomock.Any(),
-		gomock.Eq(`SELECT DISTINCT "data"->'label' FROM "search"."resources" WHERE ("data"->'namespace'?|'{"openshift","openshift-monitoring"}' AND ("cluster" IN ('local-cluster')) AND ("data"->'label' IS NOT NULL) AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))) ORDER BY "data"->'label' ASC LIMIT 1000`),
+		gomock.Eq(`SELECT DISTINCT "data"->'label' FROM "search"."resources" WHERE ("data"->'namespace'?|'{"openshift","openshift-monitoring"}' AND ("cluster" = 'local-cluster') AND ("data"->'label' IS NOT NULL) AND ("cluster" = ANY ('{"managed1","managed2"}'))) ORDER BY "data"->'label' ASC LIMIT 1000`),
 		gomock.Eq([]interface{}{})).Return(mockRows, nil)
 	// Execute function
 	result, err := resolver.autoComplete(context.TODO())
@@ -315,7 +413,7 @@ func Test_SearchCompleteWithContainer_Query(t *testing.T) {
 	expectedProps := []*string{&val1, &val2, &val3}
 	// Mock the database query
 	mockPool.EXPECT().Query(gomock.Any(),
-		gomock.Eq(`SELECT DISTINCT "data"->'container' FROM "search"."resources" WHERE ("data"->'namespace'?|'{"openshift","openshift-monitoring"}' AND ("cluster" IN ('local-cluster')) AND ("data"->'container' IS NOT NULL) AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))) ORDER BY "data"->'container' ASC LIMIT 1000`),
+		gomock.Eq(`SELECT DISTINCT "data"->'container' FROM "search"."resources" WHERE ("data"->'namespace'?|'{"openshift","openshift-monitoring"}' AND ("cluster" = 'local-cluster') AND ("data"->'container' IS NOT NULL) AND ("cluster" = ANY ('{"managed1","managed2"}'))) ORDER BY "data"->'container' ASC LIMIT 1000`),
 		gomock.Eq([]interface{}{})).Return(mockRows, nil)
 
 	// Execute function
@@ -349,3 +447,255 @@ func Test_SearchComplete_EmptyQueryWithoutRbac(t *testing.T) {
 	}
 	assert.Equal(t, resolver.query, "", "query should be empty as there is no rbac clause")
 }
+
+func Test_SearchComplete_Filter(t *testing.T) {
+	limit := 2
+	filter := "con"
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "kind",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	resolver.limit = &limit
+	resolver.filter = &filter
+	rows := []map[string]interface{}{{"prop": "ConfigMap"}, {"prop": "Console"}}
+
+	// The filter is matched ignoring case and the limit still applies.
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT DISTINCT "data"->'kind' FROM "search"."resources" WHERE (("data"->'kind' IS NOT NULL) AND ("data"->>'kind' ILIKE '%con%') AND ("cluster" = ANY ('{}'))) ORDER BY "data"->'kind' ASC LIMIT 2`),
+		gomock.Eq([]interface{}{})).Return(&MockRows{mockData: rows}, nil)
+
+	result, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	assert.Nil(t, err)
+	AssertStringArrayEqual(t, result, stringArrayToPointer([]string{"ConfigMap", "Console"}), "Error filtering kind")
+}
+
+func Test_SearchComplete_FilterCluster(t *testing.T) {
+	filter := "50%_"
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "cluster",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	resolver.filter = &filter
+
+	// The LIKE wildcards in the filter are escaped.
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT DISTINCT "cluster" FROM "search"."resources" WHERE (("cluster" IS NOT NULL) AND ("cluster" != '') AND ("cluster" = ANY ('{}')) AND ("cluster" ILIKE '%50\%\_%') AND ("cluster" = ANY ('{}'))) ORDER BY "cluster" ASC LIMIT 1000`),
+		gomock.Eq([]interface{}{})).Return(&MockRows{mockData: []map[string]interface{}{}}, nil)
+
+	result, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	assert.Nil(t, err)
+	assert.Len(t, result, 0)
+}
+
+func Test_SearchComplete_FilterLabels(t *testing.T) {
+	filter := "APP"
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "label",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	resolver.filter = &filter
+	rows := []map[string]interface{}{
+		{"propArray": map[string]interface{}{"app": "web", "tier": "frontend"}},
+		{"propArray": map[string]interface{}{"app.kubernetes.io/name": "search", "version": "v1"}},
+	}
+
+	// The rows match the JSON text of the labels, only the matching labels are returned.
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT DISTINCT "data"->'label' FROM "search"."resources" WHERE (("data"->'label' IS NOT NULL) AND ("data"->>'label' ILIKE '%APP%') AND ("cluster" = ANY ('{}'))) ORDER BY "data"->'label' ASC LIMIT 1000`),
+		gomock.Eq([]interface{}{})).Return(&MockRows{mockData: rows}, nil)
+
+	result, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	assert.Nil(t, err)
+	AssertStringArrayEqual(t, result, stringArrayToPointer([]string{"app=web", "app.kubernetes.io/name=search"}),
+		"Error filtering labels")
+}
+
+func Test_SearchComplete_FilterNumbers(t *testing.T) {
+	filter := "1"
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "restarts",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	resolver.filter = &filter
+	rows := []map[string]interface{}{{"prop": "1"}, {"prop": "10"}, {"prop": "21"}}
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(&MockRows{mockData: rows}, nil)
+
+	result, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	// Returns the matching values instead of the isNumber range.
+	assert.Nil(t, err)
+	AssertStringArrayEqual(t, result, stringArrayToPointer([]string{"1", "10", "21"}), "Error filtering numbers")
+}
+
+func Test_SearchComplete_Boolean(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected []string
+	}{
+		{"boolean", []string{"false", "true"}, []string{"isBoolean", "true", "false"}},
+		{"single boolean", []string{"true"}, []string{"isBoolean", "true", "false"}},
+		{"boolean and empty", []string{"", "true"}, []string{"isBoolean", "true", "false"}},
+		{"mixed", []string{"false", "true", "unknown"}, []string{"false", "true", "unknown"}},
+		{"number", []string{"0", "1"}, []string{"isNumber", "0", "1"}},
+		{"empty", []string{}, []string{}},
+	}
+	for _, tt := range tests {
+		resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "ready",
+			rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+		rows := []map[string]interface{}{}
+		for _, value := range tt.values {
+			rows = append(rows, map[string]interface{}{"prop": value})
+		}
+		mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(&MockRows{mockData: rows}, nil)
+
+		result, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+		assert.Nil(t, err, tt.name)
+		assert.Len(t, result, len(tt.expected), tt.name)
+		for i, value := range result {
+			if tt.expected[0] == "isBoolean" || tt.expected[0] == "isNumber" { // The order matters.
+				assert.Equal(t, tt.expected[i], *value, tt.name)
+			} else {
+				assert.Contains(t, tt.expected, *value, tt.name)
+			}
+		}
+	}
+}
+
+// Should return an empty list when the property doesn't match any rows.
+func Test_SearchComplete_NoRows(t *testing.T) {
+	for _, prop := range []string{"current", "created", "name"} {
+		resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, prop,
+			rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+		mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&MockRows{mockData: []map[string]interface{}{}}, nil)
+
+		result, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+		assert.Nil(t, err)
+		assert.Equal(t, []*string{}, result, prop)
+	}
+	assert.False(t, isNumber([]*string{}))
+	assert.False(t, isDate([]*string{}))
+	assert.False(t, isBoolean([]*string{}))
+}
+
+func Test_SearchComplete_Numbers(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []interface{}
+		expected []string
+	}{
+		{"floats", []interface{}{"1.5", "0.25", "10.75"}, []string{"isNumber", "0.25", "10.75"}},
+		{"negative", []interface{}{"-3", "2", "-10"}, []string{"isNumber", "-10", "2"}},
+		{"scientific notation", []interface{}{"2e3", "150", "1e-2"}, []string{"isNumber", "1e-2", "2e3"}},
+		{"mixed int and float", []interface{}{"5", "1.5", "12"}, []string{"isNumber", "1.5", "12"}},
+		{"json numbers", []interface{}{float64(5), float64(-1.5), float64(2000)}, []string{"isNumber", "-1.5", "2000"}},
+		{"not numbers", []interface{}{"1.5", "NaN", "Inf"}, []string{"1.5", "Inf", "NaN"}},
+	}
+	for _, tt := range tests {
+		resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "cpu",
+			rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+		rows := []map[string]interface{}{}
+		for _, value := range tt.values {
+			rows = append(rows, map[string]interface{}{"prop": value})
+		}
+		mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(&MockRows{mockData: rows}, nil)
+
+		result, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+		assert.Nil(t, err, tt.name)
+		assert.Len(t, result, len(tt.expected), tt.name)
+		for i, value := range result {
+			if tt.expected[0] == "isNumber" { // The order matters.
+				assert.Equal(t, tt.expected[i], *value, tt.name)
+			} else {
+				assert.Contains(t, tt.expected, *value, tt.name)
+			}
+		}
+	}
+}
+
+// Should observe the duration of each autocomplete query with the query status.
+func Test_SearchComplete_QueryDurationMetric(t *testing.T) {
+	ctx := context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456")
+	okSamples := querySampleCount(metrics.AutocompleteQueryDuration, "ok")
+	errorSamples := querySampleCount(metrics.AutocompleteQueryDuration, "error")
+
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "kind",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(&MockRows{mockData: []map[string]interface{}{{"prop": "Pod"}}}, nil)
+	_, err := resolver.autoComplete(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, okSamples+1, querySampleCount(metrics.AutocompleteQueryDuration, "ok"))
+
+	// Results from the cache don't query the database.
+	_, err = resolver.autoComplete(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, okSamples+1, querySampleCount(metrics.AutocompleteQueryDuration, "ok"))
+
+	resolver, mockPool = newMockSearchComplete(t, &model.SearchInput{}, "kind",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("query failed"))
+	_, err = resolver.autoComplete(ctx)
+	assert.NotNil(t, err)
+	assert.Equal(t, errorSamples+1, querySampleCount(metrics.AutocompleteQueryDuration, "error"))
+}
+
+// Should return the kind and name of the resources referenced by the property.
+func Test_SearchComplete_ResolveReferences(t *testing.T) {
+	kind := "Pod"
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&kind}}}}
+	ud := rbac.UserData{CsResources: []rbac.Resource{}, ManagedClusters: map[string]struct{}{"managed1": {}}}
+	resolver, mockPool := newMockSearchComplete(t, searchInput, "_ownerUID", ud, map[string]string{"kind": "string"})
+	resolver.resolveReferences = true
+
+	mockRows := &MockRows{mockData: []map[string]interface{}{
+		{"prop": "ReplicaSet/nginx-5d6f8b7c9"}, {"prop": "StatefulSet/postgres"}}}
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT DISTINCT concat_ws('/', "data"->>'kind', "data"->>'name') FROM "search"."resources" WHERE (("uid" IN ((SELECT "data"->>'_ownerUID' FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND ("data"->'_ownerUID' IS NOT NULL) AND ("cluster" = ANY ('{"managed1"}')))))) AND ("cluster" = ANY ('{"managed1"}'))) ORDER BY concat_ws('/', "data"->>'kind', "data"->>'name') ASC LIMIT 1000`),
+		gomock.Eq([]interface{}{})).Return(mockRows, nil)
+
+	result, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	assert.Nil(t, err)
+	expected := stringArrayToPointer([]string{"ReplicaSet/nginx-5d6f8b7c9", "StatefulSet/postgres"})
+	AssertStringArrayEqual(t, result, expected, "Error in Test_SearchComplete_ResolveReferences")
+}
+
+// Should filter the referenced resources by kind and name.
+func Test_SearchComplete_ResolveReferencesFilter(t *testing.T) {
+	filter := "nginx"
+	resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "_ownerUID",
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	resolver.resolveReferences = true
+	resolver.filter = &filter
+
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT DISTINCT concat_ws('/', "data"->>'kind', "data"->>'name') FROM "search"."resources" WHERE (("uid" IN ((SELECT "data"->>'_ownerUID' FROM "search"."resources" WHERE (("data"->'_ownerUID' IS NOT NULL) AND ("cluster" = ANY ('{}')))))) AND ("cluster" = ANY ('{}')) AND (concat_ws('/', "data"->>'kind', "data"->>'name') ILIKE '%nginx%')) ORDER BY concat_ws('/', "data"->>'kind', "data"->>'name') ASC LIMIT 1000`),
+		gomock.Eq([]interface{}{})).Return(&MockRows{}, nil)
+
+	_, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+	assert.Nil(t, err)
+}
+
+// Should return the values of the properties that aren't in AUTOCOMPLETE_REFERENCE_PROPERTIES, or when
+// resolveReferences isn't set.
+func Test_SearchComplete_ResolveReferencesNotReference(t *testing.T) {
+	testcases := []struct {
+		property          string
+		resolveReferences bool
+	}{
+		{"name", true},
+		{"_ownerUID", false},
+	}
+	for _, tc := range testcases {
+		resolver, mockPool := newMockSearchComplete(t, &model.SearchInput{}, tc.property,
+			rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+		resolver.resolveReferences = tc.resolveReferences
+
+		mockPool.EXPECT().Query(gomock.Any(),
+			gomock.Eq(fmt.Sprintf(`SELECT DISTINCT "data"->'%[1]s' FROM "search"."resources" WHERE (("data"->'%[1]s' IS NOT NULL) AND ("cluster" = ANY ('{}'))) ORDER BY "data"->'%[1]s' ASC LIMIT 1000`, tc.property)),
+			gomock.Eq([]interface{}{})).Return(&MockRows{}, nil)
+
+		_, err := resolver.autoComplete(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+		assert.Nil(t, err)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	klog "k8s.io/klog/v2"
+)
+
+// Position of the last item in a page. Encoded in the opaque cursor returned to the client.
+// The position only depends on the uid and sort value, so the cursor is valid when the
+// user's RBAC changes between pages.
+type searchCursor struct {
+	UID       string  `json:"uid"`
+	Property  string  `json:"property,omitempty"`  // sortBy property
+	Direction string  `json:"direction,omitempty"` // sortBy direction
+	Value     *string `json:"value,omitempty"`     // sortBy value of the last item, nil if it doesn't have the property
+}
+
+func encodeCursor(cursor searchCursor) (string, error) {
+	bytes, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func decodeCursor(encoded string) (searchCursor, error) {
+	cursor := searchCursor{}
+	bytes, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err == nil {
+		err = json.Unmarshal(bytes, &cursor)
+	}
+	if err != nil || cursor.UID == "" {
+		return cursor, invalidInputf("invalid cursor [%s]", encoded)
+	}
+	return cursor, nil
+}
+
+// Returns true if the items are paged with pageSize or cursor.
+func (s *SearchResult) paginated() bool {
+	return s.input != nil && ((s.input.PageSize != nil && *s.input.PageSize > 0) || s.input.Cursor != nil)
+}
+
+// Returns the WHERE expression to select the items after the cursor, or nil if there isn't a cursor.
+// Sample for sortBy name asc:
+//
+//	(("data"->>'name' > 'last-name') OR (("data"->>'name' = 'last-name') AND ("uid" > 'last-uid'))
+//	  OR ("data"->>'name' IS NULL))
+func (s *SearchResult) cursorExpression() (exp.Expression, error) {
+	if s.input == nil || s.input.Cursor == nil || *s.input.Cursor == "" {
+		return nil, nil
+	}
+	cursor, err := decodeCursor(*s.input.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	sortExp, direction, err := s.sortProperty()
+	if err != nil {
+		return nil, err
+	}
+	property := ""
+	if sortExp != nil {
+		property = s.input.SortBy.Property
+	}
+	if cursor.Property != property || (sortExp != nil && cursor.Direction != direction) {
+		return nil, invalidInputf("cursor doesn't match the sortBy option used to get the previous page")
+	}
+
+	afterUID := goqu.C("uid").Gt(cursor.UID)
+	if sortExp == nil {
+		return afterUID, nil
+	}
+	// Resources without the property are sorted last.
+	if cursor.Value == nil {
+		return goqu.And(sortExp.IsNull(), afterUID), nil
+	}
+	after := sortExp.Gt(*cursor.Value)
+	if direction == "desc" {
+		after = sortExp.Lt(*cursor.Value)
+	}
+	return goqu.Or(after, goqu.And(sortExp.Eq(*cursor.Value), afterUID), sortExp.IsNull()), nil
+}
+
+// Keep the position of the last item resolved, used to build the next cursor.
+func (s *SearchResult) setLastItem(uid, cluster string, data map[string]interface{}) {
+	if !s.paginated() {
+		return
+	}
+	s.lastItem = &searchCursor{UID: uid}
+	if s.input.SortBy == nil {
+		return
+	}
+	s.lastItem.Property = s.input.SortBy.Property
+	_, s.lastItem.Direction, _ = s.sortProperty()
+	if s.lastItem.Property == "cluster" {
+		s.lastItem.Value = &cluster
+		return
+	}
+	var value string
+	switch v := data[s.lastItem.Property].(type) {
+	case nil:
+		return
+	case string:
+		value = v
+	case float64:
+		value = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		value = fmt.Sprint(v)
+	}
+	s.lastItem.Value = &value
+}
+
+// Cursor to get the next page of items. Returns nil if the items aren't paged or after the last page.
+func (s *SearchResult) NextCursor(ctx context.Context) (*string, error) {
+	if !s.paginated() || !s.matchesManagedHubFilter() {
+		return nil, nil
+	}
+	if s.context == nil {
+		s.context = ctx
+	}
+	// Wait for the items, or fetch these if the items weren't requested, to find the last item.
+	if page := s.fetchPage(); page.err != nil {
+		return nil, page.err
+	}
+
+	// There isn't a next page when the page isn't full.
+	limit := s.pageLimit()
+	if s.lastItem == nil || limit == 0 || s.itemsCount < limit {
+		return nil, nil
+	}
+	cursor, err := encodeCursor(*s.lastItem)
+	if err != nil {
+		klog.Error("Error encoding the search cursor. ", err)
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// Max number of items in the page. Uses pageSize if set, otherwise the limit.
+func (s *SearchResult) pageLimit() int {
+	if s.input != nil && s.input.PageSize != nil && *s.input.PageSize > 0 {
+		return capLimit(*s.input.PageSize)
+	}
+	return s.setLimit()
+}
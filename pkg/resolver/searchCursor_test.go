@@ -0,0 +1,220 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/golang/mock/gomock"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns the rows of the dataset after the "uid" > 'x' condition in the query, up to the limit.
+// Emulates the database for the keyset pagination query.
+func mockPageRows(dataset []map[string]interface{}, query string, limit int) *MockRows {
+	after := ""
+	if match := regexp.MustCompile(`"uid" > '([^']*)'`).FindStringSubmatch(query); match != nil {
+		after = match[1]
+	}
+	rows := []map[string]interface{}{}
+	for _, row := range dataset {
+		if row["uid"].(string) > after && len(rows) < limit {
+			rows = append(rows, row)
+		}
+	}
+	return &MockRows{mockData: rows, columnHeaders: []string{"uid", "cluster", "data"}}
+}
+
+func Test_SearchResolver_CursorPages(t *testing.T) {
+	dataset := []map[string]interface{}{}
+	for i := 0; i < 7; i++ {
+		dataset = append(dataset, map[string]interface{}{"uid": fmt.Sprintf("local-cluster/uid-%d", i),
+			"cluster": "local-cluster", "data": map[string]interface{}{"kind": "Pod", "name": fmt.Sprintf("pod-%d", i)}})
+	}
+	val1 := "Pod"
+	pageSize := 3
+	var cursor *string
+	uids := []string{}
+	pages := 0
+
+	for {
+		searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val1}}},
+			PageSize: &pageSize, Cursor: cursor}
+		ud := rbac.UserData{CsResources: []rbac.Resource{}}
+		resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string"})
+		mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Eq([]interface{}{})).DoAndReturn(
+			func(ctx context.Context, query string, args ...interface{}) (*MockRows, error) {
+				assert.Contains(t, query, `ORDER BY "uid" ASC LIMIT 3`)
+				return mockPageRows(dataset, query, pageSize), nil
+			})
+
+		items, err := resolver.Items()
+		assert.Nil(t, err)
+		for _, item := range items {
+			uids = append(uids, item["_uid"].(string))
+		}
+		pages++
+		cursor, err = resolver.NextCursor(context.Background())
+		assert.Nil(t, err)
+		if cursor == nil || pages > len(dataset) {
+			break
+		}
+	}
+
+	// All the items are returned once and in order.
+	assert.Equal(t, 3, pages)
+	expected := []string{}
+	for _, row := range dataset {
+		expected = append(expected, row["uid"].(string))
+	}
+	assert.Equal(t, expected, uids)
+}
+
+func Test_SearchResolver_CursorQuery(t *testing.T) {
+	val1 := "Pod"
+	cursor, _ := encodeCursor(searchCursor{UID: "local-cluster/uid-2"})
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val1}}},
+		Cursor: &cursor}
+	csRes, nsRes, managedClusters := newUserData()
+	ud := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string"})
+
+	// The RBAC clause is applied in the inner query and the cursor in the outer query.
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT "uid", "cluster", "data" FROM (SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments'))))))))) AS "items" WHERE ("uid" > 'local-cluster/uid-2') ORDER BY "uid" ASC LIMIT 1000`),
+		gomock.Eq([]interface{}{}),
+	).Return(&MockRows{mockData: []map[string]interface{}{}, columnHeaders: []string{"uid", "cluster", "data"}}, nil)
+
+	items, err := resolver.Items()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(items))
+
+	// There isn't a next page after the last page.
+	next, err := resolver.NextCursor(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, next)
+}
+
+func Test_cursorExpression_Sorted(t *testing.T) {
+	asc, desc := "asc", "desc"
+	value := "10"
+	tests := []struct {
+		name      string
+		direction *string
+		cursor    searchCursor
+		expected  string
+	}{
+		{"asc", &asc, searchCursor{UID: "uid-1", Property: "restarts", Direction: "asc", Value: &value},
+			`SELECT * WHERE ((("data"->'restarts')::numeric > '10') OR ((("data"->'restarts')::numeric = '10') AND ("uid" > 'uid-1')) OR (("data"->'restarts')::numeric IS NULL))`},
+		{"desc", &desc, searchCursor{UID: "uid-1", Property: "restarts", Direction: "desc", Value: &value},
+			`SELECT * WHERE ((("data"->'restarts')::numeric < '10') OR ((("data"->'restarts')::numeric = '10') AND ("uid" > 'uid-1')) OR (("data"->'restarts')::numeric IS NULL))`},
+		{"without value", nil, searchCursor{UID: "uid-1", Property: "restarts", Direction: "asc"},
+			`SELECT * WHERE ((("data"->'restarts')::numeric IS NULL) AND ("uid" > 'uid-1'))`},
+		{"different direction", &desc, searchCursor{UID: "uid-1", Property: "restarts", Direction: "asc"}, ""},
+		{"different property", &asc, searchCursor{UID: "uid-1", Property: "name", Direction: "asc"}, ""},
+		{"not sorted", &asc, searchCursor{UID: "uid-1"}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cursor, _ := encodeCursor(test.cursor)
+			s := &SearchResult{
+				input: &model.SearchInput{Cursor: &cursor,
+					SortBy: &model.SearchSort{Property: "restarts", Direction: test.direction}},
+				propTypes: map[string]string{"restarts": "number"},
+			}
+			cursorExp, err := s.cursorExpression()
+			if test.expected == "" {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			sql, _, _ := goqu.From().Select(goqu.Star()).Where(cursorExp).ToSQL()
+			assert.Equal(t, test.expected, sql)
+		})
+	}
+}
+
+func Test_decodeCursor_Invalid(t *testing.T) {
+	for _, cursor := range []string{"not-base64!", "bm90IGpzb24", "e30"} { // "not json", "{}"
+		_, err := decodeCursor(cursor)
+		assert.NotNil(t, err, cursor)
+	}
+}
+
+func Test_SearchResolver_NextCursorSorted(t *testing.T) {
+	val1 := "Pod"
+	pageSize := 2
+	direction := "desc"
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val1}}},
+		PageSize: &pageSize, SortBy: &model.SearchSort{Property: "restarts", Direction: &direction}}
+	ud := rbac.UserData{CsResources: []rbac.Resource{}}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud,
+		map[string]string{"kind": "string", "restarts": "number"})
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(&MockRows{
+		mockData: []map[string]interface{}{
+			{"uid": "uid-1", "cluster": "local-cluster", "data": map[string]interface{}{"restarts": float64(12)}},
+			{"uid": "uid-2", "cluster": "local-cluster", "data": map[string]interface{}{"restarts": float64(2.5)}},
+		},
+		columnHeaders: []string{"uid", "cluster", "data"}}, nil)
+
+	// The items weren't requested, so these are resolved to find the last item.
+	next, err := resolver.NextCursor(context.Background())
+	assert.Nil(t, err)
+	assert.NotNil(t, next)
+	cursor, err := decodeCursor(*next)
+	assert.Nil(t, err)
+	value := "2.5"
+	assert.Equal(t, searchCursor{UID: "uid-2", Property: "restarts", Direction: "desc", Value: &value}, cursor)
+}
+
+func Test_SearchResolver_NextCursorNotPaged(t *testing.T) {
+	resolver, _ := newMockSearchResolver(t, &model.SearchInput{}, nil, rbac.UserData{}, nil)
+	next, err := resolver.NextCursor(context.Background())
+	assert.Nil(t, err)
+	assert.Nil(t, next)
+}
+
+// Should fetch the items once when the items and the next cursor are resolved concurrently.
+func Test_SearchResolver_NextCursorConcurrentItems(t *testing.T) {
+	val1 := "Pod"
+	pageSize := 2
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val1}}},
+		PageSize: &pageSize}
+	ud := rbac.UserData{CsResources: []rbac.Resource{}}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string"})
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Times(1).Return(&MockRows{
+		mockData: []map[string]interface{}{
+			{"uid": "uid-1", "cluster": "local-cluster", "data": map[string]interface{}{"kind": "Pod"}},
+			{"uid": "uid-2", "cluster": "local-cluster", "data": map[string]interface{}{"kind": "Pod"}},
+		},
+		columnHeaders: []string{"uid", "cluster", "data"}}, nil)
+
+	var wg sync.WaitGroup
+	var items []SearchItem
+	var next *string
+	var itemsErr, cursorErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		items, itemsErr = resolver.EncodedItems()
+	}()
+	go func() {
+		defer wg.Done()
+		next, cursorErr = resolver.NextCursor(context.Background())
+	}()
+	wg.Wait()
+
+	assert.Nil(t, itemsErr)
+	assert.Nil(t, cursorErr)
+	assert.Len(t, items, 2)
+	assert.NotNil(t, next)
+	cursor, err := decodeCursor(*next)
+	assert.Nil(t, err)
+	assert.Equal(t, searchCursor{UID: "uid-2"}, cursor)
+}
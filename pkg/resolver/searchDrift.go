@@ -0,0 +1,200 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	db "github.com/stolostron/search-v2-api/pkg/database"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	klog "k8s.io/klog/v2"
+)
+
+// Default properties used to match a resource across clusters.
+var defaultDriftIdentity = []string{"namespace", "name"}
+
+type SearchDriftResult struct {
+	pool      pgxpoolmock.PgxPool
+	kind      string
+	identity  []string
+	limit     *int
+	query     string
+	params    []interface{}
+	propTypes map[string]string
+	userData  rbac.UserData
+}
+
+func SearchDrift(ctx context.Context, kind string, identity []string, limit *int) ([]*model.SearchDrift, error) {
+	defer metrics.SlowLog("SearchDriftResolver", 0)()
+	userData, userDataErr := rbac.GetCache().GetUserData(ctx)
+	if userDataErr != nil {
+		return []*model.SearchDrift{}, userDataErr
+	}
+
+	// Check that shared cache has property types:
+	propTypes, err := rbac.GetCache().GetPropertyTypes(ctx, false)
+	if err != nil {
+		klog.Warningf("Error creating datatype map with err: [%s] ", err)
+	}
+
+	if len(identity) == 0 {
+		identity = defaultDriftIdentity
+	}
+
+	// Proceed if user's rbac data exists
+	searchDriftResult := &SearchDriftResult{
+		pool:      db.GetConnPool(ctx),
+		kind:      kind,
+		identity:  identity,
+		limit:     limit,
+		userData:  userData,
+		propTypes: propTypes,
+	}
+	return searchDriftResult.drift(ctx)
+}
+
+func (s *SearchDriftResult) drift(ctx context.Context) ([]*model.SearchDrift, error) {
+	// The denied properties are dropped from the identity, there's no drift to find without identity.
+	identity := make([]string, 0, len(s.identity))
+	for _, property := range s.identity {
+		if drop, err := checkDeniedProperty(property); err != nil {
+			return []*model.SearchDrift{}, err
+		} else if !drop {
+			identity = append(identity, property)
+		}
+	}
+	if len(identity) == 0 {
+		return []*model.SearchDrift{}, nil
+	}
+	s.identity = identity
+	if err := s.buildSearchDriftQuery(ctx); err != nil {
+		return []*model.SearchDrift{}, err
+	}
+	return s.searchDriftResults(ctx)
+}
+
+// Returns the limit for the query. The client can't exceed MAX_QUERY_LIMIT, including with -1 for all results.
+func (s *SearchDriftResult) driftLimit() int {
+	if s.limit != nil {
+		return capLimit(*s.limit)
+	}
+	return config.Cfg.QueryLimit
+}
+
+// Groups the resources of the kind by the identity properties and keeps the groups found only on the hub
+// or only on managed clusters. The RBAC clause is applied before grouping, so the drift is computed
+// with the resources the user is authorized to list.
+// Sample query:
+//
+//	SELECT COALESCE("data"->>'namespace', '') AS "id0", COALESCE("data"->>'name', '') AS "id1",
+//	  bool_or("data"?'_hubClusterResource') AS "hub",
+//	  COALESCE(array_agg(DISTINCT "cluster") FILTER (WHERE NOT "data"?'_hubClusterResource'), '{}') AS "clusters"
+//	FROM "search"."resources" WHERE ("data"->'kind'?('Deployment') AND <rbac>)
+//	GROUP BY "id0", "id1" HAVING (bool_or("data"?'_hubClusterResource') != bool_or(NOT "data"?'_hubClusterResource'))
+//	ORDER BY "id0" ASC, "id1" ASC LIMIT 1000
+func (s *SearchDriftResult) buildSearchDriftQuery(ctx context.Context) error {
+	var err error
+	s.query = ""
+	s.params = nil
+
+	if s.kind == "" {
+		return invalidInputf("kind is required for searchDrift query")
+	}
+	for _, property := range s.identity {
+		// The cluster is what we compare, so it can't be part of the identity.
+		if property == "" || property == "cluster" || property == "managedHub" {
+			return invalidInputf("property [%s] can't be used as identity for searchDrift query", property)
+		}
+	}
+
+	// WHERE CLAUSE
+	input := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&s.kind}}}}
+	whereDs, propTypes, err := WhereClauseFilter(ctx, input, s.propTypes)
+	if err != nil {
+		klog.Errorf("Error building SearchDrift query: %s", err.Error())
+		return err
+	}
+	s.propTypes = propTypes
+
+	// get user info for logging
+	_, userInfo := rbac.GetCache().GetUserUID(ctx)
+
+	// RBAC CLAUSE
+	// if one of them is not nil, userData is not empty
+	if s.userData.CsResources != nil || s.userData.NsResources != nil || s.userData.ManagedClusters != nil {
+		whereDs = append(whereDs, buildRbacWhereClause(ctx, s.userData, userInfo)) // add rbac
+	} else {
+		klog.Errorf("Error building searchDrift query: RBAC clause is required!"+
+			" None found for searchDrift query for kind %s for user %s with uid %s ",
+			s.kind, userInfo.Username, userInfo.UID)
+		return fmt.Errorf("RBAC clause is required! None found for searchDrift query")
+	}
+
+	hubExp := goqu.L("???", goqu.C("data"), goqu.Literal("?"), "_hubClusterResource") // "data"?'_hubClusterResource'
+	selectExp := make([]interface{}, 0, len(s.identity)+2)
+	groupExp := make([]interface{}, 0, len(s.identity))
+	orderExp := make([]exp.OrderedExpression, 0, len(s.identity))
+	for i, property := range s.identity {
+		column := fmt.Sprintf("id%d", i)
+		selectExp = append(selectExp, goqu.L(`COALESCE("data"->>?, '')`, property).As(column))
+		groupExp = append(groupExp, goqu.C(column))
+		orderExp = append(orderExp, goqu.C(column).Asc())
+	}
+	selectExp = append(selectExp,
+		goqu.L("bool_or(?)", hubExp).As("hub"),
+		goqu.L("COALESCE(array_agg(DISTINCT ?) FILTER (WHERE NOT ?), '{}')", goqu.C("cluster"), hubExp).
+			As("clusters"))
+
+	sql, params, err := goqu.From(goqu.S("search").Table("resources")).
+		Select(selectExp...).
+		Where(whereDs...).
+		GroupBy(groupExp...).
+		Having(goqu.L("bool_or(?) != bool_or(NOT ?)", hubExp, hubExp)).
+		Order(orderExp...).
+		Limit(uint(s.driftLimit())).
+		ToSQL()
+	if err != nil {
+		klog.Errorf("Error building SearchDrift query: %s", err.Error())
+		return err
+	}
+	s.query = sql
+	s.params = params
+	klog.V(5).Info("SearchDrift Query: ", s.query)
+	return nil
+}
+
+func (s *SearchDriftResult) searchDriftResults(ctx context.Context) ([]*model.SearchDrift, error) {
+	klog.V(2).Info("Resolving searchDriftResults()")
+	results := make([]*model.SearchDrift, 0)
+	rows, err := query(ctx, s.pool, s.query, s.params...)
+	if err != nil {
+		klog.Error("Error fetching search drift results from db ", err)
+		return results, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		drift := &model.SearchDrift{Identity: make([]string, len(s.identity)), Clusters: []string{}}
+		dest := make([]interface{}, 0, len(s.identity)+2)
+		for i := range drift.Identity {
+			dest = append(dest, &drift.Identity[i])
+		}
+		dest = append(dest, &drift.Hub, &drift.Clusters)
+		if scanErr := rows.Scan(dest...); scanErr != nil {
+			klog.Error("Error reading searchDriftResults ", scanErr)
+			continue
+		}
+		results = append(results, drift)
+	}
+	if err = rows.Err(); err != nil {
+		klog.Error("Error reading searchDriftResults ", err)
+		return make([]*model.SearchDrift, 0), err
+	}
+	return results, nil
+}
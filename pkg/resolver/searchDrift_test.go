@@ -0,0 +1,120 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/golang/mock/gomock"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMockSearchDrift(t *testing.T, kind string, identity []string, ud rbac.UserData) (*SearchDriftResult,
+	*pgxpoolmock.MockPgxPool) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	mockResolver := &SearchDriftResult{
+		pool:      mockPool,
+		kind:      kind,
+		identity:  identity,
+		userData:  ud,
+		propTypes: map[string]string{"kind": "string", "namespace": "string", "name": "string"},
+	}
+	return mockResolver, mockPool
+}
+
+func Test_SearchDrift_Query(t *testing.T) {
+	// Create a SearchDriftResult instance with a mock connection pool.
+	resolver, mockPool := newMockSearchDrift(t, "Deployment", defaultDriftIdentity,
+		rbac.UserData{CsResources: []rbac.Resource{}})
+
+	// Synthetic data set after grouping by namespace and name:
+	//   default/app1 exists on the hub and managed1 (not drift, filtered by the HAVING clause).
+	//   default/app2 exists only on the hub.
+	//   ocm/app3 exists only on managed1 and managed2.
+	mockRows := &MockRows{
+		mockData: []map[string]interface{}{
+			{"id0": "default", "id1": "app2", "hub": true, "clusters": []string{}},
+			{"id0": "ocm", "id1": "app3", "hub": false, "clusters": []string{"managed1", "managed2"}},
+		},
+		columnHeaders: []string{"id0", "id1", "hub", "clusters"},
+	}
+	// Mock the database query
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT COALESCE("data"->>'namespace', '') AS "id0", COALESCE("data"->>'name', '') AS "id1", bool_or("data"?'_hubClusterResource') AS "hub", COALESCE(array_agg(DISTINCT "cluster") FILTER (WHERE NOT "data"?'_hubClusterResource'), '{}') AS "clusters" FROM "search"."resources" WHERE ("data"->'kind'?('Deployment') AND ("cluster" = ANY ('{}'))) GROUP BY "id0", "id1" HAVING bool_or("data"?'_hubClusterResource') != bool_or(NOT "data"?'_hubClusterResource') ORDER BY "id0" ASC, "id1" ASC LIMIT 1000`),
+		gomock.Eq([]interface{}{})).Return(mockRows, nil)
+
+	// Execute function
+	result, err := resolver.drift(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	// Verify response
+	assert.Nil(t, err)
+	assert.Equal(t, []*model.SearchDrift{
+		{Identity: []string{"default", "app2"}, Hub: true, Clusters: []string{}},
+		{Identity: []string{"ocm", "app3"}, Hub: false, Clusters: []string{"managed1", "managed2"}},
+	}, result)
+}
+
+// The drift must be computed only with the resources the user is authorized to list.
+func Test_SearchDrift_QueryWithRBAC(t *testing.T) {
+	csRes, nsRes, managedClusters := newUserData()
+	limit := 5
+	resolver, mockPool := newMockSearchDrift(t, "Pod", []string{"namespace", "label"},
+		rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters})
+	resolver.limit = &limit
+
+	// Mock the database query
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT COALESCE("data"->>'namespace', '') AS "id0", COALESCE("data"->>'label', '') AS "id1", bool_or("data"?'_hubClusterResource') AS "hub", COALESCE(array_agg(DISTINCT "cluster") FILTER (WHERE NOT "data"?'_hubClusterResource'), '{}') AS "clusters" FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))) GROUP BY "id0", "id1" HAVING bool_or("data"?'_hubClusterResource') != bool_or(NOT "data"?'_hubClusterResource') ORDER BY "id0" ASC, "id1" ASC LIMIT 5`),
+		gomock.Eq([]interface{}{})).Return(&MockRows{}, nil)
+
+	// Execute function
+	result, err := resolver.drift(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	// Verify response
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(result))
+}
+
+func Test_SearchDrift_Limit(t *testing.T) {
+	resolver, _ := newMockSearchDrift(t, "Pod", defaultDriftIdentity, rbac.UserData{})
+
+	limit := 10
+	resolver.limit = &limit
+	assert.Equal(t, 10, resolver.driftLimit())
+
+	limit = 5000
+	assert.Equal(t, 5000, resolver.driftLimit()) // Can exceed QUERY_LIMIT up to MAX_QUERY_LIMIT
+
+	limit = 1000000
+	assert.Equal(t, config.Cfg.MaxQueryLimit, resolver.driftLimit()) // Can't exceed MAX_QUERY_LIMIT
+
+	limit = -1
+	assert.Equal(t, config.Cfg.MaxQueryLimit, resolver.driftLimit())
+
+	resolver.limit = nil
+	assert.Equal(t, config.Cfg.QueryLimit, resolver.driftLimit())
+}
+
+func Test_SearchDrift_InvalidInput(t *testing.T) {
+	ctx := context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456")
+	ud := rbac.UserData{CsResources: []rbac.Resource{}}
+
+	resolver, _ := newMockSearchDrift(t, "Pod", []string{"name", "cluster"}, ud)
+	_, err := resolver.drift(ctx)
+	assert.EqualError(t, err, "property [cluster] can't be used as identity for searchDrift query")
+
+	resolver, _ = newMockSearchDrift(t, "", defaultDriftIdentity, ud)
+	_, err = resolver.drift(ctx)
+	assert.EqualError(t, err, "kind is required for searchDrift query")
+
+	// RBAC clause is required.
+	resolver, _ = newMockSearchDrift(t, "Pod", defaultDriftIdentity, rbac.UserData{})
+	_, err = resolver.drift(ctx)
+	assert.EqualError(t, err, "RBAC clause is required! None found for searchDrift query")
+}
@@ -0,0 +1,125 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
+	klog "k8s.io/klog/v2"
+)
+
+// Number of items written to the export before flushing the response.
+var exportFlushItems = 100
+
+// Streams the items matching the search input as newline delimited JSON (NDJSON), one item per line.
+// The request body is a SearchInput, for example: {"filters":[{"property":"kind","values":["Pod"]}]}
+//
+// The rows are encoded and written to the response as they are read from the database, so the memory
+// used doesn't depend on the number of results. The items are sorted by uid if sortBy isn't set, and
+// the limit only applies when it's set in the input.
+func HandleExport(w http.ResponseWriter, r *http.Request) {
+	klog.V(3).Info("Received export request.")
+	input := &model.SearchInput{}
+	if err := json.NewDecoder(r.Body).Decode(input); err != nil {
+		klog.V(2).Info("Error decoding the export request. ", err)
+		http.Error(w, "{\"message\":\"Invalid search input.\"}", http.StatusBadRequest)
+		return
+	}
+	results, err := Search(r.Context(), []*model.SearchInput{input})
+	if errors.Is(err, ErrInvalidInput) {
+		http.Error(w, fmt.Sprintf("{\"message\":%q}", err.Error()), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		klog.Warning("Unable to resolve the user's access for the export. ", err)
+		http.Error(w, "{\"message\":\"Unable to resolve the user's access.\"}", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	count, err := results[0].exportItems(w)
+	if err != nil && count == 0 {
+		http.Error(w, fmt.Sprintf("{\"message\":%q}", err.Error()), errorStatus(err))
+		return
+	} else if err != nil {
+		// The status was sent with the first item. Abort the response so the client doesn't receive
+		// a truncated export as if it was complete.
+		klog.Errorf("Error streaming the export after %d items. Error: %s", count, err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// Writes the items to the export as these are read from the database. Returns the number of items written.
+func (s *SearchResult) exportItems(w io.Writer) (int, error) {
+	if !s.matchesManagedHubFilter() { // if current hub is not part of managedHub filter, stop search
+		return 0, nil
+	}
+	klog.V(2).Info("Resolving SearchResult:exportItems()")
+	s.export = true
+	if err := s.buildSearchQuery(s.context, false, false); err != nil {
+		return 0, err
+	}
+
+	timer := prometheus.NewTimer(metrics.DBQueryDuration.WithLabelValues("exportItemsFunc"))
+	defer timer.ObserveDuration()
+	start := time.Now()
+	rows, err := query(s.context, s.pool, s.query, s.params...)
+	if err != nil {
+		metrics.ObserveQueryDuration(metrics.SearchQueryDuration, start, err)
+		klog.Errorf("Error resolving query [%s] with args [%+v]. Error: [%+v]", s.query, s.params, err)
+		return 0, err
+	}
+	defer rows.Close()
+
+	e := itemEncoderPool.Get().(*itemEncoder)
+	defer itemEncoderPool.Put(e)
+	flusher, _ := w.(http.Flusher)
+	count := 0
+
+	for rows.Next() {
+		var uid, cluster string
+		var data map[string]interface{}
+		if err = rows.Scan(&uid, &cluster, &data); err != nil {
+			klog.Errorf("Error %s retrieving rows for query:%s", err.Error(), s.query)
+			continue
+		}
+		item := formatDataMap(data)
+		item["_uid"] = uid
+		item["cluster"] = cluster
+		s.trimItem(item)
+
+		encoded, ok := e.encode(item)
+		if !ok {
+			continue
+		}
+		if _, err = w.Write(encoded); err != nil {
+			metrics.ObserveQueryDuration(metrics.SearchQueryDuration, start, err)
+			return count, err
+		}
+		count++
+		if flusher != nil && count%exportFlushItems == 0 {
+			flusher.Flush()
+		}
+	}
+	err = rows.Err()
+	metrics.ObserveQueryDuration(metrics.SearchQueryDuration, start, err)
+	if err != nil {
+		return count, err
+	}
+	s.audit("export", count)
+	return count, nil
+}
+
+// Limit of the export. Unlike the items, the export isn't capped with MAX_QUERY_LIMIT.
+func (s *SearchResult) exportLimit() int {
+	if s.input != nil && s.input.Limit != nil && *s.input.Limit > 0 {
+		return *s.input.Limit
+	}
+	return 0
+}
@@ -0,0 +1,130 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+// Records each write and flush to verify the items are streamed.
+type exportRecorder struct {
+	bytes.Buffer
+	writes  int
+	flushes int
+}
+
+func (r *exportRecorder) Write(p []byte) (int, error) {
+	r.writes++
+	return r.Buffer.Write(p)
+}
+
+func (r *exportRecorder) Flush() { r.flushes++ }
+
+// Should write every item as a line, in the order read from the database, flushing while streaming.
+func Test_exportItems(t *testing.T) {
+	csRes, nsRes, managedClusters := newUserData()
+	ud := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters}
+	searchInput := &model.SearchInput{
+		Filters: []*model.SearchFilter{{Property: "kind", Values: stringArrayToPointer([]string{"pod"})}}}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string"})
+
+	mockData := []map[string]interface{}{}
+	for i := 0; i < 250; i++ {
+		mockData = append(mockData, map[string]interface{}{"uid": fmt.Sprintf("local-cluster/uid-%03d", i),
+			"cluster": "local-cluster", "data": []byte(fmt.Sprintf(`{"kind":"Pod","name":"pod-%03d"}`, i))})
+	}
+	var sql string
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Eq([]interface{}{})).
+		DoAndReturn(func(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+			sql = query
+			return &MockRows{mockData: mockData, columnHeaders: []string{"uid", "cluster", "data"}}, nil
+		})
+
+	w := &exportRecorder{}
+	count, err := resolver.exportItems(w)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 250, count)
+	// Sorted by uid and not limited by MAX_QUERY_LIMIT.
+	assert.True(t, strings.HasPrefix(sql, `SELECT "uid", "cluster", "data" FROM (SELECT DISTINCT`), sql)
+	assert.True(t, strings.HasSuffix(sql, `AS "items" ORDER BY "uid" ASC`), sql)
+	// Each item is written as it's read, instead of buffering the whole result.
+	assert.Equal(t, 250, w.writes)
+	assert.Equal(t, 2, w.flushes)
+
+	scanner := bufio.NewScanner(&w.Buffer)
+	i := 0
+	for scanner.Scan() {
+		item := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal(scanner.Bytes(), &item))
+		assert.Equal(t, fmt.Sprintf("local-cluster/uid-%03d", i), item["_uid"])
+		assert.Equal(t, fmt.Sprintf("pod-%03d", i), item["name"])
+		i++
+	}
+	assert.Equal(t, 250, i)
+}
+
+// Should apply the limit from the input, even if it's above MAX_QUERY_LIMIT.
+func Test_exportItems_Limit(t *testing.T) {
+	csRes, nsRes, managedClusters := newUserData()
+	ud := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters}
+	limit := 50000
+	searchInput := &model.SearchInput{Limit: &limit,
+		Filters: []*model.SearchFilter{{Property: "kind", Values: stringArrayToPointer([]string{"pod"})}}}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string"})
+
+	var sql string
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Eq([]interface{}{})).
+		DoAndReturn(func(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+			sql = query
+			return &MockRows{mockData: []map[string]interface{}{}, columnHeaders: []string{"uid", "cluster", "data"}}, nil
+		})
+
+	count, err := resolver.exportItems(&exportRecorder{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, count)
+	assert.True(t, strings.HasSuffix(sql, `ORDER BY "uid" ASC LIMIT 50000`), sql)
+}
+
+// Should return the query error without writing any item.
+func Test_exportItems_QueryError(t *testing.T) {
+	csRes, nsRes, managedClusters := newUserData()
+	ud := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters}
+	searchInput := &model.SearchInput{
+		Filters: []*model.SearchFilter{{Property: "kind", Values: stringArrayToPointer([]string{"pod"})}}}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string"})
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("db unavailable"))
+
+	w := &exportRecorder{}
+	count, err := resolver.exportItems(w)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, count)
+	assert.Equal(t, 0, w.Len())
+}
+
+// Should reject a request body that isn't a search input.
+func Test_HandleExport_InvalidInput(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/export", strings.NewReader("not json"))
+	rr := httptest.NewRecorder()
+
+	HandleExport(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "{\"message\":\"Invalid search input.\"}\n", rr.Body.String())
+}
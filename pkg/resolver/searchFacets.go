@@ -0,0 +1,221 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	db "github.com/stolostron/search-v2-api/pkg/database"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	klog "k8s.io/klog/v2"
+)
+
+type SearchFacetsResult struct {
+	input      *model.SearchInput
+	pool       pgxpoolmock.PgxPool
+	properties []string
+	limit      *int
+	query      string
+	params     []interface{}
+	propTypes  map[string]string
+	userData   rbac.UserData
+}
+
+func SearchFacets(ctx context.Context, properties []string, srchInput *model.SearchInput,
+	limit *int) ([]*model.SearchFacet, error) {
+	defer metrics.SlowLog("SearchFacetsResolver", 0)()
+	if err := validateSearchFacetsInput(srchInput); err != nil {
+		return []*model.SearchFacet{}, err
+	}
+	userData, userDataErr := rbac.GetCache().GetUserData(ctx)
+	if userDataErr != nil {
+		return []*model.SearchFacet{}, userDataErr
+	}
+
+	// Check that shared cache has property types:
+	propTypes, err := rbac.GetCache().GetPropertyTypes(ctx, false)
+	if err != nil {
+		klog.Warningf("Error creating datatype map with err: [%s] ", err)
+	}
+
+	// Proceed if user's rbac data exists
+	searchFacetsResult := &SearchFacetsResult{
+		input:      srchInput,
+		pool:       db.GetConnPool(ctx),
+		properties: properties,
+		limit:      limit,
+		userData:   userData,
+		propTypes:  propTypes,
+	}
+	return searchFacetsResult.facets(ctx)
+}
+
+func (s *SearchFacetsResult) facets(ctx context.Context) ([]*model.SearchFacet, error) {
+	if !matchesManagedHub(s.input) { // if current hub is not part of managedHub filter, stop search
+		return []*model.SearchFacet{}, nil
+	}
+	if err := s.buildSearchFacetsQuery(ctx); err != nil {
+		return []*model.SearchFacet{}, err
+	}
+	if s.query == "" { // No valid facet properties requested.
+		return []*model.SearchFacet{}, nil
+	}
+	return s.searchFacetsResults(ctx)
+}
+
+// Returns the max number of values per facet. The client can't exceed the configured FACET_LIMIT.
+func (s *SearchFacetsResult) facetLimit() int {
+	limit := config.Cfg.FacetLimit
+	if s.limit != nil && *s.limit > 0 && *s.limit < limit {
+		limit = *s.limit
+	} else if s.limit != nil && (*s.limit > limit || *s.limit == -1) {
+		klog.V(2).Infof("Requested facet limit %d exceeds FACET_LIMIT. Using %d.", *s.limit, limit)
+	}
+	return limit
+}
+
+// Builds a single query to get the top values for all facets.
+// Sample query:
+//
+//	SELECT * FROM (SELECT 'kind' AS "prop", "data"->>'kind' AS "value", COUNT(*) AS "count"
+//	  FROM "search"."resources" WHERE (("data"->>'kind' IS NOT NULL) AND <rbac>)
+//	  GROUP BY "value" ORDER BY "count" DESC, "value" ASC LIMIT 10) AS "t1"
+//	UNION ALL
+//	(SELECT * FROM (SELECT 'namespace' AS "prop", ... LIMIT 10) AS "t1")
+func (s *SearchFacetsResult) buildSearchFacetsQuery(ctx context.Context) error {
+	var whereDs []exp.Expression
+	var err error
+	s.query = ""
+	s.params = nil
+
+	// WHERE CLAUSE
+	if hasWhereFilters(s.input) {
+		whereDs, s.propTypes, err = WhereClauseFilter(ctx, s.input, s.propTypes)
+		if err != nil {
+			klog.Errorf("Error building SearchFacets query: %s", err.Error())
+			return err
+		}
+	}
+
+	// get user info for logging
+	_, userInfo := rbac.GetCache().GetUserUID(ctx)
+
+	// RBAC CLAUSE
+	// if one of them is not nil, userData is not empty
+	if s.userData.CsResources != nil || s.userData.NsResources != nil || s.userData.ManagedClusters != nil {
+		rbacData := restrictToInput(s.userData, s.input)
+		if err = checkFullScan("searchFacets", whereDs, rbacData, s.input); err != nil {
+			return err
+		}
+		whereDs = append(whereDs, buildRbacWhereClause(ctx, rbacData, userInfo)) // add rbac
+	} else {
+		klog.Errorf("Error building searchFacets query: RBAC clause is required!"+
+			" None found for searchFacets query %+v for user %s with uid %s ",
+			s.input, userInfo.Username, userInfo.UID)
+		return fmt.Errorf("RBAC clause is required! None found for searchFacets query")
+	}
+
+	schemaTable := goqu.S("search").Table("resources")
+	ds := goqu.From(schemaTable)
+	limit := s.facetLimit()
+
+	var facetsDs *goqu.SelectDataset
+	processed := map[string]struct{}{}
+	for _, property := range s.properties {
+		// managedHub isn't a property in the database, it's used to federate the request.
+		if _, found := processed[property]; found || property == "" || property == "managedHub" {
+			continue
+		}
+		processed[property] = struct{}{}
+		if drop, err := checkDeniedProperty(property); err != nil {
+			return err
+		} else if drop {
+			continue
+		}
+
+		var valueExp exp.Expression = goqu.L(`"data"->>?`, property)
+		if property == "cluster" {
+			valueExp = goqu.C(property)
+		}
+		facetDs := ds.Select(goqu.V(property).As("prop"), goqu.L("?", valueExp).As("value"),
+			goqu.COUNT("*").As("count")).
+			Where(append([]exp.Expression{goqu.L("?", valueExp).IsNotNull()}, whereDs...)...).
+			GroupBy(goqu.C("value")).
+			Order(goqu.C("count").Desc(), goqu.C("value").Asc()).
+			Limit(uint(limit))
+
+		if facetsDs == nil {
+			facetsDs = facetDs
+		} else {
+			facetsDs = facetsDs.UnionAll(facetDs)
+		}
+	}
+	if facetsDs == nil {
+		klog.V(3).Info("No valid properties requested for searchFacets query.")
+		return nil
+	}
+
+	sql, params, err := facetsDs.ToSQL()
+	if err != nil {
+		klog.Errorf("Error building SearchFacets query: %s", err.Error())
+		return err
+	}
+	s.query = sql
+	s.params = params
+	klog.V(5).Info("SearchFacets Query: ", s.query)
+	return nil
+}
+
+func (s *SearchFacetsResult) searchFacetsResults(ctx context.Context) ([]*model.SearchFacet, error) {
+	klog.V(2).Info("Resolving searchFacetsResults()")
+	facets := make([]*model.SearchFacet, 0)
+	rows, err := query(ctx, s.pool, s.query, s.params...)
+	if err != nil {
+		klog.Error("Error fetching search facets results from db ", err)
+		return facets, err
+	}
+	defer rows.Close()
+
+	facetsMap := map[string]*model.SearchFacet{}
+	for rows.Next() {
+		var prop, value string
+		var count int
+		if scanErr := rows.Scan(&prop, &value, &count); scanErr != nil {
+			klog.Error("Error reading searchFacetsResults ", scanErr)
+			continue
+		}
+		facet, found := facetsMap[prop]
+		if !found {
+			facet = &model.SearchFacet{Property: prop, Values: []*model.SearchFacetValue{}}
+			facetsMap[prop] = facet
+		}
+		facet.Values = append(facet.Values, &model.SearchFacetValue{Value: value, Count: count})
+	}
+	if err = rows.Err(); err != nil {
+		klog.Error("Error reading searchFacetsResults ", err)
+		return facets, err
+	}
+
+	// Return the facets in the same order as requested. Facets without values are returned empty.
+	for _, property := range s.properties {
+		if property == "" || property == "managedHub" {
+			continue
+		}
+		facet, found := facetsMap[property]
+		if !found {
+			facet = &model.SearchFacet{Property: property, Values: []*model.SearchFacetValue{}}
+			facetsMap[property] = facet
+		} else if facet == nil { // Already added.
+			continue
+		}
+		facets = append(facets, facet)
+		facetsMap[property] = nil
+	}
+	return facets, nil
+}
@@ -0,0 +1,135 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/golang/mock/gomock"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMockSearchFacets(t *testing.T, input *model.SearchInput, properties []string, ud rbac.UserData,
+	propTypes map[string]string) (*SearchFacetsResult, *pgxpoolmock.MockPgxPool) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	mockResolver := &SearchFacetsResult{
+		input:      input,
+		pool:       mockPool,
+		properties: properties,
+		userData:   ud,
+		propTypes:  propTypes,
+	}
+	return mockResolver, mockPool
+}
+
+func Test_SearchFacets_Query(t *testing.T) {
+	// Create a SearchFacetsResult instance with a mock connection pool.
+	resolver, mockPool := newMockSearchFacets(t, &model.SearchInput{}, []string{"kind", "cluster", "status"},
+		rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+
+	mockRows := &MockRows{
+		mockData: []map[string]interface{}{
+			{"prop": "kind", "value": "Pod", "count": float64(5)},
+			{"prop": "kind", "value": "ConfigMap", "count": float64(2)},
+			{"prop": "cluster", "value": "local-cluster", "count": float64(7)},
+		},
+		columnHeaders: []string{"prop", "value", "count"},
+	}
+	// Mock the database query
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT * FROM (SELECT 'kind' AS "prop", "data"->>'kind' AS "value", COUNT(*) AS "count" FROM "search"."resources" WHERE (("data"->>'kind' IS NOT NULL) AND ("cluster" = ANY ('{}'))) GROUP BY "value" ORDER BY "count" DESC, "value" ASC LIMIT 10) AS "t1" UNION ALL (SELECT * FROM (SELECT 'cluster' AS "prop", "cluster" AS "value", COUNT(*) AS "count" FROM "search"."resources" WHERE (("cluster" IS NOT NULL) AND ("cluster" = ANY ('{}'))) GROUP BY "value" ORDER BY "count" DESC, "value" ASC LIMIT 10) AS "t1") UNION ALL (SELECT * FROM (SELECT 'status' AS "prop", "data"->>'status' AS "value", COUNT(*) AS "count" FROM "search"."resources" WHERE (("data"->>'status' IS NOT NULL) AND ("cluster" = ANY ('{}'))) GROUP BY "value" ORDER BY "count" DESC, "value" ASC LIMIT 10) AS "t1")`),
+		gomock.Eq([]interface{}{})).Return(mockRows, nil)
+
+	// Execute function
+	result, err := resolver.facets(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	// Verify response
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(result))
+	assert.Equal(t, "kind", result[0].Property)
+	assert.Equal(t, 2, len(result[0].Values))
+	assert.Equal(t, "Pod", result[0].Values[0].Value)
+	assert.Equal(t, 5, result[0].Values[0].Count)
+	assert.Equal(t, "ConfigMap", result[0].Values[1].Value)
+	assert.Equal(t, "cluster", result[1].Property)
+	assert.Equal(t, 7, result[1].Values[0].Count)
+	assert.Equal(t, "status", result[2].Property)
+	assert.Equal(t, 0, len(result[2].Values))
+}
+
+func Test_SearchFacets_Query_WithFilterAndRbac(t *testing.T) {
+	// Create a SearchFacetsResult instance with a mock connection pool.
+	value1 := "default"
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "namespace",
+		Values: []*string{&value1}}}}
+	csRes, nsRes, mc := newUserData()
+	ud := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: mc}
+	limit := 3
+	resolver, mockPool := newMockSearchFacets(t, searchInput, []string{"kind", "status"}, ud,
+		map[string]string{"namespace": "string"})
+	resolver.limit = &limit
+
+	// The RBAC clause only includes the hub resources in the filtered namespace.
+	rbacClause := `("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND (data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))))`
+	// Mock the database query. The RBAC clause must be applied to all facets.
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT * FROM (SELECT 'kind' AS "prop", "data"->>'kind' AS "value", COUNT(*) AS "count" FROM "search"."resources" WHERE (("data"->>'kind' IS NOT NULL) AND "data"->'namespace'?('default') AND (`+rbacClause+`)) GROUP BY "value" ORDER BY "count" DESC, "value" ASC LIMIT 3) AS "t1" UNION ALL (SELECT * FROM (SELECT 'status' AS "prop", "data"->>'status' AS "value", COUNT(*) AS "count" FROM "search"."resources" WHERE (("data"->>'status' IS NOT NULL) AND "data"->'namespace'?('default') AND (`+rbacClause+`)) GROUP BY "value" ORDER BY "count" DESC, "value" ASC LIMIT 3) AS "t1")`),
+		gomock.Eq([]interface{}{})).Return(&MockRows{}, nil)
+
+	// Execute function
+	result, err := resolver.facets(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"))
+
+	// Verify response
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(result))
+}
+
+// Should not exceed the configured FACET_LIMIT.
+func Test_SearchFacets_Limit(t *testing.T) {
+	resolver, _ := newMockSearchFacets(t, &model.SearchInput{}, []string{"kind"}, rbac.UserData{}, nil)
+	assert.Equal(t, 10, resolver.facetLimit())
+
+	limit := 5
+	resolver.limit = &limit
+	assert.Equal(t, 5, resolver.facetLimit())
+
+	limit = 1000
+	assert.Equal(t, 10, resolver.facetLimit())
+
+	limit = -1
+	assert.Equal(t, 10, resolver.facetLimit())
+}
+
+// Should reject an invalid query input before resolving the user's access.
+func Test_SearchFacets_InvalidInput(t *testing.T) {
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{}}}}
+
+	result, err := SearchFacets(context.Background(), []string{"kind"}, searchInput, nil)
+
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.Equal(t, "invalid search input: query.filters[0].values can't be empty", err.Error())
+	assert.Empty(t, result)
+}
+
+// Should return empty facets without querying the database when the managedHub filter doesn't match this hub.
+func Test_SearchFacets_ManagedHubNotMatched(t *testing.T) {
+	defer func(hubName string) { config.Cfg.HubName = hubName }(config.Cfg.HubName)
+	config.Cfg.HubName = "test-hub-a"
+	managedHub := "test-hub-b"
+	searchInput := &model.SearchInput{
+		Filters: []*model.SearchFilter{{Property: "managedHub", Values: []*string{&managedHub}}}}
+	resolver, _ := newMockSearchFacets(t, searchInput, []string{"kind"}, rbac.UserData{CsResources: []rbac.Resource{}},
+		map[string]string{"kind": "string", "managedHub": "string"})
+
+	result, err := resolver.facets(context.Background())
+
+	assert.Nil(t, err)
+	assert.Empty(t, result)
+	assert.Equal(t, "", resolver.query)
+}
@@ -0,0 +1,83 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+	"k8s.io/utils/strings/slices"
+)
+
+// Returns the expression to select the data of the items. When the input has fields, only these properties
+// are selected from the data. The cluster is a column, so it isn't included in the data.
+// Sample for fields name and status:
+//
+//	jsonb_strip_nulls(jsonb_build_object('name', "data"->'name', 'status', "data"->'status')) AS "data"
+func (s *SearchResult) selectData() (interface{}, error) {
+	fields, err := s.dataFields()
+	if fields == nil || err != nil {
+		return selectAllowedData(), err
+	}
+	sql := make([]string, 0, len(fields))
+	args := make([]interface{}, 0, len(fields)*2)
+	for _, field := range fields {
+		sql = append(sql, `?, "data"->?`)
+		args = append(args, field, field)
+	}
+	return goqu.L(fmt.Sprintf("jsonb_strip_nulls(jsonb_build_object(%s))", strings.Join(sql, ", ")), args...).
+		As("data"), nil
+}
+
+// Returns the properties selected from the data, or nil to select all the data.
+// The sortBy property is also selected for the cursor of the next page.
+func (s *SearchResult) dataFields() ([]string, error) {
+	if s.input == nil || s.input.Fields == nil {
+		return nil, nil
+	}
+	fields := []string{}
+	for _, field := range s.input.Fields {
+		if field == "" || strings.Contains(field, ".") {
+			return nil, invalidInputf("invalid field [%s], use the name of a property", field)
+		}
+		if drop, err := checkDeniedProperty(field); drop || err != nil {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if field != "cluster" && !slices.Contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	if sortField := s.cursorOnlyField(); sortField != "" {
+		fields = append(fields, sortField)
+	}
+	return fields, nil
+}
+
+// Returns the sortBy property when it's only selected to build the cursor of the next page.
+func (s *SearchResult) cursorOnlyField() string {
+	if s.input == nil || s.input.Fields == nil || s.input.SortBy == nil || !s.paginated() {
+		return ""
+	}
+	property := s.input.SortBy.Property
+	if property == "cluster" || slices.Contains(s.input.Fields, property) {
+		return ""
+	}
+	return property
+}
+
+// Removes the properties of the item that weren't requested with the fields.
+// The cluster is always resolved from its column, and the sortBy property may be selected for the cursor.
+func (s *SearchResult) trimItem(item map[string]interface{}) {
+	if s.input == nil || s.input.Fields == nil {
+		return
+	}
+	if !slices.Contains(s.input.Fields, "cluster") {
+		delete(item, "cluster")
+	}
+	if sortField := s.cursorOnlyField(); sortField != "" {
+		delete(item, sortField)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+// Rows with the data projected by the database to the fields.
+func mockFieldsRows(data ...map[string]interface{}) *MockRows {
+	rows := &MockRows{columnHeaders: []string{"uid", "cluster", "data"}}
+	for i, d := range data {
+		uid := []string{"local-cluster/uid-1", "managed1/uid-2"}[i]
+		cluster := []string{"local-cluster", "managed1"}[i]
+		rows.mockData = append(rows.mockData, map[string]interface{}{"uid": uid, "cluster": cluster, "data": d})
+	}
+	return rows
+}
+
+// Should select only the requested fields and return them with the uid.
+func Test_SearchResolver_Fields(t *testing.T) {
+	defer func(serialization string) { config.Cfg.ItemsSerialization = serialization }(config.Cfg.ItemsSerialization)
+	csRes, nsRes, managedClusters := newUserData()
+	ud := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters}
+	val := "Template"
+	searchInput := &model.SearchInput{
+		Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val}}},
+		Fields:  []string{"name", "status", "name"},
+	}
+	propTypesMock := map[string]string{"kind": "string"}
+	query := `SELECT DISTINCT "uid", "cluster", jsonb_strip_nulls(jsonb_build_object('name', "data"->'name', 'status', "data"->'status')) AS "data" FROM "search"."resources" WHERE ("data"->'kind'?('Template') AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))) LIMIT 1000`
+
+	// Both serializations return the same items.
+	for _, serialization := range []string{"map", "stream"} {
+		config.Cfg.ItemsSerialization = serialization
+		resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, propTypesMock)
+		mockPool.EXPECT().Query(gomock.Any(), gomock.Eq(query), gomock.Eq([]interface{}{})).
+			Return(mockFieldsRows(map[string]interface{}{"name": "tmpl-1", "status": "Ready"},
+				map[string]interface{}{"name": "tmpl-2"}), nil)
+
+		items, err := resolver.EncodedItems()
+
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(items))
+		encoded := []string{}
+		for _, item := range items {
+			buf := &bytes.Buffer{}
+			item.MarshalGQL(buf)
+			encoded = append(encoded, buf.String())
+		}
+		assert.JSONEq(t, `{"_uid":"local-cluster/uid-1","name":"tmpl-1","status":"Ready"}`, encoded[0])
+		assert.JSONEq(t, `{"_uid":"managed1/uid-2","name":"tmpl-2"}`, encoded[1])
+	}
+}
+
+// Should select the sortBy property for the cursor of the next page, without returning it.
+func Test_SearchResolver_FieldsSortedPage(t *testing.T) {
+	val1 := "Pod"
+	pageSize := 2
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val1}}},
+		PageSize: &pageSize, SortBy: &model.SearchSort{Property: "restarts"}, Fields: []string{"name", "cluster"}}
+	ud := rbac.UserData{CsResources: []rbac.Resource{}}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud,
+		map[string]string{"kind": "string", "restarts": "number"})
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT "uid", "cluster", jsonb_strip_nulls(jsonb_build_object('name', "data"->'name', 'restarts', "data"->'restarts')) AS "data" FROM (SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND ("cluster" = ANY ('{}')))) AS "items" ORDER BY ("data"->'restarts')::numeric ASC NULLS LAST, "uid" ASC LIMIT 2`),
+		gomock.Eq([]interface{}{}),
+	).Return(mockFieldsRows(map[string]interface{}{"name": "pod-1", "restarts": float64(1)},
+		map[string]interface{}{"name": "pod-2", "restarts": float64(3)}), nil)
+
+	items, err := resolver.Items()
+
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"_uid": "local-cluster/uid-1", "cluster": "local-cluster", "name": "pod-1"},
+		{"_uid": "managed1/uid-2", "cluster": "managed1", "name": "pod-2"},
+	}, items)
+	value := "3"
+	assert.Equal(t, &searchCursor{UID: "managed1/uid-2", Property: "restarts", Direction: "asc", Value: &value},
+		resolver.lastItem)
+}
+
+// Should reject or drop the denied fields, and reject the invalid fields.
+func Test_SearchResolver_FieldsDenied(t *testing.T) {
+	defer func(denylist []string, action string) {
+		config.Cfg.PropertyDenylist = denylist
+		config.Cfg.PropertyDenylistAction = action
+	}(config.Cfg.PropertyDenylist, config.Cfg.PropertyDenylistAction)
+	config.Cfg.PropertyDenylist = []string{"secretAnnotation"}
+
+	// Reject
+	config.Cfg.PropertyDenylistAction = "reject"
+	resolver := &SearchResult{input: &model.SearchInput{Fields: []string{"name", "secretAnnotation"}}}
+	_, err := resolver.selectData()
+	assert.True(t, errors.Is(err, ErrDeniedProperty), "Expected ErrDeniedProperty. Got: %v", err)
+
+	// Drop
+	config.Cfg.PropertyDenylistAction = "drop"
+	fields, err := resolver.dataFields()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"name"}, fields)
+
+	// Invalid
+	for _, field := range []string{"", "metadata.name"} {
+		resolver = &SearchResult{input: &model.SearchInput{Fields: []string{field}}}
+		_, err = resolver.dataFields()
+		assert.EqualError(t, err, "invalid field ["+field+"], use the name of a property")
+	}
+}
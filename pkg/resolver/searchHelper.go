@@ -2,6 +2,7 @@ package resolver
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"regexp"
@@ -16,6 +17,7 @@ import (
 	"github.com/doug-martin/goqu/v9"
 	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/lib/pq"
+	"github.com/stolostron/search-v2-api/graph/model"
 	"github.com/stolostron/search-v2-api/pkg/config"
 	"github.com/stolostron/search-v2-api/pkg/rbac"
 	"k8s.io/klog/v2"
@@ -26,12 +28,12 @@ func getPropertyType(ctx context.Context, refresh bool) (map[string]string, erro
 	return propTypesCache, err
 }
 
-// Extract operator (<=, >=, !=, !, <, >, =) if any from string
+// Extract operator (=~*, =~, <=, >=, !=, !~, !, ~, <, >, =) if any from string
 func getOperatorFromString(value string) (string, string) {
 	operator := "="
 	operand := value
 
-	prefixes := []string{"<=", ">=", "!=", "!", "<", ">", "="}
+	prefixes := []string{regexFoldOperator, regexOperator, "<=", ">=", "!=", "!~", "!", "~", "<", ">", "="}
 	for _, prefix := range prefixes {
 		if cutString, yes := strings.CutPrefix(value, prefix); yes {
 			operator = prefix
@@ -43,11 +45,14 @@ func getOperatorFromString(value string) (string, string) {
 	return operator, operand
 }
 
-// Extract operator (<=, >=, !=, !, <, >, =) if any from values. Combine with other operators like "*" if present.
+// Extract operator (<=, >=, !=, !~, !, ~, <, >, =) if any from values. Combine with other operators like "*" if present.
 func extractOperator(values []string, innerOperator string,
 	operatorOperandMap map[string][]string) map[string][]string {
 	for _, value := range values {
 		operator, operand := getOperatorFromString(value)
+		if operator == "!=" { // Same as "!", group the values to exclude all of them.
+			operator = "!"
+		}
 		if innerOperator != "" {
 			updateOperatorValueMap(operator+":"+innerOperator, operatorOperandMap, operand)
 		} else {
@@ -61,17 +66,62 @@ func extractOperator(values []string, innerOperator string,
 // Returns a map that stores operator and values
 func getPartialMatchFilter(filter string, values []string, dataType interface{},
 	operatorOperandMap map[string][]string) map[string][]string {
-	for i, val := range values {
-		if strings.Contains(val, "*") {
-			values[i] = strings.ReplaceAll(val, "*", "%")
+	if dataType == "object" || dataType == "array" {
+		for i, val := range values {
+			if strings.Contains(val, "*") {
+				values[i] = strings.ReplaceAll(val, "*", "%")
+			}
+		}
+		if dataType == "object" {
+			return extractOperator(values, "*@>", operatorOperandMap)
 		}
-	}
-	if dataType == "object" {
-		return extractOperator(values, "*@>", operatorOperandMap)
-	} else if dataType == "array" {
 		return extractOperator(values, "*[]", operatorOperandMap)
 	}
-	return extractOperator(values, "*", operatorOperandMap)
+	// The values without glob characters keep the exact match, so the query can use the index.
+	for _, val := range values {
+		if isGlobPattern(val) {
+			extractOperator([]string{globToLikePattern(val)}, "*", operatorOperandMap)
+		} else {
+			extractOperator([]string{val}, "", operatorOperandMap)
+		}
+	}
+	return operatorOperandMap
+}
+
+// Returns true if the value has the glob characters "*" (any characters) or "?" (a single character).
+func isGlobPattern(value string) bool {
+	return strings.ContainsAny(value, "*?")
+}
+
+// Translate the glob characters to a LIKE pattern. The LIKE wildcards (%, _) are escaped first,
+// so these are matched literally.
+// Sample: nginx-*_v? => nginx-%\_v_
+func globToLikePattern(value string) string {
+	return strings.NewReplacer("*", "%", "?", "_").Replace(escapeLikePattern(value))
+}
+
+// Translate a LIKE pattern to a regular expression matching the whole string.
+func likePatternToRegexp(pattern string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	escaped := false
+	for _, c := range pattern {
+		switch {
+		case escaped:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '%':
+			sb.WriteString(".*")
+		case c == '_':
+			sb.WriteString(".")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
 }
 
 // compareValues checks if a string is equal to any string in an array of strings.
@@ -91,6 +141,8 @@ func getWhereClauseExpression(prop, operator string, values []string, dataType s
 		"and operator: ", operator)
 	exps := []exp.Expression{}
 	var lhsExp interface{}
+	// The case-insensitive operators (~ and !~) compare the text of the value.
+	caseInsensitive := isCaseInsensitiveOperator(operator)
 
 	// check if the property is cluster
 	if prop == "cluster" {
@@ -100,8 +152,8 @@ func getWhereClauseExpression(prop, operator string, values []string, dataType s
 		// So, fetch results based on the other filters.
 		return exps
 	} else {
-		lhsExp = goqu.L(`"data"->>?`, prop)
-		if dataType == "number" {
+		lhsExp = jsonText(prop)
+		if dataType == "number" && !caseInsensitive {
 			lhsExp = goqu.L(`("data"->?)?`, prop, goqu.L("::numeric"))
 		}
 	}
@@ -111,9 +163,25 @@ func getWhereClauseExpression(prop, operator string, values []string, dataType s
 			exps = append(exps, goqu.L(`?`, lhsExp).Like(val))
 		}
 	case "!:*", "!=:*":
+		notExps := []exp.Expression{} // Exclude all the values.
 		for _, val := range values {
-			exps = append(exps, goqu.L("NOT(?)", goqu.L(`?`, lhsExp).Like(val)))
+			notExps = append(notExps, goqu.L("NOT(?)", goqu.L(`?`, lhsExp).Like(val)))
 		}
+		exps = append(exps, goqu.And(notExps...))
+	case "~":
+		exps = append(exps, goqu.L(`?`, lhsExp).ILike(goqu.Any(pq.Array(escapeLikePatterns(values)))))
+	case "!~":
+		exps = append(exps, goqu.L("NOT(?)", goqu.L(`?`, lhsExp).ILike(goqu.Any(pq.Array(escapeLikePatterns(values))))))
+	case "~:*":
+		for _, val := range values {
+			exps = append(exps, goqu.L(`?`, lhsExp).ILike(val))
+		}
+	case "!~:*":
+		notExps := []exp.Expression{}
+		for _, val := range values {
+			notExps = append(notExps, goqu.L("NOT(?)", goqu.L(`?`, lhsExp).ILike(val)))
+		}
+		exps = append(exps, goqu.And(notExps...))
 	case "<=":
 		for _, val := range values {
 			exps = append(exps, goqu.L(`?`, lhsExp).Lte(val))
@@ -122,11 +190,15 @@ func getWhereClauseExpression(prop, operator string, values []string, dataType s
 		for _, val := range values {
 			exps = append(exps, goqu.L(`?`, lhsExp).Gte(val))
 		}
-	case "!=":
-		exps = append(exps, goqu.L(`?`, lhsExp).Neq(values))
-
-	case "!":
-		exps = append(exps, goqu.L(`?`, lhsExp).NotIn(values))
+	case "!", "!=":
+		if prop == "kind" && isLower(values) {
+			// Same case-insensitive comparison used to match the kind, so "!pod" excludes "Pod".
+			exps = append(exps, goqu.L("NOT(?)", goqu.L(`"data"->>?`, prop).ILike(goqu.Any(pq.Array(values)))))
+		} else if len(values) == 1 { // for single value, use "!=" instead of a one-element NOT IN list
+			exps = append(exps, goqu.L(`?`, lhsExp).Neq(values[0]))
+		} else {
+			exps = append(exps, goqu.L(`?`, lhsExp).NotIn(values))
+		}
 
 	case "<":
 		for _, val := range values {
@@ -153,9 +225,15 @@ func getWhereClauseExpression(prop, operator string, values []string, dataType s
 			exps = append(exps, goqu.L(`"data"->? @> ?`, prop, val))
 		}
 	case "!:@>", "!=:@>":
+		notExps := []exp.Expression{} // Exclude all the values.
 		for _, val := range values {
-			exps = append(exps, goqu.L("NOT(?)", goqu.L(`"data"->? @> ?`, prop, val)))
+			notExps = append(notExps, goqu.L("NOT(?)", goqu.L(`"data"->? @> ?`, prop, val)))
 		}
+		exps = append(exps, goqu.And(notExps...))
+	case "=:?": // Has any of the keys. Sample: "data"->'label' ?| '{"app","env"}'
+		exps = append(exps, goqu.L("???", goqu.L(`"data"->?`, prop), goqu.Literal("?|"), pq.Array(values)))
+	case "!:?": // Doesn't have any of the keys.
+		exps = append(exps, goqu.L("NOT(???)", goqu.L(`"data"->?`, prop), goqu.Literal("?|"), pq.Array(values)))
 	case "?|":
 		exps = append(exps, goqu.L(`"data"->? ? ?`, prop, "?|", values))
 	default:
@@ -167,12 +245,14 @@ func getWhereClauseExpression(prop, operator string, values []string, dataType s
 		} else if isString(values) && prop != "cluster" {
 			if len(values) == 1 { // for single value, use "?" operator
 				// Refer to https://www.postgresql.org/docs/9.5/functions-json.html#FUNCTIONS-JSONB-OP-TABLE
-				lhsExp = goqu.L(`"data"->?`, prop)
+				lhsExp = jsonValue(prop)
 				exps = append(exps, goqu.L("???", lhsExp, goqu.Literal("?"), values))
 			} else { // if there are many values, use "?|" operator
-				lhsExp = goqu.L(`"data"->?`, prop)
+				lhsExp = jsonValue(prop)
 				exps = append(exps, goqu.L("???", lhsExp, goqu.Literal("?|"), pq.Array(values)))
 			}
+		} else if len(values) == 1 { // for single value, use "=" instead of a one-element IN list
+			exps = append(exps, goqu.L(`?`, lhsExp).Eq(values[0]))
 		} else {
 			exps = append(exps, goqu.L(`?`, lhsExp).In(values))
 		}
@@ -211,6 +291,103 @@ func createSubQueryForArray(dataType, prop string, values []string) *goqu.Select
 	}
 }
 
+// The Kubernetes metadata paths stored as top-level properties in the flattened data.
+var metadataProperties = map[string]string{
+	"metadata.name":              "name",
+	"metadata.namespace":         "namespace",
+	"metadata.creationTimestamp": "created",
+}
+
+// The labels are stored in the label property. The label keys can contain dots, like app.kubernetes.io/name.
+const metadataLabelsPrefix = "metadata.labels."
+
+// Returns the keys to the property in the flattened data. The Kubernetes metadata paths are mapped to the
+// flattened properties, for example metadata.labels.app to [label app]. Other dotted paths are split into
+// the nested keys.
+func dataPath(prop string) []string {
+	if flattened, found := metadataProperties[prop]; found {
+		return []string{flattened}
+	}
+	if key, found := strings.CutPrefix(prop, metadataLabelsPrefix); found {
+		return []string{"label", key}
+	}
+	return strings.Split(prop, ".")
+}
+
+// Returns the top-level property in the flattened data for the Kubernetes metadata paths, for example
+// metadata.name to name. Other properties are returned unchanged.
+func flattenedProperty(prop string) string {
+	if flattened, found := metadataProperties[prop]; found {
+		return flattened
+	}
+	return prop
+}
+
+// Returns the keys of a nested property, for example metadata.labels.app, or nil for a top-level property.
+func propertyPath(prop string) []string {
+	if !strings.Contains(prop, ".") {
+		return nil
+	}
+	if path := dataPath(prop); len(path) > 1 {
+		return path
+	}
+	return nil
+}
+
+// Returns the accessor of the property in the data, the text accessor (->>) is used for the last key.
+func dataAccessor(prop string, text bool) exp.LiteralExpression {
+	path := dataPath(prop)
+	args := make([]interface{}, len(path))
+	for i, key := range path {
+		args[i] = key
+	}
+	sql := `"data"` + strings.Repeat("->?", len(path)-1)
+	if text {
+		return goqu.L(sql+"->>?", args...)
+	}
+	return goqu.L(sql+"->?", args...)
+}
+
+// Returns the JSONB value of the property. Nested properties chain the accessors.
+// Sample: "data"->'name' or "data"->'label'->'app'
+func jsonValue(prop string) exp.LiteralExpression {
+	return dataAccessor(prop, false)
+}
+
+// Returns the text value of the property. Nested properties chain the accessors.
+// Sample: "data"->>'name' or "data"->'label'->>'app'
+func jsonText(prop string) exp.LiteralExpression {
+	return dataAccessor(prop, true)
+}
+
+// Returns true for the case-insensitive operators (~ and !~), including the partial match.
+func isCaseInsensitiveOperator(operator string) bool {
+	return strings.HasPrefix(strings.TrimPrefix(operator, "!"), "~")
+}
+
+// Escape the LIKE wildcards (%, _) and the escape character, so the value is matched as is.
+func escapeLikePattern(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// Check if the value is in the list, ignoring case.
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func escapeLikePatterns(values []string) []string {
+	escaped := make([]string, len(values))
+	for i, val := range values {
+		escaped[i] = escapeLikePattern(val)
+	}
+	return escaped
+}
+
 // Check if the values contain numerical values
 func isString(values []string) bool {
 	for _, v := range values {
@@ -249,7 +426,7 @@ func updateOperatorValueMap(operator string, operatorValueMap map[string][]strin
 // Returns a map that stores operator and values
 func getOperatorIfDateFilter(filter string, values []string,
 	opValueMap map[string][]string) map[string][]string {
-	now := time.Now()
+	now := timeNow().UTC()
 	for _, val := range values {
 		operator, operand := getOperatorFromString(val)
 		if operator == "=" { // For dates, unless specified otherwise, always check for values '>'
@@ -279,6 +456,127 @@ func getOperatorIfDateFilter(filter string, values []string,
 	return opValueMap
 }
 
+// Operators to match the value with a POSIX regular expression, and the case-insensitive variant.
+const (
+	regexOperator     = "=~"
+	regexFoldOperator = "=~*"
+)
+
+// Max length of the regular expressions, to limit the cost of matching complex patterns in the database.
+const maxRegexLength = 256
+
+// Returns the expressions for the regex values (=~ and =~*) and the remaining values of the filter.
+// The regex values aren't translated like the other values, so the glob characters keep their meaning in the pattern.
+// Sample: "data"->>'name' ~ '^web-[0-9]+$' or "data"->>'name' ~* '^web-'
+func regexExpressions(property string, values []string) ([]exp.Expression, []string, error) {
+	exps := []exp.Expression{}
+	remaining := make([]string, 0, len(values))
+	for _, value := range values {
+		operator, pattern := getOperatorFromString(value)
+		// managedHub isn't a property in the database, it's used to federate the request.
+		if (operator != regexOperator && operator != regexFoldOperator) || property == "managedHub" {
+			remaining = append(remaining, value)
+			continue
+		}
+		if err := checkRegex(pattern); err != nil {
+			return nil, values, err
+		}
+		var lhsExp interface{} = jsonText(property)
+		if property == "cluster" {
+			lhsExp = goqu.C(property)
+		}
+		exps = append(exps, goqu.L("? ? ?", lhsExp, goqu.Literal(strings.TrimPrefix(operator, "=")), pattern))
+	}
+	return exps, remaining, nil
+}
+
+// Checks the pattern of a regex value before sending it to the database. The patterns must compile with the
+// Go syntax, which rejects the backreferences, and can't exceed maxRegexLength.
+func checkRegex(pattern string) error {
+	if pattern == "" {
+		return invalidInputf("regular expression can't be empty")
+	}
+	if len(pattern) > maxRegexLength {
+		return invalidInputf("regular expression exceeds the max length of %d characters", maxRegexLength)
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return invalidInputf("invalid regular expression [%s]: %w", pattern, err)
+	}
+	return nil
+}
+
+// Returns the current time. Replaced by the unit tests to build the relative time ranges from a fixed time.
+var timeNow = time.Now
+
+// Relative time range from N units of time ago until now. Sample: 6hours
+var relativeTimeRange = regexp.MustCompile(`^(\d+)\s*(hour|day|week|month|year)s?$`)
+
+// Start of an RFC3339 timestamp, used to identify the absolute time ranges. Sample: 2024-01-01
+var timestampPrefix = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+
+// Returns the expressions for the time range values and the remaining values of the filter.
+// The values are compared as timestamps, so the property values must be RFC3339 timestamps, like created.
+// Supports the last N hours, days, weeks, months or years, for example 6hours, and the range between
+// two RFC3339 timestamps, for example 2024-01-01T00:00:00Z..2024-01-02T00:00:00Z.
+// Sample: ("data"->>'created')::timestamptz BETWEEN '2024-01-01T00:00:00Z' AND '2024-01-02T00:00:00Z'
+func timeRangeExpressions(property string, values []string) ([]exp.Expression, []string, error) {
+	exps := []exp.Expression{}
+	remaining := make([]string, 0, len(values))
+	for _, value := range values {
+		start, end, isRange, err := parseTimeRange(value)
+		if err != nil {
+			return nil, values, err
+		}
+		if !isRange {
+			remaining = append(remaining, value)
+			continue
+		}
+		exps = append(exps, goqu.L(`(?)::timestamptz`, jsonText(property)).Between(goqu.Range(start, end)))
+	}
+	return exps, remaining, nil
+}
+
+// Parses a relative or absolute time range. Returns false if the value isn't a time range,
+// or an error if the value is a time range with malformed timestamps.
+func parseTimeRange(value string) (string, string, bool, error) {
+	if match := relativeTimeRange.FindStringSubmatch(value); match != nil {
+		count, err := strconv.Atoi(match[1])
+		if err != nil {
+			return "", "", false, invalidInputf("invalid time range [%s]: %w", value, err)
+		}
+		now := timeNow().UTC()
+		var start time.Time
+		switch match[2] {
+		case "hour":
+			start = now.Add(time.Duration(-count) * time.Hour)
+		case "day":
+			start = now.AddDate(0, 0, -count)
+		case "week":
+			start = now.AddDate(0, 0, -7*count)
+		case "month":
+			start = now.AddDate(0, -count, 0)
+		case "year":
+			start = now.AddDate(-count, 0, 0)
+		}
+		return start.Format(time.RFC3339), now.Format(time.RFC3339), true, nil
+	}
+
+	start, end, found := strings.Cut(value, "..")
+	if !found || (!timestampPrefix.MatchString(start) && !timestampPrefix.MatchString(end)) {
+		return "", "", false, nil
+	}
+	if !isDate([]*string{&start, &end}) {
+		return "", "", false, invalidInputf("invalid time range [%s], the range must be two RFC3339 timestamps. "+
+			"Sample: 2024-01-01T00:00:00Z..2024-01-02T00:00:00Z", value)
+	}
+	startTime, _ := time.Parse(time.RFC3339, start)
+	endTime, _ := time.Parse(time.RFC3339, end)
+	if startTime.After(endTime) {
+		return "", "", false, invalidInputf("invalid time range [%s], the start is after the end", value)
+	}
+	return start, end, true, nil
+}
+
 // Labels are sorted alphabetically to ensure consistency, then encoded in a
 // string with the following format.
 // key1:value1; key2:value2; ...
@@ -326,25 +624,32 @@ func convertToString(data interface{}) string {
 func formatDataMap(data map[string]interface{}) map[string]interface{} {
 	item := make(map[string]interface{})
 	for key, value := range data {
-		switch v := value.(type) {
-		case string:
-			item[key] = v //strings.ToLower(v)
-		case bool:
-			item[key] = strconv.FormatBool(v)
-		case float64:
-			item[key] = strconv.FormatInt(int64(v), 10)
-		case map[string]interface{}:
-			item[key] = formatLabels(v)
-		case []interface{}:
-			item[key] = formatArray(v)
-		default:
-			klog.Warningf("Error formatting property with key: %+v  type: %+v\n", key, reflect.TypeOf(v))
-			continue
+		if formatted, ok := formatValue(key, value); ok {
+			item[key] = formatted
 		}
 	}
 	return item
 }
 
+// Format a property value as string. Returns false if the value type isn't supported.
+func formatValue(key string, value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true //strings.ToLower(v)
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return strconv.FormatInt(int64(v), 10), true
+	case map[string]interface{}:
+		return formatLabels(v), true
+	case []interface{}:
+		return formatArray(v), true
+	default:
+		klog.Warningf("Error formatting property with key: %+v  type: %+v\n", key, reflect.TypeOf(v))
+		return "", false
+	}
+}
+
 // helper function to point values in string  array
 func PointerToStringArray(pointerArray []*string) []string {
 
@@ -372,19 +677,19 @@ func decodeObject(isPartialMatch bool, values []string) ([]string, error) {
 	for i, val := range values {
 		operator, operand := getOperatorFromString(val)
 		labels := strings.Split(operand, "=")
-		if len(labels) == 2 {
-			if isPartialMatch {
-				cleanedVal[i] = fmt.Sprintf(`%s%s:%s`, operator, labels[0], labels[1])
-			} else {
-				cleanedVal[i] = fmt.Sprintf(`%s{"%s":"%s"}`, operator, labels[0], labels[1])
-			}
-		} else {
-			if isPartialMatch {
-				cleanedVal[i] = fmt.Sprintf(`%s%s`, operator, labels[0])
-			} else {
-				return cleanedVal,
-					fmt.Errorf("incorrect label format, label filters must have the format key=value")
-			}
+		switch {
+		case len(labels) == 2 && isPartialMatch:
+			cleanedVal[i] = fmt.Sprintf(`%s%s:%s`, operator, labels[0], labels[1])
+		case len(labels) == 2:
+			// Encode the JSON, so quotes and backslashes in the label don't make it invalid.
+			label, _ := json.Marshal(map[string]string{labels[0]: labels[1]})
+			cleanedVal[i] = operator + string(label)
+		case len(labels) == 1 && labels[0] != "":
+			// Only the key, matches the resources with the label regardless of the value.
+			cleanedVal[i] = fmt.Sprintf(`%s%s`, operator, labels[0])
+		default:
+			return cleanedVal,
+				invalidInputf("incorrect label format, label filters must have the format key or key=value")
 		}
 
 	}
@@ -398,7 +703,8 @@ func decodeArray(isPartialMatch bool, values []string) ([]string, error) {
 		operator, operand := getOperatorFromString(val)
 
 		if !isPartialMatch {
-			cleanedVal[i] = fmt.Sprintf(`%s["%s"]`, operator, operand)
+			item, _ := json.Marshal([]string{operand})
+			cleanedVal[i] = operator + string(item)
 		} else {
 			cleanedVal[i] = val
 		}
@@ -409,6 +715,15 @@ func decodeArray(isPartialMatch bool, values []string) ([]string, error) {
 func decodePropertyTypes(values []string, dataType string) ([]string, error) {
 	isPartialMatch := compareValues(values, []string{"*"})
 
+	if dataType == "object" || dataType == "array" {
+		for _, val := range values {
+			if operator, _ := getOperatorFromString(val); isCaseInsensitiveOperator(operator) {
+				return values, invalidInputf("case-insensitive operator [%s] isn't supported for %s properties",
+					operator, dataType)
+			}
+		}
+	}
+
 	switch dataType {
 	case "object":
 		return decodeObject(isPartialMatch, values)
@@ -437,13 +752,21 @@ func getKeys(stringKeyMap interface{}) []string {
 
 // Set limit for queries
 func (s *SearchResult) setLimit() int {
-	var limit int
-	if s.input != nil && s.input.Limit != nil && *s.input.Limit > 0 {
-		limit = *s.input.Limit
-	} else if s.input != nil && s.input.Limit != nil && *s.input.Limit == -1 {
-		klog.V(2).Info("No limit set on query. Fetching all results.")
-	} else {
-		limit = config.Cfg.QueryLimit
+	if s.input != nil && s.input.Limit != nil {
+		return capLimit(*s.input.Limit)
+	}
+	return config.Cfg.QueryLimit
+}
+
+// Clamps the limit requested by the client to MAX_QUERY_LIMIT. A limit of -1 requests all results,
+// which returns as many results as MAX_QUERY_LIMIT allows.
+func capLimit(limit int) int {
+	if limit == 0 || limit < -1 {
+		return config.Cfg.QueryLimit
+	}
+	if limit == -1 || limit > config.Cfg.MaxQueryLimit {
+		klog.V(2).Infof("Requested limit %d exceeds MAX_QUERY_LIMIT. Using %d.", limit, config.Cfg.MaxQueryLimit)
+		return config.Cfg.MaxQueryLimit
 	}
 	return limit
 }
@@ -451,11 +774,18 @@ func (s *SearchResult) setLimit() int {
 func matchOperatorToProperty(dataType string, opValueMap map[string][]string,
 	values []string, property string) map[string][]string {
 	if (dataType == "object" || dataType == "array") && !compareValues(values, []string{"*"}) {
-		opValueMap = extractOperator(values, "@>", opValueMap)
+		for _, value := range values {
+			// The label keys without a value are matched with the key exists operator.
+			if _, operand := getOperatorFromString(value); dataType == "object" && !strings.HasPrefix(operand, "{") {
+				opValueMap = extractOperator([]string{value}, "?", opValueMap)
+			} else {
+				opValueMap = extractOperator([]string{value}, "@>", opValueMap)
+			}
+		}
 	} else if compareValues(values, []string{"hour", "day", "week", "month", "year"}) {
 		// Check if value is a number or date and get the cleaned up value
 		opValueMap = getOperatorIfDateFilter(property, values, opValueMap)
-	} else if compareValues(values, []string{"*"}) { //partialMatch
+	} else if compareValues(values, []string{"*", "?"}) { //partialMatch
 		opValueMap = getPartialMatchFilter(property, values, dataType, opValueMap)
 	} else {
 		opValueMap = extractOperator(values, "", opValueMap)
@@ -466,19 +796,13 @@ func matchOperatorToProperty(dataType string, opValueMap map[string][]string,
 // partialMatchStringPattern checks if config.Cfg.HubName partially matches any pattern in the values slice.
 // It loops through each pattern, prepares it for matching, and checks for a match.
 // If a match is found, it returns true, indicating a match is found. Else, returns false.
-func partialMatchStringPattern(values []string) (bool, error) {
-	for _, pattern := range values {
-		klog.V(5).Info("ManagedHub filter pattern to match: ", pattern, " hubname: ", config.Cfg.HubName)
-		//fix prefix
-		if !strings.HasPrefix(pattern, "*") {
-			pattern = "^" + pattern
-		}
-		//fix suffix
-		if !strings.HasSuffix(pattern, "*") {
-			pattern = pattern + "$"
-		}
-		// fix start and end of string and match multiple characters
-		pattern = strings.ReplaceAll(pattern, "%", ".*")
+func partialMatchStringPattern(values []string, ignoreCase bool) (bool, error) {
+	for _, value := range values {
+		klog.V(5).Info("ManagedHub filter pattern to match: ", value, " hubname: ", config.Cfg.HubName)
+		pattern := likePatternToRegexp(value)
+		if ignoreCase {
+			pattern = "(?i)" + pattern
+		}
 		matched, err := regexp.MatchString(pattern, config.Cfg.HubName)
 
 		if err != nil {
@@ -494,7 +818,8 @@ func partialMatchStringPattern(values []string) (bool, error) {
 }
 
 // processOpValueMapManagedHub processes the key-value pair for a managedHub filter.
-// It handles different key cases such as "!", "!=", "=", "!:*", "!=:*", and "=:*".
+// It handles different key cases such as "!", "!=", "=", "!:*", "!=:*", and "=:*",
+// and the case-insensitive "~", "!~", "~:*" and "!~:*".
 // It returns a boolean indicating whether the search should proceed based on the evaluation of the key and values.
 func processOpValueMapManagedHub(key string, values []string) bool {
 	result := false
@@ -504,23 +829,72 @@ func processOpValueMapManagedHub(key string, values []string) bool {
 		result = !slices.Contains((values), config.Cfg.HubName) // Search should not proceed if there is a match
 	case "=":
 		result = slices.Contains(values, config.Cfg.HubName) // Search to proceed if there is a match
+	case "~":
+		result = containsFold(values, config.Cfg.HubName) // Same as "=", ignoring case
+	case "!~":
+		result = !containsFold(values, config.Cfg.HubName)
 	case "!:*", "!=:*":
-		match, err := partialMatchStringPattern(values)
+		match, err := partialMatchStringPattern(values, false)
 		if err != nil {
 			klog.Errorf("Error processing partial match for ManagedHub filter:", err)
 			return false
 		}
 		result = !match // Return the inverse of match to indicate search should not proceed if there is a partial match
 	case "=:*":
-		match, err := partialMatchStringPattern(values)
+		match, err := partialMatchStringPattern(values, false)
 		if err != nil {
 			klog.Errorf("Error processing partial match for ManagedHub filter:", err)
 			return false
 		}
 		result = match // Return match to indicate search should proceed if there is a partial match
+	case "~:*", "!~:*":
+		match, err := partialMatchStringPattern(values, true)
+		if err != nil {
+			klog.Errorf("Error processing partial match for ManagedHub filter:", err)
+			return false
+		}
+		result = match == (key == "~:*")
 	}
 	klog.V(4).Infof("ManagedHub filter hubname: %s operation: %s values: %+v  result: %t",
 		config.Cfg.HubName, key, values, result)
 
 	return result
 }
+
+// Returns true if none of the expressions adds a condition to the WHERE clause.
+func isEmptyWhereClause(whereDs []exp.Expression) bool {
+	for _, whereExp := range whereDs {
+		if whereExp == nil {
+			continue
+		}
+		if expList, ok := whereExp.(exp.ExpressionList); ok && isEmptyWhereClause(expList.Expressions()) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Detects a query that scans the entire resources table. The filters don't add a condition and the RBAC
+// clause collapses to (cluster != 'local-cluster' OR "data"?'_hubClusterResource') for a user authorized
+// to search all the resources. The action is configured with EMPTY_WHERE_ACTION: log, warn (default) or
+// confirm, which requires confirmFullScan in the input.
+func checkFullScan(queryName string, filtersDs []exp.Expression, userrbac rbac.UserData,
+	input *model.SearchInput) error {
+	if !isEmptyWhereClause(filtersDs) || !hasUnrestrictedAccess(userrbac) {
+		return nil
+	}
+	switch config.Cfg.EmptyWhereAction {
+	case "confirm":
+		if input == nil || input.ConfirmFullScan == nil || !*input.ConfirmFullScan {
+			klog.V(3).Infof("Rejected %s query that would scan all resources without confirmFullScan.", queryName)
+			return invalidInputf("%s query would scan all resources, set confirmFullScan to run it", queryName)
+		}
+		klog.V(3).Infof("%s query will scan all resources, confirmed with confirmFullScan.", queryName)
+	case "log":
+		klog.V(3).Infof("%s query doesn't restrict the results. It will scan all resources.", queryName)
+	default:
+		klog.Warningf("%s query doesn't restrict the results. It will scan all resources.", queryName)
+	}
+	return nil
+}
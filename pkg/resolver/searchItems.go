@@ -0,0 +1,120 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/stolostron/search-v2-api/pkg/config"
+	klog "k8s.io/klog/v2"
+)
+
+// SearchItem is a search result item serialized as the GraphQL Map scalar.
+// Holds either the formatted data map or the item already encoded as JSON.
+type SearchItem struct {
+	data    map[string]interface{} // Used by the default map serialization.
+	encoded []byte                 // Used by the stream serialization.
+}
+
+// Encoder and buffer reused to serialize the items.
+type itemEncoder struct {
+	buf *bytes.Buffer
+	enc *json.Encoder
+}
+
+var itemEncoderPool = sync.Pool{
+	New: func() interface{} {
+		buf := &bytes.Buffer{}
+		return &itemEncoder{buf: buf, enc: json.NewEncoder(buf)}
+	},
+}
+
+// MarshalGQL implements the graphql.Marshaler interface.
+func (i SearchItem) MarshalGQL(w io.Writer) {
+	if i.encoded != nil {
+		_, _ = w.Write(i.encoded)
+		return
+	}
+	if i.data == nil {
+		_, _ = io.WriteString(w, "null")
+		return
+	}
+	e := itemEncoderPool.Get().(*itemEncoder)
+	defer itemEncoderPool.Put(e)
+	e.buf.Reset()
+	if err := e.enc.Encode(i.data); err != nil {
+		klog.Error("Error encoding search item. ", err)
+		_, _ = io.WriteString(w, "null")
+		return
+	}
+	_, _ = w.Write(e.buf.Bytes())
+}
+
+// UnmarshalGQL implements the graphql.Unmarshaler interface.
+func (i *SearchItem) UnmarshalGQL(v interface{}) error {
+	data, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%T is not a map", v)
+	}
+	i.data = data
+	i.encoded = nil
+	return nil
+}
+
+// Returns the items serialized for the GraphQL response.
+// The serialization is configured with ITEMS_SERIALIZATION:
+//
+//	map    - (default) format each item into a map, which is encoded to JSON by the GraphQL handler.
+//	stream - encode each item to JSON as it's read from the database, instead of keeping the maps until the response.
+func (s *SearchResult) EncodedItems() ([]SearchItem, error) {
+	page := s.fetchPage()
+	if page.err != nil {
+		return nil, page.err
+	}
+	return page.items, nil
+}
+
+// Items of the search, fetched once. Items(), EncodedItems() and NextCursor() are resolved concurrently
+// and share the page, so the query runs once and the cursor is built from the items returned to the client.
+// The fields of the page, and the itemsCount and lastItem of the search, are only set in the once.
+type searchPage struct {
+	once  sync.Once
+	items []SearchItem
+	err   error
+}
+
+// Fetch the items once for the search, serialized as configured with ITEMS_SERIALIZATION.
+func (s *SearchResult) fetchPage() *searchPage {
+	s.page.once.Do(func() {
+		items := []SearchItem{}
+		addItem := func(item map[string]interface{}) {
+			items = append(items, SearchItem{data: item})
+		}
+		if config.Cfg.ItemsSerialization == "stream" {
+			e := itemEncoderPool.Get().(*itemEncoder)
+			defer itemEncoderPool.Put(e)
+			addItem = func(item map[string]interface{}) {
+				if encoded, ok := e.encode(item); ok {
+					items = append(items, SearchItem{encoded: encoded})
+				}
+			}
+		}
+		if s.page.err = s.searchItems(addItem); s.page.err == nil {
+			s.page.items = items
+		}
+	})
+	return &s.page
+}
+
+// Encode the item to JSON. The output is the same as MarshalGQL() for the item map.
+func (e *itemEncoder) encode(item map[string]interface{}) ([]byte, bool) {
+	e.buf.Reset()
+	if err := e.enc.Encode(item); err != nil {
+		klog.Error("Error encoding search item. ", err)
+		return nil, false
+	}
+	return append([]byte(nil), e.buf.Bytes()...), true
+}
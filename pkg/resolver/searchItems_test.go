@@ -0,0 +1,140 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+// Marshal the items as the GraphQL handler does.
+func marshalSearchItems(items []SearchItem) string {
+	var buf bytes.Buffer
+	for _, item := range items {
+		item.MarshalGQL(&buf)
+	}
+	return buf.String()
+}
+
+// Search result with the mock data encoded as raw JSON, same as the jsonb column read from the database.
+func newMockItemsResolver(t gomock.TestReporter, mockData []map[string]interface{}) *SearchResult {
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+			return &MockRows{mockData: mockData, columnHeaders: []string{"uid", "cluster", "data"}}, nil
+		})
+	val1 := "Pod"
+	return &SearchResult{context: context.Background(), pool: mockPool,
+		input:     &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val1}}}},
+		propTypes: map[string]string{"kind": "string"},
+		userData:  rbac.UserData{CsResources: []rbac.Resource{}}}
+}
+
+// Resolve the items with the serialization configured with ITEMS_SERIALIZATION.
+func resolveSearchItems(t gomock.TestReporter, resolver *SearchResult, serialization string) []SearchItem {
+	defer func(serialization string) { config.Cfg.ItemsSerialization = serialization }(config.Cfg.ItemsSerialization)
+	config.Cfg.ItemsSerialization = serialization
+	items, err := resolver.EncodedItems()
+	if err != nil {
+		t.Errorf("Error resolving the items with %s serialization: %s", serialization, err)
+	}
+	return items
+}
+
+func newMockItemsData(t gomock.TestReporter) []map[string]interface{} {
+	mockRows := newMockRowsWithoutRBAC("./mocks/mock.json", &model.SearchInput{}, "", 0)
+	mockData := make([]map[string]interface{}, len(mockRows.mockData))
+	for i, row := range mockRows.mockData {
+		raw, err := json.Marshal(row["data"])
+		if err != nil {
+			t.Errorf("Error encoding mock data: %s", err)
+		}
+		mockData[i] = map[string]interface{}{"uid": row["uid"], "cluster": row["cluster"], "data": raw}
+	}
+	return mockData
+}
+
+// Should produce the same output with the map and stream serialization.
+func Test_SearchItems_MapAndStreamEquivalent(t *testing.T) {
+	mockData := newMockItemsData(t)
+	// Add special cases: labels, arrays, numbers, booleans, null, and characters escaped in JSON.
+	mockData = append(mockData, map[string]interface{}{"uid": "local-cluster/abc", "cluster": "local-cluster",
+		"data": []byte(`{"kind":"Pod","label":{"b":"2","a":"<1>"},"container":["z","A"],"restarts":3,` +
+			`"_hubClusterResource":true,"empty":null,"name":"a\"b\\c&d",` +
+			`"desc":"line\nbreak\u2028\u0001\t"}`)})
+
+	mapResolver := newMockItemsResolver(t, mockData)
+	mapItems := resolveSearchItems(t, mapResolver, "map")
+	streamResolver := newMockItemsResolver(t, mockData)
+	streamItems := resolveSearchItems(t, streamResolver, "stream")
+
+	assert.Equal(t, len(mockData), len(streamItems))
+	assert.Equal(t, marshalSearchItems(mapItems), marshalSearchItems(streamItems))
+	assert.Equal(t, PointerToStringArray(mapResolver.uids), PointerToStringArray(streamResolver.uids))
+}
+
+// Should use the serialization configured with ITEMS_SERIALIZATION.
+func Test_SearchResolver_EncodedItems(t *testing.T) {
+	defer func(serialization string) { config.Cfg.ItemsSerialization = serialization }(config.Cfg.ItemsSerialization)
+	mockData := newMockItemsData(t)
+
+	for _, serialization := range []string{"map", "stream"} {
+		config.Cfg.ItemsSerialization = serialization
+		resolver := newMockItemsResolver(t, mockData)
+
+		items, err := resolver.EncodedItems()
+		assert.Nil(t, err)
+		assert.Equal(t, len(mockData), len(items), "Unexpected items with %s serialization.", serialization)
+		if serialization == "stream" {
+			assert.NotNil(t, items[0].encoded)
+		} else {
+			assert.NotNil(t, items[0].data)
+		}
+	}
+}
+
+func Test_SearchItem_UnmarshalGQL(t *testing.T) {
+	item := SearchItem{}
+	assert.Nil(t, item.UnmarshalGQL(map[string]interface{}{"kind": "Pod"}))
+	assert.Equal(t, "{\"kind\":\"Pod\"}\n", marshalSearchItems([]SearchItem{item}))
+	assert.NotNil(t, item.UnmarshalGQL("Pod"))
+	assert.Equal(t, "null", marshalSearchItems([]SearchItem{{}}))
+}
+
+// Compare allocations with: go test ./pkg/resolver -run none -bench SearchItems -benchmem
+func benchmarkSearchItems(b *testing.B, serialization string) {
+	mockData := newMockItemsData(b)
+	for len(mockData) < 1000 {
+		mockData = append(mockData, mockData...)
+	}
+	resolver := newMockItemsResolver(b, mockData)
+	var buf bytes.Buffer
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		buf.Reset()
+		resolver.uids = nil
+		for _, item := range resolveSearchItems(b, resolver, serialization) {
+			item.MarshalGQL(&buf)
+		}
+	}
+}
+
+func BenchmarkSearchItems_Map(b *testing.B) {
+	benchmarkSearchItems(b, "map")
+}
+
+func BenchmarkSearchItems_Stream(b *testing.B) {
+	benchmarkSearchItems(b, "stream")
+}
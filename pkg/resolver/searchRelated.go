@@ -0,0 +1,55 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
+	klog "k8s.io/klog/v2"
+)
+
+// Returns the resources related to the resource with the uid, for example the pods owned by a deployment.
+// The relationships are found with the same query as the related field of the search results, up to the
+// depth and matching the kinds. Both the resource and the related resources are filtered by the user's RBAC.
+func SearchRelated(ctx context.Context, uid string, depth *int, kinds []string) ([]*SearchRelatedResult, error) {
+	defer metrics.SlowLog("SearchRelatedResolver", 0)()
+	if uid == "" {
+		return nil, invalidInputf("uid is required for searchRelated query")
+	}
+	results, err := Search(ctx, []*model.SearchInput{relatedInput(depth, kinds)})
+	if err != nil {
+		return nil, err
+	}
+	related, err := results[0].searchRelated(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*SearchRelatedResult, len(related))
+	for i := range related {
+		result[i] = &related[i]
+	}
+	return result, nil
+}
+
+// Builds the search input with the options to find the related resources.
+func relatedInput(depth *int, kinds []string) *model.SearchInput {
+	return &model.SearchInput{
+		RelatedDepth: depth,
+		RelatedKinds: stringArrayToPointer(kinds),
+	}
+}
+
+// Gets the resource with the uid first, so nothing is returned when the user isn't authorized to list it.
+func (s *SearchResult) searchRelated(ctx context.Context, uid string) ([]SearchRelatedResult, error) {
+	resource, err := (&GetResourceResult{pool: s.pool, uid: uid, userData: s.userData}).resource(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if resource == nil {
+		klog.V(3).Infof("Resource with uid %s for searchRelated not found or not authorized.", uid)
+		return []SearchRelatedResult{}, nil
+	}
+	s.uids = []*string{&uid}
+	return s.Related(ctx)
+}
@@ -0,0 +1,173 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+// Owner and child edges of a deployment in managed1. The user isn't authorized to list the secret.
+var relatedTestEdges = []struct{ source, sourceKind, dest, destKind string }{
+	{"managed1/rs-1", "ReplicaSet", "managed1/deploy-1", "Deployment"},
+	{"managed1/pod-1", "Pod", "managed1/rs-1", "ReplicaSet"},
+	{"managed1/pod-2", "Pod", "managed1/rs-1", "ReplicaSet"},
+	{"managed1/pod-1", "Pod", "managed1/configmap-1", "ConfigMap"},
+	{"managed1/pod-2", "Pod", "managed1/secret-1", "Secret"},
+}
+
+var relatedTestAuthorized = map[string]bool{"managed1/deploy-1": true, "managed1/rs-1": true,
+	"managed1/pod-1": true, "managed1/pod-2": true, "managed1/configmap-1": true}
+
+// Mimics the relations query with the seeded edges. Traverses the edges in both directions up to the level
+// and returns the authorized resources, same as the RBAC clause joined to the relations.
+func mockRelationRows(uid string, level int) *MockRows {
+	levels := map[string]int{uid: 0}
+	kinds := map[string]string{}
+	current := []string{uid}
+	for l := 1; l <= level; l++ {
+		next := []string{}
+		for _, edge := range relatedTestEdges {
+			for _, c := range current {
+				if edge.source != c && edge.dest != c {
+					continue
+				}
+				for other, kind := range map[string]string{edge.source: edge.sourceKind, edge.dest: edge.destKind} {
+					if _, found := levels[other]; !found {
+						levels[other] = l
+						kinds[other] = kind
+						next = append(next, other)
+					}
+				}
+			}
+		}
+		current = next
+	}
+
+	rows := &MockRows{columnHeaders: []string{"uid", "kind", "level", "path"}}
+	for related, l := range levels {
+		if related == uid || !relatedTestAuthorized[related] {
+			continue
+		}
+		rows.mockData = append(rows.mockData, map[string]interface{}{"uid": related, "kind": kinds[related],
+			"level": float64(l), "path": []string{uid, related}})
+	}
+	return rows
+}
+
+// Mimics the query to get the items for the related uids.
+func mockRelatedItemRows(uids []string) *MockRows {
+	rows := &MockRows{columnHeaders: []string{"uid", "cluster", "data"}}
+	for _, uid := range uids {
+		kind := ""
+		for _, edge := range relatedTestEdges {
+			if edge.source == uid {
+				kind = edge.sourceKind
+			} else if edge.dest == uid {
+				kind = edge.destKind
+			}
+		}
+		rows.mockData = append(rows.mockData, map[string]interface{}{"uid": uid, "cluster": "managed1",
+			"data": map[string]interface{}{"kind": kind, "name": strings.TrimPrefix(uid, "managed1/")}})
+	}
+	return rows
+}
+
+// Returns the related uids grouped by kind.
+func relatedUIDsByKind(t *testing.T, results []SearchRelatedResult) map[string][]string {
+	uidsByKind := map[string][]string{}
+	for _, result := range results {
+		for _, item := range result.Items {
+			uidsByKind[result.Kind] = append(uidsByKind[result.Kind], item["_uid"].(string))
+		}
+		sort.Strings(uidsByKind[result.Kind])
+		assert.Equal(t, len(result.Items), *result.Count)
+	}
+	return uidsByKind
+}
+
+func Test_SearchRelated(t *testing.T) {
+	defer func(level int) { config.Cfg.RelationLevel = level }(config.Cfg.RelationLevel)
+	config.Cfg.RelationLevel = 0
+	ud := rbac.UserData{CsResources: []rbac.Resource{}, ManagedClusters: map[string]struct{}{"managed1": {}}}
+	depth := func(d int) *int { return &d }
+
+	tests := []struct {
+		name     string
+		uid      string
+		depth    *int
+		kinds    []string
+		expected map[string][]string
+	}{
+		{"default depth", "managed1/deploy-1", nil, nil,
+			map[string][]string{"ReplicaSet": {"managed1/rs-1"}}},
+		{"owner and children", "managed1/rs-1", nil, nil,
+			map[string][]string{"Deployment": {"managed1/deploy-1"}, "Pod": {"managed1/pod-1", "managed1/pod-2"}}},
+		{"depth 2", "managed1/deploy-1", depth(2), nil,
+			map[string][]string{"ReplicaSet": {"managed1/rs-1"}, "Pod": {"managed1/pod-1", "managed1/pod-2"}}},
+		{"depth 3 without unauthorized secret", "managed1/deploy-1", depth(3), nil,
+			map[string][]string{"ReplicaSet": {"managed1/rs-1"}, "Pod": {"managed1/pod-1", "managed1/pod-2"},
+				"ConfigMap": {"managed1/configmap-1"}}},
+		{"depth limited to max", "managed1/deploy-1", depth(10), nil,
+			map[string][]string{"ReplicaSet": {"managed1/rs-1"}, "Pod": {"managed1/pod-1", "managed1/pod-2"},
+				"ConfigMap": {"managed1/configmap-1"}}},
+		{"kinds", "managed1/deploy-1", depth(3), []string{"pod"},
+			map[string][]string{"Pod": {"managed1/pod-1", "managed1/pod-2"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolver, mockPool := newMockSearchResolver(t, relatedInput(test.depth, test.kinds), nil, ud, nil)
+
+			// The resource is resolved with the RBAC clause.
+			mockPool.EXPECT().Query(gomock.Any(),
+				gomock.Eq(fmt.Sprintf(`SELECT "uid", "cluster", "data" FROM "search"."resources" WHERE (("uid" = '%s') AND ("cluster" = ANY ('{"managed1"}'))) LIMIT 1`, test.uid)),
+				gomock.Eq([]interface{}{}),
+			).Return(mockRelatedItemRows([]string{test.uid}), nil)
+
+			// The relations are traversed up to the depth and joined to the authorized resources.
+			mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Eq([]interface{}{})).
+				DoAndReturn(func(ctx context.Context, sql string, params ...interface{}) (pgx.Rows, error) {
+					assert.Contains(t, sql, fmt.Sprintf(`WHERE (("level" <= %d) AND ("uid" NOT IN ('%s')))`,
+						resolver.level, test.uid))
+					assert.Contains(t, sql, `INNER JOIN "search"."resources" ON ("related"."uid" = "resources".uid) WHERE ("cluster" = ANY ('{"managed1"}'))`)
+					return mockRelationRows(test.uid, resolver.level), nil
+				})
+
+			// Items of the related resources matching the kinds.
+			mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, sql string, params ...interface{}) (pgx.Rows, error) {
+					assert.True(t, strings.HasPrefix(sql, `SELECT "uid", "cluster", "data" FROM "search"."resources" WHERE ("uid" IN (`))
+					return mockRelatedItemRows(PointerToStringArray(resolver.uids)), nil
+				})
+
+			result, err := resolver.searchRelated(context.Background(), test.uid)
+
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, relatedUIDsByKind(t, result))
+		})
+	}
+}
+
+// Should return an empty result without querying the relations when the user can't access the resource.
+func Test_SearchRelated_UnauthorizedResource(t *testing.T) {
+	ud := rbac.UserData{CsResources: []rbac.Resource{}, ManagedClusters: map[string]struct{}{"managed2": {}}}
+	resolver, mockPool := newMockSearchResolver(t, relatedInput(nil, nil), nil, ud, nil)
+
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT "uid", "cluster", "data" FROM "search"."resources" WHERE (("uid" = 'managed1/deploy-1') AND ("cluster" = ANY ('{"managed2"}'))) LIMIT 1`),
+		gomock.Eq([]interface{}{}),
+	).Return(&MockRows{}, nil)
+
+	result, err := resolver.searchRelated(context.Background(), "managed1/deploy-1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []SearchRelatedResult{}, result)
+}
@@ -2,6 +2,8 @@ package resolver
 
 import (
 	"context"
+	"sync"
+	"time"
 
 	"github.com/doug-martin/goqu/v9"
 	"github.com/doug-martin/goqu/v9/exp"
@@ -13,10 +15,61 @@ import (
 )
 
 type SearchSchema struct {
-	pool     pgxpoolmock.PgxPool
-	query    string
-	params   []interface{}
-	userData rbac.UserData
+	pool      pgxpoolmock.PgxPool
+	query     string
+	params    []interface{}
+	propTypes map[string]string // Database types of the properties, used when the values aren't sampled.
+	userData  rbac.UserData
+}
+
+// Metadata of a property in the search schema. Used by the UI to render the filter for the property.
+type PropertySchema struct {
+	Type string `json:"type"` // number, date, boolean, string, object or array.
+}
+
+// Cached schema results. Keyed by the query, which includes the user's RBAC clause, so the users
+// with the same access share the cached schema. Also caches the schema samples, which use a different query.
+type schemaCache struct {
+	lock    sync.Mutex
+	entries map[string]schemaCacheEntry
+}
+
+type schemaCacheEntry struct {
+	value     interface{} // Not modified after it's cached.
+	updatedAt time.Time
+}
+
+var searchSchemaCache = &schemaCache{entries: map[string]schemaCacheEntry{}}
+
+// Returns the cached value for the query, or false if it isn't cached or it expired.
+func (c *schemaCache) get(query string) (interface{}, bool) {
+	ttl := time.Duration(config.Cfg.SchemaCacheTTL) * time.Millisecond
+	if ttl <= 0 {
+		return nil, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, found := c.entries[query]
+	if !found || time.Since(entry.updatedAt) >= ttl {
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Cache the value for the query. Expired entries are removed.
+func (c *schemaCache) set(query string, value interface{}) {
+	ttl := time.Duration(config.Cfg.SchemaCacheTTL) * time.Millisecond
+	if ttl <= 0 {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for key, entry := range c.entries {
+		if time.Since(entry.updatedAt) >= ttl {
+			delete(c.entries, key)
+		}
+	}
+	c.entries[query] = schemaCacheEntry{value: value, updatedAt: time.Now()}
 }
 
 func SearchSchemaResolver(ctx context.Context) (map[string]interface{}, error) {
@@ -24,13 +77,64 @@ func SearchSchemaResolver(ctx context.Context) (map[string]interface{}, error) {
 	if userDataErr != nil {
 		return nil, userDataErr
 	}
+	propTypes, err := getPropertyType(ctx, false)
+	if err != nil {
+		klog.Warningf("Error creating datatype map. Error: [%s] ", err)
+	}
 	// Proceed if user's rbac data exists
 	searchSchemaResult := &SearchSchema{
-		pool:     db.GetConnPool(ctx),
-		userData: userData,
+		pool:      db.GetConnPool(ctx),
+		propTypes: propTypes,
+		userData:  userData,
 	}
 	searchSchemaResult.buildSearchSchemaQuery(ctx)
-	return searchSchemaResult.searchSchemaResults(ctx)
+	srchSchema, err := searchSchemaResult.searchSchemaResults(ctx)
+	if err != nil {
+		return srchSchema, err
+	}
+	srchSchema["properties"] = searchSchemaResult.propertySchemas(ctx, srchSchema["allProperties"].([]string))
+	return srchSchema, nil
+}
+
+// Returns the type of each property, inferred from the most common values of the property.
+// The values are sampled with the searchSchemaSamples query, which is cached with SCHEMA_CACHE_TTL.
+func (s *SearchSchema) propertySchemas(ctx context.Context, properties []string) map[string]PropertySchema {
+	samples := map[string]interface{}{}
+	sampler := &SearchSchemaSamples{pool: s.pool, userData: s.userData}
+	if err := sampler.buildSearchSchemaSamplesQuery(ctx); err == nil {
+		if samples, err = sampler.searchSchemaSamplesResults(ctx); err != nil {
+			klog.Warning("Error sampling the property values to infer the property types. ", err)
+		}
+	}
+
+	schemas := make(map[string]PropertySchema, len(properties))
+	for _, prop := range properties {
+		values, _ := samples[prop].([]string)
+		schemas[prop] = PropertySchema{Type: inferPropertyType(values, s.propTypes[prop])}
+	}
+	return schemas
+}
+
+// Infers the property type from the sampled values with the same checks used by searchComplete.
+// Properties without sampled values, like labels and arrays, use the database type.
+func inferPropertyType(values []string, dbType string) string {
+	if len(values) > 0 {
+		vals := stringArrayToPointer(values)
+		switch {
+		case isNumber(vals):
+			return "number"
+		case isDate(vals):
+			return "date"
+		case isBoolean(vals):
+			return "boolean"
+		default:
+			return "string"
+		}
+	}
+	if dbType != "" {
+		return dbType
+	}
+	return "string"
 }
 
 // Build the query to get all the properties (or keys) from the resources in the database.
@@ -73,15 +177,14 @@ func (s *SearchSchema) buildSearchSchemaQuery(ctx context.Context) {
 
 	//SELECT CLAUSE
 	jsb := goqu.L("jsonb_object_keys(jsonb_strip_nulls(?))", goqu.C("data")).As("prop") //remove null fields
-	//Adding an arbitrarily high number 100000 as limit here in the inner query
-	// Adding a LIMIT helps to speed up the query
-	// Adding a high number so as to get almost all the distinct properties from the database
+	// The LIMIT in the inner query (AUTOCOMPLETE_SCAN_LIMIT) speeds up the query.
+	// A high limit gets almost all the distinct properties from the database.
 	if whereDs != nil {
 		selectDs = ds.SelectDistinct("prop").From(ds.Select(jsb).Where(whereDs).
-			Limit(uint(config.Cfg.QueryLimit) * 100).As("schema"))
+			Limit(uint(config.Cfg.AutocompleteScanLimit)).As("schema"))
 	} else {
 		selectDs = ds.SelectDistinct("prop").From(ds.Select(jsb).
-			Limit(uint(config.Cfg.QueryLimit) * 100).As("schema"))
+			Limit(uint(config.Cfg.AutocompleteScanLimit)).As("schema"))
 	}
 	//Get the query
 	sql, params, err := selectDs.ToSQL()
@@ -97,14 +200,27 @@ func (s *SearchSchema) searchSchemaResults(ctx context.Context) (map[string]inte
 	klog.V(2).Info("Resolving searchSchemaResults()")
 	srchSchema := map[string]interface{}{}
 	// These default properties are always present and we want them at the top.
-	schema := []string{"cluster", "kind", "label", "name", "namespace", "status"}
+	schema := withoutDeniedProperties([]string{"cluster", "kind", "label", "name", "namespace", "status"})
 	// Use a map to remove duplicates efficiently.
 	schemaMap := map[string]struct{}{}
 	for _, key := range schema {
 		schemaMap[key] = struct{}{}
 	}
 
-	rows, err := s.pool.Query(ctx, s.query)
+	// The query is empty when the user doesn't have access to any resources. Return only the default properties.
+	if s.query == "" {
+		srchSchema["allProperties"] = schema
+		return srchSchema, nil
+	}
+
+	// Use the cached schema to avoid scanning the resources with every request.
+	if cached, found := searchSchemaCache.get(s.query); found {
+		klog.V(5).Info("Using search schema from cache.")
+		srchSchema["allProperties"] = append([]string{}, cached.([]string)...)
+		return srchSchema, nil
+	}
+
+	rows, err := query(ctx, s.pool, s.query)
 	if err != nil {
 		klog.Error("Error fetching search schema results from db ", err)
 		return srchSchema, err
@@ -114,13 +230,19 @@ func (s *SearchSchema) searchSchemaResults(ctx context.Context) (map[string]inte
 		prop := ""
 		_ = rows.Scan(&prop)
 		// Skip properties that start with _ because those are used internally and aren't intended to be exposed.
-		if prop[0:1] == "_" {
+		// Also skip the properties hidden with PROPERTY_DENYLIST.
+		if prop[0:1] == "_" || isDeniedProperty(prop) {
 			continue
 		}
 		if _, present := schemaMap[prop]; !present {
 			schema = append(schema, prop)
 		}
 	}
+	if err = rows.Err(); err != nil {
+		klog.Error("Error reading search schema results ", err)
+		return srchSchema, err
+	}
 	srchSchema["allProperties"] = schema
+	searchSchemaCache.set(s.query, append([]string{}, schema...))
 	return srchSchema, nil
 }
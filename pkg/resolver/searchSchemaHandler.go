@@ -0,0 +1,65 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	klog "k8s.io/klog/v2"
+)
+
+// Resolves the search schema for the handler. Defined as a variable so unit tests can replace it with a mock.
+var searchSchemaForHandler = SearchSchemaResolver
+
+// Returns the search schema, same as the searchSchema query, with HTTP caching support.
+// The schema changes rarely but it's fetched on every UI load. The response includes an ETag derived from
+// the schema content, and requests with a matching If-None-Match header get 304 Not Modified without a body.
+func HandleSearchSchema(w http.ResponseWriter, r *http.Request) {
+	klog.V(3).Info("Received search schema request.")
+	schema, err := searchSchemaForHandler(r.Context())
+	if err != nil {
+		klog.Warning("Error resolving the search schema. ", err)
+		http.Error(w, fmt.Sprintf("{\"message\":%q}", err.Error()), errorStatus(err))
+		return
+	}
+	body, err := json.Marshal(schema)
+	if err != nil {
+		klog.Error("Error encoding the search schema. ", err)
+		http.Error(w, "{\"message\":\"Error encoding the search schema.\"}", http.StatusInternalServerError)
+		return
+	}
+
+	etag := schemaETag(body)
+	// The schema depends on the user's access, so it can only be cached by the client.
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(body); err != nil {
+		klog.V(2).Info("Error writing the search schema response. ", err)
+	}
+}
+
+// Returns a strong ETag with the hash of the schema content.
+func schemaETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf("%q", fmt.Sprintf("%x", sum[:16]))
+}
+
+// Checks if the If-None-Match header matches the ETag. The header is a list of ETags or *.
+// Uses the weak comparison, as required for If-None-Match.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
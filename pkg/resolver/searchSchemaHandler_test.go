@@ -0,0 +1,82 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Replace the schema resolver with a mock returning the schema.
+func mockSearchSchemaForHandler(schema map[string]interface{}, err error) func() {
+	original := searchSchemaForHandler
+	searchSchemaForHandler = func(ctx context.Context) (map[string]interface{}, error) {
+		return schema, err
+	}
+	return func() { searchSchemaForHandler = original }
+}
+
+// Should return the schema with an ETag, and 304 for a conditional request with the same ETag.
+func Test_HandleSearchSchema_ETag(t *testing.T) {
+	defer mockSearchSchemaForHandler(map[string]interface{}{
+		"allProperties": []string{"cluster", "kind", "name"},
+		"properties":    map[string]PropertySchema{"cluster": {Type: "string"}},
+	}, nil)()
+
+	// Fresh request.
+	w := httptest.NewRecorder()
+	HandleSearchSchema(w, httptest.NewRequest("GET", "/searchapi/schema", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"allProperties":["cluster","kind","name"],"properties":{"cluster":{"type":"string"}}}`,
+		w.Body.String())
+	etag := w.Header().Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{32}"$`, etag)
+	assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+
+	// Conditional request with the same ETag, also when it's weak or in a list.
+	for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		r := httptest.NewRequest("GET", "/searchapi/schema", nil)
+		r.Header.Set("If-None-Match", ifNoneMatch)
+		w = httptest.NewRecorder()
+		HandleSearchSchema(w, r)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+	}
+}
+
+// Should return the schema when it changed since the client's ETag.
+func Test_HandleSearchSchema_Changed(t *testing.T) {
+	restore := mockSearchSchemaForHandler(map[string]interface{}{"allProperties": []string{"cluster"}}, nil)
+	w := httptest.NewRecorder()
+	HandleSearchSchema(w, httptest.NewRequest("GET", "/searchapi/schema", nil))
+	etag := w.Header().Get("ETag")
+	restore()
+
+	defer mockSearchSchemaForHandler(map[string]interface{}{"allProperties": []string{"cluster", "kind"}}, nil)()
+	r := httptest.NewRequest("GET", "/searchapi/schema", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	HandleSearchSchema(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"allProperties":["cluster","kind"]}`, w.Body.String())
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+}
+
+// Should return an error without an ETag when the schema can't be resolved.
+func Test_HandleSearchSchema_Error(t *testing.T) {
+	defer mockSearchSchemaForHandler(nil, errors.New("database unavailable"))()
+
+	w := httptest.NewRecorder()
+	HandleSearchSchema(w, httptest.NewRequest("GET", "/searchapi/schema", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Empty(t, w.Header().Get("ETag"))
+}
@@ -0,0 +1,158 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	db "github.com/stolostron/search-v2-api/pkg/database"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	klog "k8s.io/klog/v2"
+)
+
+type SearchSchemaSamples struct {
+	pool     pgxpoolmock.PgxPool
+	limit    *int
+	query    string
+	params   []interface{}
+	userData rbac.UserData
+}
+
+func SearchSchemaSamplesResolver(ctx context.Context, limit *int) (map[string]interface{}, error) {
+	defer metrics.SlowLog("SearchSchemaSamplesResolver", 0)()
+	userData, userDataErr := rbac.GetCache().GetUserData(ctx)
+	if userDataErr != nil {
+		return nil, userDataErr
+	}
+	// Proceed if user's rbac data exists
+	searchSchemaSamples := &SearchSchemaSamples{
+		pool:     db.GetConnPool(ctx),
+		limit:    limit,
+		userData: userData,
+	}
+	if err := searchSchemaSamples.buildSearchSchemaSamplesQuery(ctx); err != nil {
+		return map[string]interface{}{}, err
+	}
+	return searchSchemaSamples.searchSchemaSamplesResults(ctx)
+}
+
+// Returns the max number of values per property. The client can't exceed the configured SCHEMA_SAMPLE_LIMIT.
+func (s *SearchSchemaSamples) sampleLimit() int {
+	limit := config.Cfg.SchemaSampleLimit
+	if s.limit != nil && *s.limit > 0 && *s.limit < limit {
+		limit = *s.limit
+	} else if s.limit != nil && (*s.limit > limit || *s.limit == -1) {
+		klog.V(2).Infof("Requested schema sample limit %d exceeds SCHEMA_SAMPLE_LIMIT. Using %d.", *s.limit, limit)
+	}
+	return limit
+}
+
+// Builds a single query to get the most common values of every property. Each resource is expanded
+// into its properties with jsonb_each, the same discovery used by the schema query, and the values are
+// ranked by count within each property. Labels and arrays aren't sampled.
+// Sample query:
+//
+//	SELECT "prop", "value" FROM (SELECT kv.key AS "prop", kv.value #>> '{}' AS "value",
+//	  row_number() OVER (PARTITION BY kv.key ORDER BY COUNT(*) DESC, kv.value #>> '{}' ASC) AS "rank"
+//	  FROM (SELECT "data" || jsonb_build_object('cluster', "cluster") AS "data" FROM "search"."resources"
+//	    WHERE <rbac> LIMIT 100000) AS "resources", jsonb_each("resources"."data") AS kv(key, value)
+//	  WHERE jsonb_typeof(kv.value) IN ('string', 'number', 'boolean')
+//	  GROUP BY kv.key, kv.value #>> '{}') AS "samples"
+//	WHERE ("rank" <= 5) ORDER BY "prop" ASC, "rank" ASC
+func (s *SearchSchemaSamples) buildSearchSchemaSamplesQuery(ctx context.Context) error {
+	s.query = ""
+	s.params = nil
+
+	// get user info for logging
+	_, userInfo := rbac.GetCache().GetUserUID(ctx)
+
+	// RBAC CLAUSE
+	// if one of them is not nil, userData is not empty
+	var whereDs exp.ExpressionList
+	if s.userData.CsResources != nil || s.userData.NsResources != nil || s.userData.ManagedClusters != nil {
+		whereDs = buildRbacWhereClause(ctx, s.userData, userInfo) // add rbac
+	} else {
+		klog.Errorf("Error building searchSchemaSamples query: RBAC clause is required!"+
+			" None found for searchSchemaSamples query for user %s with uid %s ",
+			userInfo.Username, userInfo.UID)
+		return fmt.Errorf("RBAC clause is required! None found for searchSchemaSamples query")
+	}
+
+	// The cluster is a column, add it to the data so it's sampled like the other properties.
+	// The LIMIT (AUTOCOMPLETE_SCAN_LIMIT) bounds the resources scanned, same as the schema query.
+	resourcesDs := goqu.From(goqu.S("search").Table("resources")).
+		Select(goqu.L(`"data" || jsonb_build_object('cluster', "cluster")`).As("data")).
+		Where(whereDs).
+		Limit(uint(config.Cfg.AutocompleteScanLimit)).
+		As("resources")
+
+	valueExp := goqu.L(`kv.value #>> '{}'`)
+	samplesDs := goqu.From(resourcesDs, goqu.L(`jsonb_each("resources"."data") AS kv(key, value)`)).
+		Select(goqu.L("kv.key").As("prop"), valueExp.As("value"),
+			goqu.L("row_number() OVER (PARTITION BY kv.key ORDER BY COUNT(*) DESC, ? ASC)", valueExp).As("rank")).
+		Where(goqu.L("jsonb_typeof(kv.value) IN ('string', 'number', 'boolean')")).
+		GroupBy(goqu.L("kv.key"), valueExp).
+		As("samples")
+
+	sql, params, err := goqu.From(samplesDs).
+		Select("prop", "value").
+		Where(goqu.C("rank").Lte(s.sampleLimit())).
+		Order(goqu.C("prop").Asc(), goqu.C("rank").Asc()).
+		ToSQL()
+	if err != nil {
+		klog.Errorf("Error building SearchSchemaSamples query: %s", err.Error())
+		return err
+	}
+	s.query = sql
+	s.params = params
+	klog.V(5).Info("SearchSchemaSamples Query: ", s.query)
+	return nil
+}
+
+func (s *SearchSchemaSamples) searchSchemaSamplesResults(ctx context.Context) (map[string]interface{}, error) {
+	klog.V(2).Info("Resolving searchSchemaSamplesResults()")
+	samples := map[string]interface{}{}
+
+	// Use the cached samples, the query scans and groups all the properties of the resources.
+	if cached, found := searchSchemaCache.get(s.query); found {
+		klog.V(5).Info("Using search schema samples from cache.")
+		return cached.(map[string]interface{}), nil
+	}
+
+	rows, err := query(ctx, s.pool, s.query, s.params...)
+	if err != nil {
+		klog.Error("Error fetching search schema samples from db ", err)
+		return samples, err
+	}
+	defer rows.Close()
+
+	values := map[string][]string{}
+	for rows.Next() {
+		var prop, value string
+		if scanErr := rows.Scan(&prop, &value); scanErr != nil {
+			klog.Error("Error reading searchSchemaSamplesResults ", scanErr)
+			continue
+		}
+		// Skip properties that start with _ because those are used internally and aren't intended to be exposed.
+		// Also skip the properties hidden with PROPERTY_DENYLIST.
+		if strings.HasPrefix(prop, "_") || isDeniedProperty(prop) {
+			continue
+		}
+		values[prop] = append(values[prop], value)
+	}
+	if err = rows.Err(); err != nil {
+		klog.Error("Error reading searchSchemaSamplesResults ", err)
+		return samples, err
+	}
+	for prop, propValues := range values {
+		samples[prop] = propValues
+	}
+	searchSchemaCache.set(s.query, samples)
+	return samples, nil
+}
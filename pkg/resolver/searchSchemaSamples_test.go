@@ -0,0 +1,108 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/golang/mock/gomock"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMockSearchSchemaSamples(t *testing.T, ud rbac.UserData, limit *int) (*SearchSchemaSamples,
+	*pgxpoolmock.MockPgxPool) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	searchSchemaCache = &schemaCache{entries: map[string]schemaCacheEntry{}}
+	return &SearchSchemaSamples{pool: mockPool, limit: limit, userData: ud}, mockPool
+}
+
+func Test_SearchSchemaSamples_Query(t *testing.T) {
+	resolver, _ := newMockSearchSchemaSamples(t, rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+
+	err := resolver.buildSearchSchemaSamplesQuery(context.TODO())
+
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT "prop", "value" FROM (SELECT kv.key AS "prop", kv.value #>> '{}' AS "value", row_number() OVER (PARTITION BY kv.key ORDER BY COUNT(*) DESC, kv.value #>> '{}' ASC) AS "rank" FROM (SELECT "data" || jsonb_build_object('cluster', "cluster") AS "data" FROM "search"."resources" WHERE ("cluster" = ANY ('{}')) LIMIT 100000) AS "resources", jsonb_each("resources"."data") AS kv(key, value) WHERE jsonb_typeof(kv.value) IN ('string', 'number', 'boolean') GROUP BY kv.key, kv.value #>> '{}') AS "samples" WHERE ("rank" <= 5) ORDER BY "prop" ASC, "rank" ASC`, resolver.query)
+}
+
+// Should return an error when the user doesn't have RBAC data.
+func Test_SearchSchemaSamples_QueryNoRbac(t *testing.T) {
+	resolver, _ := newMockSearchSchemaSamples(t, rbac.UserData{}, nil)
+
+	err := resolver.buildSearchSchemaSamplesQuery(context.TODO())
+
+	assert.EqualError(t, err, "RBAC clause is required! None found for searchSchemaSamples query")
+	assert.Equal(t, "", resolver.query)
+}
+
+// Should use the requested limit, without exceeding SCHEMA_SAMPLE_LIMIT.
+func Test_SearchSchemaSamples_Limit(t *testing.T) {
+	defer func(limit int) { config.Cfg.SchemaSampleLimit = limit }(config.Cfg.SchemaSampleLimit)
+	config.Cfg.SchemaSampleLimit = 5
+	three, ten, noLimit := 3, 10, -1
+	tests := []struct {
+		limit    *int
+		expected int
+	}{
+		{nil, 5},
+		{&three, 3},
+		{&ten, 5},
+		{&noLimit, 5},
+	}
+	for _, tt := range tests {
+		resolver, _ := newMockSearchSchemaSamples(t, rbac.UserData{CsResources: []rbac.Resource{}}, tt.limit)
+		assert.Equal(t, tt.expected, resolver.sampleLimit())
+
+		assert.Nil(t, resolver.buildSearchSchemaSamplesQuery(context.TODO()))
+		assert.Contains(t, resolver.query, `WHERE ("rank" <= `+strconv.Itoa(tt.expected)+`)`)
+	}
+}
+
+// Should group the sample values by property and skip the internal properties.
+func Test_SearchSchemaSamples_Results(t *testing.T) {
+	resolver, mockPool := newMockSearchSchemaSamples(t, rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	mockRows := &MockRows{
+		mockData: []map[string]interface{}{
+			{"prop": "cluster", "value": "local-cluster"},
+			{"prop": "kind", "value": "Pod"},
+			{"prop": "kind", "value": "ConfigMap"},
+			{"prop": "_hubClusterResource", "value": "true"},
+			{"prop": "status", "value": "Running"},
+		},
+		columnHeaders: []string{"prop", "value"},
+	}
+	assert.Nil(t, resolver.buildSearchSchemaSamplesQuery(context.TODO()))
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq(resolver.query)).Return(mockRows, nil)
+
+	result, err := resolver.searchSchemaSamplesResults(context.TODO())
+
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"cluster": []string{"local-cluster"},
+		"kind":    []string{"Pod", "ConfigMap"},
+		"status":  []string{"Running"},
+	}, result)
+}
+
+// Should use the cached samples for the same query.
+func Test_SearchSchemaSamples_Cache(t *testing.T) {
+	resolver, mockPool := newMockSearchSchemaSamples(t, rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+	mockRows := &MockRows{
+		mockData:      []map[string]interface{}{{"prop": "kind", "value": "Pod"}},
+		columnHeaders: []string{"prop", "value"},
+	}
+	assert.Nil(t, resolver.buildSearchSchemaSamplesQuery(context.TODO()))
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq(resolver.query)).Return(mockRows, nil).Times(1)
+
+	first, err := resolver.searchSchemaSamplesResults(context.TODO())
+	assert.Nil(t, err)
+	second, err := resolver.searchSchemaSamplesResults(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, first, second)
+}
@@ -3,10 +3,13 @@ package resolver
 
 import (
 	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
 	"github.com/stolostron/search-v2-api/pkg/rbac"
 	"github.com/stretchr/testify/assert"
 )
@@ -26,10 +29,23 @@ func Test_SearchSchema_Query(t *testing.T) {
 	}
 }
 
+// Should use AUTOCOMPLETE_SCAN_LIMIT as the limit of the inner query.
+func Test_SearchSchema_QueryScanLimit(t *testing.T) {
+	defer func(limit int) { config.Cfg.AutocompleteScanLimit = limit }(config.Cfg.AutocompleteScanLimit)
+	config.Cfg.AutocompleteScanLimit = 5000
+	resolver, _ := newMockSearchSchema(t)
+	resolver.userData = rbac.UserData{CsResources: []rbac.Resource{}}
+
+	resolver.buildSearchSchemaQuery(context.TODO())
+
+	assert.Equal(t, `SELECT DISTINCT "prop" FROM (SELECT jsonb_object_keys(jsonb_strip_nulls("data")) AS "prop" FROM "search"."resources" WHERE ("cluster" = ANY ('{}')) LIMIT 5000) AS "schema"`, resolver.query)
+}
+
 func Test_SearchSchema_Results(t *testing.T) {
 	// Create a SearchSchemaResolver instance with a mock connection pool.
 	searchInput := &model.SearchInput{}
 	resolver, mockPool := newMockSearchSchema(t)
+	searchSchemaCache = &schemaCache{entries: map[string]schemaCacheEntry{}}
 	csRes, nsRes, managedClusters := newUserData()
 	resolver.userData = rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters}
 
@@ -68,3 +84,183 @@ func Test_SearchSchema_EmptyQueryNoUserData(t *testing.T) {
 	assert.Equal(t, resolver.query, "", "query should be empty as there is no rbac clause")
 
 }
+
+func Test_SearchSchema_DiscoveredProperties(t *testing.T) {
+	resolver, mockPool := newMockSearchSchema(t)
+	searchSchemaCache = &schemaCache{entries: map[string]schemaCacheEntry{}}
+	resolver.userData = rbac.UserData{CsResources: []rbac.Resource{}}
+	resolver.buildSearchSchemaQuery(context.TODO())
+
+	// Mock the database query with the keys found in the resources.
+	// MockRows scans a single string from the uid.
+	mockRows := &MockRows{mockData: []map[string]interface{}{
+		{"uid": "apiversion"}, {"uid": "cluster"}, {"uid": "_uid"}, {"uid": "kind"}, {"uid": "restarts"}}}
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq(resolver.query)).Return(mockRows, nil)
+
+	res, err := resolver.searchSchemaResults(context.TODO())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"cluster", "kind", "label", "name", "namespace", "status", "apiversion", "restarts"},
+		res["allProperties"])
+}
+
+func Test_SearchSchema_Cache(t *testing.T) {
+	defer func(ttl int) { config.Cfg.SchemaCacheTTL = ttl }(config.Cfg.SchemaCacheTTL)
+	config.Cfg.SchemaCacheTTL = 60000
+	resolver, mockPool := newMockSearchSchema(t)
+	searchSchemaCache = &schemaCache{entries: map[string]schemaCacheEntry{}}
+	resolver.userData = rbac.UserData{CsResources: []rbac.Resource{}}
+	resolver.buildSearchSchemaQuery(context.TODO())
+	expected := []string{"cluster", "kind", "label", "name", "namespace", "status", "apiversion"}
+
+	// First request queries the database.
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq(resolver.query)).
+		Return(&MockRows{mockData: []map[string]interface{}{{"uid": "apiversion"}}}, nil)
+	res, err := resolver.searchSchemaResults(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, expected, res["allProperties"])
+
+	// Next request uses the cache, the mock fails if the database is queried again.
+	res, err = resolver.searchSchemaResults(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, expected, res["allProperties"])
+
+	// Queries the database after the cache expires.
+	entry := searchSchemaCache.entries[resolver.query]
+	entry.updatedAt = time.Now().Add(-61 * time.Second)
+	searchSchemaCache.entries[resolver.query] = entry
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq(resolver.query)).
+		Return(&MockRows{mockData: []map[string]interface{}{{"uid": "restarts"}}}, nil)
+	res, err = resolver.searchSchemaResults(context.TODO())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"cluster", "kind", "label", "name", "namespace", "status", "restarts"},
+		res["allProperties"])
+}
+
+func Test_SearchSchema_CacheDisabled(t *testing.T) {
+	defer func(ttl int) { config.Cfg.SchemaCacheTTL = ttl }(config.Cfg.SchemaCacheTTL)
+	config.Cfg.SchemaCacheTTL = 0
+	resolver, mockPool := newMockSearchSchema(t)
+	searchSchemaCache = &schemaCache{entries: map[string]schemaCacheEntry{}}
+	resolver.userData = rbac.UserData{CsResources: []rbac.Resource{}}
+	resolver.buildSearchSchemaQuery(context.TODO())
+
+	// Each request queries the database.
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq(resolver.query)).
+		Return(&MockRows{mockData: []map[string]interface{}{}}, nil)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq(resolver.query)).
+		Return(&MockRows{mockData: []map[string]interface{}{}}, nil)
+	_, err := resolver.searchSchemaResults(context.TODO())
+	assert.Nil(t, err)
+	_, err = resolver.searchSchemaResults(context.TODO())
+	assert.Nil(t, err)
+	assert.Empty(t, searchSchemaCache.entries)
+}
+
+// The schema should only include the properties from the resources the user can access.
+func Test_SearchSchema_RBAC(t *testing.T) {
+	tests := []struct {
+		name     string
+		userData rbac.UserData
+		sql      string
+		keys     []string
+		expected []string
+	}{
+		{
+			name: "admin",
+			userData: rbac.UserData{
+				CsResources:             []rbac.Resource{{Apigroup: "*", Kind: "*"}},
+				NsResources:             map[string][]rbac.Resource{"*": {{Apigroup: "*", Kind: "*"}}},
+				ManagedClusterAllAccess: true,
+			},
+			sql:      `SELECT DISTINCT "prop" FROM (SELECT jsonb_object_keys(jsonb_strip_nulls("data")) AS "prop" FROM "search"."resources" WHERE (("cluster" != 'local-cluster') OR "data"?'_hubClusterResource') LIMIT 100000) AS "schema"`,
+			keys:     []string{"kind", "name", "namespace", "restarts", "capacity"},
+			expected: []string{"cluster", "kind", "label", "name", "namespace", "status", "restarts", "capacity"},
+		},
+		{
+			name: "namespace user",
+			userData: rbac.UserData{
+				CsResources:     []rbac.Resource{},
+				NsResources:     map[string][]rbac.Resource{"ocm": {{Apigroup: "", Kind: "pods"}}},
+				ManagedClusters: map[string]struct{}{},
+			},
+			sql:      `SELECT DISTINCT "prop" FROM (SELECT jsonb_object_keys(jsonb_strip_nulls("data")) AS "prop" FROM "search"."resources" WHERE (("cluster" = ANY ('{}')) OR ("data"?'_hubClusterResource' AND (data->'namespace'?|'{"ocm"}' AND (NOT("data"?'apigroup') AND data->'kind_plural'?'pods')))) LIMIT 100000) AS "schema"`,
+			keys:     []string{"kind", "name", "namespace", "restarts"},
+			expected: []string{"cluster", "kind", "label", "name", "namespace", "status", "restarts"},
+		},
+	}
+	for _, tt := range tests {
+		resolver, mockPool := newMockSearchSchema(t)
+		searchSchemaCache = &schemaCache{entries: map[string]schemaCacheEntry{}}
+		resolver.userData = tt.userData
+		mockData := []map[string]interface{}{}
+		for _, key := range tt.keys {
+			mockData = append(mockData, map[string]interface{}{"uid": key})
+		}
+		mockPool.EXPECT().Query(gomock.Any(), gomock.Eq(tt.sql)).Return(&MockRows{mockData: mockData}, nil)
+
+		resolver.buildSearchSchemaQuery(context.TODO())
+		res, err := resolver.searchSchemaResults(context.TODO())
+
+		assert.Nil(t, err, tt.name)
+		assert.Equal(t, tt.expected, res["allProperties"], tt.name)
+	}
+}
+
+// Should return the default properties without querying the database when the user doesn't have access.
+func Test_SearchSchema_NoAccess(t *testing.T) {
+	resolver, _ := newMockSearchSchema(t)
+	resolver.userData = rbac.UserData{}
+
+	resolver.buildSearchSchemaQuery(context.TODO())
+	res, err := resolver.searchSchemaResults(context.TODO())
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"cluster", "kind", "label", "name", "namespace", "status"}, res["allProperties"])
+}
+
+// Should infer the type of each property from the sampled values.
+func Test_SearchSchema_PropertyTypes(t *testing.T) {
+	resolver, mockPool := newMockSearchSchema(t)
+	searchSchemaCache = &schemaCache{entries: map[string]schemaCacheEntry{}}
+	resolver.userData = rbac.UserData{CsResources: []rbac.Resource{}}
+	resolver.propTypes = map[string]string{"label": "object", "container": "array"}
+	samples := &SearchSchemaSamples{userData: resolver.userData}
+	assert.Nil(t, samples.buildSearchSchemaSamplesQuery(context.TODO()))
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq(samples.query)).Return(&MockRows{
+		mockData: []map[string]interface{}{
+			{"prop": "restarts", "value": "1"},
+			{"prop": "restarts", "value": "3"},
+			{"prop": "created", "value": "2022-01-01T17:17:09Z"},
+			{"prop": "ready", "value": "true"},
+			{"prop": "kind", "value": "Pod"},
+		},
+		columnHeaders: []string{"prop", "value"},
+	}, nil)
+
+	result := resolver.propertySchemas(context.TODO(),
+		[]string{"restarts", "created", "ready", "kind", "label", "container", "name"})
+
+	assert.Equal(t, map[string]PropertySchema{
+		"restarts":  {Type: "number"},
+		"created":   {Type: "date"},
+		"ready":     {Type: "boolean"},
+		"kind":      {Type: "string"},
+		"label":     {Type: "object"},
+		"container": {Type: "array"},
+		"name":      {Type: "string"},
+	}, result)
+}
+
+// Should fall back to the database types when the values can't be sampled.
+func Test_SearchSchema_PropertyTypesSampleError(t *testing.T) {
+	resolver, mockPool := newMockSearchSchema(t)
+	searchSchemaCache = &schemaCache{entries: map[string]schemaCacheEntry{}}
+	resolver.userData = rbac.UserData{CsResources: []rbac.Resource{}}
+	resolver.propTypes = map[string]string{"label": "object"}
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any()).Return(nil, errors.New("sample error"))
+
+	result := resolver.propertySchemas(context.TODO(), []string{"label", "kind"})
+
+	assert.Equal(t, map[string]PropertySchema{"label": {Type: "object"}, "kind": {Type: "string"}}, result)
+}
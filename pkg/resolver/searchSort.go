@@ -0,0 +1,62 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"strings"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
+	klog "k8s.io/klog/v2"
+)
+
+// Returns the ORDER BY expressions for the sortBy input, or nil if the items aren't sorted.
+// The uid is added last, so the order is stable when the property has the same value.
+// Sample:
+//
+//	ORDER BY ("data"->'current')::numeric DESC NULLS LAST, "uid" ASC
+func (s *SearchResult) sortExpressions() ([]exp.OrderedExpression, error) {
+	sortExp, direction, err := s.sortProperty()
+	if sortExp == nil || err != nil {
+		return nil, err
+	}
+
+	// Resources without the property are returned last in both directions.
+	orderExp := sortExp.Asc().NullsLast()
+	if direction == "desc" {
+		orderExp = sortExp.Desc().NullsLast()
+	}
+	klog.V(5).Infof("Sorting search results by [%s] %s", s.input.SortBy.Property, direction)
+	return []exp.OrderedExpression{orderExp, goqu.C("uid").Asc()}, nil
+}
+
+// Returns the expression to get the value of the sortBy property and the sort direction.
+// Returns a nil expression if the items aren't sorted.
+func (s *SearchResult) sortProperty() (exp.LiteralExpression, string, error) {
+	if s.input == nil || s.input.SortBy == nil {
+		return nil, "", nil
+	}
+	property := s.input.SortBy.Property
+	propType := s.propTypes[property]
+	if property == "" || property == "managedHub" || propType == "object" || propType == "array" ||
+		isDeniedProperty(property) {
+		return nil, "", invalidInputf("property [%s] can't be used to sort the search results", property)
+	}
+
+	direction := "asc"
+	if s.input.SortBy.Direction != nil && *s.input.SortBy.Direction != "" {
+		direction = strings.ToLower(*s.input.SortBy.Direction)
+	}
+	if direction != "asc" && direction != "desc" {
+		return nil, "", invalidInputf("invalid sort direction [%s], use asc or desc", *s.input.SortBy.Direction)
+	}
+
+	switch {
+	case property == "cluster":
+		return goqu.L("?", goqu.C(property)), direction, nil
+	case propType == "number":
+		return goqu.L(`("data"->?)::numeric`, property), direction, nil
+	default:
+		// Dates are formatted as RFC3339 UTC strings, so the text follows the chronological order.
+		return goqu.L(`"data"->>?`, property), direction, nil
+	}
+}
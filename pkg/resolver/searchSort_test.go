@@ -0,0 +1,115 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/golang/mock/gomock"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_sortExpressions(t *testing.T) {
+	asc, desc, upper, invalid := "asc", "desc", "DESC", "up"
+	tests := []struct {
+		name      string
+		property  string
+		direction *string
+		expected  string
+	}{
+		{"default direction", "name", nil, `SELECT * ORDER BY "data"->>'name' ASC NULLS LAST, "uid" ASC`},
+		{"string asc", "name", &asc, `SELECT * ORDER BY "data"->>'name' ASC NULLS LAST, "uid" ASC`},
+		{"string desc", "name", &desc, `SELECT * ORDER BY "data"->>'name' DESC NULLS LAST, "uid" ASC`},
+		{"direction ignores case", "name", &upper, `SELECT * ORDER BY "data"->>'name' DESC NULLS LAST, "uid" ASC`},
+		{"number asc", "current", &asc, `SELECT * ORDER BY ("data"->'current')::numeric ASC NULLS LAST, "uid" ASC`},
+		{"number desc", "current", &desc, `SELECT * ORDER BY ("data"->'current')::numeric DESC NULLS LAST, "uid" ASC`},
+		{"date asc", "created", &asc, `SELECT * ORDER BY "data"->>'created' ASC NULLS LAST, "uid" ASC`},
+		{"date desc", "created", &desc, `SELECT * ORDER BY "data"->>'created' DESC NULLS LAST, "uid" ASC`},
+		{"cluster", "cluster", &desc, `SELECT * ORDER BY "cluster" DESC NULLS LAST, "uid" ASC`},
+		{"invalid direction", "name", &invalid, ""},
+		{"managedHub", "managedHub", &asc, ""},
+		{"labels", "label", &asc, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &SearchResult{
+				input: &model.SearchInput{SortBy: &model.SearchSort{Property: test.property, Direction: test.direction}},
+				propTypes: map[string]string{"name": "string", "current": "number", "created": "string",
+					"cluster": "string", "label": "object"},
+			}
+			orderExp, err := s.sortExpressions()
+			if test.expected == "" {
+				assert.NotNil(t, err)
+				assert.Nil(t, orderExp)
+				return
+			}
+			assert.Nil(t, err)
+			sql, _, _ := goqu.From().Select(goqu.Star()).Order(orderExp...).ToSQL()
+			assert.Equal(t, test.expected, sql)
+		})
+	}
+}
+
+func Test_sortExpressions_NoSort(t *testing.T) {
+	s := &SearchResult{input: &model.SearchInput{}}
+	orderExp, err := s.sortExpressions()
+	assert.Nil(t, err)
+	assert.Nil(t, orderExp)
+}
+
+func Test_SearchResolver_ItemsSorted(t *testing.T) {
+	// Create a SearchResolver instance with a mock connection pool.
+	val1 := "Pod"
+	direction := "desc"
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val1}}},
+		SortBy: &model.SearchSort{Property: "restarts", Direction: &direction}}
+	propTypesMock := map[string]string{"kind": "string", "restarts": "number"}
+	ud := rbac.UserData{CsResources: []rbac.Resource{}}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, propTypesMock)
+
+	// Mock the database query. The items are sorted and limited in the outer query.
+	mockRows := newMockRows("./mocks/mock.json")
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT "uid", "cluster", "data" FROM (SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND ("cluster" = ANY ('{}')))) AS "items" ORDER BY ("data"->'restarts')::numeric DESC NULLS LAST, "uid" ASC LIMIT 1000`),
+		gomock.Eq([]interface{}{}),
+	).Return(mockRows, nil)
+
+	result, err := resolver.Items()
+	assert.Nil(t, err)
+	assert.Equal(t, len(mockRows.mockData), len(result))
+}
+
+func Test_SearchResolver_CountIgnoresSort(t *testing.T) {
+	// Create a SearchResolver instance with a mock connection pool.
+	val1 := "Pod"
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val1}}},
+		SortBy: &model.SearchSort{Property: "name"}}
+	ud := rbac.UserData{CsResources: []rbac.Resource{}}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string"})
+
+	mockRow := &Row{MockValue: 1}
+	mockPool.EXPECT().QueryRow(gomock.Any(),
+		gomock.Eq(`SELECT COUNT("uid") FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND ("cluster" = ANY ('{}')))`),
+		gomock.Eq([]interface{}{})).Return(mockRow)
+
+	r, err := resolver.Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, mockRow.MockValue, r)
+}
+
+func Test_SearchResolver_ItemsInvalidSort(t *testing.T) {
+	val1 := "Pod"
+	direction := "sideways"
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val1}}},
+		SortBy: &model.SearchSort{Property: "name", Direction: &direction}}
+	ud := rbac.UserData{CsResources: []rbac.Resource{}}
+	resolver, _ := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string"})
+
+	result, err := resolver.Items()
+	assert.NotNil(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, "", resolver.query)
+}
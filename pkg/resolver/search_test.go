@@ -3,16 +3,29 @@ package resolver
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/doug-martin/goqu/v9"
+	"github.com/doug-martin/goqu/v9/exp"
 	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stolostron/search-v2-api/graph/model"
 	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
 	"github.com/stolostron/search-v2-api/pkg/rbac"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 func Test_SearchResolver_Count(t *testing.T) {
@@ -30,7 +43,7 @@ func Test_SearchResolver_Count(t *testing.T) {
 		gomock.Eq([]interface{}{})).Return(mockRow)
 
 	// Execute function
-	r, err := resolver.Count()
+	r, err := resolver.Count(context.Background())
 	assert.Nil(t, err)
 
 	// Verify response
@@ -56,7 +69,7 @@ func Test_SearchResolver_MatchManagedHubCount(t *testing.T) {
 		gomock.Eq([]interface{}{})).Return(mockRow)
 
 	// Execute function
-	r, err := resolver.Count()
+	r, err := resolver.Count(context.Background())
 	assert.Nil(t, err)
 
 	// Verify response
@@ -76,7 +89,7 @@ func Test_SearchResolver_NotMatchManagedHub(t *testing.T) {
 	resolver, _ := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}}, propTypesMock)
 
 	// Execute function
-	r, err := resolver.Count()
+	r, err := resolver.Count(context.Background())
 	assert.Nil(t, err)
 
 	// Verify response
@@ -109,7 +122,7 @@ func Test_SearchResolver_Count_WithRBAC(t *testing.T) {
 		gomock.Eq([]interface{}{})).Return(mockRow)
 
 	// Execute function
-	r, err := resolver.Count()
+	r, err := resolver.Count(context.Background())
 	assert.Nil(t, err)
 
 	// Verify response
@@ -118,6 +131,126 @@ func Test_SearchResolver_Count_WithRBAC(t *testing.T) {
 	}
 }
 
+// The searchCount query only runs the count query, with the filters and the RBAC clause.
+func Test_SearchCount_FiltersAndRBAC(t *testing.T) {
+	csRes, nsRes, managedClusters := newUserData()
+	tests := []struct {
+		name     string
+		input    *model.SearchInput
+		userData rbac.UserData
+		count    int
+		expected string
+	}{
+		{"filter in managed clusters", &model.SearchInput{Clusters: []string{"managed1"},
+			Filters: []*model.SearchFilter{{Property: "kind", Values: stringArrayToPointer([]string{"Pod"})}}},
+			rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters}, 3,
+			`SELECT COUNT("uid") FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND ("cluster" = 'managed1') AND ("cluster" = ANY ('{"managed1"}')))`},
+		{"keyword and filter", &model.SearchInput{Keywords: stringArrayToPointer([]string{"nginx"}),
+			Filters: []*model.SearchFilter{{Property: "namespace", Values: stringArrayToPointer([]string{"default", "ocm"})}}},
+			rbac.UserData{CsResources: []rbac.Resource{}, ManagedClusters: managedClusters}, 5,
+			`SELECT COUNT("uid") FROM "search"."resources" WHERE (EXISTS((SELECT 1 FROM jsonb_each_text("data") WHERE ("value" ILIKE '%nginx%'))) AND "data"->'namespace'?|'{"default","ocm"}' AND ("cluster" = ANY ('{"managed1","managed2"}')))`},
+		{"user without access", &model.SearchInput{
+			Filters: []*model.SearchFilter{{Property: "kind", Values: stringArrayToPointer([]string{"Pod"})}}},
+			rbac.UserData{CsResources: []rbac.Resource{}}, 0,
+			`SELECT COUNT("uid") FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND ("cluster" = ANY ('{}')))`},
+		{"include and exclude clusters", &model.SearchInput{Clusters: []string{"managed1", "managed2", "managed3"},
+			ExcludeClusters: []string{"managed2"},
+			Filters:         []*model.SearchFilter{{Property: "kind", Values: stringArrayToPointer([]string{"Pod"})}}},
+			rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters}, 2,
+			`SELECT COUNT("uid") FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND ("cluster" IN ('managed1', 'managed2', 'managed3')) AND ("cluster" != 'managed2') AND ("cluster" = ANY ('{"managed1"}')))`},
+		{"exclude all authorized clusters", &model.SearchInput{Clusters: []string{"managed1", "managed3"},
+			ExcludeClusters: []string{"managed1"},
+			Filters:         []*model.SearchFilter{{Property: "kind", Values: stringArrayToPointer([]string{"Pod"})}}},
+			rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters}, 0,
+			`SELECT COUNT("uid") FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND ("cluster" IN ('managed1', 'managed3')) AND ("cluster" != 'managed1') AND ("cluster" = ANY ('{}')))`},
+		{"exclude clusters with access to all clusters", &model.SearchInput{
+			ExcludeClusters: []string{"local-cluster", "managed3"},
+			Filters:         []*model.SearchFilter{{Property: "kind", Values: stringArrayToPointer([]string{"Pod"})}}},
+			rbac.UserData{CsResources: []rbac.Resource{{Apigroup: "*", Kind: "*"}},
+				NsResources:             map[string][]rbac.Resource{"*": {{Apigroup: "*", Kind: "*"}}},
+				ManagedClusterAllAccess: true}, 7,
+			`SELECT COUNT("uid") FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND ("cluster" NOT IN ('local-cluster', 'managed3')) AND ("cluster" != 'local-cluster'))`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resolver, mockPool := newMockSearchResolver(t, test.input, nil, test.userData,
+				map[string]string{"kind": "string", "namespace": "string"})
+			mockPool.EXPECT().QueryRow(gomock.Any(), gomock.Eq(test.expected), gomock.Eq([]interface{}{})).
+				Return(&Row{MockValue: test.count})
+
+			count, err := resolver.Count(context.Background())
+
+			assert.Nil(t, err)
+			assert.Equal(t, test.count, count)
+		})
+	}
+}
+
+// Should return the same uids as the items query, without selecting the data column.
+func Test_SearchUIDs_SameAsItems(t *testing.T) {
+	csRes, nsRes, managedClusters := newUserData()
+	ud := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters}
+	searchInput := &model.SearchInput{
+		Filters: []*model.SearchFilter{{Property: "kind", Values: stringArrayToPointer([]string{"template"})}}}
+	propTypesMock := map[string]string{"kind": "string"}
+	where := `WHERE (("data"->>'kind' ILIKE ANY ('{"template"}')) AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))) LIMIT 1000`
+
+	mockRows := func() *MockRows {
+		return &MockRows{mockData: []map[string]interface{}{
+			{"uid": "local-cluster/uid-1", "cluster": "local-cluster", "data": map[string]interface{}{"kind": "Template"}},
+			{"uid": "managed1/uid-2", "cluster": "managed1", "data": map[string]interface{}{"kind": "Template"}},
+		}, columnHeaders: []string{"uid", "cluster", "data"}}
+	}
+
+	itemsResolver, itemsPool := newMockSearchResolver(t, searchInput, nil, ud, propTypesMock)
+	itemsPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" `+where),
+		gomock.Eq([]interface{}{}),
+	).Return(mockRows(), nil)
+	items, err := itemsResolver.Items()
+	assert.Nil(t, err)
+
+	uidsResolver, uidsPool := newMockSearchResolver(t, searchInput, nil, ud, propTypesMock)
+	uidsPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT "uid" FROM "search"."resources" `+where),
+		gomock.Eq([]interface{}{}),
+	).Return(mockRows(), nil)
+	uids, err := uidsResolver.uidList()
+	assert.Nil(t, err)
+
+	expected := []string{}
+	for _, item := range items {
+		expected = append(expected, item["_uid"].(string))
+	}
+	assert.Equal(t, []string{"local-cluster/uid-1", "managed1/uid-2"}, expected)
+	assert.Equal(t, expected, uids)
+}
+
+func Test_SearchResolver_Count_NegationWithRBAC(t *testing.T) {
+	csRes, nsRes, managedClusters := newUserData()
+	ud := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters}
+	// Create a SearchResolver instance with a mock connection pool.
+	val1 := "!Pod"
+	val2 := "!Service"
+	val3 := "ocm"
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "kind", Values: []*string{&val1, &val2}},
+		{Property: "namespace", Values: []*string{&val3}}}}
+	propTypesMock := map[string]string{"kind": "string", "namespace": "string"}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, propTypesMock)
+
+	// Mock the database query. The negation is added with AND to the positive filter and the RBAC clause.
+	mockRow := &Row{MockValue: 10}
+	mockPool.EXPECT().QueryRow(gomock.Any(),
+		gomock.Eq(`SELECT COUNT("uid") FROM "search"."resources" WHERE (("data"->>'kind' NOT IN ('Pod', 'Service')) AND "data"->'namespace'?('ocm') AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments'))))))`),
+		gomock.Eq([]interface{}{})).Return(mockRow)
+
+	// Execute function
+	r, err := resolver.Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, mockRow.MockValue, r)
+}
+
 func Test_SearchResolver_CountWithOperator(t *testing.T) {
 	// Create a SearchResolver instance with a mock connection pool.
 	val1 := ">=1"
@@ -133,7 +266,7 @@ func Test_SearchResolver_CountWithOperator(t *testing.T) {
 		gomock.Eq([]interface{}{})).Return(mockRow)
 
 	// Execute function
-	r, err := resolver.Count()
+	r, err := resolver.Count(context.Background())
 	assert.Nil(t, err)
 	// Verify response
 	if r != mockRow.MockValue {
@@ -152,11 +285,11 @@ func Test_SearchResolver_CountWithOperatorNum(t *testing.T) {
 	// Mock the database query
 	mockRow := &Row{MockValue: 1}
 	mockPool.EXPECT().QueryRow(gomock.Any(),
-		gomock.Eq(`SELECT COUNT("uid") FROM "search"."resources" WHERE ((("data"->'current')::numeric IN ('1')) AND ("cluster" = ANY ('{}')))`),
+		gomock.Eq(`SELECT COUNT("uid") FROM "search"."resources" WHERE ((("data"->'current')::numeric = '1') AND ("cluster" = ANY ('{}')))`),
 		gomock.Eq([]interface{}{})).Return(mockRow)
 
 	// Execute function
-	r, err := resolver.Count()
+	r, err := resolver.Count(context.Background())
 	assert.Nil(t, err)
 	// Verify response
 	if r != mockRow.MockValue {
@@ -179,7 +312,7 @@ func Test_SearchResolver_CountWithOperatorString(t *testing.T) {
 		gomock.Eq([]interface{}{})).Return(mockRow)
 
 	// Execute function
-	r, err := resolver.Count()
+	r, err := resolver.Count(context.Background())
 	assert.Nil(t, err)
 	// Verify response
 	if r != mockRow.MockValue {
@@ -262,19 +395,19 @@ func Test_SearchResolver_ItemsWithNumOperator(t *testing.T) {
 	val5 := "!4"
 	testOperatorNot := TestOperatorItem{
 		searchInput: &model.SearchInput{Filters: []*model.SearchFilter{{Property: "current", Values: []*string{&val5}}}},
-		mockQuery:   `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ((("data"->'current')::numeric NOT IN ('4')) AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))) LIMIT 1000`,
+		mockQuery:   `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ((("data"->'current')::numeric != '4') AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))) LIMIT 1000`,
 	}
 
 	val6 := "!=4"
 	testOperatorNotEqual := TestOperatorItem{
 		searchInput: &model.SearchInput{Filters: []*model.SearchFilter{{Property: "current", Values: []*string{&val6}}}},
-		mockQuery:   `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ((("data"->'current')::numeric NOT IN ('4')) AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))) LIMIT 1000`,
+		mockQuery:   `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ((("data"->'current')::numeric != '4') AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))) LIMIT 1000`,
 	}
 
 	val7 := "=3"
 	testOperatorEqual := TestOperatorItem{
 		searchInput: &model.SearchInput{Filters: []*model.SearchFilter{{Property: "current", Values: []*string{&val7}}}},
-		mockQuery:   `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ((("data"->'current')::numeric IN ('3')) AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))) LIMIT 1000`,
+		mockQuery:   `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ((("data"->'current')::numeric = '3') AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))) LIMIT 1000`,
 	}
 
 	testOperatorMultiple := TestOperatorItem{
@@ -417,7 +550,7 @@ func Test_SearchResolver_Items_Multiple_Filter(t *testing.T) {
 	// Mock the database queries.
 	mockRows := newMockRowsWithoutRBAC("./mocks/mock.json", searchInput, "string", 0)
 	mockPool.EXPECT().Query(gomock.Any(),
-		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'namespace'?|'{"openshift","openshift-monitoring"}' AND ("cluster" IN ('local-cluster')) AND ("cluster" = ANY ('{}'))) LIMIT 10`),
+		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'namespace'?|'{"openshift","openshift-monitoring"}' AND ("cluster" = 'local-cluster') AND ("cluster" = ANY ('{}'))) LIMIT 10`),
 		// gomock.Eq("SELECT uid, cluster, data FROM search.resources  WHERE lower(data->> 'namespace')=any($1) AND cluster=$2 LIMIT 10"),
 		gomock.Eq([]interface{}{}),
 	).Return(mockRows, nil)
@@ -467,7 +600,7 @@ func Test_SearchWithMultipleClusterFilter_NegativeLimit_Query(t *testing.T) {
 
 	// Mock the database query
 	mockPool.EXPECT().Query(gomock.Any(),
-		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'namespace'?('openshift') AND ("cluster" IN ('local-cluster', 'remote-1')) AND ("cluster" = ANY ('{}')))`),
+		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'namespace'?('openshift') AND ("cluster" IN ('local-cluster', 'remote-1')) AND ("cluster" = ANY ('{}'))) LIMIT 100000`),
 		gomock.Eq([]interface{}{})).Return(mockRows, nil)
 
 	// Execute function
@@ -498,6 +631,112 @@ func Test_SearchWithMultipleClusterFilter_NegativeLimit_Query(t *testing.T) {
 	}
 }
 
+func Test_SearchResolver_CountKeywords(t *testing.T) {
+	// Create a SearchResolver instance with a mock connection pool.
+	val1 := "Template"
+	limit := 10
+	searchInput := &model.SearchInput{Keywords: []*string{&val1}, Limit: &limit}
+	ud := rbac.UserData{CsResources: []rbac.Resource{}}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string"})
+
+	// Mock the database query. A resource matching the keyword in many properties is counted once, without the limit.
+	mockRow := &Row{MockValue: 12}
+	mockPool.EXPECT().QueryRow(gomock.Any(),
+		gomock.Eq(`SELECT COUNT("uid") FROM "search"."resources" WHERE (EXISTS((SELECT 1 FROM jsonb_each_text("data") WHERE ("value" ILIKE '%Template%'))) AND ("cluster" = ANY ('{}')))`),
+		gomock.Eq([]interface{}{})).Return(mockRow)
+
+	r, err := resolver.Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, mockRow.MockValue, r)
+}
+
+func Test_SearchResolver_CountConcurrentWithItems(t *testing.T) {
+	// Create a SearchResolver instance with a mock connection pool.
+	val1 := "Pod"
+	limit := 2
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val1}}},
+		Limit: &limit}
+	csRes, nsRes, managedClusters := newUserData()
+	ud := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string"})
+
+	// The count query waits for the items query, so the test only completes if both run at the same time.
+	itemsStarted := make(chan struct{})
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, query string, args ...interface{}) (*MockRows, error) {
+			close(itemsStarted)
+			return newMockRows("./mocks/mock.json"), nil
+		})
+	mockPool.EXPECT().QueryRow(gomock.Any(),
+		gomock.Eq(`SELECT COUNT("uid") FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments'))))))))`),
+		gomock.Eq([]interface{}{})).DoAndReturn(
+		func(ctx context.Context, query string, args ...interface{}) *Row {
+			select {
+			case <-itemsStarted:
+			case <-time.After(5 * time.Second):
+				t.Error("The count query didn't run concurrently with the items query.")
+			}
+			return &Row{MockValue: 25}
+		})
+
+	resolver.startCount()
+	items, err := resolver.Items()
+	assert.Nil(t, err)
+	r, err := resolver.Count(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 25, r)
+	assert.Greater(t, r, len(items))
+}
+
+// Should match the LIKE wildcards and the escape character in the keywords as is.
+func Test_SearchResolver_KeywordWildcards(t *testing.T) {
+	searchInput := &model.SearchInput{Keywords: stringArrayToPointer([]string{`50%_off\`})}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}},
+		map[string]string{"kind": "string"})
+
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE (EXISTS((SELECT 1 FROM jsonb_each_text("data") WHERE ("value" ILIKE '%50\%\_off\\%'))) AND ("cluster" = ANY ('{}'))) LIMIT 1000`),
+		gomock.Eq([]interface{}{}),
+	).Return(&MockRows{}, nil)
+
+	_, err := resolver.Items()
+	assert.Nil(t, err)
+}
+
+// Each keyword can match a different property, and all the keywords must match the resource.
+func Test_SearchResolver_MultipleKeywords(t *testing.T) {
+	limit := 10
+	searchInput := &model.SearchInput{Keywords: stringArrayToPointer([]string{"nginx", "prod"}), Limit: &limit}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}},
+		map[string]string{"kind": "string"})
+
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE (EXISTS((SELECT 1 FROM jsonb_each_text("data") WHERE ("value" ILIKE '%nginx%'))) AND EXISTS((SELECT 1 FROM jsonb_each_text("data") WHERE ("value" ILIKE '%prod%'))) AND ("cluster" = ANY ('{}'))) LIMIT 10`),
+		gomock.Eq([]interface{}{}),
+	).Return(&MockRows{}, nil)
+
+	_, err := resolver.Items()
+	assert.Nil(t, err)
+}
+
+// The keyword search only matches the resources the user is authorized to list.
+func Test_SearchResolver_KeywordsWithRBAC(t *testing.T) {
+	csRes, nsRes, managedClusters := newUserData()
+	limit := 10
+	searchInput := &model.SearchInput{Keywords: stringArrayToPointer([]string{"nginx"}), Limit: &limit}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil,
+		rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters},
+		map[string]string{"kind": "string"})
+
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE (EXISTS((SELECT 1 FROM jsonb_each_text("data") WHERE ("value" ILIKE '%nginx%'))) AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))) LIMIT 10`),
+		gomock.Eq([]interface{}{}),
+	).Return(&MockRows{}, nil)
+
+	_, err := resolver.Items()
+	assert.Nil(t, err)
+}
+
 func Test_SearchResolver_Keywords(t *testing.T) {
 	// Create a SearchResolver instance with a mock connection pool.
 	val1 := "Template"
@@ -512,7 +751,7 @@ func Test_SearchResolver_Keywords(t *testing.T) {
 	mockRows := newMockRowsWithoutRBAC("./mocks/mock.json", searchInput, "string", 0)
 
 	mockPool.EXPECT().Query(gomock.Any(),
-		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources", jsonb_each_text("data") WHERE (("value" ILIKE '%Template%') AND ("cluster" = ANY ('{}'))) LIMIT 10`),
+		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE (EXISTS((SELECT 1 FROM jsonb_each_text("data") WHERE ("value" ILIKE '%Template%'))) AND ("cluster" = ANY ('{}'))) LIMIT 10`),
 		gomock.Eq([]interface{}{}),
 	).Return(mockRows, nil)
 
@@ -575,6 +814,52 @@ func Test_SearchResolver_Uids(t *testing.T) {
 	}
 }
 
+// Should clamp the limit to MAX_QUERY_LIMIT when the client requests all results or a larger limit.
+func Test_SearchResolver_LimitCapped(t *testing.T) {
+	defer func(maxLimit int) { config.Cfg.MaxQueryLimit = maxLimit }(config.Cfg.MaxQueryLimit)
+	config.Cfg.MaxQueryLimit = 50
+
+	for _, limit := range []int{-1, 1000000000} {
+		limit := limit
+		value1 := "openshift"
+		searchInput := &model.SearchInput{
+			Filters: []*model.SearchFilter{{Property: "namespace", Values: []*string{&value1}}}, Limit: &limit}
+		resolver, mockPool := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}},
+			map[string]string{"namespace": "string"})
+
+		mockPool.EXPECT().Query(gomock.Any(),
+			gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'namespace'?('openshift') AND ("cluster" = ANY ('{}'))) LIMIT 50`),
+			gomock.Eq([]interface{}{})).Return(&MockRows{}, nil)
+
+		_, err := resolver.Items()
+		assert.Nil(t, err)
+	}
+}
+
+func Test_capLimit(t *testing.T) {
+	defer func(maxLimit int) { config.Cfg.MaxQueryLimit = maxLimit }(config.Cfg.MaxQueryLimit)
+	config.Cfg.MaxQueryLimit = 5000
+
+	assert.Equal(t, 5000, capLimit(-1))
+	assert.Equal(t, 5000, capLimit(1000000000))
+	assert.Equal(t, 10, capLimit(10))
+	assert.Equal(t, 5000, capLimit(5000))
+	assert.Equal(t, config.Cfg.QueryLimit, capLimit(0))
+	assert.Equal(t, config.Cfg.QueryLimit, capLimit(-5))
+}
+
+// The user without access to any managed cluster or hub resource gets a valid clause that matches nothing.
+func Test_buildRbacWhereClauseNoAccess(t *testing.T) {
+	ud := rbac.UserData{CsResources: []rbac.Resource{}, NsResources: map[string][]rbac.Resource{},
+		ManagedClusters: map[string]struct{}{}}
+
+	rbacCombined := buildRbacWhereClause(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"),
+		ud, getUserInfo())
+	expectedSql := `SELECT * WHERE ("cluster" = ANY ('{}'))`
+	gotSql, _, _ := goqu.Select().Where(rbacCombined).ToSQL()
+	assert.Equal(t, expectedSql, gotSql)
+}
+
 func Test_buildRbacWhereClauseCs(t *testing.T) {
 	csres, _, _ := newUserData()
 	ud := rbac.UserData{CsResources: csres}
@@ -619,6 +904,17 @@ func Test_buildRbacWhereClauseCsNsAndMc(t *testing.T) {
 	assert.Equal(t, expectedSql, gotSql)
 }
 
+// Users with access to all managed clusters match any cluster except the hub, the managed clusters list is ignored.
+func Test_buildRbacWhereClauseManagedClusterAllAccess(t *testing.T) {
+	csres, nsScopeAccess, _ := newUserData()
+	ud := rbac.UserData{CsResources: csres, NsResources: nsScopeAccess, ManagedClusterAllAccess: true}
+	rbacCombined := buildRbacWhereClause(context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456"),
+		ud, getUserInfo())
+	expectedSql := `SELECT * WHERE (("cluster" != 'local-cluster') OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))`
+	gotSql, _, _ := goqu.Select().Where(rbacCombined).ToSQL()
+	assert.Equal(t, expectedSql, gotSql)
+}
+
 func Test_SearchResolver_Items_Labels(t *testing.T) {
 	// Create a SearchResolver instance with a mock connection pool.
 	cluster := "local-cluster"
@@ -636,7 +932,7 @@ func Test_SearchResolver_Items_Labels(t *testing.T) {
 	mockRows := newMockRowsWithoutRBAC("./mocks/mock.json", searchInput, "string", limit)
 
 	mockPool.EXPECT().Query(gomock.Any(),
-		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'kind'?('Template') AND ("cluster" IN ('local-cluster')) AND "data"->'label' @> '{"samples.operator.openshift.io/managed":"true"}' AND ("cluster" = ANY ('{}'))) LIMIT 10`),
+		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'kind'?('Template') AND ("cluster" = 'local-cluster') AND "data"->'label' @> '{"samples.operator.openshift.io/managed":"true"}' AND ("cluster" = ANY ('{}'))) LIMIT 10`),
 		gomock.Eq([]interface{}{}),
 	).Return(mockRows, nil)
 
@@ -684,7 +980,7 @@ func Test_SearchResolver_Items_Container(t *testing.T) {
 	mockRows := newMockRowsWithoutRBAC("./mocks/mock.json", searchInput, "array", limit)
 
 	mockPool.EXPECT().Query(gomock.Any(),
-		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'kind'?('Template') AND ("cluster" IN ('local-cluster')) AND "data"->'container' @> '["acm-agent"]' AND ("cluster" = ANY ('{}'))) LIMIT 10`),
+		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'kind'?('Template') AND ("cluster" = 'local-cluster') AND "data"->'container' @> '["acm-agent"]' AND ("cluster" = ANY ('{}'))) LIMIT 10`),
 		gomock.Eq([]interface{}{}),
 	).Return(mockRows, nil)
 
@@ -816,7 +1112,7 @@ func Test_buildSearchQuery_EmptyQueryWithoutRbac(t *testing.T) {
 	// This should become empty after function execution
 	resolver.query = "mock Query"
 	// execute function
-	_, err := resolver.Count()
+	err := resolver.buildSearchQuery(resolver.context, true, false)
 	if !strings.Contains(err.Error(), "RBAC clause is required!") {
 		t.Errorf("Expected error %s. but got %s", "RBAC clause is required! None found for search query", err.Error())
 	}
@@ -838,7 +1134,7 @@ func Test_buildSearchQuery_EmptyQueryNoFilter(t *testing.T) {
 	// This should become empty after function execution
 	resolver.query = "mock Query"
 	// execute function
-	_, err := resolver.Count()
+	err := resolver.buildSearchQuery(resolver.context, true, false)
 	if !strings.Contains(err.Error(), "query input must contain a filter or keyword") {
 		t.Errorf("Expected error %s. but got %s", "query input must contain a filter or keyword", err.Error())
 	}
@@ -851,9 +1147,9 @@ func Test_SearchResolver_SearchUserAllAccess(t *testing.T) {
 	propTypesMock := map[string]string{"kind": "string"}
 	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val1}}}}
 	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, rbac.UserData{
-		CsResources:     []rbac.Resource{},
-		NsResources:     map[string][]rbac.Resource{},
-		ManagedClusters: map[string]struct{}{"*": {}},
+		CsResources:             []rbac.Resource{},
+		NsResources:             map[string][]rbac.Resource{},
+		ManagedClusterAllAccess: true,
 	},
 		propTypesMock)
 	// Mock the database queries.
@@ -890,7 +1186,7 @@ func Test_SearchResolver_Items_WrongLabelFormat(t *testing.T) {
 	cluster := "local-cluster"
 	val1 := "Template"
 
-	val2 := "samples.operator.openshift.io/managed"
+	val2 := "samples.operator.openshift.io/managed=true=false"
 	limit := 10
 	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val1}}, {Property: "cluster", Values: []*string{&cluster}}, {Property: "label", Values: []*string{&val2}}}, Limit: &limit}
 	ud := rbac.UserData{CsResources: []rbac.Resource{}}
@@ -903,7 +1199,7 @@ func Test_SearchResolver_Items_WrongLabelFormat(t *testing.T) {
 
 	// Execute the function
 	result, err := resolver.Items()
-	assert.Equal(t, "incorrect label format, label filters must have the format key=value", err.Error())
+	assert.Equal(t, "incorrect label format, label filters must have the format key or key=value", err.Error())
 	// Verify returned items.
 	if len(result) != len(mockRows.mockData) {
 		t.Errorf("Items() received incorrect number of items. Expected %d Got: %d", len(mockRows.mockData), len(result))
@@ -944,7 +1240,7 @@ func Test_SearchResolver_Items_MultipleLabels(t *testing.T) {
 
 	// Mock the database queries.
 	mockRows := newMockRowsWithoutRBAC("./mocks/mock.json", searchInput, "string", limit)
-	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'kind'?('Template') AND ("cluster" IN ('local-cluster')) AND EXISTS((SELECT 1 FROM jsonb_each_text("data"->'label') As kv(key, value) WHERE (((key LIKE 'samples%') AND (value LIKE 'tru%')) OR ((key LIKE 'app%') AND (value LIKE '%prometheus%'))))) AND ("cluster" = ANY ('{}'))) LIMIT 10`), gomock.Eq([]interface{}{})).Return(mockRows, nil)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'kind'?('Template') AND ("cluster" = 'local-cluster') AND EXISTS((SELECT 1 FROM jsonb_each_text("data"->'label') As kv(key, value) WHERE (((key LIKE 'samples%') AND (value LIKE 'tru%')) OR ((key LIKE 'app%') AND (value LIKE '%prometheus%'))))) AND ("cluster" = ANY ('{}'))) LIMIT 10`), gomock.Eq([]interface{}{})).Return(mockRows, nil)
 
 	// Execute the function
 	result, err := resolver.Items()
@@ -1102,7 +1398,7 @@ func TestSearchResolverArrayLabel(t *testing.T) {
 			val2:          "acm-agent-2",
 			filterProp1:   "container",
 			filterProp2:   "container",
-			expectedQuery: `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'container' @> '["acm-agent-1"]' AND ("cluster" IN ('local-cluster')) AND "data"->'container' @> '["acm-agent-2"]' AND ("cluster" = ANY ('{"test"}'))) LIMIT 10`,
+			expectedQuery: `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'container' @> '["acm-agent-1"]' AND ("cluster" = 'local-cluster') AND "data"->'container' @> '["acm-agent-2"]' AND ("cluster" = ANY ('{"test"}'))) LIMIT 10`,
 		},
 		{
 			name:          "Match 1 Arrays And Partial Match 2nd array",
@@ -1111,7 +1407,7 @@ func TestSearchResolverArrayLabel(t *testing.T) {
 			val2:          "*acm-agent-2",
 			filterProp1:   "container",
 			filterProp2:   "container",
-			expectedQuery: `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'container' @> '["acm-agent-1"]' AND ("cluster" IN ('local-cluster')) AND EXISTS((SELECT 1 FROM jsonb_array_elements_text("data"->'container') As arrayProp WHERE (arrayProp LIKE '%acm-agent-2'))) AND ("cluster" = ANY ('{"test"}'))) LIMIT 10`,
+			expectedQuery: `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'container' @> '["acm-agent-1"]' AND ("cluster" = 'local-cluster') AND EXISTS((SELECT 1 FROM jsonb_array_elements_text("data"->'container') As arrayProp WHERE (arrayProp LIKE '%acm-agent-2'))) AND ("cluster" = ANY ('{"test"}'))) LIMIT 10`,
 		},
 	}
 
@@ -1162,6 +1458,195 @@ func Test_decodePropertyTypes(t *testing.T) {
 	assert.Nil(t, err, "expected no error")
 }
 
+// A single value is compared with equality, multiple values use an IN list.
+func Test_whereClauseFilter_SingleValue(t *testing.T) {
+	propTypes := map[string]string{"name": "string", "cluster": "string", "current": "number"}
+	tests := []struct {
+		name     string
+		property string
+		values   []string
+		expected string
+	}{
+		{"cluster single value", "cluster", []string{"local-cluster"}, `SELECT * WHERE ("cluster" = 'local-cluster')`},
+		{"cluster multiple values", "cluster", []string{"local-cluster", "managed1"},
+			`SELECT * WHERE ("cluster" IN ('local-cluster', 'managed1'))`},
+		{"cluster not single value", "cluster", []string{"!local-cluster"}, `SELECT * WHERE ("cluster" != 'local-cluster')`},
+		{"cluster not multiple values", "cluster", []string{"!local-cluster", "!managed1"},
+			`SELECT * WHERE ("cluster" NOT IN ('local-cluster', 'managed1'))`},
+		{"number single value", "current", []string{"3"}, `SELECT * WHERE (("data"->'current')::numeric = '3')`},
+		{"number multiple values", "current", []string{"3", "4"},
+			`SELECT * WHERE (("data"->'current')::numeric IN ('3', '4'))`},
+		{"string single value", "name", []string{"pod1"}, `SELECT * WHERE "data"->'name'?('pod1')`},
+		{"string multiple values", "name", []string{"pod1", "pod2"}, `SELECT * WHERE "data"->'name'?|'{"pod1","pod2"}'`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := &model.SearchInput{Filters: []*model.SearchFilter{
+				{Property: test.property, Values: stringArrayToPointer(test.values)}}}
+			whereDs, _, err := WhereClauseFilter(context.Background(), input, propTypes)
+			assert.Nil(t, err)
+			sql, _, err := goqu.From().Select(goqu.Star()).Where(whereDs...).ToSQL()
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, sql)
+		})
+	}
+}
+
+func Test_whereClauseFilter_CaseInsensitive(t *testing.T) {
+	propTypes := map[string]string{"name": "string", "cluster": "string", "current": "number",
+		"label": "object", "container": "array"}
+	tests := []struct {
+		name     string
+		property string
+		value    string
+		expected string
+	}{
+		{"exact match is unchanged", "name", "MyPod", `SELECT * WHERE "data"->'name'?('MyPod')`},
+		{"match ignoring case", "name", "~MyPod", `SELECT * WHERE ("data"->>'name' ILIKE ANY ('{"MyPod"}'))`},
+		{"escape wildcards", "name", "~my_pod%", `SELECT * WHERE ("data"->>'name' ILIKE ANY ('{"my\\_pod\\%"}'))`},
+		{"not match ignoring case", "name", "!~MyPod", `SELECT * WHERE NOT(("data"->>'name' ILIKE ANY ('{"MyPod"}')))`},
+		{"partial match ignoring case", "name", "~*Pod*", `SELECT * WHERE ("data"->>'name' ILIKE '%Pod%')`},
+		{"partial not match ignoring case", "name", "!~Pod*", `SELECT * WHERE NOT(("data"->>'name' ILIKE 'Pod%'))`},
+		{"cluster exact match is unchanged", "cluster", "Local-Cluster", `SELECT * WHERE ("cluster" = 'Local-Cluster')`},
+		{"cluster match ignoring case", "cluster", "~Local-Cluster", `SELECT * WHERE ("cluster" ILIKE ANY ('{"Local-Cluster"}'))`},
+		{"number matches text", "current", "~1", `SELECT * WHERE ("data"->>'current' ILIKE ANY ('{"1"}'))`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value := test.value
+			input := &model.SearchInput{Filters: []*model.SearchFilter{{Property: test.property, Values: []*string{&value}}}}
+			whereDs, _, err := WhereClauseFilter(context.Background(), input, propTypes)
+			assert.Nil(t, err)
+			sql, _, err := goqu.From().Select(goqu.Star()).Where(whereDs...).ToSQL()
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, sql)
+		})
+	}
+
+	// Labels and arrays don't support the case-insensitive operators.
+	for _, property := range []string{"label", "container"} {
+		value := "~app=Search"
+		input := &model.SearchInput{Filters: []*model.SearchFilter{{Property: property, Values: []*string{&value}}}}
+		_, _, err := WhereClauseFilter(context.Background(), input, propTypes)
+		assert.NotNil(t, err)
+	}
+}
+
+func Test_whereClauseFilter_Negation(t *testing.T) {
+	propTypes := map[string]string{"kind": "string", "cluster": "string", "label": "object"}
+	tests := []struct {
+		name     string
+		property string
+		values   []string
+		expected string
+	}{
+		{"single value", "kind", []string{"!Pod"}, `SELECT * WHERE ("data"->>'kind' != 'Pod')`},
+		{"single value !=", "kind", []string{"!=Pod"}, `SELECT * WHERE ("data"->>'kind' != 'Pod')`},
+		{"multiple values", "kind", []string{"!Pod", "!=Service"},
+			`SELECT * WHERE ("data"->>'kind' NOT IN ('Pod', 'Service'))`},
+		{"lower case kind", "kind", []string{"!pod", "!service"},
+			`SELECT * WHERE NOT(("data"->>'kind' ILIKE ANY ('{"pod","service"}')))`},
+		{"cluster", "cluster", []string{"!local-cluster"}, `SELECT * WHERE ("cluster" != 'local-cluster')`},
+		{"multiple partial values", "kind", []string{"!Pod*", "!=Serv*"},
+			`SELECT * WHERE (NOT(("data"->>'kind' LIKE 'Pod%')) AND NOT(("data"->>'kind' LIKE 'Serv%')))`},
+		{"multiple labels", "label", []string{"!app=search", "!env=dev"},
+			`SELECT * WHERE (NOT("data"->'label' @> '{"app":"search"}') AND NOT("data"->'label' @> '{"env":"dev"}'))`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := &model.SearchInput{Filters: []*model.SearchFilter{
+				{Property: test.property, Values: stringArrayToPointer(test.values)}}}
+			whereDs, _, err := WhereClauseFilter(context.Background(), input, propTypes)
+			assert.Nil(t, err)
+			sql, _, err := goqu.From().Select(goqu.Star()).Where(whereDs...).ToSQL()
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, sql)
+		})
+	}
+}
+
+func Test_whereClauseFilter_PropertyPath(t *testing.T) {
+	propTypes := map[string]string{"name": "string"}
+	tests := []struct {
+		name     string
+		property string
+		values   []string
+		expected string
+	}{
+		{"top-level property is unchanged", "name", []string{"pod1"}, `SELECT * WHERE "data"->'name'?('pod1')`},
+		{"metadata name", "metadata.name", []string{"pod1"}, `SELECT * WHERE "data"->'name'?('pod1')`},
+		{"metadata namespace exists", "metadata.namespace", []string{":exists"},
+			`SELECT * WHERE "data"?'namespace'`},
+		{"metadata label", "metadata.labels.app", []string{"search", "console"},
+			`SELECT * WHERE "data"->'label'->'app'?|'{"search","console"}'`},
+		{"metadata label with dots", "metadata.labels.app.kubernetes.io/name", []string{"search"},
+			`SELECT * WHERE "data"->'label'->'app.kubernetes.io/name'?('search')`},
+		{"metadata label not equal", "metadata.labels.app", []string{"!search"},
+			`SELECT * WHERE ("data"->'label'->>'app' != 'search')`},
+		{"metadata label partial match", "metadata.labels.app", []string{"sea*"},
+			`SELECT * WHERE ("data"->'label'->>'app' LIKE 'sea%')`},
+		{"metadata creation time range", "metadata.creationTimestamp", []string{"2024-01-01T00:00:00Z..2024-01-02T00:00:00Z"},
+			`SELECT * WHERE (("data"->>'created')::timestamptz BETWEEN '2024-01-01T00:00:00Z' AND '2024-01-02T00:00:00Z')`},
+		{"nested path", "status.phase", []string{"Running"}, `SELECT * WHERE "data"->'status'->'phase'?('Running')`},
+		{"nested path exists", "status.phase", []string{":exists"},
+			`SELECT * WHERE ("data"->'status'->'phase' IS NOT NULL)`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := &model.SearchInput{Filters: []*model.SearchFilter{
+				{Property: test.property, Values: stringArrayToPointer(test.values)}}}
+			whereDs, _, err := WhereClauseFilter(context.Background(), input, propTypes)
+			assert.Nil(t, err)
+			sql, _, err := goqu.From().Select(goqu.Star()).Where(whereDs...).ToSQL()
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, sql)
+		})
+	}
+
+	// Paths with empty segments are rejected.
+	for _, property := range []string{"metadata..name", ".metadata", "metadata."} {
+		input := &model.SearchInput{Filters: []*model.SearchFilter{
+			{Property: property, Values: stringArrayToPointer([]string{"pod1"})}}}
+		_, _, err := WhereClauseFilter(context.Background(), input, propTypes)
+		assert.EqualError(t, err, fmt.Sprintf("invalid property path [%s]", property))
+	}
+}
+
+// Returns the value at the keys of the data, like the JSONB accessors.
+func valueAtDataPath(data map[string]interface{}, path []string) interface{} {
+	var value interface{} = data
+	for _, key := range path {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// Should find the value of the Kubernetes metadata paths in the flattened data of the resources.
+func Test_dataPath_FlattenedData(t *testing.T) {
+	mockRows := newMockRowsWithoutRBAC("./mocks/mock.json", &model.SearchInput{}, "", 0)
+	data := mockRows.mockData[0]["data"].(map[string]interface{})
+
+	tests := []struct {
+		property string
+		expected interface{}
+	}{
+		{"metadata.name", "eap-cd-starter-s2i"},
+		{"metadata.namespace", "openshift"},
+		{"metadata.creationTimestamp", "2021-07-14T10:20:37Z"},
+		{"metadata.labels.samples.operator.openshift.io/managed", "true"},
+		{"metadata.labels.missing", nil},
+		{"label.missing", nil},
+		{"kind", "Template"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, valueAtDataPath(data, dataPath(test.property)), test.property)
+	}
+}
+
 func TestMatchesManagedHubFilter(t *testing.T) {
 	type test struct {
 		name        string
@@ -1232,6 +1717,42 @@ func TestMatchesManagedHubFilter(t *testing.T) {
 			filterProp1: "managedHub",
 			expectedRes: true,
 		},
+		{
+			name:        "Match hub name ignoring case operator ~",
+			val1:        "~TEST-Hub-A",
+			filterProp1: "managedHub",
+			expectedRes: true,
+		},
+		{
+			name:        "Not hub name ignoring case operator !~",
+			val1:        "!~Test-Hub-A",
+			filterProp1: "managedHub",
+			expectedRes: false,
+		},
+		{
+			name:        "Partial Match hub name ignoring case operator ~",
+			val1:        "~*HUB-A",
+			filterProp1: "managedHub",
+			expectedRes: true,
+		},
+		{
+			name:        "Partial Match Not hub name ignoring case operator !~",
+			val1:        "!~TEST-*",
+			filterProp1: "managedHub",
+			expectedRes: false,
+		},
+		{
+			name:        "Single char match hub name operator ?",
+			val1:        "test-hub-?",
+			filterProp1: "managedHub",
+			expectedRes: true,
+		},
+		{
+			name:        "Regexp characters are matched literally",
+			val1:        "test.hub.*",
+			filterProp1: "managedHub",
+			expectedRes: false,
+		},
 		{
 			name:        "Error in pattern",
 			val1:        "=hub*(",
@@ -1265,3 +1786,789 @@ func TestMatchesManagedHubFilter(t *testing.T) {
 		})
 	}
 }
+
+func Test_isEmptyWhereClause(t *testing.T) {
+	assert.True(t, isEmptyWhereClause(nil))
+	assert.True(t, isEmptyWhereClause([]exp.Expression{goqu.Or(), goqu.And(goqu.Or())}))
+	assert.False(t, isEmptyWhereClause([]exp.Expression{goqu.C("cluster").Eq("local-cluster")}))
+	assert.False(t, isEmptyWhereClause([]exp.Expression{goqu.Or(), goqu.Or(goqu.C("cluster").Eq("local-cluster"))}))
+}
+
+// User authorized to search all the resources, the RBAC clause doesn't restrict the results.
+func allAccessUserData() rbac.UserData {
+	return rbac.UserData{
+		CsResources:             []rbac.Resource{{Apigroup: "*", Kind: "*"}},
+		NsResources:             map[string][]rbac.Resource{"*": {{Apigroup: "*", Kind: "*"}}},
+		ManagedClusterAllAccess: true,
+	}
+}
+
+func Test_hasUnrestrictedAccess(t *testing.T) {
+	csRes, nsRes, mc := newUserData()
+	assert.True(t, hasUnrestrictedAccess(allAccessUserData()))
+	assert.False(t, hasUnrestrictedAccess(rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: mc}))
+
+	allClusters := allAccessUserData()
+	allClusters.NsResources = nsRes
+	assert.False(t, hasUnrestrictedAccess(allClusters))
+
+	hubOnly := allAccessUserData()
+	hubOnly.ManagedClusters = mc
+	hubOnly.ManagedClusterAllAccess = false
+	assert.False(t, hasUnrestrictedAccess(hubOnly))
+}
+
+func Test_checkFullScan(t *testing.T) {
+	defer func() { config.Cfg.EmptyWhereAction = "warn" }()
+	whereDs := []exp.Expression{goqu.C("cluster").Eq("local-cluster")}
+	csRes, nsRes, mc := newUserData()
+	restricted := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: mc}
+	confirmed := true
+
+	for _, action := range []string{"log", "warn"} {
+		config.Cfg.EmptyWhereAction = action
+		assert.Nil(t, checkFullScan("search", nil, allAccessUserData(), nil))
+		assert.Nil(t, checkFullScan("search", whereDs, allAccessUserData(), nil))
+	}
+
+	config.Cfg.EmptyWhereAction = "confirm"
+	err := checkFullScan("search", []exp.Expression{goqu.Or()}, allAccessUserData(), &model.SearchInput{})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.Equal(t, "search query would scan all resources, set confirmFullScan to run it", err.Error())
+	assert.Nil(t, checkFullScan("search", nil, allAccessUserData(), &model.SearchInput{ConfirmFullScan: &confirmed}))
+	assert.Nil(t, checkFullScan("search", whereDs, allAccessUserData(), nil))
+	assert.Nil(t, checkFullScan("search", nil, restricted, nil))
+}
+
+// Should require confirmFullScan for a user authorized to search all resources when the filters don't restrict
+// the results, and run the query when it's confirmed.
+func Test_SearchResolver_ConfirmFullScan(t *testing.T) {
+	defer func() { config.Cfg.EmptyWhereAction = "warn" }()
+	config.Cfg.EmptyWhereAction = "confirm"
+	scope := "all"
+	searchInput := &model.SearchInput{Scope: &scope}
+	resolver, _ := newMockSearchResolver(t, searchInput, nil, allAccessUserData(), map[string]string{})
+
+	err := resolver.buildSearchQuery(context.Background(), false, false)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.Equal(t, "", resolver.query)
+
+	confirmed := true
+	searchInput.ConfirmFullScan = &confirmed
+	err = resolver.buildSearchQuery(context.Background(), false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE (("cluster" != 'local-cluster') OR "data"?'_hubClusterResource') LIMIT 1000`,
+		resolver.query)
+}
+
+// Should run the query without confirmFullScan when a filter restricts the results.
+func Test_SearchResolver_ConfirmFullScan_Filtered(t *testing.T) {
+	defer func() { config.Cfg.EmptyWhereAction = "warn" }()
+	config.Cfg.EmptyWhereAction = "confirm"
+	val1 := "Pod"
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val1}}}}
+	resolver, _ := newMockSearchResolver(t, searchInput, nil, allAccessUserData(), map[string]string{"kind": "string"})
+
+	err := resolver.buildSearchQuery(context.Background(), false, false)
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", resolver.query)
+}
+
+// Should use a single predicate for multiple namespaces and only include the authorized namespaces in the filter.
+func Test_SearchResolver_MultipleNamespaces(t *testing.T) {
+	ns1, ns2, ns3 := "default", "kube-system", "open-cluster-management"
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "namespace", Values: []*string{&ns1, &ns2, &ns3}}}}
+	csRes, nsRes, mc := newUserData()
+	resolver, _ := newMockSearchResolver(t, searchInput, nil,
+		rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: mc},
+		map[string]string{"namespace": "string"})
+
+	err := resolver.buildSearchQuery(context.Background(), false, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'namespace'?|'{"default","kube-system","open-cluster-management"}' AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND (data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services')))))) LIMIT 1000`,
+		resolver.query)
+}
+
+func Test_namespaceFilterValues(t *testing.T) {
+	ns1, ns2, ns3, partial, singleChar, notEqual := "default", "ocm", "=kube-system", "open-*", "ocm-?", "!default"
+	kind := "Pod"
+
+	// No namespace filter.
+	assert.Nil(t, namespaceFilterValues(&model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "kind", Values: []*string{&kind}}}}))
+	// Exact match values.
+	assert.Equal(t, []string{"default", "ocm", "kube-system"}, namespaceFilterValues(&model.SearchInput{
+		Filters: []*model.SearchFilter{{Property: "namespace", Values: []*string{&ns1, &ns2, &ns3}}}}))
+	// Intersection of multiple namespace filters.
+	assert.Equal(t, []string{"ocm"}, namespaceFilterValues(&model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "namespace", Values: []*string{&ns1, &ns2}}, {Property: "namespace", Values: []*string{&ns2}}}}))
+	// Partial match and operators can't be used to restrict the RBAC clause.
+	assert.Nil(t, namespaceFilterValues(&model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "namespace", Values: []*string{&ns1, &partial}}}}))
+	assert.Nil(t, namespaceFilterValues(&model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "namespace", Values: []*string{&singleChar}}}}))
+	assert.Nil(t, namespaceFilterValues(&model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "namespace", Values: stringArrayToPointer([]string{":exists"})}}}))
+	assert.Nil(t, namespaceFilterValues(&model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "namespace", Values: []*string{&notEqual}}}}))
+}
+
+func Test_restrictToNamespaces(t *testing.T) {
+	csRes, nsRes, mc := newUserData()
+	userData := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: mc}
+
+	// Without namespace filter.
+	assert.Equal(t, userData, restrictToNamespaces(userData, nil))
+
+	// Only the authorized namespaces in the filter.
+	result := restrictToNamespaces(userData, []string{"ocm", "kube-system"})
+	assert.Equal(t, map[string][]rbac.Resource{"ocm": nsRes["ocm"]}, result.NsResources)
+	assert.Equal(t, 0, len(result.CsResources))
+	assert.Equal(t, mc, result.ManagedClusters)
+
+	// User with access to all namespaces.
+	allAccess := rbac.UserData{NsResources: map[string][]rbac.Resource{"*": {{Apigroup: "*", Kind: "*"}}}}
+	assert.Equal(t, allAccess, restrictToNamespaces(allAccess, []string{"ocm"}))
+}
+
+func Test_restrictToClusters(t *testing.T) {
+	csRes, nsRes, mc := newUserData()
+	userData := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: mc}
+
+	// Without clusters.
+	assert.Equal(t, userData, restrictToClusters(userData, nil))
+
+	// Only the authorized managed clusters, without the hub resources.
+	result := restrictToClusters(userData, []string{"managed1", "managed3"})
+	assert.Equal(t, map[string]struct{}{"managed1": {}}, result.ManagedClusters)
+	assert.Equal(t, 0, len(result.CsResources))
+	assert.Equal(t, 0, len(result.NsResources))
+
+	// The hub resources are kept when the hub cluster is requested.
+	result = restrictToClusters(userData, []string{"local-cluster"})
+	assert.Equal(t, map[string]struct{}{}, result.ManagedClusters)
+	assert.Equal(t, csRes, result.CsResources)
+	assert.Equal(t, nsRes, result.NsResources)
+
+	// User with access to all managed clusters.
+	allAccess := rbac.UserData{ManagedClusterAllAccess: true}
+	result = restrictToClusters(allAccess, []string{"managed3"})
+	assert.Equal(t, map[string]struct{}{"managed3": {}}, result.ManagedClusters)
+	assert.False(t, result.ManagedClusterAllAccess)
+}
+
+func Test_excludeClusters(t *testing.T) {
+	csRes, nsRes, mc := newUserData()
+	userData := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: mc}
+
+	// Without excluded clusters.
+	assert.Equal(t, userData, excludeClusters(userData, nil))
+
+	// The excluded managed clusters are removed, the hub resources are kept.
+	result := excludeClusters(userData, []string{"managed1", "managed3"})
+	assert.Equal(t, map[string]struct{}{"managed2": {}}, result.ManagedClusters)
+	assert.Equal(t, csRes, result.CsResources)
+	assert.Equal(t, nsRes, result.NsResources)
+
+	// The hub resources are removed when the hub cluster is excluded.
+	result = excludeClusters(userData, []string{"local-cluster"})
+	assert.Equal(t, mc, result.ManagedClusters)
+	assert.Equal(t, 0, len(result.CsResources))
+	assert.Equal(t, 0, len(result.NsResources))
+
+	// User with access to all managed clusters keeps the all access, the WHERE clause excludes the clusters.
+	allAccess := rbac.UserData{ManagedClusterAllAccess: true}
+	assert.True(t, excludeClusters(allAccess, []string{"managed3"}).ManagedClusterAllAccess)
+}
+
+// Apigroups, kinds and namespaces from CRDs are escaped as SQL string literals. The JSONB ? operator and
+// the -> sequences in the values don't change the query.
+func Test_matchApigroupKind_SpecialCharacters(t *testing.T) {
+	nsResources := map[string][]rbac.Resource{
+		"it's?": {
+			{Apigroup: "it's?.example.io", Kind: "a->b'; DROP TABLE resources; --"},
+			{Apigroup: "", Kind: "kind?"},
+		},
+	}
+
+	sql, _, err := goqu.From("t").Where(matchNamespacedResources(nsResources, getUserInfo())).ToSQL()
+
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT * FROM "t" WHERE (data->'namespace'?|'{"it''s?"}' AND `+
+		`((data->'apigroup'?'it''s?.example.io' AND data->'kind_plural'?'a->b''; DROP TABLE resources; --') OR `+
+		`(NOT("data"?'apigroup') AND data->'kind_plural'?'kind?')))`, sql)
+}
+
+// The JSON values for label and array filters should be encoded, so quotes and backslashes keep them valid.
+func Test_decodeObjectAndArray_SpecialCharacters(t *testing.T) {
+	labels, err := decodeObject(false, []string{`app=my"app`, `!=path=C:\dir`, `team?=a->b`})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`={"app":"my\"app"}`, `!={"path":"C:\\dir"}`, `={"team?":"a-\u003eb"}`}, labels)
+	for _, label := range labels {
+		_, value := getOperatorFromString(label)
+		assert.True(t, json.Valid([]byte(value)), "Expected valid JSON: %s", value)
+	}
+
+	items, err := decodeArray(false, []string{`say "hi"`, `!it's`})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{`=["say \"hi\""]`, `!["it's"]`}, items)
+}
+
+// Each namespace should be in exactly one group, keyed by the namespace's resources.
+func Test_consolidateNsResources(t *testing.T) {
+	nsResources := map[string][]rbac.Resource{
+		"ns1": {{Apigroup: "", Kind: "pods"}},
+		"ns2": {{Apigroup: "", Kind: "pods"}},
+		"ns3": {{Apigroup: "apps", Kind: "deployments"}, {Apigroup: "", Kind: "pods"}},
+	}
+
+	groups, keys, err := consolidateNsResources(nsResources)
+
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, getKeys(groups), keys)
+	assert.Equal(t, 2, len(groups))
+	grouped := map[string][]rbac.Resource{}
+	for _, key := range keys {
+		resources := []rbac.Resource{}
+		assert.Nil(t, json.Unmarshal([]byte(key), &resources))
+		for _, ns := range groups[key] {
+			_, found := grouped[ns]
+			assert.False(t, found, "Namespace %s is in more than one group.", ns)
+			grouped[ns] = resources
+		}
+	}
+	assert.Equal(t, nsResources, grouped)
+}
+
+// Should record the ratio of namespace groups to namespaces after consolidating the namespaces.
+func Test_matchNamespacedResources_ConsolidationRatio(t *testing.T) {
+	metric := &dto.Metric{}
+	assert.Nil(t, metrics.RBACNamespaceConsolidationRatio.Write(metric))
+	samples, sum := metric.GetHistogram().GetSampleCount(), metric.GetHistogram().GetSampleSum()
+	nsResources := map[string][]rbac.Resource{
+		"ns1": {{Apigroup: "", Kind: "pods"}},
+		"ns2": {{Apigroup: "", Kind: "pods"}},
+		"ns3": {{Apigroup: "", Kind: "pods"}},
+		"ns4": {{Apigroup: "", Kind: "configmaps"}},
+	}
+
+	matchNamespacedResources(nsResources, getUserInfo())
+
+	assert.Nil(t, metrics.RBACNamespaceConsolidationRatio.Write(metric))
+	assert.Equal(t, samples+1, metric.GetHistogram().GetSampleCount())
+	assert.Equal(t, sum+0.5, metric.GetHistogram().GetSampleSum()) // 4 namespaces in 2 groups.
+}
+
+// Should fall back to the non-consolidated namespaces and count the fallback when consolidation fails.
+func Test_matchNamespacedResources_ConsolidationFallback(t *testing.T) {
+	defer func(unmarshal func([]byte, any) error) { unmarshalNsResources = unmarshal }(unmarshalNsResources)
+	unmarshalNsResources = func([]byte, any) error { return errors.New("unmarshal error") }
+	fallbacks := testutil.ToFloat64(metrics.RBACNamespaceConsolidationFallback)
+	_, nsResources, _ := newUserData()
+
+	sql, _, err := goqu.From("t").Where(matchNamespacedResources(nsResources, getUserInfo())).ToSQL()
+
+	assert.Nil(t, err)
+	assert.Equal(t, fallbacks+1, testutil.ToFloat64(metrics.RBACNamespaceConsolidationFallback))
+	assert.Contains(t, sql, `data->'namespace'?'ocm'`)
+	assert.Contains(t, sql, `data->'namespace'?'default'`)
+}
+
+// The requested clusters are ANDed with the RBAC clause, so the search can't widen the user's access.
+func Test_SearchResolver_Clusters(t *testing.T) {
+	tests := []struct {
+		name     string
+		clusters []string
+		expected string
+	}{
+		{"subset of the authorized clusters", []string{"managed1"},
+			`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND ("cluster" = 'managed1') AND ("cluster" = ANY ('{"managed1"}'))) LIMIT 1000`},
+		{"equal to the authorized clusters", []string{"managed1", "managed2"},
+			`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND ("cluster" IN ('managed1', 'managed2')) AND ("cluster" = ANY ('{"managed1","managed2"}'))) LIMIT 1000`},
+		{"superset of the authorized clusters", []string{"managed1", "managed2", "managed3", "local-cluster"},
+			`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND ("cluster" IN ('managed1', 'managed2', 'managed3', 'local-cluster')) AND ("cluster" = ANY ('{"managed1","managed2"}'))) LIMIT 1000`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kind := "Pod"
+			searchInput := &model.SearchInput{Clusters: test.clusters,
+				Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&kind}}}}
+			ud := rbac.UserData{CsResources: []rbac.Resource{},
+				ManagedClusters: map[string]struct{}{"managed1": {}, "managed2": {}}}
+			resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string"})
+
+			mockPool.EXPECT().Query(gomock.Any(), gomock.Eq(test.expected), gomock.Eq([]interface{}{})).
+				Return(&MockRows{}, nil)
+
+			_, err := resolver.Items()
+			assert.Nil(t, err)
+		})
+	}
+}
+
+// Should observe the duration of each search query with the query status.
+func Test_SearchResolver_QueryDurationMetric(t *testing.T) {
+	val1 := "template"
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val1}}}}
+	ud := rbac.UserData{CsResources: []rbac.Resource{}}
+	propTypesMock := map[string]string{"kind": "string"}
+	okSamples := querySampleCount(metrics.SearchQueryDuration, "ok")
+	errorSamples := querySampleCount(metrics.SearchQueryDuration, "error")
+
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, propTypesMock)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(newMockRowsWithoutRBAC("./mocks/mock.json", searchInput, "string", 0), nil)
+	_, err := resolver.Items()
+	assert.Nil(t, err)
+	assert.Equal(t, okSamples+1, querySampleCount(metrics.SearchQueryDuration, "ok"))
+
+	resolver, mockPool = newMockSearchResolver(t, searchInput, nil, ud, propTypesMock)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("query failed"))
+	_, err = resolver.Items()
+	assert.NotNil(t, err)
+	assert.Equal(t, okSamples+1, querySampleCount(metrics.SearchQueryDuration, "ok"))
+	assert.Equal(t, errorSamples+1, querySampleCount(metrics.SearchQueryDuration, "error"))
+}
+
+// Should combine the filters in a group with OR, and AND the groups with the other filters.
+func Test_whereClauseFilter_FilterGroups(t *testing.T) {
+	propTypes := map[string]string{"kind": "string", "namespace": "string", "name": "string", "cluster": "string"}
+	input := &model.SearchInput{
+		Filters: []*model.SearchFilter{{Property: "cluster", Values: stringArrayToPointer([]string{"local-cluster"})}},
+		FilterGroups: []*model.SearchFilterGroup{
+			{Filters: []*model.SearchFilter{
+				{Property: "kind", Values: stringArrayToPointer([]string{"Pod"})},
+				{Property: "namespace", Values: stringArrayToPointer([]string{"kube-system", "default"})},
+			}},
+			{Filters: []*model.SearchFilter{
+				{Property: "name", Values: stringArrayToPointer([]string{"search*"})},
+				{Property: "kind", Values: stringArrayToPointer([]string{"!Secret"})},
+			}},
+			{Filters: []*model.SearchFilter{{Property: "name", Values: []*string{}}}}, // Ignored without values.
+		},
+	}
+
+	whereDs, _, err := WhereClauseFilter(context.Background(), input, propTypes)
+	assert.Nil(t, err)
+	sql, _, err := goqu.From().Select(goqu.Star()).Where(whereDs...).ToSQL()
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT * WHERE (("cluster" = 'local-cluster') AND ("data"->'kind'?('Pod') OR "data"->'namespace'?|'{"kube-system","default"}') AND (("data"->>'name' LIKE 'search%') OR ("data"->>'kind' != 'Secret')))`, sql)
+}
+
+// Should return an error for a managedHub filter in a group.
+func Test_whereClauseFilter_FilterGroupsManagedHub(t *testing.T) {
+	input := &model.SearchInput{FilterGroups: []*model.SearchFilterGroup{{Filters: []*model.SearchFilter{
+		{Property: "kind", Values: stringArrayToPointer([]string{"Pod"})},
+		{Property: "managedHub", Values: stringArrayToPointer([]string{"hub-a"})},
+	}}}}
+
+	_, _, err := WhereClauseFilter(context.Background(), input, map[string]string{"kind": "string"})
+	assert.EqualError(t, err, "the managedHub property isn't supported in filterGroups")
+}
+
+// The RBAC clause should be ANDed with the filter groups, so a group can't match unauthorized resources.
+func Test_SearchResolver_FilterGroupsWithRBAC(t *testing.T) {
+	searchInput := &model.SearchInput{FilterGroups: []*model.SearchFilterGroup{{Filters: []*model.SearchFilter{
+		{Property: "kind", Values: stringArrayToPointer([]string{"Pod"})},
+		{Property: "namespace", Values: stringArrayToPointer([]string{"kube-system"})},
+	}}}}
+	ud := rbac.UserData{NsResources: map[string][]rbac.Resource{"ocm": {{Apigroup: "", Kind: "pods"}}}}
+	resolver, _ := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string", "namespace": "string"})
+
+	err := resolver.buildSearchQuery(context.Background(), false, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE (("data"->'kind'?('Pod') OR "data"->'namespace'?('kube-system')) AND (("cluster" = ANY ('{}')) OR ("data"?'_hubClusterResource' AND (data->'namespace'?|'{"ocm"}' AND (NOT("data"?'apigroup') AND data->'kind_plural'?'pods'))))) LIMIT 1000`, resolver.query)
+}
+
+// Should translate the glob characters (*, ?) in the values to LIKE patterns.
+func Test_whereClauseFilter_Glob(t *testing.T) {
+	propTypes := map[string]string{"name": "string"}
+	tests := []struct {
+		name     string
+		values   []string
+		expected string
+	}{
+		{"prefix", []string{"nginx-*"}, `SELECT * WHERE ("data"->>'name' LIKE 'nginx-%')`},
+		{"suffix", []string{"*-controller"}, `SELECT * WHERE ("data"->>'name' LIKE '%-controller')`},
+		{"single char", []string{"pod-?"}, `SELECT * WHERE ("data"->>'name' LIKE 'pod-_')`},
+		{"escaped literal", []string{"50%_off*"}, `SELECT * WHERE ("data"->>'name' LIKE '50\%\_off%')`},
+		{"exact match", []string{"50%_off"}, `SELECT * WHERE "data"->'name'?('50%_off')`},
+		{"multiple values", []string{"nginx-*", "redis-?", "postgres"},
+			`SELECT * WHERE ("data"->'name'?('postgres') OR ("data"->>'name' LIKE 'nginx-%') OR ("data"->>'name' LIKE 'redis-_'))`},
+		{"negation", []string{"!nginx-*", "!redis-?"},
+			`SELECT * WHERE (NOT(("data"->>'name' LIKE 'nginx-%')) AND NOT(("data"->>'name' LIKE 'redis-_')))`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := &model.SearchInput{
+				Filters: []*model.SearchFilter{{Property: "name", Values: stringArrayToPointer(test.values)}},
+			}
+			whereDs, _, err := WhereClauseFilter(context.Background(), input, propTypes)
+			assert.Nil(t, err)
+			sql, _, err := goqu.From().Select(goqu.Star()).Where(whereDs...).ToSQL()
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, sql)
+		})
+	}
+}
+
+// Should match the labels by key only or by key=value, using the JSONB operators.
+func Test_whereClauseFilter_Labels(t *testing.T) {
+	propTypes := map[string]string{"label": "object"}
+	tests := []struct {
+		name     string
+		filters  [][]string // Values of each label filter.
+		expected string
+	}{
+		{"key only", [][]string{{"app"}}, `SELECT * WHERE "data"->'label'?|'{"app"}'`},
+		{"multiple keys", [][]string{{"app", "env"}}, `SELECT * WHERE "data"->'label'?|'{"app","env"}'`},
+		{"without key", [][]string{{"!app", "!=env"}}, `SELECT * WHERE NOT("data"->'label'?|'{"app","env"}')`},
+		{"key=value", [][]string{{"app=nginx"}}, `SELECT * WHERE "data"->'label' @> '{"app":"nginx"}'`},
+		{"key or key=value", [][]string{{"app=nginx", "env"}},
+			`SELECT * WHERE ("data"->'label'?|'{"env"}' OR "data"->'label' @> '{"app":"nginx"}')`},
+		{"multiple label filters", [][]string{{"app=nginx"}, {"env"}, {"!tier=db"}},
+			`SELECT * WHERE ("data"->'label' @> '{"app":"nginx"}' AND "data"->'label'?|'{"env"}' AND NOT("data"->'label' @> '{"tier":"db"}'))`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := &model.SearchInput{}
+			for _, values := range test.filters {
+				input.Filters = append(input.Filters,
+					&model.SearchFilter{Property: "label", Values: stringArrayToPointer(values)})
+			}
+			whereDs, _, err := WhereClauseFilter(context.Background(), input, propTypes)
+			assert.Nil(t, err)
+			sql, _, err := goqu.From().Select(goqu.Star()).Where(whereDs...).ToSQL()
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, sql)
+		})
+	}
+
+	// Invalid labels, without a key or with more than one value.
+	input := &model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "label", Values: stringArrayToPointer([]string{"!", "app=nginx=true"})}}}
+	_, _, err := WhereClauseFilter(context.Background(), input, propTypes)
+	assert.EqualError(t, err, "incorrect label format, label filters must have the format key or key=value")
+}
+
+// Should match the resources with or without the property using the :exists and !:exists values.
+func Test_whereClauseFilter_Exists(t *testing.T) {
+	propTypes := map[string]string{"kind": "string", "status": "string"}
+	tests := []struct {
+		name     string
+		filters  []*model.SearchFilter
+		expected string
+	}{
+		{"exists", []*model.SearchFilter{
+			{Property: "deletionTimestamp", Values: stringArrayToPointer([]string{":exists"})}},
+			`SELECT * WHERE "data"?'deletionTimestamp'`},
+		{"not exists", []*model.SearchFilter{
+			{Property: "ownerReference", Values: stringArrayToPointer([]string{"!:exists"})}},
+			`SELECT * WHERE NOT("data"?'ownerReference')`},
+		{"cluster exists", []*model.SearchFilter{
+			{Property: "cluster", Values: stringArrayToPointer([]string{":exists"})}},
+			`SELECT * WHERE ("cluster" IS NOT NULL)`},
+		{"with a value filter", []*model.SearchFilter{
+			{Property: "kind", Values: stringArrayToPointer([]string{"Pod"})},
+			{Property: "deletionTimestamp", Values: stringArrayToPointer([]string{":exists"})}},
+			`SELECT * WHERE ("data"->'kind'?('Pod') AND "data"?'deletionTimestamp')`},
+		{"with values of the same property", []*model.SearchFilter{
+			{Property: "status", Values: stringArrayToPointer([]string{"Running", "!:exists"})}},
+			`SELECT * WHERE (NOT("data"?'status') OR "data"->'status'?('Running'))`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			whereDs, _, err := WhereClauseFilter(context.Background(), &model.SearchInput{Filters: test.filters}, propTypes)
+			assert.Nil(t, err)
+			sql, _, err := goqu.From().Select(goqu.Star()).Where(whereDs...).ToSQL()
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, sql)
+		})
+	}
+}
+
+// The exists filter should be ANDed with the RBAC clause.
+func Test_SearchResolver_ExistsWithRBAC(t *testing.T) {
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{
+		{Property: "kind", Values: stringArrayToPointer([]string{"Pod"})},
+		{Property: "ownerReference", Values: stringArrayToPointer([]string{"!:exists"})},
+	}}
+	ud := rbac.UserData{NsResources: map[string][]rbac.Resource{"ocm": {{Apigroup: "", Kind: "pods"}}}}
+	resolver, _ := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"kind": "string"})
+
+	err := resolver.buildSearchQuery(context.Background(), false, false)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->'kind'?('Pod') AND NOT("data"?'ownerReference') AND (("cluster" = ANY ('{}')) OR ("data"?'_hubClusterResource' AND (data->'namespace'?|'{"ocm"}' AND (NOT("data"?'apigroup') AND data->'kind_plural'?'pods'))))) LIMIT 1000`, resolver.query)
+}
+
+// Should compare the time ranges as timestamps, for an absolute range and the last N units of time.
+func Test_SearchResolver_TimeRange(t *testing.T) {
+	defer func(now func() time.Time) { timeNow = now }(timeNow)
+	timeNow = func() time.Time { return time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name     string
+		values   []string
+		expected string
+	}{
+		{"absolute range", []string{"2024-01-01T00:00:00Z..2024-01-02T00:00:00Z"},
+			`(("data"->>'created')::timestamptz BETWEEN '2024-01-01T00:00:00Z' AND '2024-01-02T00:00:00Z')`},
+		{"last 6 hours", []string{"6hours"},
+			`(("data"->>'created')::timestamptz BETWEEN '2024-03-10T06:00:00Z' AND '2024-03-10T12:00:00Z')`},
+		{"last 2 weeks", []string{"2 weeks"},
+			`(("data"->>'created')::timestamptz BETWEEN '2024-02-25T12:00:00Z' AND '2024-03-10T12:00:00Z')`},
+		{"range or keyword", []string{"1hour", "day"},
+			`((("data"->>'created')::timestamptz BETWEEN '2024-03-10T11:00:00Z' AND '2024-03-10T12:00:00Z') OR ("data"->>'created' > '2024-03-09T12:00:00Z'))`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			searchInput := &model.SearchInput{Filters: []*model.SearchFilter{
+				{Property: "created", Values: stringArrayToPointer(test.values)}}}
+			resolver, _ := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}},
+				map[string]string{"created": "string"})
+
+			err := resolver.buildSearchQuery(context.Background(), false, false)
+
+			assert.Nil(t, err)
+			assert.Equal(t, `SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE (`+
+				test.expected+` AND ("cluster" = ANY ('{}'))) LIMIT 1000`, resolver.query)
+		})
+	}
+}
+
+// Should compare the time range of a nested property with the path accessor.
+func Test_timeRangeExpressions_PropertyPath(t *testing.T) {
+	exps, _, err := timeRangeExpressions("status.startTime", []string{"2024-01-01T00:00:00Z..2024-01-02T00:00:00Z"})
+	assert.Nil(t, err)
+
+	sql, _, _ := goqu.From("resources").Where(exps...).ToSQL()
+	assert.Equal(t, `SELECT * FROM "resources" WHERE (("data"->'status'->>'startTime')::timestamptz `+
+		`BETWEEN '2024-01-01T00:00:00Z' AND '2024-01-02T00:00:00Z')`, sql)
+}
+
+// Should reject the time ranges with malformed timestamps.
+func Test_timeRangeExpressions_Invalid(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"2024-01-01..2024-01-02", "invalid time range [2024-01-01..2024-01-02], the range must be two RFC3339 " +
+			"timestamps. Sample: 2024-01-01T00:00:00Z..2024-01-02T00:00:00Z"},
+		{"2024-01-01T00:00:00Z..tomorrow", "invalid time range [2024-01-01T00:00:00Z..tomorrow], the range must " +
+			"be two RFC3339 timestamps. Sample: 2024-01-01T00:00:00Z..2024-01-02T00:00:00Z"},
+		{"2024-01-02T00:00:00Z..2024-01-01T00:00:00Z",
+			"invalid time range [2024-01-02T00:00:00Z..2024-01-01T00:00:00Z], the start is after the end"},
+	}
+	for _, test := range tests {
+		_, _, err := timeRangeExpressions("created", []string{test.value})
+		assert.EqualError(t, err, test.expected)
+	}
+
+	// Values that aren't time ranges are returned to match them as usual.
+	exps, remaining, err := timeRangeExpressions("name", []string{"a..b", "hour"})
+	assert.Nil(t, err)
+	assert.Empty(t, exps)
+	assert.Equal(t, []string{"a..b", "hour"}, remaining)
+}
+
+// Should match the regex values with the POSIX regex operators, without translating the glob characters.
+func Test_whereClauseFilter_Regex(t *testing.T) {
+	propTypes := map[string]string{"name": "string"}
+	tests := []struct {
+		name     string
+		property string
+		values   []string
+		expected string
+	}{
+		{"regex", "name", []string{"=~^web-[0-9]+$"}, `SELECT * WHERE "data"->>'name' ~ '^web-[0-9]+$'`},
+		{"case-insensitive regex", "name", []string{"=~*^WEB-.?"}, `SELECT * WHERE "data"->>'name' ~* '^WEB-.?'`},
+		{"cluster", "cluster", []string{"=~^prod-"}, `SELECT * WHERE "cluster" ~ '^prod-'`},
+		{"property path", "metadata.labels.app", []string{"=~^nginx"},
+			`SELECT * WHERE "data"->'label'->>'app' ~ '^nginx'`},
+		{"with other values", "name", []string{"=~^web-", "postgres"},
+			`SELECT * WHERE ("data"->>'name' ~ '^web-' OR "data"->'name'?('postgres'))`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := &model.SearchInput{
+				Filters: []*model.SearchFilter{{Property: test.property, Values: stringArrayToPointer(test.values)}},
+			}
+			whereDs, _, err := WhereClauseFilter(context.Background(), input, propTypes)
+			assert.Nil(t, err)
+			sql, _, err := goqu.From().Select(goqu.Star()).Where(whereDs...).ToSQL()
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, sql)
+		})
+	}
+}
+
+// Should return the items matching the regex. The mock matches the pattern like the database.
+func Test_SearchResolver_Regex(t *testing.T) {
+	names := []string{"web-1", "web-12", "web-a", "api-1"}
+	tests := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"^web-[0-9]+$", []string{"web-1", "web-12"}},
+		{"^db-[0-9]+$", []string{}},
+	}
+	for _, test := range tests {
+		searchInput := &model.SearchInput{
+			Filters: []*model.SearchFilter{{Property: "name", Values: stringArrayToPointer([]string{"=~" + test.pattern})}},
+		}
+		ud := rbac.UserData{CsResources: []rbac.Resource{}}
+		resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"name": "string"})
+		mockPool.EXPECT().Query(gomock.Any(),
+			gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE ("data"->>'name' ~ '`+
+				test.pattern+`' AND ("cluster" = ANY ('{}'))) LIMIT 1000`),
+			gomock.Eq([]interface{}{}),
+		).DoAndReturn(func(ctx context.Context, query string, args ...interface{}) (pgx.Rows, error) {
+			rows := &MockRows{columnHeaders: []string{"uid", "cluster", "data"}}
+			for _, name := range names {
+				if regexp.MustCompile(test.pattern).MatchString(name) {
+					rows.mockData = append(rows.mockData, map[string]interface{}{
+						"uid": "local-cluster/" + name, "cluster": "local-cluster", "data": map[string]interface{}{"name": name}})
+				}
+			}
+			return rows, nil
+		})
+
+		items, err := resolver.Items()
+
+		assert.Nil(t, err)
+		found := []string{}
+		for _, item := range items {
+			found = append(found, item["name"].(string))
+		}
+		assert.Equal(t, test.expected, found)
+	}
+}
+
+// Should reject the invalid regular expressions before sending the query to the database.
+func Test_regexExpressions_Invalid(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"=~^web-(", "invalid regular expression [^web-(]: error parsing regexp: missing closing ): `^web-(`"},
+		{`=~*(a)\1`, "invalid regular expression [(a)\\1]: error parsing regexp: invalid escape sequence: `\\1`"},
+		{"=~", "regular expression can't be empty"},
+		{"=~" + strings.Repeat("a", maxRegexLength+1), "regular expression exceeds the max length of 256 characters"},
+	}
+	for _, test := range tests {
+		_, _, err := regexExpressions("name", []string{test.value})
+		assert.EqualError(t, err, test.expected)
+	}
+
+	// Values that aren't regex values are returned to match them as usual.
+	exps, remaining, err := regexExpressions("name", []string{"~web", "web-*"})
+	assert.Nil(t, err)
+	assert.Empty(t, exps)
+	assert.Equal(t, []string{"~web", "web-*"}, remaining)
+}
+
+// Should trace building the query and the database query, with the number of rows read.
+func Test_SearchResolver_Spans(t *testing.T) {
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+
+	searchInput := &model.SearchInput{
+		Filters: []*model.SearchFilter{{Property: "kind", Values: stringArrayToPointer([]string{"Template"})}}}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}},
+		map[string]string{"kind": "string"})
+	ctx, root := otel.Tracer("test").Start(resolver.context, "request")
+	resolver.context = ctx
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(&MockRows{
+		mockData: []map[string]interface{}{
+			{"uid": "local-cluster/uid-1", "cluster": "local-cluster", "data": map[string]interface{}{"kind": "Template"}},
+			{"uid": "managed1/uid-2", "cluster": "managed1", "data": map[string]interface{}{"kind": "Template"}},
+		}, columnHeaders: []string{"uid", "cluster", "data"}}, nil)
+
+	items, err := resolver.Items()
+	root.End()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(items))
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	// The user's token review is resolved while building the RBAC clause.
+	assert.Equal(t, 4, len(spans))
+	rootID := spans["request"].SpanContext.SpanID()
+	assert.Equal(t, rootID, spans["resolver.buildSearchQuery"].Parent.SpanID())
+	assert.Equal(t, spans["resolver.buildSearchQuery"].SpanContext.SpanID(), spans["rbac.TokenReview"].Parent.SpanID())
+	assert.Equal(t, rootID, spans["db.query"].Parent.SpanID())
+	assert.Contains(t, spans["db.query"].Attributes, attribute.Int("db.rows", 2))
+}
+
+// Should limit the search to cluster-scoped or namespaced resources with the scope option.
+func Test_SearchResolver_Scope(t *testing.T) {
+	csRes, nsRes, managedClusters := newUserData()
+	ud := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters}
+	rbacClause := `(("cluster" = ANY ('{}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))`
+
+	testcases := []struct {
+		scope    *string
+		expected string
+	}{
+		{nil, `WHERE (("cluster" = 'local-cluster') AND ` + rbacClause + `)`},
+		{stringArrayToPointer([]string{"all"})[0], `WHERE (("cluster" = 'local-cluster') AND ` + rbacClause + `)`},
+		{stringArrayToPointer([]string{"cluster"})[0],
+			`WHERE (("cluster" = 'local-cluster') AND NOT("data"?'namespace') AND ` + rbacClause + `)`},
+		{stringArrayToPointer([]string{"namespace"})[0],
+			`WHERE (("cluster" = 'local-cluster') AND "data"?'namespace' AND ` + rbacClause + `)`},
+	}
+
+	for _, tc := range testcases {
+		searchInput := &model.SearchInput{Clusters: []string{"local-cluster"}, Scope: tc.scope}
+		resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{})
+		// A mix of cluster-scoped and namespaced resources.
+		mockPool.EXPECT().Query(gomock.Any(),
+			gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" `+tc.expected+` LIMIT 1000`),
+			gomock.Eq([]interface{}{}),
+		).Return(&MockRows{mockData: []map[string]interface{}{
+			{"uid": "local-cluster/node-1", "cluster": "local-cluster", "data": map[string]interface{}{"kind": "Node"}},
+			{"uid": "local-cluster/pod-1", "cluster": "local-cluster",
+				"data": map[string]interface{}{"kind": "Pod", "namespace": "default"}},
+		}, columnHeaders: []string{"uid", "cluster", "data"}}, nil)
+
+		_, err := resolver.Items()
+		assert.Nil(t, err)
+	}
+}
+
+// Should accept the scope as the only filter.
+func Test_SearchResolver_ScopeOnly(t *testing.T) {
+	csRes, nsRes, managedClusters := newUserData()
+	ud := rbac.UserData{CsResources: csRes, NsResources: nsRes, ManagedClusters: managedClusters}
+	scope := "cluster"
+	searchInput := &model.SearchInput{Scope: &scope}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{})
+	mockPool.EXPECT().Query(gomock.Any(),
+		gomock.Eq(`SELECT DISTINCT "uid", "cluster", "data" FROM "search"."resources" WHERE (NOT("data"?'namespace') AND (("cluster" = ANY ('{"managed1","managed2"}')) OR ("data"?'_hubClusterResource' AND ((NOT("data"?'namespace') AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'nodes') OR (data->'apigroup'?'storage.k8s.io' AND data->'kind_plural'?'csinodes'))) OR ((data->'namespace'?|'{"default"}' AND ((NOT("data"?'apigroup') AND data->'kind_plural'?'configmaps') OR (data->'apigroup'?'v4' AND data->'kind_plural'?'services'))) OR (data->'namespace'?|'{"ocm"}' AND ((data->'apigroup'?'v1' AND data->'kind_plural'?'pods') OR (data->'apigroup'?'v2' AND data->'kind_plural'?'deployments')))))))) LIMIT 1000`),
+		gomock.Eq([]interface{}{}),
+	).Return(&MockRows{mockData: []map[string]interface{}{
+		{"uid": "local-cluster/node-1", "cluster": "local-cluster", "data": map[string]interface{}{"kind": "Node"}},
+	}, columnHeaders: []string{"uid", "cluster", "data"}}, nil)
+
+	result, err := resolver.Items()
+
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+}
+
+// Should reject an invalid scope.
+func Test_SearchResolver_InvalidScope(t *testing.T) {
+	scope := "global"
+	searchInput := &model.SearchInput{Clusters: []string{"local-cluster"}, Scope: &scope}
+	resolver, _ := newMockSearchResolver(t, searchInput, nil, rbac.UserData{CsResources: []rbac.Resource{}}, nil)
+
+	_, err := resolver.Items()
+
+	assert.EqualError(t, err, "invalid scope [global], use cluster, namespace or all")
+}
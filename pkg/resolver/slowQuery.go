@@ -0,0 +1,239 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	db "github.com/stolostron/search-v2-api/pkg/database"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
+	"github.com/stolostron/search-v2-api/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	klog "k8s.io/klog/v2"
+)
+
+// Returned when a query exceeds QUERY_TIMEOUT.
+var ErrQueryTimeout = errors.New("the search query exceeded the timeout")
+
+// Postgres error code when the statement_timeout cancels the query.
+const queryCanceledCode = "57014"
+
+// Postgres error codes when the server closed the connection: any connection_exception (class 08),
+// admin_shutdown, crash_shutdown and cannot_connect_now.
+const connectionExceptionClass = "08"
+
+var connectionErrorCodes = map[string]bool{"57P01": true, "57P02": true, "57P03": true}
+
+// Matches param values that look like UIDs or tokens: UUIDs, OpenShift tokens, JWTs and long opaque strings.
+var sensitiveParam = regexp.MustCompile(
+	`(?i)[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}|^sha256~|^eyJ|^[a-z0-9+/_=.~-]{32,}$`)
+
+// Run the query and log it when it's slower than SLOW_LOG.
+// The query is canceled after QUERY_TIMEOUT. The timeout covers reading the rows, until these are closed.
+// The span of the query ends when the rows are closed, with the number of rows read.
+func query(ctx context.Context, pool pgxpoolmock.PgxPool, sql string, params ...interface{}) (pgx.Rows, error) {
+	ctx, span := tracing.Start(ctx, "db.query", semconv.DBSystemPostgreSQL)
+	ctx, cancel := withQueryTimeout(ctx)
+	start := time.Now()
+	rows, err := pool.Query(ctx, sql, params...)
+	if isConnectionError(ctx, err) {
+		// The pool destroys the broken connection when it's released, so the retry acquires a healthy one.
+		klog.Warning("Lost the database connection, retrying the query once. ", err)
+		rows, err = pool.Query(ctx, sql, params...)
+	}
+	logSlowQuery(start, sql, params)
+	if err != nil || rows == nil {
+		cancel()
+		err = queryError(ctx, err)
+		tracing.End(span, err)
+		return rows, err
+	}
+	return &timeoutRows{Rows: rows, ctx: ctx, cancel: cancel, span: span}, nil
+}
+
+// Same as query(), for queries returning a single row.
+func queryRow(ctx context.Context, pool pgxpoolmock.PgxPool, sql string, params ...interface{}) pgx.Row {
+	ctx, span := tracing.Start(ctx, "db.query", semconv.DBSystemPostgreSQL)
+	ctx, cancel := withQueryTimeout(ctx)
+	start := time.Now()
+	row := pool.QueryRow(ctx, sql, params...)
+	logSlowQuery(start, sql, params)
+	return &timeoutRow{Row: row, ctx: ctx, cancel: cancel, span: span,
+		retry: func() pgx.Row { return pool.QueryRow(ctx, sql, params...) }}
+}
+
+// Returns a context canceled after QUERY_TIMEOUT. The timeout is disabled when QUERY_TIMEOUT is 0.
+// The context is also canceled if a connection isn't acquired from the pool within DB_ACQUIRE_TIMEOUT.
+func withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	cancelQuery := func() {}
+	if config.Cfg.QueryTimeout > 0 {
+		ctx, cancelQuery = context.WithTimeout(ctx, time.Duration(config.Cfg.QueryTimeout)*time.Millisecond)
+	}
+	ctx, cancelAcquire := db.WithAcquireTimeout(ctx)
+	return ctx, func() {
+		cancelAcquire()
+		cancelQuery()
+	}
+}
+
+// Replace the error with a clear message when the query exceeded QUERY_TIMEOUT, either canceled by
+// the client context or by the Postgres statement_timeout.
+func queryError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if db.AcquireTimedOut(ctx) {
+		klog.Warningf("Query canceled, a database connection wasn't acquired within %dms. %s",
+			config.Cfg.DBAcquireTimeout, err)
+		metrics.DBPoolExhausted.Inc()
+		return db.ErrPoolExhausted
+	}
+	var pgErr *pgconn.PgError
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) ||
+		(errors.As(err, &pgErr) && pgErr.Code == queryCanceledCode) {
+		klog.Warningf("Query canceled after exceeding the timeout of %dms. %s", config.Cfg.QueryTimeout, err)
+		return fmt.Errorf("%w after %dms", ErrQueryTimeout, config.Cfg.QueryTimeout)
+	}
+	return err
+}
+
+// Returns the HTTP status for a query error. The server busy error returns 503, so clients can back off.
+// An invalid input returns 400.
+func errorStatus(err error) int {
+	if errors.Is(err, db.ErrPoolExhausted) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, ErrInvalidInput) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// Checks if the query failed because the database connection was lost, for example when Postgres restarts.
+// The errors returned by the query itself aren't retried.
+func isConnectionError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, connectionExceptionClass) || connectionErrorCodes[pgErr.Code]
+	}
+	var netErr net.Error
+	return pgconn.SafeToRetry(err) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &netErr)
+}
+
+// Releases the query context and ends the span when the rows are closed.
+type timeoutRows struct {
+	pgx.Rows
+	ctx    context.Context
+	cancel context.CancelFunc
+	span   trace.Span
+	count  int // Number of rows read.
+	closed bool
+}
+
+func (r *timeoutRows) Next() bool {
+	next := r.Rows.Next()
+	if next {
+		r.count++
+	}
+	return next
+}
+
+func (r *timeoutRows) Close() {
+	r.Rows.Close()
+	r.cancel()
+	if !r.closed {
+		r.closed = true
+		r.span.SetAttributes(attribute.Int("db.rows", r.count))
+		tracing.End(r.span, r.Err())
+	}
+}
+
+func (r *timeoutRows) Err() error {
+	if err := r.Rows.Err(); err != nil {
+		return queryError(r.ctx, err)
+	}
+	return nil
+}
+
+// Releases the query context after the row is scanned.
+type timeoutRow struct {
+	pgx.Row
+	ctx    context.Context
+	cancel context.CancelFunc
+	retry  func() pgx.Row // Runs the query again when the connection was lost.
+	span   trace.Span
+}
+
+func (r *timeoutRow) Scan(dest ...interface{}) error {
+	defer r.cancel()
+	err := r.Row.Scan(dest...)
+	if isConnectionError(r.ctx, err) && r.retry != nil {
+		klog.Warning("Lost the database connection, retrying the query once. ", err)
+		err = r.retry().Scan(dest...)
+	}
+	err = queryError(r.ctx, err)
+	tracing.End(r.span, err)
+	return err
+}
+
+// Log the redacted SQL and params if the query took longer than SLOW_LOG.
+func logSlowQuery(start time.Time, sql string, params []interface{}) {
+	elapsed := time.Since(start)
+	if elapsed <= time.Duration(config.Cfg.SlowLog)*time.Millisecond {
+		return
+	}
+	klog.V(1).Infof("Slow query took %s. Query: [%s] Params: %v", elapsed, redactSQL(sql), redactParams(params))
+}
+
+// Matches the string literals in the SQL. The quotes are escaped by doubling them.
+var sqlStringLiteral = regexp.MustCompile(`'(?:[^']|'')*'`)
+
+// Replace the string literals in the SQL, which hold the values inlined by goqu, like the uids and filter values.
+// The literals following the JSON operators (->, ->>, #> and #>>) are property names, these are kept.
+// Sample: "data"->'name'?('my-app') => "data"->'name'?('[REDACTED]')
+func redactSQL(sql string) string {
+	var sb strings.Builder
+	last := 0
+	for _, match := range sqlStringLiteral.FindAllStringIndex(sql, -1) {
+		sb.WriteString(sql[last:match[0]])
+		before := strings.TrimRight(sql[:match[0]], " ")
+		if strings.HasSuffix(before, "->") || strings.HasSuffix(before, "->>") || strings.HasSuffix(before, "#>") ||
+			strings.HasSuffix(before, "#>>") {
+			sb.WriteString(sql[match[0]:match[1]])
+		} else {
+			sb.WriteString("'[REDACTED]'")
+		}
+		last = match[1]
+	}
+	sb.WriteString(sql[last:])
+	return sb.String()
+}
+
+// Replace the param values that look like UIDs or tokens, so these aren't written to the logs.
+func redactParams(params []interface{}) []interface{} {
+	redacted := make([]interface{}, len(params))
+	for i, param := range params {
+		redacted[i] = param
+		if value, ok := param.(string); ok && sensitiveParam.MatchString(value) {
+			redacted[i] = "[REDACTED]"
+		}
+	}
+	return redacted
+}
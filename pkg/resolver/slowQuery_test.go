@@ -0,0 +1,389 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/driftprogramming/pgxpoolmock"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	db "github.com/stolostron/search-v2-api/pkg/database"
+	"github.com/stolostron/search-v2-api/pkg/metrics"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stretchr/testify/assert"
+	klog "k8s.io/klog/v2"
+)
+
+// Capture the klog output with verbosity 1.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	var buf bytes.Buffer
+	klog.LogToStderr(false)
+	klog.SetOutput(&buf)
+	flags := flag.NewFlagSet("klog", flag.ContinueOnError)
+	klog.InitFlags(flags)
+	_ = flags.Set("v", "1")
+	t.Cleanup(func() {
+		_ = flags.Set("v", "0")
+		klog.SetOutput(os.Stderr)
+		klog.LogToStderr(true)
+	})
+	return &buf
+}
+
+// Should log the SQL and the redacted params when the query is slower than SLOW_LOG.
+func Test_query_SlowLog(t *testing.T) {
+	defer func(slowLog int) { config.Cfg.SlowLog = slowLog }(config.Cfg.SlowLog)
+	config.Cfg.SlowLog = 1
+	buf := captureLogs(t)
+
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq("SELECT slow"), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, sql string, params ...interface{}) (pgx.Rows, error) {
+			time.Sleep(5 * time.Millisecond)
+			return &MockRows{}, nil
+		})
+
+	_, err := query(context.Background(), mockPool, "SELECT slow", "Pod",
+		"local-cluster/0b5a2f4e-8d8b-4c52-9f8a-1c2d3e4f5a6b")
+	klog.Flush()
+
+	assert.Nil(t, err)
+	assert.Contains(t, buf.String(), "Slow query took")
+	assert.Contains(t, buf.String(), "Query: [SELECT slow] Params: [Pod [REDACTED]]")
+	assert.NotContains(t, buf.String(), "0b5a2f4e-8d8b-4c52-9f8a-1c2d3e4f5a6b")
+}
+
+// Should redact the values inlined in the SQL of the slow queries, keeping the property names.
+func Test_query_SlowLogRedactsSQL(t *testing.T) {
+	defer func(slowLog int) { config.Cfg.SlowLog = slowLog }(config.Cfg.SlowLog)
+	config.Cfg.SlowLog = 1
+	buf := captureLogs(t)
+	ctx := context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456")
+	ud := rbac.UserData{CsResources: []rbac.Resource{}, ManagedClusters: map[string]struct{}{"prod-east": {}}}
+	slowQuery := func(ctx context.Context, sql string, params ...interface{}) (pgx.Rows, error) {
+		time.Sleep(5 * time.Millisecond)
+		return &MockRows{}, nil
+	}
+
+	// getResource
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(slowQuery)
+	resource := &GetResourceResult{pool: mockPool, uid: "local-cluster/0b5a2f4e-8d8b-4c52-9f8a-1c2d3e4f5a6b",
+		userData: ud}
+	_, err := resource.resource(ctx)
+	assert.Nil(t, err)
+
+	// search with filters
+	name := "payroll-db"
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "name", Values: []*string{&name}}}}
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, map[string]string{"name": "string"})
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(slowQuery)
+	_, err = resolver.Items()
+	assert.Nil(t, err)
+	klog.Flush()
+
+	logs := buf.String()
+	assert.Contains(t, logs, `WHERE (("uid" = '[REDACTED]') AND ("cluster" = ANY ('[REDACTED]'))) LIMIT 1]`)
+	assert.Contains(t, logs, `WHERE ("data"->'name'?('[REDACTED]') AND ("cluster" = ANY ('[REDACTED]')))`)
+	assert.NotContains(t, logs, "0b5a2f4e-8d8b-4c52-9f8a-1c2d3e4f5a6b")
+	assert.NotContains(t, logs, "payroll-db")
+	assert.NotContains(t, logs, "prod-east")
+}
+
+// Should not log the queries faster than SLOW_LOG.
+func Test_query_NotSlow(t *testing.T) {
+	defer func(slowLog int) { config.Cfg.SlowLog = slowLog }(config.Cfg.SlowLog)
+	config.Cfg.SlowLog = 60000
+	buf := captureLogs(t)
+
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq("SELECT fast")).Return(&MockRows{}, nil)
+
+	_, err := query(context.Background(), mockPool, "SELECT fast")
+	klog.Flush()
+
+	assert.Nil(t, err)
+	assert.NotContains(t, buf.String(), "Slow query")
+}
+
+// Should cancel the query and return a timeout error when the query exceeds QUERY_TIMEOUT.
+func Test_query_Timeout(t *testing.T) {
+	defer func(timeout int) { config.Cfg.QueryTimeout = timeout }(config.Cfg.QueryTimeout)
+	config.Cfg.QueryTimeout = 10
+
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	var queryCtx context.Context
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq("SELECT blocked")).
+		DoAndReturn(func(ctx context.Context, sql string, params ...interface{}) (pgx.Rows, error) {
+			queryCtx = ctx
+			<-ctx.Done() // Blocks until the timeout.
+			return nil, ctx.Err()
+		})
+
+	rows, err := query(context.Background(), mockPool, "SELECT blocked")
+
+	assert.Nil(t, rows)
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+	assert.Equal(t, "the search query exceeded the timeout after 10ms", err.Error())
+	assert.ErrorIs(t, queryCtx.Err(), context.DeadlineExceeded)
+}
+
+// Should return a timeout error when Postgres cancels the query with the statement_timeout.
+func Test_query_StatementTimeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq("SELECT slow")).
+		Return(nil, &pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"})
+
+	_, err := query(context.Background(), mockPool, "SELECT slow")
+
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+}
+
+// Should return the timeout error when the statement_timeout cancels the query while reading the rows.
+// pgx returns this error from rows.Err(), after the rows read before the timeout.
+func Test_resolvers_StatementTimeoutReadingRows(t *testing.T) {
+	defer func(serialization string) { config.Cfg.ItemsSerialization = serialization }(config.Cfg.ItemsSerialization)
+	timeoutErr := &pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"}
+	ctx := context.WithValue(context.Background(), rbac.ContextAuthTokenKey, "123456")
+	val := "Pod"
+	searchInput := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val}}}}
+	ud := rbac.UserData{CsResources: []rbac.Resource{}}
+	propTypes := map[string]string{"kind": "string"}
+	itemRows := func() *MockRows {
+		return &MockRows{mockData: []map[string]interface{}{
+			{"uid": "local-cluster/uid-1", "cluster": "local-cluster", "data": map[string]interface{}{"kind": "Pod"}}},
+			columnHeaders: []string{"uid", "cluster", "data"}, err: timeoutErr}
+	}
+
+	// Items
+	resolver, mockPool := newMockSearchResolver(t, searchInput, nil, ud, propTypes)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(itemRows(), nil)
+	items, err := resolver.Items()
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+	assert.Empty(t, items)
+
+	// Items encoded with the stream serialization
+	config.Cfg.ItemsSerialization = "stream"
+	resolver, mockPool = newMockSearchResolver(t, searchInput, nil, ud, propTypes)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(itemRows(), nil)
+	encoded, err := resolver.EncodedItems()
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+	assert.Empty(t, encoded)
+
+	// UIDs
+	resolver, mockPool = newMockSearchResolver(t, searchInput, nil, ud, propTypes)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(
+		&MockRows{mockData: []map[string]interface{}{{"uid": "local-cluster/uid-1"}}, err: timeoutErr}, nil)
+	_, err = resolver.uidList()
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+
+	// Autocomplete with counts
+	complete, mockPool := newMockSearchComplete(t, &model.SearchInput{}, "kind", ud, nil)
+	countRows := mockCountRows([]string{"Pod"}, []int{10})
+	countRows.err = timeoutErr
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(countRows, nil)
+	counts, err := complete.autoCompleteWithCounts(ctx)
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+	assert.Empty(t, counts)
+
+	// Drift
+	drift, mockPool := newMockSearchDrift(t, "Deployment", defaultDriftIdentity, ud)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Any(), gomock.Any()).Return(&MockRows{
+		mockData:      []map[string]interface{}{{"id0": "default", "id1": "app", "hub": true, "clusters": []string{}}},
+		columnHeaders: []string{"id0", "id1", "hub", "clusters"}, err: timeoutErr}, nil)
+	drifts, err := drift.drift(ctx)
+	assert.ErrorIs(t, err, ErrQueryTimeout)
+	assert.Empty(t, drifts)
+}
+
+// Rows holding a connection of the mock pool until these are closed.
+type poolRows struct {
+	MockRows
+	release func()
+}
+
+func (r *poolRows) Close() { r.release() }
+
+// With a pool of 1 connection, the second query should fail with the server busy error when the first query
+// holds the connection for longer than DB_ACQUIRE_TIMEOUT. The acquire timeout doesn't cancel the first query.
+func Test_query_PoolExhausted(t *testing.T) {
+	defer func(timeout int) { config.Cfg.DBAcquireTimeout = timeout }(config.Cfg.DBAcquireTimeout)
+	config.Cfg.DBAcquireTimeout = 20
+	exhaustedBefore := testutil.ToFloat64(metrics.DBPoolExhausted)
+
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	conns := make(chan struct{}, 1) // Pool sized to 1 connection.
+	var queryCtxs []context.Context
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq("SELECT blocking")).Times(3).
+		DoAndReturn(func(ctx context.Context, sql string, params ...interface{}) (pgx.Rows, error) {
+			queryCtxs = append(queryCtxs, ctx)
+			select { // Same as pgxpool, waits for a free connection until the context is canceled.
+			case conns <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			db.StopAcquireTimer(ctx) // Same as the BeforeAcquire hook.
+			return &poolRows{release: func() { <-conns }}, nil
+		})
+
+	first, err := query(context.Background(), mockPool, "SELECT blocking")
+	assert.Nil(t, err)
+
+	start := time.Now()
+	second, err := query(context.Background(), mockPool, "SELECT blocking")
+	assert.Nil(t, second)
+	assert.ErrorIs(t, err, db.ErrPoolExhausted)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, exhaustedBefore+1, testutil.ToFloat64(metrics.DBPoolExhausted))
+	assert.Nil(t, queryCtxs[0].Err(), "Expected the first query to keep running after the acquire timeout.")
+
+	// The connection is available after the first query completes.
+	first.Close()
+	third, err := query(context.Background(), mockPool, "SELECT blocking")
+	assert.Nil(t, err)
+	third.Close()
+}
+
+// Should keep the query context until the rows are closed, then release it.
+func Test_query_ReleaseContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	var queryCtx context.Context
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq("SELECT fast")).
+		DoAndReturn(func(ctx context.Context, sql string, params ...interface{}) (pgx.Rows, error) {
+			queryCtx = ctx
+			return &MockRows{}, nil
+		})
+
+	rows, err := query(context.Background(), mockPool, "SELECT fast")
+	assert.Nil(t, err)
+	assert.Nil(t, queryCtx.Err())
+
+	rows.Close()
+	assert.ErrorIs(t, queryCtx.Err(), context.Canceled)
+}
+
+// Should release the query context after the row is scanned.
+func Test_queryRow_ReleaseContext(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	var queryCtx context.Context
+	mockPool.EXPECT().QueryRow(gomock.Any(), gomock.Eq("SELECT count")).
+		DoAndReturn(func(ctx context.Context, sql string, params ...interface{}) pgx.Row {
+			queryCtx = ctx
+			return &Row{MockValue: 5}
+		})
+
+	var count int
+	err := queryRow(context.Background(), mockPool, "SELECT count").Scan(&count)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 5, count)
+	assert.ErrorIs(t, queryCtx.Err(), context.Canceled)
+}
+
+// Should retry the query once when the database connection was lost.
+func Test_query_RetryConnectionError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	gomock.InOrder(
+		mockPool.EXPECT().Query(gomock.Any(), gomock.Eq("SELECT fast")).
+			Return(nil, &pgconn.PgError{Code: "57P01", Message: "terminating connection due to administrator command"}),
+		mockPool.EXPECT().Query(gomock.Any(), gomock.Eq("SELECT fast")).Return(&MockRows{}, nil),
+	)
+
+	rows, err := query(context.Background(), mockPool, "SELECT fast")
+
+	assert.Nil(t, err)
+	assert.NotNil(t, rows)
+}
+
+// Should retry only once when the database is still unreachable.
+func Test_query_RetryConnectionErrorOnce(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq("SELECT fast")).Return(nil, io.ErrUnexpectedEOF).Times(2)
+
+	_, err := query(context.Background(), mockPool, "SELECT fast")
+
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+// Should not retry the queries failing with a SQL error.
+func Test_query_NoRetrySQLError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	mockPool.EXPECT().Query(gomock.Any(), gomock.Eq("SELECT bad")).
+		Return(nil, &pgconn.PgError{Code: "42601", Message: "syntax error"}).Times(1)
+
+	_, err := query(context.Background(), mockPool, "SELECT bad")
+
+	assert.NotNil(t, err)
+	assert.NotErrorIs(t, err, ErrQueryTimeout)
+}
+
+// Should run the query again when the row scan fails because the database connection was lost.
+func Test_queryRow_RetryConnectionError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	gomock.InOrder(
+		mockPool.EXPECT().QueryRow(gomock.Any(), gomock.Eq("SELECT count")).
+			Return(&Row{MockError: &pgconn.PgError{Code: "08006", Message: "connection failure"}}),
+		mockPool.EXPECT().QueryRow(gomock.Any(), gomock.Eq("SELECT count")).Return(&Row{MockValue: 5}),
+	)
+
+	var count int
+	err := queryRow(context.Background(), mockPool, "SELECT count").Scan(&count)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 5, count)
+}
+
+func Test_isConnectionError(t *testing.T) {
+	ctx := context.Background()
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	assert.True(t, isConnectionError(ctx, &pgconn.PgError{Code: "08006"}))
+	assert.True(t, isConnectionError(ctx, &pgconn.PgError{Code: "57P03"}))
+	assert.True(t, isConnectionError(ctx, io.EOF))
+	assert.True(t, isConnectionError(ctx, &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}))
+	assert.False(t, isConnectionError(ctx, nil))
+	assert.False(t, isConnectionError(ctx, &pgconn.PgError{Code: "57014"}))
+	assert.False(t, isConnectionError(ctx, &pgconn.PgError{Code: "42P01"}))
+	assert.False(t, isConnectionError(ctx, pgx.ErrNoRows))
+	assert.False(t, isConnectionError(canceledCtx, io.EOF))
+}
+
+func Test_redactSQL(t *testing.T) {
+	assert.Equal(t, `SELECT "data"->>'name' FROM "search"."resources" WHERE ("data"#>>'{"metadata","labels"}' `+
+		`LIKE '[REDACTED]' AND "data"?'[REDACTED]') LIMIT 10`,
+		redactSQL(`SELECT "data"->>'name' FROM "search"."resources" WHERE ("data"#>>'{"metadata","labels"}' `+
+			`LIKE 'it''s %' AND "data"?'deletionTimestamp') LIMIT 10`))
+}
+
+func Test_redactParams(t *testing.T) {
+	params := []interface{}{"Pod", 10, "sha256~abcdef", "eyJhbGciOiJSUzI1NiJ9.payload",
+		"4f9c2a7e1b3d5f6a8c0e2b4d6f8a1c3e5b7d9f0a", "0b5a2f4e-8d8b-4c52-9f8a-1c2d3e4f5a6b", "open-cluster-management"}
+
+	assert.Equal(t, []interface{}{"Pod", 10, "[REDACTED]", "[REDACTED]", "[REDACTED]", "[REDACTED]",
+		"open-cluster-management"}, redactParams(params))
+}
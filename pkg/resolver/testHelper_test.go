@@ -16,12 +16,23 @@ import (
 	"github.com/golang/mock/gomock"
 	"github.com/jackc/pgconn"
 	"github.com/jackc/pgproto3/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stolostron/search-v2-api/graph/model"
 	"github.com/stolostron/search-v2-api/pkg/rbac"
 	authv1 "k8s.io/api/authentication/v1"
 	"k8s.io/klog/v2"
 )
 
+// Returns the number of samples observed by the query duration histogram with the status.
+func querySampleCount(histogram *prometheus.HistogramVec, status string) uint64 {
+	metric := &dto.Metric{}
+	if err := histogram.WithLabelValues(status).(prometheus.Metric).Write(metric); err != nil {
+		panic(err)
+	}
+	return metric.GetHistogram().GetSampleCount()
+}
+
 func newUserData() ([]rbac.Resource, map[string][]rbac.Resource, map[string]struct{}) {
 	csres := []rbac.Resource{{Apigroup: "", Kind: "nodes"}, {Apigroup: "storage.k8s.io", Kind: "csinodes"}}
 	nsres1 := []rbac.Resource{{Apigroup: "v1", Kind: "pods"}, {Apigroup: "v2", Kind: "deployments"}}
@@ -60,6 +71,7 @@ func newMockSearchComplete(t *testing.T, input *model.SearchInput, property stri
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 	mockPool := pgxpoolmock.NewMockPgxPool(ctrl)
+	searchCompleteCache = newAutocompleteCache() // Don't use the results cached by other tests.
 	mockResolver := &SearchCompleteResult{
 		input:     input,
 		pool:      mockPool,
@@ -86,9 +98,13 @@ func newMockSearchSchema(t *testing.T) (*SearchSchema, *pgxpoolmock.MockPgxPool)
 // ====================================================
 type Row struct {
 	MockValue int
+	MockError error
 }
 
 func (r *Row) Scan(dest ...interface{}) error {
+	if r.MockError != nil {
+		return r.MockError
+	}
 	*dest[0].(*int) = r.MockValue
 	return nil
 }
@@ -381,6 +397,7 @@ type MockRows struct {
 	mockData      []map[string]interface{}
 	index         int
 	columnHeaders []string
+	err           error // Returned by Err() after reading the rows.
 }
 
 // ====================================================
@@ -392,7 +409,7 @@ type MockRows struct {
 
 func (r *MockRows) Close() {}
 
-func (r *MockRows) Err() error { return nil }
+func (r *MockRows) Err() error { return r.err }
 
 func (r *MockRows) CommandTag() pgconn.CommandTag { return nil }
 
@@ -414,8 +431,32 @@ func (r *MockRows) Scan(dest ...interface{}) error {
 				*dest[i].(*int) = int(r.mockData[r.index-1][r.columnHeaders[i]].(float64))
 			case *string:
 				*dest[i].(*string) = r.mockData[r.index-1][r.columnHeaders[i]].(string)
+			case *bool:
+				*dest[i].(*bool) = r.mockData[r.index-1][r.columnHeaders[i]].(bool)
+			case *[]string:
+				if i >= len(r.columnHeaders) { // Not all mock data sets include the array columns.
+					klog.Info("unexpected type %T", v)
+					continue
+				}
+				*dest[i].(*[]string) = r.mockData[r.index-1][r.columnHeaders[i]].([]string)
 			case *map[string]interface{}:
+				if raw, ok := r.mockData[r.index-1][r.columnHeaders[i]].([]byte); ok { // Decode like pgx does for jsonb.
+					if err := json.Unmarshal(raw, dest[i]); err != nil {
+						return err
+					}
+					continue
+				}
 				*dest[i].(*map[string]interface{}) = r.mockData[r.index-1][r.columnHeaders[i]].(map[string]interface{})
+			case *[]byte:
+				if raw, ok := r.mockData[r.index-1][r.columnHeaders[i]].([]byte); ok {
+					*dest[i].(*[]byte) = raw
+					continue
+				}
+				raw, err := json.Marshal(r.mockData[r.index-1][r.columnHeaders[i]])
+				if err != nil {
+					return err
+				}
+				*dest[i].(*[]byte) = raw
 			case *interface{}:
 				dest[i] = r.mockData[r.index-1][r.columnHeaders[i]]
 			case nil:
@@ -430,7 +471,7 @@ func (r *MockRows) Scan(dest ...interface{}) error {
 		_, ok := r.mockData[r.index-1]["prop"] //Check if prop is present in mockdata
 
 		if ok {
-			*dest[0].(*interface{}) = r.mockData[r.index-1]["prop"].(string)
+			*dest[0].(*interface{}) = r.mockData[r.index-1]["prop"]
 
 		} else {
 			_, ok := r.mockData[r.index-1]["propArray"]
@@ -0,0 +1,15 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	klog "k8s.io/klog/v2"
+)
+
+// Returns the sorted names of the managed clusters the user can access, from the user's cached data.
+func UserManagedClusters(ctx context.Context) ([]string, error) {
+	klog.V(2).Info("Resolving UserManagedClusters()")
+	return rbac.GetCache().GetUserManagedClusters(ctx)
+}
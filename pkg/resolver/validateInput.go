@@ -0,0 +1,125 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/stolostron/search-v2-api/graph/model"
+)
+
+// Returned when the search input is malformed. The message lists the invalid fields, so it's returned to the client.
+var ErrInvalidInput = errors.New("invalid search input")
+
+// Validates the search input before building the queries, so malformed inputs fail fast with a precise error.
+// Checks the property names and values of the filters, including the regex patterns, the limit and the sortBy option.
+func ValidateSearchInput(input *model.SearchInput) error {
+	return invalidInputError(validateSearchInput(input, ""))
+}
+
+// Validates the inputs of the searchComplete queries. The query input is validated like a search input.
+func validateSearchCompleteInput(property string, input *model.SearchInput, limit *int) error {
+	problems := []string{}
+	if strings.TrimSpace(property) == "" {
+		problems = append(problems, "property can't be empty")
+	}
+	if limit != nil && *limit < -1 {
+		problems = append(problems, fmt.Sprintf("limit must be greater than or equal to -1, got %d", *limit))
+	}
+	problems = append(problems, validateSearchInput(input, "query.")...)
+	return invalidInputError(problems)
+}
+
+// Validates the query input of the searchFacets query like a search input.
+func validateSearchFacetsInput(input *model.SearchInput) error {
+	return invalidInputError(validateSearchInput(input, "query."))
+}
+
+// Returns the problems found in the input. The field names are prefixed to locate them in the request.
+func validateSearchInput(input *model.SearchInput, prefix string) []string {
+	problems := []string{}
+	if input == nil {
+		return problems
+	}
+	problems = append(problems, validateFilters(input.Filters, prefix+"filters")...)
+	for i, group := range input.FilterGroups {
+		if group != nil {
+			problems = append(problems, validateFilters(group.Filters, fmt.Sprintf("%sfilterGroups[%d].filters", prefix, i))...)
+		}
+	}
+	if input.Limit != nil && *input.Limit < -1 {
+		problems = append(problems,
+			fmt.Sprintf("%slimit must be greater than or equal to -1, got %d", prefix, *input.Limit))
+	}
+	if input.SortBy != nil {
+		if strings.TrimSpace(input.SortBy.Property) == "" {
+			problems = append(problems, prefix+"sortBy.property can't be empty")
+		}
+		if d := input.SortBy.Direction; d != nil && *d != "" && !strings.EqualFold(*d, "asc") &&
+			!strings.EqualFold(*d, "desc") {
+			problems = append(problems, fmt.Sprintf("%ssortBy.direction must be asc or desc, got %q", prefix, *d))
+		}
+	}
+	for i, field := range input.Fields {
+		if strings.TrimSpace(field) == "" {
+			problems = append(problems, fmt.Sprintf("%sfields[%d] can't be empty", prefix, i))
+		}
+	}
+	return problems
+}
+
+// Checks that each filter has a property name and values, and that the regular expressions compile.
+func validateFilters(filters []*model.SearchFilter, name string) []string {
+	problems := []string{}
+	for i, filter := range filters {
+		if filter == nil {
+			continue
+		}
+		if strings.TrimSpace(filter.Property) == "" {
+			problems = append(problems, fmt.Sprintf("%s[%d].property can't be empty", name, i))
+		}
+		if len(filter.Values) == 0 {
+			problems = append(problems, fmt.Sprintf("%s[%d].values can't be empty", name, i))
+		}
+		for j, value := range filter.Values {
+			if value == nil {
+				problems = append(problems, fmt.Sprintf("%s[%d].values[%d] can't be null", name, i, j))
+				continue
+			}
+			if operator, pattern := getOperatorFromString(*value); operator == regexOperator ||
+				operator == regexFoldOperator {
+				if err := checkRegex(pattern); err != nil {
+					problems = append(problems, fmt.Sprintf("%s[%d].values[%d] %s", name, i, j, err))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// Error for an invalid value of the input found while building the query. The message is kept as is,
+// and the error matches ErrInvalidInput, so the message is returned to the client.
+type inputError struct {
+	err error
+}
+
+func (e *inputError) Error() string {
+	return e.err.Error()
+}
+
+func (e *inputError) Unwrap() []error {
+	return []error{ErrInvalidInput, e.err}
+}
+
+// Formats an error for an invalid value of the input. Supports %w to wrap another error.
+func invalidInputf(format string, args ...interface{}) error {
+	return &inputError{err: fmt.Errorf(format, args...)}
+}
+
+func invalidInputError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidInput, strings.Join(problems, "; "))
+}
@@ -0,0 +1,120 @@
+// Copyright Contributors to the Open Cluster Management project
+package resolver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ValidateSearchInput(t *testing.T) {
+	val := "Pod"
+	limit := func(l int) *int { return &l }
+	direction := func(d string) *string { return &d }
+	validFilter := &model.SearchFilter{Property: "kind", Values: []*string{&val}}
+
+	tests := []struct {
+		name     string
+		input    *model.SearchInput
+		expected string
+	}{
+		{"valid input", &model.SearchInput{Filters: []*model.SearchFilter{validFilter}, Limit: limit(-1),
+			SortBy: &model.SearchSort{Property: "name", Direction: direction("DESC")}, Fields: []string{"name"}}, ""},
+		{"nil input", nil, ""},
+		{"empty property", &model.SearchInput{Filters: []*model.SearchFilter{validFilter,
+			{Property: " ", Values: []*string{&val}}}},
+			"invalid search input: filters[1].property can't be empty"},
+		{"empty values", &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{}}}},
+			"invalid search input: filters[0].values can't be empty"},
+		{"null value", &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{&val, nil}}}},
+			"invalid search input: filters[0].values[1] can't be null"},
+		{"filter group", &model.SearchInput{FilterGroups: []*model.SearchFilterGroup{
+			{Filters: []*model.SearchFilter{validFilter}}, {Filters: []*model.SearchFilter{{Property: "", Values: nil}}}}},
+			"invalid search input: filterGroups[1].filters[0].property can't be empty; filterGroups[1].filters[0].values can't be empty"},
+		{"negative limit", &model.SearchInput{Filters: []*model.SearchFilter{validFilter}, Limit: limit(-2)},
+			"invalid search input: limit must be greater than or equal to -1, got -2"},
+		{"empty sort property", &model.SearchInput{Filters: []*model.SearchFilter{validFilter},
+			SortBy: &model.SearchSort{Property: ""}},
+			"invalid search input: sortBy.property can't be empty"},
+		{"invalid sort direction", &model.SearchInput{Filters: []*model.SearchFilter{validFilter},
+			SortBy: &model.SearchSort{Property: "name", Direction: direction("up")}},
+			`invalid search input: sortBy.direction must be asc or desc, got "up"`},
+		{"invalid regex", &model.SearchInput{Filters: []*model.SearchFilter{{Property: "name",
+			Values: stringArrayToPointer([]string{"=~^web-[0-9]+$", "=~*web-("})}}},
+			"invalid search input: filters[0].values[1] invalid regular expression [web-(]: " +
+				"error parsing regexp: missing closing ): `web-(`"},
+		{"empty field", &model.SearchInput{Filters: []*model.SearchFilter{validFilter}, Fields: []string{"name", ""}},
+			"invalid search input: fields[1] can't be empty"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateSearchInput(test.input)
+			if test.expected == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, ErrInvalidInput), "Expected ErrInvalidInput. Got: %v", err)
+			assert.EqualError(t, err, test.expected)
+		})
+	}
+}
+
+func Test_validateSearchCompleteInput(t *testing.T) {
+	limit := -5
+	input := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "kind", Values: []*string{}}}}
+
+	err := validateSearchCompleteInput("", input, &limit)
+
+	assert.True(t, errors.Is(err, ErrInvalidInput), "Expected ErrInvalidInput. Got: %v", err)
+	assert.EqualError(t, err, "invalid search input: property can't be empty; limit must be greater than or equal to -1, "+
+		"got -5; query.filters[0].values can't be empty")
+	assert.Nil(t, validateSearchCompleteInput("kind", nil, nil))
+}
+
+// Should reject the invalid input before resolving the user's access or querying the database.
+func Test_Search_InvalidInput(t *testing.T) {
+	input := &model.SearchInput{Filters: []*model.SearchFilter{{Property: "", Values: []*string{}}}}
+
+	_, err := Search(context.Background(), []*model.SearchInput{input})
+	assert.True(t, errors.Is(err, ErrInvalidInput), "Expected ErrInvalidInput. Got: %v", err)
+
+	_, err = SearchComplete(context.Background(), "kind", input, nil, nil, nil)
+	assert.True(t, errors.Is(err, ErrInvalidInput), "Expected ErrInvalidInput. Got: %v", err)
+}
+
+// The errors found while building the query for an invalid input should match ErrInvalidInput, keeping the message.
+func Test_invalidInputErrors(t *testing.T) {
+	direction := "up"
+	cursor := "bad-cursor"
+	tests := []struct {
+		name     string
+		err      func() error
+		expected string
+	}{
+		{"cursor", func() error { _, err := decodeCursor(cursor); return err }, "invalid cursor [bad-cursor]"},
+		{"sort property", func() error {
+			_, _, err := (&SearchResult{input: &model.SearchInput{SortBy: &model.SearchSort{Property: "managedHub"}}}).
+				sortProperty()
+			return err
+		}, "property [managedHub] can't be used to sort the search results"},
+		{"sort direction", func() error {
+			_, _, err := (&SearchResult{input: &model.SearchInput{
+				SortBy: &model.SearchSort{Property: "name", Direction: &direction}}}).sortProperty()
+			return err
+		}, "invalid sort direction [up], use asc or desc"},
+		{"field", func() error {
+			_, err := (&SearchResult{input: &model.SearchInput{Fields: []string{"metadata.name"}}}).dataFields()
+			return err
+		}, "invalid field [metadata.name], use the name of a property"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.err()
+			assert.True(t, errors.Is(err, ErrInvalidInput), "Expected ErrInvalidInput. Got: %v", err)
+			assert.EqualError(t, err, test.expected)
+		})
+	}
+}
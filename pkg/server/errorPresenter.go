@@ -0,0 +1,97 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/database"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stolostron/search-v2-api/pkg/resolver"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"k8s.io/klog/v2"
+)
+
+const correlationIdKey = "correlationId"
+
+// Extension with the error code for the errors that clients can handle, like backing off when the server is busy.
+const codeKey = "code"
+const serverBusyCode = "SERVER_BUSY"
+const badUserInputCode = "BAD_USER_INPUT"
+const queryTimeoutCode = "QUERY_TIMEOUT"
+
+// Presents the errors returned by the resolvers in the GraphQL response.
+// Each error gets a correlation ID, which is logged with the full error detail.
+// Unless VERBOSE_ERRORS is enabled, the message of the internal errors is replaced with a generic message
+// so details like SQL fragments aren't leaked to the client.
+func errorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	correlationId := newCorrelationId()
+	klog.Errorf("Error resolving GraphQL request. correlationId: %s path: %s error: %+v",
+		correlationId, gqlErr.Path.String(), err)
+
+	presented := &gqlerror.Error{
+		Message:    gqlErr.Message,
+		Path:       gqlErr.Path,
+		Locations:  gqlErr.Locations,
+		Extensions: map[string]interface{}{},
+	}
+	if config.Cfg.VerboseErrors || !isInternalError(gqlErr) {
+		for key, value := range gqlErr.Extensions {
+			presented.Extensions[key] = value
+		}
+	} else {
+		presented.Message = fmt.Sprintf("Error resolving the request. Use the correlationId %s to find the "+
+			"details in the search-api logs.", correlationId)
+	}
+	// The server busy error doesn't have internal details, keep the message so clients know to back off.
+	if errors.Is(err, database.ErrPoolExhausted) {
+		presented.Message = database.ErrPoolExhausted.Error()
+		presented.Extensions[codeKey] = serverBusyCode
+	}
+	// The invalid input errors describe the invalid values of the request, keep the message so clients can fix it.
+	if errors.Is(err, resolver.ErrInvalidInput) {
+		presented.Extensions[codeKey] = badUserInputCode
+	}
+	// The timeout error only has the configured timeout, keep the message so clients can narrow the query.
+	if errors.Is(err, resolver.ErrQueryTimeout) {
+		presented.Extensions[codeKey] = queryTimeoutCode
+	}
+	presented.Extensions[correlationIdKey] = correlationId
+	return presented
+}
+
+// Errors intended for the client, the message is kept even when VERBOSE_ERRORS is disabled.
+var clientErrors = []error{resolver.ErrInvalidInput, resolver.ErrQueryTimeout, rbac.ErrUserAccess}
+
+// Returns true for the errors returned by the resolvers, which can include internal details.
+// The errors created by the GraphQL handler, like the parse and validation errors, don't wrap
+// another error and are presented unchanged.
+func isInternalError(gqlErr *gqlerror.Error) bool {
+	wrapped := gqlErr.Unwrap()
+	if wrapped == nil {
+		return false
+	}
+	for _, clientErr := range clientErrors {
+		if errors.Is(wrapped, clientErr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Random identifier to correlate the error in the response with the logs.
+func newCorrelationId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		klog.Warning("Error generating correlation ID. ", err)
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,174 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stolostron/search-v2-api/graph/model"
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/database"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stolostron/search-v2-api/pkg/resolver"
+	"github.com/stretchr/testify/assert"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func Test_errorPresenter_Production(t *testing.T) {
+	config.Cfg.VerboseErrors = false
+
+	result := errorPresenter(context.Background(),
+		errors.New(`ERROR: syntax error at or near "FROM" (SQLSTATE 42601)`))
+
+	correlationId, ok := result.Extensions[correlationIdKey].(string)
+	assert.True(t, ok)
+	assert.NotEmpty(t, correlationId)
+	assert.NotContains(t, result.Message, "SQLSTATE")
+	assert.Equal(t, "Error resolving the request. Use the correlationId "+correlationId+
+		" to find the details in the search-api logs.", result.Message)
+}
+
+func Test_errorPresenter_Development(t *testing.T) {
+	config.Cfg.VerboseErrors = true
+	defer func() { config.Cfg.VerboseErrors = false }()
+
+	result := errorPresenter(context.Background(),
+		errors.New(`ERROR: syntax error at or near "FROM" (SQLSTATE 42601)`))
+
+	assert.NotEmpty(t, result.Extensions[correlationIdKey])
+	assert.Equal(t, `ERROR: syntax error at or near "FROM" (SQLSTATE 42601)`, result.Message)
+}
+
+// Each error should get a different correlation ID.
+func Test_errorPresenter_UniqueCorrelationId(t *testing.T) {
+	first := errorPresenter(context.Background(), errors.New("error"))
+	second := errorPresenter(context.Background(), errors.New("error"))
+
+	assert.NotEqual(t, first.Extensions[correlationIdKey], second.Extensions[correlationIdKey])
+}
+
+// The server busy error keeps the message and adds the error code, so clients can back off.
+func Test_errorPresenter_ServerBusy(t *testing.T) {
+	config.Cfg.VerboseErrors = false
+
+	result := errorPresenter(context.Background(), fmt.Errorf("search failed: %w", database.ErrPoolExhausted))
+
+	assert.Equal(t, database.ErrPoolExhausted.Error(), result.Message)
+	assert.Equal(t, "SERVER_BUSY", result.Extensions["code"])
+	assert.NotEmpty(t, result.Extensions[correlationIdKey])
+}
+
+func Test_errorPresenter_InvalidInput(t *testing.T) {
+	config.Cfg.VerboseErrors = false
+	err := fmt.Errorf("%w: filters[0].values can't be empty", resolver.ErrInvalidInput)
+
+	result := errorPresenter(context.Background(), err)
+
+	assert.Equal(t, "invalid search input: filters[0].values can't be empty", result.Message)
+	assert.Equal(t, "BAD_USER_INPUT", result.Extensions["code"])
+	assert.NotEmpty(t, result.Extensions[correlationIdKey])
+}
+
+// The errors found while building the query for an invalid input keep the message in production.
+func Test_errorPresenter_InvalidInputBuildingQuery(t *testing.T) {
+	config.Cfg.VerboseErrors = false
+	defer func(denylist []string, action string) {
+		config.Cfg.PropertyDenylist = denylist
+		config.Cfg.PropertyDenylistAction = action
+	}(config.Cfg.PropertyDenylist, config.Cfg.PropertyDenylistAction)
+	config.Cfg.PropertyDenylist = []string{"annotation"}
+	config.Cfg.PropertyDenylistAction = "reject"
+	scope := "pods"
+	filter := func(property string, values ...string) []*model.SearchFilter {
+		pointers := make([]*string, len(values))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		return []*model.SearchFilter{{Property: property, Values: pointers}}
+	}
+	propTypes := map[string]string{"kind": "string", "created": "string", "label": "object"}
+
+	tests := []struct {
+		name     string
+		input    *model.SearchInput
+		expected string
+	}{
+		{"scope", &model.SearchInput{Filters: filter("kind", "Pod"), Scope: &scope},
+			"invalid scope [pods], use cluster, namespace or all"},
+		{"property path", &model.SearchInput{Filters: filter("metadata..name", "app")},
+			"invalid property path [metadata..name]"},
+		{"managedHub in filterGroups", &model.SearchInput{
+			FilterGroups: []*model.SearchFilterGroup{{Filters: filter("managedHub", "hub1")}}},
+			"the managedHub property isn't supported in filterGroups"},
+		{"time range", &model.SearchInput{Filters: filter("created", "2024-01-02T00:00:00Z..2024-01-01T00:00:00Z")},
+			"invalid time range [2024-01-02T00:00:00Z..2024-01-01T00:00:00Z], the start is after the end"},
+		{"case-insensitive operator", &model.SearchInput{Filters: filter("label", "~app=web")},
+			"case-insensitive operator [~] isn't supported for object properties"},
+		{"denied property", &model.SearchInput{Filters: filter("annotation", "token")},
+			"the property can't be used in search queries: annotation"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := resolver.WhereClauseFilter(context.Background(), test.input, propTypes)
+
+			result := errorPresenter(context.Background(), err)
+
+			assert.Equal(t, test.expected, result.Message)
+			assert.Equal(t, "BAD_USER_INPUT", result.Extensions["code"])
+		})
+	}
+}
+
+// The query timeout error keeps the message in production, so clients know to narrow the query.
+func Test_errorPresenter_QueryTimeout(t *testing.T) {
+	config.Cfg.VerboseErrors = false
+	err := fmt.Errorf("%w after %dms", resolver.ErrQueryTimeout, 100)
+
+	result := errorPresenter(context.Background(), err)
+
+	assert.Equal(t, "the search query exceeded the timeout after 100ms", result.Message)
+	assert.Equal(t, "QUERY_TIMEOUT", result.Extensions["code"])
+	assert.NotEmpty(t, result.Extensions[correlationIdKey])
+}
+
+// The parse and validation errors from the GraphQL handler don't wrap an internal error, they're presented unchanged.
+func Test_errorPresenter_ValidationError(t *testing.T) {
+	config.Cfg.VerboseErrors = false
+	err := &gqlerror.Error{
+		Message:    `Cannot query field "foo" on type "Query".`,
+		Locations:  []gqlerror.Location{{Line: 1, Column: 3}},
+		Extensions: map[string]interface{}{"code": "GRAPHQL_VALIDATION_FAILED"},
+	}
+
+	result := errorPresenter(context.Background(), err)
+
+	assert.Equal(t, `Cannot query field "foo" on type "Query".`, result.Message)
+	assert.Equal(t, "GRAPHQL_VALIDATION_FAILED", result.Extensions["code"])
+	assert.Equal(t, []gqlerror.Location{{Line: 1, Column: 3}}, result.Locations)
+	assert.NotEmpty(t, result.Extensions[correlationIdKey])
+}
+
+// The errors resolving the user's access keep the message, so clients know to retry.
+func Test_errorPresenter_UserAccessError(t *testing.T) {
+	config.Cfg.VerboseErrors = false
+	err := fmt.Errorf("%w: %w", rbac.ErrUserAccess, rbac.ErrRBACUnavailable)
+
+	result := errorPresenter(context.Background(), gqlerror.WrapPath(ast.Path{ast.PathName("search")}, err))
+
+	assert.Equal(t, err.Error(), result.Message)
+}
+
+// The resolver errors wrapped by the GraphQL executor are still sanitized.
+func Test_errorPresenter_WrappedInternalError(t *testing.T) {
+	config.Cfg.VerboseErrors = false
+	err := errors.New(`ERROR: relation "search.resources" does not exist (SQLSTATE 42P01)`)
+
+	result := errorPresenter(context.Background(), gqlerror.WrapPath(ast.Path{ast.PathName("search")}, err))
+
+	assert.NotContains(t, result.Message, "SQLSTATE")
+	assert.Equal(t, "search", result.Path.String())
+}
@@ -6,17 +6,35 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/stolostron/search-v2-api/pkg/database"
+	"github.com/stolostron/search-v2-api/pkg/rbac"
 	"k8s.io/klog/v2"
 )
 
+// Checks the database is reachable. Defined as a variable so unit tests can replace it.
+var checkDatabase = database.Healthz
+
+// Checks the shared RBAC cache is loaded. Defined as a variable so unit tests can replace it.
+var checkSharedCache = func() bool { return rbac.GetCache().SharedCacheReady() }
+
 // LivenessProbe is used to check if this service is alive.
 func livenessProbe(w http.ResponseWriter, r *http.Request) {
 	klog.V(5).Info("livenessProbe")
 	fmt.Fprint(w, "OK")
 }
 
-// ReadinessProbe checks if database is available.
+// ReadinessProbe checks if database is available and the shared RBAC cache is loaded.
 func readinessProbe(w http.ResponseWriter, r *http.Request) {
 	klog.V(5).Info("readinessProbe")
+	if err := checkDatabase(r.Context()); err != nil {
+		klog.Warning("Readiness probe failed. Database is not available. ", err)
+		http.Error(w, "Database not available", http.StatusServiceUnavailable)
+		return
+	}
+	if !checkSharedCache() {
+		klog.Warning("Readiness probe failed. Shared cache is not ready.")
+		http.Error(w, "Shared cache not ready", http.StatusServiceUnavailable)
+		return
+	}
 	fmt.Fprint(w, "OK")
 }
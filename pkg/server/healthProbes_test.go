@@ -3,6 +3,8 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -41,6 +43,11 @@ func TestLivenessProbe(t *testing.T) {
 
 // Test the readiness probe.
 func TestReadinessProbe(t *testing.T) {
+	defer func(check func(context.Context) error) { checkDatabase = check }(checkDatabase)
+	checkDatabase = func(ctx context.Context) error { return nil }
+	defer func(check func() bool) { checkSharedCache = check }(checkSharedCache)
+	checkSharedCache = func() bool { return true }
+
 	// Create a request to pass to our handler. We don't have any query parameters for now, so we'll
 	// pass 'nil' as the third parameter.
 	req, err := http.NewRequest("GET", "/readiness", nil)
@@ -69,3 +76,52 @@ func TestReadinessProbe(t *testing.T) {
 			rr.Body.String(), expected)
 	}
 }
+
+// Test the readiness probe when the database isn't available.
+func TestReadinessProbe_DatabaseUnavailable(t *testing.T) {
+	defer func(check func(context.Context) error) { checkDatabase = check }(checkDatabase)
+	checkDatabase = func(ctx context.Context) error { return errors.New("connection refused") }
+
+	req, err := http.NewRequest("GET", "/readiness", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(readinessProbe).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusServiceUnavailable)
+	}
+	// The error details are only logged.
+	expected := "Database not available\n"
+	if rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v",
+			rr.Body.String(), expected)
+	}
+}
+
+// Test the readiness probe before the shared cache is loaded.
+func TestReadinessProbe_SharedCacheNotReady(t *testing.T) {
+	defer func(check func(context.Context) error) { checkDatabase = check }(checkDatabase)
+	checkDatabase = func(ctx context.Context) error { return nil }
+	defer func(check func() bool) { checkSharedCache = check }(checkSharedCache)
+	checkSharedCache = func() bool { return false }
+
+	req, err := http.NewRequest("GET", "/readiness", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(readinessProbe).ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusServiceUnavailable {
+		t.Errorf("handler returned wrong status code: got %v want %v",
+			status, http.StatusServiceUnavailable)
+	}
+	expected := "Shared cache not ready\n"
+	if rr.Body.String() != expected {
+		t.Errorf("handler returned unexpected body: got %v want %v",
+			rr.Body.String(), expected)
+	}
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -15,9 +16,12 @@ import (
 	"github.com/stolostron/search-v2-api/graph"
 	"github.com/stolostron/search-v2-api/graph/generated"
 	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stolostron/search-v2-api/pkg/database"
 	"github.com/stolostron/search-v2-api/pkg/federated"
 	"github.com/stolostron/search-v2-api/pkg/metrics"
 	"github.com/stolostron/search-v2-api/pkg/rbac"
+	"github.com/stolostron/search-v2-api/pkg/resolver"
+	"github.com/stolostron/search-v2-api/pkg/tracing"
 )
 
 func StartAndListen() {
@@ -59,13 +63,20 @@ func StartAndListen() {
 	// Add authentication middleware to the /searchapi (ContextPath) subroute.
 	apiSubrouter := router.PathPrefix(config.Cfg.ContextPath).Subrouter()
 
+	apiSubrouter.Use(tracing.Middleware)
 	apiSubrouter.Use(metrics.PrometheusMiddleware)
 	apiSubrouter.Use(rbac.CheckDBAvailability)
 	apiSubrouter.Use(rbac.AuthenticateUser)
 	apiSubrouter.Use(rbac.AuthorizeUser)
 
-	apiSubrouter.Handle("/graphql", handler.NewDefaultServer(generated.NewExecutableSchema(
-		generated.Config{Resolvers: &graph.Resolver{}})))
+	graphqlServer := handler.NewDefaultServer(generated.NewExecutableSchema(
+		generated.Config{Resolvers: &graph.Resolver{}}))
+	graphqlServer.SetErrorPresenter(errorPresenter)
+	apiSubrouter.Handle("/graphql", graphqlServer)
+	apiSubrouter.HandleFunc("/cache/invalidate", rbac.InvalidateUserCache).Methods("POST")
+	apiSubrouter.HandleFunc("/cache/user", rbac.HandleDebugUserRBAC).Methods("GET")
+	apiSubrouter.HandleFunc("/export", resolver.HandleExport).Methods("POST")
+	apiSubrouter.HandleFunc("/schema", resolver.HandleSearchSchema).Methods("GET")
 
 	srv := &http.Server{
 		Addr:              fmt.Sprintf(":%d", port),
@@ -75,9 +86,14 @@ func StartAndListen() {
 		ReadHeaderTimeout: 5 * time.Second,
 	}
 
-	klog.Infof(`Search API is now running on https://localhost:%d%s/graphql`, port, config.Cfg.ContextPath)
-	serverErr := srv.ListenAndServeTLS("./sslcert/tls.crt", "./sslcert/tls.key")
-	if serverErr != nil {
-		klog.Fatal("Server process ended with error. ", serverErr)
-	}
+	go func() {
+		klog.Infof(`Search API is now running on https://localhost:%d%s/graphql`, port, config.Cfg.ContextPath)
+		serverErr := srv.ListenAndServeTLS("./sslcert/tls.crt", "./sslcert/tls.key")
+		if serverErr != nil && !errors.Is(serverErr, http.ErrServerClosed) {
+			klog.Fatal("Server process ended with error. ", serverErr)
+		}
+	}()
+
+	// Drain the in-flight requests and close the database connections on shutdown.
+	shutdownOnSignal(srv, database.ClosePool)
 }
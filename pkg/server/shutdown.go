@@ -0,0 +1,43 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/stolostron/search-v2-api/pkg/config"
+	klog "k8s.io/klog/v2"
+)
+
+// Waits for SIGTERM or SIGINT, then shuts down the server.
+func shutdownOnSignal(srv *http.Server, closePool func()) {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	sig := <-stop
+	klog.Infof("Received %s signal. Shutting down the server.", sig)
+	_ = shutdown(srv, closePool)
+}
+
+// Stops accepting new requests and waits up to SHUTDOWN_GRACE_SECONDS for the in-flight requests to complete.
+// The database connections are closed after the requests complete, so the in-flight queries aren't canceled.
+func shutdown(srv *http.Server, closePool func()) error {
+	ctx, cancel := context.WithTimeout(context.Background(),
+		time.Duration(config.Cfg.ShutdownGraceSeconds)*time.Second)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		klog.Warningf("In-flight requests didn't complete within %ds. Closing the connections. %s",
+			config.Cfg.ShutdownGraceSeconds, err)
+		_ = srv.Close()
+	} else {
+		klog.Info("In-flight requests completed.")
+	}
+	closePool()
+	return err
+}
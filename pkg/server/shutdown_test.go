@@ -0,0 +1,98 @@
+// Copyright Contributors to the Open Cluster Management project
+
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// Server with a slow handler. Returns a channel that receives when the handler starts.
+func slowServer(delay time.Duration) (*httptest.Server, chan struct{}) {
+	started := make(chan struct{}, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		time.Sleep(delay)
+		_, _ = w.Write([]byte("OK"))
+	}))
+	return ts, started
+}
+
+// Should wait for the in-flight request to complete before closing the pool.
+func Test_shutdown_DrainsInFlightRequests(t *testing.T) {
+	defer func(grace int) { config.Cfg.ShutdownGraceSeconds = grace }(config.Cfg.ShutdownGraceSeconds)
+	config.Cfg.ShutdownGraceSeconds = 5
+	ts, started := slowServer(200 * time.Millisecond)
+	defer ts.Close()
+
+	// Record the order of the request completion and the pool close.
+	var lock sync.Mutex
+	events := []string{}
+	record := func(event string) {
+		lock.Lock()
+		defer lock.Unlock()
+		events = append(events, event)
+	}
+
+	var body string
+	var reqErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		res, err := http.Get(ts.URL)
+		if err != nil {
+			reqErr = err
+			return
+		}
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		body = string(b)
+		record("request completed")
+	}()
+	<-started
+
+	err := shutdown(ts.Config, func() { record("pool closed") })
+	<-done
+
+	assert.Nil(t, err)
+	assert.Nil(t, reqErr)
+	assert.Equal(t, "OK", body)
+	assert.Equal(t, []string{"request completed", "pool closed"}, events)
+
+	// New requests are rejected after shutdown.
+	_, err = http.Get(ts.URL)
+	assert.NotNil(t, err)
+}
+
+// Should close the pool after SHUTDOWN_GRACE_SECONDS when the in-flight requests don't complete.
+func Test_shutdown_GraceTimeout(t *testing.T) {
+	defer func(grace int) { config.Cfg.ShutdownGraceSeconds = grace }(config.Cfg.ShutdownGraceSeconds)
+	config.Cfg.ShutdownGraceSeconds = 0
+	ts, started := slowServer(time.Second)
+	defer ts.Close()
+
+	go func() {
+		res, err := http.Get(ts.URL)
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+	<-started
+
+	poolClosed := false
+	start := time.Now()
+	err := shutdown(ts.Config, func() { poolClosed = true })
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "Expected DeadlineExceeded. Got: %v", err)
+	assert.True(t, poolClosed)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
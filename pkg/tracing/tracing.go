@@ -0,0 +1,88 @@
+// Copyright Contributors to the Open Cluster Management project
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	klog "k8s.io/klog/v2"
+)
+
+// Name of the tracer used to create the spans.
+const tracerName = "github.com/stolostron/search-v2-api"
+
+// Identifies this service in the exported traces.
+const serviceName = "search-v2-api"
+
+// Configure the exporter to send the traces to the OTLP endpoint set with TRACING_ENDPOINT.
+// Tracing is disabled when the endpoint isn't set, the spans are created with the no-op provider.
+// Returns a function to flush the pending spans on shutdown.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	if config.Cfg.TracingEndpoint == "" {
+		klog.V(1).Info("Tracing is disabled. To enable set env variable TRACING_ENDPOINT.")
+		return func(context.Context) error { return nil }, nil
+	}
+	endpoint, err := url.Parse(config.Cfg.TracingEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRACING_ENDPOINT: %w", err)
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint.Host)}
+	if endpoint.Path != "" && endpoint.Path != "/" {
+		opts = append(opts, otlptracehttp.WithURLPath(endpoint.Path))
+	}
+	if endpoint.Scheme == "http" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the tracing exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	klog.Infof("Tracing is enabled. Exporting traces to %s", config.Cfg.TracingEndpoint)
+	return provider.Shutdown, nil
+}
+
+// Start a span, child of the span in the context.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End the span, recording the error if it isn't nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Middleware to start the span of the request. Continues the trace of the client when the
+// request has the traceparent header.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(tracerName).Start(ctx, r.Method+" "+r.URL.Path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPMethod(r.Method), semconv.URLPath(r.URL.Path)))
+		defer span.End()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
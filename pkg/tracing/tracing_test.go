@@ -0,0 +1,81 @@
+// Copyright Contributors to the Open Cluster Management project
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stolostron/search-v2-api/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Record the spans in memory. Restores the previous provider and propagator when the test ends.
+func mockExporter(t *testing.T) *tracetest.InMemoryExporter {
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return exporter
+}
+
+// Tracing is disabled by default.
+func Test_Init_Disabled(t *testing.T) {
+	defer func(endpoint string) { config.Cfg.TracingEndpoint = endpoint }(config.Cfg.TracingEndpoint)
+	config.Cfg.TracingEndpoint = ""
+
+	shutdown, err := Init(context.Background())
+
+	assert.Nil(t, err)
+	assert.Nil(t, shutdown(context.Background()))
+}
+
+// Should configure the exporter with the TRACING_ENDPOINT.
+func Test_Init_Endpoint(t *testing.T) {
+	defer func(endpoint string) { config.Cfg.TracingEndpoint = endpoint }(config.Cfg.TracingEndpoint)
+	config.Cfg.TracingEndpoint = "http://otel-collector:4318"
+	mockExporter(t) // Restores the global provider set by Init.
+
+	shutdown, err := Init(context.Background())
+
+	assert.Nil(t, err)
+	_, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+	assert.True(t, ok, "Expected the SDK tracer provider.")
+	assert.Nil(t, shutdown(context.Background()))
+}
+
+// Should start the request span, continuing the trace from the traceparent header.
+func Test_Middleware(t *testing.T) {
+	exporter := mockExporter(t)
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "child")
+		End(span, errors.New("child failed"))
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/searchapi/graphql", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	assert.Equal(t, 2, len(spans))
+	child, request := spans[0], spans[1]
+	assert.Equal(t, "POST /searchapi/graphql", request.Name)
+	assert.Equal(t, traceID, request.SpanContext.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", request.Parent.SpanID().String())
+	assert.Equal(t, "child", child.Name)
+	assert.Equal(t, request.SpanContext.SpanID(), child.Parent.SpanID())
+	assert.Equal(t, codes.Error, child.Status.Code)
+	assert.Equal(t, "child failed", child.Status.Description)
+}